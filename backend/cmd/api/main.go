@@ -9,15 +9,21 @@ import (
     "context"
     "time"
     
+    "github.com/KotovBoris/AutoSave/backend/internal/authz"
     "github.com/KotovBoris/AutoSave/backend/internal/banks"
     "github.com/KotovBoris/AutoSave/backend/internal/config"
+    "github.com/KotovBoris/AutoSave/backend/internal/fx"
     "github.com/KotovBoris/AutoSave/backend/internal/handlers"
+    "github.com/KotovBoris/AutoSave/backend/internal/mailer"
+    "github.com/KotovBoris/AutoSave/backend/internal/ratelimit"
     "github.com/KotovBoris/AutoSave/backend/internal/repository"
     "github.com/KotovBoris/AutoSave/backend/internal/router"
+    "github.com/KotovBoris/AutoSave/backend/internal/scheduler"
     "github.com/KotovBoris/AutoSave/backend/internal/services"
     "github.com/KotovBoris/AutoSave/backend/pkg/database"
     "github.com/KotovBoris/AutoSave/backend/pkg/jwt"
     "github.com/KotovBoris/AutoSave/backend/pkg/logger"
+    "github.com/KotovBoris/AutoSave/backend/pkg/validator"
     
     "github.com/gin-gonic/gin"
 )
@@ -65,22 +71,154 @@ func main() {
 
     // Initialize bank factory
     bankFactory := banks.NewFactory(cfg, log.Logger)
+    if err := bankFactory.Load(context.Background(), repos.Bank); err != nil {
+        log.Fatal().Err(err).Msg("Failed to load banks")
+    }
+    validator.SetBankValidator(bankFactory.ValidateBankID)
     log.Info().Msg("Bank factory initialized")
 
+    // Initialize exchange rates
+    fxRates := fx.NewRates(cfg.FXRatesFile, cfg.FXRatesURL, log.Logger)
+    if err := fxRates.Load(context.Background()); err != nil {
+        log.Fatal().Err(err).Msg("Failed to load FX rates")
+    }
+
+    // Initialize mailer
+    appMailer, err := mailer.New(mailer.Config{
+        Driver:       cfg.MailDriver,
+        From:         cfg.MailFrom,
+        SMTPHost:     cfg.SMTPHost,
+        SMTPPort:     cfg.SMTPPort,
+        SMTPUsername: cfg.SMTPUsername,
+        SMTPPassword: cfg.SMTPPassword,
+        Dir:          cfg.MailDir,
+    }, log.Logger)
+    if err != nil {
+        log.Fatal().Err(err).Msg("Failed to initialize mailer")
+    }
+    log.Info().Str("driver", cfg.MailDriver).Msg("Mailer initialized")
+
+    // Initialize rate limiter
+    var limiter ratelimit.Limiter
+    if cfg.RateLimitEnabled {
+        limiter, err = ratelimit.New(cfg.RateLimitStore, cfg.RedisURL)
+        if err != nil {
+            log.Fatal().Err(err).Msg("Failed to initialize rate limiter")
+        }
+        log.Info().Str("store", cfg.RateLimitStore).Msg("Rate limiter initialized")
+    }
+
     // Initialize services
-    authService := services.NewAuthService(repos.User, jwtUtil, log.Logger)
-    bankService := services.NewBankService(repos.Bank, repos.Account, repos.Transaction, bankFactory, log.Logger)
+    bankCredentials := services.NewBankCredentials(repos.Bank, bankFactory, log.Logger)
+    productCatalog := services.NewProductCatalog(repos.Bank, bankCredentials, log.Logger)
+    categoryService := services.NewCategoryService(repos.CategoryRule, repos.Transaction, repos.Account, log.Logger)
+    operationService := services.NewOperationService(
+        repos.Operation,
+        repos.Goal,
+        repos.Loan,
+        repos.Deposit,
+        log.Logger,
+    )
+    authService := services.NewAuthService(
+        repos.User,
+        repos.Session,
+        repos.UserToken,
+        jwtUtil,
+        appMailer,
+        services.LoginLockoutConfig{
+            Threshold: cfg.LoginLockoutThreshold,
+            Base:      cfg.LoginLockoutBase,
+            Max:       cfg.LoginLockoutMax,
+        },
+        cfg.JWTRefreshExpiry,
+        cfg.DefaultCurrency,
+        cfg.AppURL,
+        log.Logger,
+    )
+    totpService := services.NewTOTPService(repos.TOTP, repos.User, cfg.TOTPIssuer, log.Logger)
+    bankService := services.NewBankService(
+        repos.Bank,
+        repos.Account,
+        repos.Transaction,
+        bankFactory,
+        bankCredentials,
+        categoryService,
+        services.TransactionSyncConfig{
+            PageSize:       cfg.SyncPageSize,
+            Overlap:        cfg.SyncOverlap,
+            BackfillMonths: cfg.SyncBackfillMonths,
+        },
+        log.Logger,
+    )
     accountService := services.NewAccountService(repos.Account, repos.Transaction, log.Logger)
-    analysisService := services.NewAnalysisService(repos.User, repos.Transaction, log.Logger)
-    goalService := services.NewGoalService(repos.Goal, repos.Deposit, repos.User, repos.Bank, log.Logger)
+    analysisService := services.NewAnalysisService(repos.User, repos.Transaction, fxRates, cfg.SalaryLookbackMonths, log.Logger)
+    recurringService := services.NewRecurringService(repos.Recurring, repos.Transaction, log.Logger)
+    goalService := services.NewGoalService(
+        repos.Goal,
+        repos.Deposit,
+        repos.User,
+        repos.Bank,
+        operationService,
+        bankCredentials,
+        productCatalog,
+        fxRates,
+        log.Logger,
+    )
+    loanService := services.NewLoanService(repos.Loan, repos.Bank, operationService, log.Logger)
+    autopilotService := services.NewAutopilotService(
+        repos.Goal,
+        repos.Deposit,
+        repos.User,
+        repos.Bank,
+        repos.Account,
+        operationService,
+        bankCredentials,
+        productCatalog,
+        log.Logger,
+    )
+    loanAutopayService := services.NewLoanAutopayService(
+        repos.Loan,
+        repos.Account,
+        operationService,
+        loanService,
+        bankCredentials,
+        log.Logger,
+    )
+    emergencyService := services.NewEmergencyService(
+        repos.Goal,
+        repos.Deposit,
+        repos.User,
+        operationService,
+        bankCredentials,
+        fxRates,
+        log.Logger,
+    )
     log.Info().Msg("Services initialized")
 
+    // Initialize background jobs
+    jobScheduler := scheduler.New(cfg.SchedulerInterval, log.Logger)
+    jobScheduler.Register("autopilot-deposits", autopilotService.RunDueDeposits)
+    jobScheduler.Register("loan-autopay", loanAutopayService.RunAutopay)
+    if fxRates.Configured() {
+        jobScheduler.Register("fx-rates", fxRates.Refresh)
+    }
+    if cfg.SchedulerEnabled {
+        jobScheduler.Start(context.Background())
+    }
+
     // Initialize handlers
     authHandler := handlers.NewAuthHandler(authService)
     bankHandler := handlers.NewBankHandler(bankService)
     accountHandler := handlers.NewAccountHandler(accountService)
     analysisHandler := handlers.NewAnalysisHandler(analysisService)
     goalHandler := handlers.NewGoalHandler(goalService)
+    loanHandler := handlers.NewLoanHandler(loanService)
+    emergencyHandler := handlers.NewEmergencyHandler(emergencyService)
+    operationHandler := handlers.NewOperationHandler(operationService)
+    productHandler := handlers.NewProductHandler(productCatalog)
+    categoryHandler := handlers.NewCategoryHandler(categoryService)
+    recurringHandler := handlers.NewRecurringHandler(recurringService)
+    totpHandler := handlers.NewTOTPHandler(totpService)
     log.Info().Msg("Handlers initialized")
 
     // Setup router
@@ -90,9 +228,28 @@ func main() {
         accountHandler,
         analysisHandler,
         goalHandler,
+        loanHandler,
+        emergencyHandler,
+        operationHandler,
+        productHandler,
+        categoryHandler,
+        recurringHandler,
+        totpHandler,
         jwtUtil,
+        authService,
+        authService,
+        totpService,
+        authz.NewAuthorizer(repos),
+        limiter,
+        router.RateLimits{
+            API:       cfg.RateLimitAPI,
+            Auth:      cfg.RateLimitAuth,
+            Login:     cfg.RateLimitLogin,
+            Expensive: cfg.RateLimitExpensive,
+        },
         log.Logger,
         cfg.CORSAllowedOrigins,
+        cfg.TrustedProxies,
     )
     engine := appRouter.Setup()
     log.Info().Msg("Router initialized")
@@ -116,6 +273,8 @@ func main() {
     <-quit
     log.Warn().Msg("Shutting down server...")
 
+    jobScheduler.Stop()
+
     ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
 
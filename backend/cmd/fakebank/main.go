@@ -0,0 +1,62 @@
+package main
+
+import (
+    "context"
+    "flag"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/fakebank"
+    "github.com/KotovBoris/AutoSave/backend/pkg/logger"
+
+    "github.com/rs/zerolog"
+)
+
+// fakebank runs an in-memory bank for local development. Point a bank
+// instance at it with a base URL such as http://localhost:8090.
+func main() {
+    addr := flag.String("addr", ":8090", "address to listen on")
+    clientID := flag.String("client-id", "", "team client id the bank accepts, empty accepts any")
+    clientSecret := flag.String("client-secret", "", "team client secret")
+    manual := flag.Bool("manual-approval", false, "leave consents awaiting approval, like SBank")
+    demoClient := flag.String("demo-client", "team-1", "client id to seed demo accounts for, empty seeds nothing")
+    flag.Parse()
+
+    log := logger.New(zerolog.InfoLevel, "console")
+
+    bank := fakebank.New(fakebank.Options{
+        ClientID:       *clientID,
+        ClientSecret:   *clientSecret,
+        ManualApproval: *manual,
+    })
+    if *demoClient != "" {
+        bank.SeedDemo(*demoClient)
+        log.Info().Str("client", *demoClient).Msg("Seeded demo accounts")
+    }
+
+    server := &http.Server{
+        Addr:    *addr,
+        Handler: bank,
+    }
+
+    go func() {
+        log.Info().Str("addr", *addr).Msg("Fake bank is listening")
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Fatal().Err(err).Msg("Fake bank failed to start")
+        }
+    }()
+
+    quit := make(chan os.Signal, 1)
+    signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+    <-quit
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    if err := server.Shutdown(ctx); err != nil {
+        log.Fatal().Err(err).Msg("Fake bank forced to shutdown")
+    }
+}
@@ -0,0 +1,146 @@
+// Package authz decides whether a user may act on a resource named in a
+// request path. Handlers behind it can take the resource to be the user's.
+package authz
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+)
+
+var (
+    // ErrNotFound means there is no such resource
+    ErrNotFound = errors.New("resource not found")
+    // ErrForbidden means the resource belongs to another user
+    ErrForbidden = errors.New("resource belongs to another user")
+    // ErrInvalidID means the ID cannot name a resource of the kind
+    ErrInvalidID = errors.New("invalid resource id")
+)
+
+// Resource is a kind of resource users own
+type Resource string
+
+const (
+    Account          Resource = "account"
+    Transaction      Resource = "transaction"
+    Goal             Resource = "goal"
+    Deposit          Resource = "deposit"
+    Loan             Resource = "loan"
+    BankConnection   Resource = "bank connection"
+    CategoryRule     Resource = "category rule"
+    RecurringPayment Resource = "recurring payment"
+)
+
+// Authorizer looks up who owns resources
+type Authorizer struct {
+    accountRepo     repository.AccountRepository
+    transactionRepo repository.TransactionRepository
+    goalRepo        repository.GoalRepository
+    depositRepo     repository.DepositRepository
+    loanRepo        repository.LoanRepository
+    bankRepo        repository.BankRepository
+    ruleRepo        repository.CategoryRuleRepository
+    recurringRepo   repository.RecurringRepository
+}
+
+func NewAuthorizer(repos *repository.Repositories) *Authorizer {
+    return &Authorizer{
+        accountRepo:     repos.Account,
+        transactionRepo: repos.Transaction,
+        goalRepo:        repos.Goal,
+        depositRepo:     repos.Deposit,
+        loanRepo:        repos.Loan,
+        bankRepo:        repos.Bank,
+        ruleRepo:        repos.CategoryRule,
+        recurringRepo:   repos.Recurring,
+    }
+}
+
+// Authorize returns nil when userID owns the resource with id, ErrNotFound
+// when there is none and ErrForbidden when it is someone else's. Bank
+// connections are named by bank ID, a bank the user has not connected is
+// not found.
+func (a *Authorizer) Authorize(ctx context.Context, userID int, resource Resource, id string) error {
+    if resource == BankConnection {
+        conn, err := a.bankRepo.GetConnection(ctx, userID, id)
+        if err != nil {
+            return err
+        }
+        if conn == nil {
+            return ErrNotFound
+        }
+        return nil
+    }
+
+    n, err := strconv.Atoi(id)
+    if err != nil || n < 1 {
+        return ErrInvalidID
+    }
+
+    ownerID, err := a.owner(ctx, resource, n)
+    if err != nil {
+        if errors.Is(err, repository.ErrNotFound) {
+            return ErrNotFound
+        }
+        return fmt.Errorf("failed to authorize %s %d: %w", resource, n, err)
+    }
+    if ownerID != userID {
+        return ErrForbidden
+    }
+
+    return nil
+}
+
+// owner returns the user who owns a resource
+func (a *Authorizer) owner(ctx context.Context, resource Resource, id int) (int, error) {
+    switch resource {
+    case Account:
+        account, err := a.accountRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return account.UserID, nil
+    case Transaction:
+        // Transactions belong to whoever owns their account
+        tx, err := a.transactionRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return a.owner(ctx, Account, tx.AccountID)
+    case Goal:
+        goal, err := a.goalRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return goal.UserID, nil
+    case Deposit:
+        deposit, err := a.depositRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return deposit.UserID, nil
+    case Loan:
+        loan, err := a.loanRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return loan.UserID, nil
+    case CategoryRule:
+        rule, err := a.ruleRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return rule.UserID, nil
+    case RecurringPayment:
+        payment, err := a.recurringRepo.GetByID(ctx, id)
+        if err != nil {
+            return 0, err
+        }
+        return payment.UserID, nil
+    default:
+        return 0, fmt.Errorf("unknown resource %q", resource)
+    }
+}
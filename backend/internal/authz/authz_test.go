@@ -0,0 +1,62 @@
+package authz_test
+
+import (
+    "context"
+    "errors"
+    "strconv"
+    "testing"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/authz"
+    "github.com/KotovBoris/AutoSave/backend/internal/authz/authztest"
+)
+
+func TestAuthorize(t *testing.T) {
+    authorizer := authz.NewAuthorizer(authztest.Repositories())
+
+    owned := strconv.Itoa(authztest.OwnedID)
+    other := strconv.Itoa(authztest.OtherID)
+    missing := strconv.Itoa(authztest.MissingID)
+
+    resources := []authz.Resource{
+        authz.Account,
+        authz.Transaction,
+        authz.Goal,
+        authz.Deposit,
+        authz.Loan,
+        authz.CategoryRule,
+        authz.RecurringPayment,
+    }
+
+    type testCase struct {
+        name     string
+        resource authz.Resource
+        id       string
+        want     error
+    }
+
+    var tests []testCase
+    for _, resource := range resources {
+        tests = append(tests,
+            testCase{"own", resource, owned, nil},
+            testCase{"other user's", resource, other, authz.ErrForbidden},
+            testCase{"missing", resource, missing, authz.ErrNotFound},
+            testCase{"not a number", resource, "abc", authz.ErrInvalidID},
+            testCase{"zero", resource, "0", authz.ErrInvalidID},
+        )
+    }
+    // Connections are looked up per user, another user's is not found
+    tests = append(tests,
+        testCase{"own", authz.BankConnection, authztest.OwnedBank, nil},
+        testCase{"other user's", authz.BankConnection, authztest.OtherBank, authz.ErrNotFound},
+        testCase{"missing", authz.BankConnection, "nobank", authz.ErrNotFound},
+    )
+
+    for _, tt := range tests {
+        t.Run(string(tt.resource)+"/"+tt.name, func(t *testing.T) {
+            err := authorizer.Authorize(context.Background(), authztest.Owner, tt.resource, tt.id)
+            if !errors.Is(err, tt.want) {
+                t.Fatalf("Authorize(%s %q) = %v, want %v", tt.resource, tt.id, err, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,150 @@
+// Package authztest provides in-memory repositories holding one resource of
+// each kind for two users, for tests of ownership checks
+package authztest
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+)
+
+const (
+    // Owner and Other are the two users
+    Owner = 1
+    Other = 2
+
+    // OwnedID is the ID of every resource of Owner, OtherID of every
+    // resource of Other. Nothing has MissingID.
+    OwnedID   = 1
+    OtherID   = 2
+    MissingID = 99
+
+    // Owner has connected OwnedBank, Other OtherBank
+    OwnedBank = "abank"
+    OtherBank = "vbank"
+)
+
+// Repositories returns the repositories the authorizer reads, the others
+// are nil
+func Repositories() *repository.Repositories {
+    accounts := accountRepo{rows: map[int]*models.Account{}}
+    transactions := transactionRepo{rows: map[int]*models.Transaction{}}
+    goals := goalRepo{rows: map[int]*models.Goal{}}
+    deposits := depositRepo{rows: map[int]*models.Deposit{}}
+    loans := loanRepo{rows: map[int]*models.Loan{}}
+    rules := ruleRepo{rows: map[int]*models.CategoryRule{}}
+    recurring := recurringRepo{rows: map[int]*models.RecurringPayment{}}
+
+    for id, userID := range map[int]int{OwnedID: Owner, OtherID: Other} {
+        accounts.rows[id] = &models.Account{ID: id, UserID: userID}
+        transactions.rows[id] = &models.Transaction{ID: id, AccountID: id}
+        goals.rows[id] = &models.Goal{ID: id, UserID: userID}
+        deposits.rows[id] = &models.Deposit{ID: id, UserID: userID}
+        loans.rows[id] = &models.Loan{ID: id, UserID: userID}
+        rules.rows[id] = &models.CategoryRule{ID: id, UserID: userID}
+        recurring.rows[id] = &models.RecurringPayment{ID: id, UserID: userID}
+    }
+
+    return &repository.Repositories{
+        Account:      accounts,
+        Transaction:  transactions,
+        Goal:         goals,
+        Deposit:      deposits,
+        Loan:         loans,
+        CategoryRule: rules,
+        Recurring:    recurring,
+        Bank: bankRepo{connections: []models.BankConnection{
+            {UserID: Owner, BankID: OwnedBank},
+            {UserID: Other, BankID: OtherBank},
+        }},
+    }
+}
+
+// The fakes embed their interface, calling a method the authorizer does
+// not use panics
+
+func get[T any](rows map[int]*T, id int, name string) (*T, error) {
+    if row, ok := rows[id]; ok {
+        return row, nil
+    }
+    return nil, fmt.Errorf("%s %w", name, repository.ErrNotFound)
+}
+
+type accountRepo struct {
+    repository.AccountRepository
+    rows map[int]*models.Account
+}
+
+func (r accountRepo) GetByID(ctx context.Context, id int) (*models.Account, error) {
+    return get(r.rows, id, "account")
+}
+
+type transactionRepo struct {
+    repository.TransactionRepository
+    rows map[int]*models.Transaction
+}
+
+func (r transactionRepo) GetByID(ctx context.Context, id int) (*models.Transaction, error) {
+    return get(r.rows, id, "transaction")
+}
+
+type goalRepo struct {
+    repository.GoalRepository
+    rows map[int]*models.Goal
+}
+
+func (r goalRepo) GetByID(ctx context.Context, id int) (*models.Goal, error) {
+    return get(r.rows, id, "goal")
+}
+
+type depositRepo struct {
+    repository.DepositRepository
+    rows map[int]*models.Deposit
+}
+
+func (r depositRepo) GetByID(ctx context.Context, id int) (*models.Deposit, error) {
+    return get(r.rows, id, "deposit")
+}
+
+type loanRepo struct {
+    repository.LoanRepository
+    rows map[int]*models.Loan
+}
+
+func (r loanRepo) GetByID(ctx context.Context, id int) (*models.Loan, error) {
+    return get(r.rows, id, "loan")
+}
+
+type ruleRepo struct {
+    repository.CategoryRuleRepository
+    rows map[int]*models.CategoryRule
+}
+
+func (r ruleRepo) GetByID(ctx context.Context, id int) (*models.CategoryRule, error) {
+    return get(r.rows, id, "category rule")
+}
+
+type recurringRepo struct {
+    repository.RecurringRepository
+    rows map[int]*models.RecurringPayment
+}
+
+func (r recurringRepo) GetByID(ctx context.Context, id int) (*models.RecurringPayment, error) {
+    return get(r.rows, id, "recurring payment")
+}
+
+type bankRepo struct {
+    repository.BankRepository
+    connections []models.BankConnection
+}
+
+func (r bankRepo) GetConnection(ctx context.Context, userID int, bankID string) (*models.BankConnection, error) {
+    for i := range r.connections {
+        if r.connections[i].UserID == userID && r.connections[i].BankID == bankID {
+            return &r.connections[i], nil
+        }
+    }
+    return nil, nil
+}
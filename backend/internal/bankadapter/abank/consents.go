@@ -1,6 +1,7 @@
 package abank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "time"
@@ -9,7 +10,7 @@ import (
 )
 
 // CreateAccountConsent creates consent for account access
-func (a *Adapter) CreateAccountConsent(token, clientID, requestingBank string, permissions []string) (*bankadapter.ConsentResponse, error) {
+func (a *Adapter) CreateAccountConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*bankadapter.ConsentResponse, error) {
     request := map[string]interface{}{
         "client_id":            clientID,
         "permissions":          permissions,
@@ -21,7 +22,7 @@ func (a *Adapter) CreateAccountConsent(token, clientID, requestingBank string, p
     headers := a.GetAuthHeaders(token)
     headers["X-Requesting-Bank"] = requestingBank
     
-    resp, err := a.DoRequest("POST", endpointAccountConsents, headers, request)
+    resp, err := a.DoRequest(ctx, "POST", endpointAccountConsents, headers, request)
     if err != nil {
         return nil, fmt.Errorf("failed to create account consent: %w", err)
     }
@@ -57,7 +58,7 @@ func (a *Adapter) CreateAccountConsent(token, clientID, requestingBank string, p
 }
 
 // CreateProductConsent creates consent for product management
-func (a *Adapter) CreateProductConsent(token, clientID, requestingBank string, permissions []string) (*bankadapter.ConsentResponse, error) {
+func (a *Adapter) CreateProductConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*bankadapter.ConsentResponse, error) {
     request := map[string]interface{}{
         "requesting_bank":        requestingBank,
         "client_id":              clientID,
@@ -72,7 +73,7 @@ func (a *Adapter) CreateProductConsent(token, clientID, requestingBank string, p
     
     headers := a.GetAuthHeaders(token)
     
-    resp, err := a.DoRequest("POST", endpointProductAgreementConsents, headers, request)
+    resp, err := a.DoRequest(ctx, "POST", endpointProductAgreementConsents, headers, request)
     if err != nil {
         return nil, fmt.Errorf("failed to create product consent: %w", err)
     }
@@ -102,7 +103,7 @@ func (a *Adapter) CreateProductConsent(token, clientID, requestingBank string, p
 }
 
 // CreatePaymentConsent creates consent for payments
-func (a *Adapter) CreatePaymentConsent(token, clientID, requestingBank string, consent bankadapter.PaymentConsentRequest) (*bankadapter.ConsentResponse, error) {
+func (a *Adapter) CreatePaymentConsent(ctx context.Context, token, clientID, requestingBank string, consent bankadapter.PaymentConsentRequest) (*bankadapter.ConsentResponse, error) {
     request := map[string]interface{}{
         "requesting_bank":   requestingBank,
         "client_id":         clientID,
@@ -121,7 +122,7 @@ func (a *Adapter) CreatePaymentConsent(token, clientID, requestingBank string, c
     headers := a.GetAuthHeaders(token)
     headers["X-Requesting-Bank"] = requestingBank
     
-    resp, err := a.DoRequest("POST", endpointPaymentConsents, headers, request)
+    resp, err := a.DoRequest(ctx, "POST", endpointPaymentConsents, headers, request)
     if err != nil {
         return nil, fmt.Errorf("failed to create payment consent: %w", err)
     }
@@ -145,11 +146,11 @@ func (a *Adapter) CreatePaymentConsent(token, clientID, requestingBank string, c
 }
 
 // GetConsent retrieves consent details
-func (a *Adapter) GetConsent(token, consentID string) (*bankadapter.ConsentResponse, error) {
+func (a *Adapter) GetConsent(ctx context.Context, token, consentID string) (*bankadapter.ConsentResponse, error) {
     path := fmt.Sprintf(endpointAccountConsent, consentID)
     headers := a.GetAuthHeaders(token)
     
-    resp, err := a.DoRequest("GET", path, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", path, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get consent: %w", err)
     }
@@ -182,11 +183,11 @@ func (a *Adapter) GetConsent(token, consentID string) (*bankadapter.ConsentRespo
 }
 
 // DeleteConsent revokes consent
-func (a *Adapter) DeleteConsent(token, consentID string) error {
+func (a *Adapter) DeleteConsent(ctx context.Context, token, consentID string) error {
     path := fmt.Sprintf(endpointAccountConsent, consentID)
     headers := a.GetAuthHeaders(token)
     
-    resp, err := a.DoRequest("DELETE", path, headers, nil)
+    resp, err := a.DoRequest(ctx, "DELETE", path, headers, nil)
     if err != nil {
         return fmt.Errorf("failed to delete consent: %w", err)
     }
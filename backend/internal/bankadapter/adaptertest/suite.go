@@ -0,0 +1,873 @@
+// Package adaptertest is the integration test suite every bank adapter runs
+// against the fake bank in package fakebank
+package adaptertest
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/fakebank"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+const (
+    teamID       = "team-1"
+    clientSecret = "secret"
+    clientID     = "team-1-1"
+    otherClient  = "team-1-2"
+)
+
+var accountPermissions = []string{"ReadAccountsDetail", "ReadBalances", "ReadTransactionsDetail"}
+
+var productPermissions = []string{"read_product_agreements", "open_product_agreements", "close_product_agreements"}
+
+// Suite describes the adapter under test
+type Suite struct {
+    // New creates the adapter for the bank described by cfg
+    New bankadapter.Driver
+    // ManualApproval runs the fake bank like SBank: consents wait for the
+    // client to approve them
+    ManualApproval bool
+}
+
+// env is one fake bank with an adapter pointed at it
+type env struct {
+    t       *testing.T
+    ctx     context.Context
+    bank    *fakebank.Server
+    adapter bankadapter.BankAdapter
+    breaker *bankadapter.CircuitBreaker
+    manual  bool
+}
+
+func (s Suite) setup(t *testing.T) *env {
+    t.Helper()
+
+    bank := fakebank.New(fakebank.Options{
+        ClientID:       teamID,
+        ClientSecret:   clientSecret,
+        ManualApproval: s.ManualApproval,
+    })
+    server := httptest.NewServer(bank)
+    t.Cleanup(server.Close)
+
+    logger := zerolog.Nop()
+    breaker := bankadapter.NewCircuitBreaker(3, time.Minute)
+    adapter := s.New(bankadapter.DriverConfig{
+        BankID:       "testbank",
+        Name:         "Test Bank",
+        BaseURL:      server.URL,
+        ClientID:     teamID,
+        ClientSecret: clientSecret,
+        TeamID:       teamID,
+        DepositRate:  9.5,
+        Logger:       &logger,
+        Retry: bankadapter.RetryPolicy{
+            MaxAttempts: 3,
+            BaseDelay:   time.Millisecond,
+            MaxDelay:    20 * time.Millisecond,
+        },
+        Breaker: breaker,
+    })
+
+    return &env{
+        t:       t,
+        ctx:     context.Background(),
+        bank:    bank,
+        adapter: adapter,
+        breaker: breaker,
+        manual:  s.ManualApproval,
+    }
+}
+
+func (e *env) token() string {
+    e.t.Helper()
+
+    token, err := e.adapter.GetBankToken(e.ctx)
+    if err != nil {
+        e.t.Fatalf("GetBankToken: %v", err)
+    }
+    return token.AccessToken
+}
+
+// accountConsent creates an account consent for client and approves it
+// when the bank asks the client to
+func (e *env) accountConsent(token, client string) string {
+    e.t.Helper()
+
+    consent, err := e.adapter.CreateAccountConsent(e.ctx, token, client, teamID, accountPermissions)
+    if err != nil {
+        e.t.Fatalf("CreateAccountConsent: %v", err)
+    }
+    if e.manual {
+        e.bank.Approve(consent.ConsentID)
+    }
+    return consent.ConsentID
+}
+
+func (e *env) productConsent(token, client string) string {
+    e.t.Helper()
+
+    consent, err := e.adapter.CreateProductConsent(e.ctx, token, client, teamID, productPermissions)
+    if err != nil {
+        e.t.Fatalf("CreateProductConsent: %v", err)
+    }
+    if e.manual {
+        e.bank.Approve(consent.ConsentID)
+    }
+    return consent.ConsentID
+}
+
+// Run runs the suite
+func Run(t *testing.T, s Suite) {
+    tests := []struct {
+        name string
+        run  func(t *testing.T, e *env)
+    }{
+        {"BankInfo", testBankInfo},
+        {"Health", testHealth},
+        {"Token", testToken},
+        {"TokenRejected", testTokenRejected},
+        {"TokenExpired", testTokenExpired},
+        {"AccountConsent", testAccountConsent},
+        {"RevokedConsent", testRevokedConsent},
+        {"Accounts", testAccounts},
+        {"AccountOfOtherClient", testAccountOfOtherClient},
+        {"BalanceUnavailable", testBalanceUnavailable},
+        {"CreateAndCloseAccount", testCreateAndCloseAccount},
+        {"TransactionPages", testTransactionPages},
+        {"TransactionWindow", testTransactionWindow},
+        {"Products", testProducts},
+        {"Deposits", testDeposits},
+        {"DepositInsufficientFunds", testDepositInsufficientFunds},
+        {"Payments", testPayments},
+        {"Cards", testCards},
+        {"RetryTransient", testRetryTransient},
+        {"NoRetryClientError", testNoRetryClientError},
+        {"NoRetryPost", testNoRetryPost},
+        {"NoRetryClose", testNoRetryClose},
+        {"RetryKeyedPayment", testRetryKeyedPayment},
+        {"RetryAfterTooLong", testRetryAfterTooLong},
+        {"CircuitBreaker", testCircuitBreaker},
+        {"ContextCancelled", testContextCancelled},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.run(t, s.setup(t))
+        })
+    }
+}
+
+func testBankInfo(t *testing.T, e *env) {
+    info := e.adapter.GetBankInfo()
+    if info.ID != "testbank" || info.Name != "Test Bank" || info.DepositRate != 9.5 {
+        t.Errorf("GetBankInfo = %+v, want the configured id, name and rate", info)
+    }
+}
+
+func testHealth(t *testing.T, e *env) {
+    if !e.adapter.IsHealthy(e.ctx) {
+        t.Error("IsHealthy = false for a running bank")
+    }
+
+    e.bank.Inject(fakebank.Fault{Path: "/health", Status: http.StatusServiceUnavailable})
+    if e.adapter.IsHealthy(e.ctx) {
+        t.Error("IsHealthy = true for a bank answering 503")
+    }
+}
+
+func testToken(t *testing.T, e *env) {
+    token, err := e.adapter.GetBankToken(e.ctx)
+    if err != nil {
+        t.Fatalf("GetBankToken: %v", err)
+    }
+    if token.AccessToken == "" || token.ExpiresIn <= 0 || token.IssuedAt.IsZero() {
+        t.Errorf("GetBankToken = %+v, want a token with a lifetime", token)
+    }
+
+    refreshed, err := e.adapter.RefreshToken(e.ctx, token.RefreshToken)
+    if err != nil {
+        t.Fatalf("RefreshToken: %v", err)
+    }
+    if refreshed.AccessToken == token.AccessToken {
+        t.Error("RefreshToken returned the old token")
+    }
+}
+
+func testTokenRejected(t *testing.T, e *env) {
+    e.bank.Inject(fakebank.Fault{
+        Path:   "/auth/bank-token",
+        Status: http.StatusUnauthorized,
+        Code:   bankadapter.ErrCodeUnauthorized,
+    })
+
+    _, err := e.adapter.GetBankToken(e.ctx)
+    bankErr := requireBankError(t, err, http.StatusUnauthorized)
+    if bankErr.Code != bankadapter.ErrCodeUnauthorized {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeUnauthorized)
+    }
+    if bankadapter.IsTransient(err) {
+        t.Error("rejected credentials reported as transient")
+    }
+    if calls := e.bank.Calls("POST", "/auth/bank-token"); calls != 1 {
+        t.Errorf("token requests = %d, want 1", calls)
+    }
+}
+
+func testTokenExpired(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    e.bank.ExpireTokens()
+
+    _, err := e.adapter.GetAccounts(e.ctx, token, clientID, consent, teamID)
+    requireBankError(t, err, http.StatusUnauthorized)
+}
+
+func testAccountConsent(t *testing.T, e *env) {
+    token := e.token()
+
+    consent, err := e.adapter.CreateAccountConsent(e.ctx, token, clientID, teamID, accountPermissions)
+    if err != nil {
+        t.Fatalf("CreateAccountConsent: %v", err)
+    }
+    if consent.ConsentID == "" || consent.ExpiresAt.Before(time.Now()) {
+        t.Errorf("CreateAccountConsent = %+v, want an id and a future expiry", consent)
+    }
+
+    want := fakebank.ConsentAuthorised
+    if e.manual {
+        want = fakebank.ConsentAwaitingAuthorisation
+    }
+    if consent.Status != want || consent.AutoApproved == e.manual {
+        t.Errorf("consent status = %s, auto approved %v; want %s", consent.Status, consent.AutoApproved, want)
+    }
+
+    if e.manual {
+        // Unapproved consents give no access
+        _, err := e.adapter.GetAccounts(e.ctx, token, clientID, consent.ConsentID, teamID)
+        requireBankError(t, err, http.StatusForbidden)
+        e.bank.Approve(consent.ConsentID)
+    }
+
+    got, err := e.adapter.GetConsent(e.ctx, token, consent.ConsentID)
+    if err != nil {
+        t.Fatalf("GetConsent: %v", err)
+    }
+    if got.Status != fakebank.ConsentAuthorised || len(got.Permissions) != len(accountPermissions) {
+        t.Errorf("GetConsent = %+v, want an authorised consent with the requested permissions", got)
+    }
+
+    _, err = e.adapter.GetConsent(e.ctx, token, "consent-missing")
+    requireBankError(t, err, http.StatusNotFound)
+}
+
+func testRevokedConsent(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(100)})
+
+    if err := e.adapter.DeleteConsent(e.ctx, token, consent); err != nil {
+        t.Fatalf("DeleteConsent: %v", err)
+    }
+
+    got, err := e.adapter.GetConsent(e.ctx, token, consent)
+    if err != nil {
+        t.Fatalf("GetConsent: %v", err)
+    }
+    if got.Status != fakebank.ConsentRevoked {
+        t.Errorf("status after DeleteConsent = %s, want %s", got.Status, fakebank.ConsentRevoked)
+    }
+
+    _, err = e.adapter.GetAccounts(e.ctx, token, clientID, consent, teamID)
+    bankErr := requireBankError(t, err, http.StatusForbidden)
+    if bankErr.Code != bankadapter.ErrCodeConsentRequired {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeConsentRequired)
+    }
+}
+
+func testAccounts(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    current := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Nickname: "Main", Balance: money.MustParse("1500.50")})
+    e.bank.AddAccount(fakebank.Account{ClientID: clientID, Nickname: "Savings", Balance: money.FromMajor(20)})
+    e.bank.AddAccount(fakebank.Account{ClientID: otherClient, Balance: money.FromMajor(999)})
+
+    accounts, err := e.adapter.GetAccounts(e.ctx, token, clientID, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetAccounts: %v", err)
+    }
+    if len(accounts) != 2 {
+        t.Fatalf("GetAccounts returned %d accounts, want the client's 2", len(accounts))
+    }
+    if accounts[0].ID != current || accounts[0].Nickname != "Main" || accounts[0].Balance.Amount != money.MustParse("1500.50") {
+        t.Errorf("first account = %+v, want Main with balance 1500.50", accounts[0])
+    }
+    if accounts[0].Identification == "" || accounts[0].Currency != "RUB" {
+        t.Errorf("first account = %+v, want identification and currency", accounts[0])
+    }
+
+    details, err := e.adapter.GetAccountDetails(e.ctx, token, current, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetAccountDetails: %v", err)
+    }
+    if details.ID != current || details.Balance.Amount != money.MustParse("1500.50") {
+        t.Errorf("GetAccountDetails = %+v", details)
+    }
+
+    balance, err := e.adapter.GetAccountBalance(e.ctx, token, current, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetAccountBalance: %v", err)
+    }
+    if balance.Amount != money.MustParse("1500.50") || balance.Currency != "RUB" || balance.Type != "InterimAvailable" {
+        t.Errorf("GetAccountBalance = %+v", balance)
+    }
+}
+
+func testAccountOfOtherClient(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    foreign := e.bank.AddAccount(fakebank.Account{ClientID: otherClient, Balance: money.FromMajor(999)})
+
+    _, err := e.adapter.GetAccountBalance(e.ctx, token, foreign, consent, teamID)
+    bankErr := requireBankError(t, err, http.StatusNotFound)
+    if bankErr.Code != bankadapter.ErrCodeNotFound {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeNotFound)
+    }
+}
+
+func testBalanceUnavailable(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(100)})
+    e.bank.Inject(fakebank.Fault{Path: "/accounts/*/balances", Status: http.StatusNotFound})
+
+    // Accounts are still listed, only without a balance
+    accounts, err := e.adapter.GetAccounts(e.ctx, token, clientID, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetAccounts: %v", err)
+    }
+    if len(accounts) != 1 || accounts[0].Balance.Amount != 0 {
+        t.Errorf("GetAccounts = %+v, want one account with a zero balance", accounts)
+    }
+}
+
+func testCreateAndCloseAccount(t *testing.T, e *env) {
+    token := e.token()
+    main := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(10)})
+
+    created, err := e.adapter.CreateAccount(e.ctx, token, clientID, "Savings", money.FromMajor(250))
+    if err != nil {
+        t.Fatalf("CreateAccount: %v", err)
+    }
+    if created.ID == "" || created.AccountType != "Savings" || created.Balance.Amount != money.FromMajor(250) {
+        t.Errorf("CreateAccount = %+v", created)
+    }
+
+    // An account with money on it needs somewhere to send it
+    err = e.adapter.CloseAccount(e.ctx, token, clientID, created.ID, bankadapter.AccountCloseRequest{Action: "close"})
+    requireBankError(t, err, http.StatusUnprocessableEntity)
+
+    err = e.adapter.CloseAccount(e.ctx, token, clientID, created.ID, bankadapter.AccountCloseRequest{
+        Action:               "transfer",
+        DestinationAccountID: main,
+    })
+    if err != nil {
+        t.Fatalf("CloseAccount: %v", err)
+    }
+
+    closed, _ := e.bank.Account(created.ID)
+    dest, _ := e.bank.Account(main)
+    if !closed.Closed || dest.Balance != money.FromMajor(260) {
+        t.Errorf("after close: closed=%v, destination balance %s; want closed and 260", closed.Closed, dest.Balance)
+    }
+}
+
+func testTransactionPages(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    account := e.bank.AddAccount(fakebank.Account{ClientID: clientID})
+
+    start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+    for i := 0; i < 7; i++ {
+        amount := money.FromMajor(int64(100 * (i + 1)))
+        if i%2 == 1 {
+            amount = -amount
+        }
+        e.bank.AddTransaction(fakebank.Transaction{
+            AccountID:    account,
+            Amount:       amount,
+            Description:  "payment",
+            Counterparty: "Shop",
+            Category:     "groceries",
+            Booked:       start.AddDate(0, 0, i),
+        })
+    }
+
+    from, to := start.AddDate(0, -1, 0), start.AddDate(0, 1, 0)
+    var all []bankadapter.Transaction
+    for page := 1; ; page++ {
+        got, err := e.adapter.GetTransactions(e.ctx, token, account, consent, teamID, from, to, page, 3)
+        if err != nil {
+            t.Fatalf("GetTransactions page %d: %v", page, err)
+        }
+        if got.Page != page || got.TotalPages != 3 {
+            t.Errorf("page %d: Page=%d TotalPages=%d, want %d of 3", page, got.Page, got.TotalPages, page)
+        }
+        if !got.FirstAvailable.Equal(start) {
+            t.Errorf("page %d: FirstAvailable = %v, want %v", page, got.FirstAvailable, start)
+        }
+        all = append(all, got.Transactions...)
+        if !got.HasMore {
+            break
+        }
+        if page > 3 {
+            t.Fatal("GetTransactions keeps reporting more pages")
+        }
+    }
+
+    if len(all) != 7 {
+        t.Fatalf("read %d transactions over all pages, want 7", len(all))
+    }
+
+    seen := make(map[string]bool)
+    for _, tx := range all {
+        if seen[tx.TransactionID] {
+            t.Errorf("transaction %s returned twice", tx.TransactionID)
+        }
+        seen[tx.TransactionID] = true
+
+        if tx.CreditDebitIndicator == "Debit" && tx.Amount >= 0 {
+            t.Errorf("debit %s has amount %s, want negative", tx.TransactionID, tx.Amount)
+        }
+        if tx.Currency != "RUB" || tx.BookingDateTime.IsZero() || tx.CounterpartyName != "Shop" {
+            t.Errorf("transaction %+v is missing fields", tx)
+        }
+    }
+}
+
+func testTransactionWindow(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    account := e.bank.AddAccount(fakebank.Account{ClientID: clientID})
+
+    start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+    for i := 0; i < 10; i++ {
+        e.bank.AddTransaction(fakebank.Transaction{AccountID: account, Amount: money.FromMajor(10), Booked: start.AddDate(0, 0, i)})
+    }
+
+    got, err := e.adapter.GetTransactions(e.ctx, token, account, consent, teamID, start.AddDate(0, 0, 2), start.AddDate(0, 0, 4), 1, 50)
+    if err != nil {
+        t.Fatalf("GetTransactions: %v", err)
+    }
+    if len(got.Transactions) != 3 || got.HasMore {
+        t.Errorf("GetTransactions returned %d transactions, more=%v; want the 3 inside the window", len(got.Transactions), got.HasMore)
+    }
+}
+
+func testProducts(t *testing.T, e *env) {
+    token := e.token()
+    deposit := e.bank.AddProduct(fakebank.Product{
+        Type:         "deposit",
+        Name:         "Deposit",
+        InterestRate: 8.5,
+        MinAmount:    money.FromMajor(1000),
+        MaxAmount:    money.FromMajor(100000),
+        TermMonths:   []int{3, 6},
+    })
+    e.bank.AddProduct(fakebank.Product{Type: "card", Name: "Card"})
+
+    all, err := e.adapter.GetProducts(e.ctx, token, "")
+    if err != nil {
+        t.Fatalf("GetProducts: %v", err)
+    }
+    if len(all) != 2 {
+        t.Errorf("GetProducts returned %d products, want 2", len(all))
+    }
+
+    deposits, err := e.adapter.GetProducts(e.ctx, token, "deposit")
+    if err != nil {
+        t.Fatalf("GetProducts(deposit): %v", err)
+    }
+    if len(deposits) != 1 || deposits[0].ProductID != deposit || deposits[0].InterestRate != 8.5 {
+        t.Errorf("GetProducts(deposit) = %+v", deposits)
+    }
+
+    details, err := e.adapter.GetProductDetails(e.ctx, token, deposit)
+    if err != nil {
+        t.Fatalf("GetProductDetails: %v", err)
+    }
+    if details.MinAmount != money.FromMajor(1000) || details.MaxAmount != money.FromMajor(100000) || len(details.TermMonths) != 2 {
+        t.Errorf("GetProductDetails = %+v", details)
+    }
+
+    _, err = e.adapter.GetProductDetails(e.ctx, token, "prod-missing")
+    requireBankError(t, err, http.StatusNotFound)
+}
+
+func testDeposits(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.productConsent(token, clientID)
+    source := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(50000)})
+    product := e.bank.AddProduct(fakebank.Product{Type: "deposit", Name: "Deposit", InterestRate: 8, MinAmount: money.FromMajor(1000), TermMonths: []int{6}})
+
+    opened, err := e.adapter.OpenDeposit(e.ctx, token, clientID, consent, teamID, bankadapter.DepositRequest{
+        ProductID:       product,
+        Amount:          money.FromMajor(20000),
+        TermMonths:      6,
+        SourceAccountID: source,
+    })
+    if err != nil {
+        t.Fatalf("OpenDeposit: %v", err)
+    }
+    if opened.AgreementID == "" || opened.Amount != money.FromMajor(20000) || opened.Status != "active" || opened.InterestRate != 8 {
+        t.Errorf("OpenDeposit = %+v", opened)
+    }
+    if months := opened.MaturityDate.Sub(opened.OpenedDate).Hours() / 24 / 30; months < 5.5 || months > 6.5 {
+        t.Errorf("deposit matures after %.1f months, want 6", months)
+    }
+    if acc, _ := e.bank.Account(source); acc.Balance != money.FromMajor(30000) {
+        t.Errorf("source balance after OpenDeposit = %s, want 30000", acc.Balance)
+    }
+
+    agreements, err := e.adapter.GetAgreements(e.ctx, token, clientID, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetAgreements: %v", err)
+    }
+    if len(agreements) != 1 || agreements[0].AgreementID != opened.AgreementID {
+        t.Errorf("GetAgreements = %+v, want the opened deposit", agreements)
+    }
+
+    details, err := e.adapter.GetAgreementDetails(e.ctx, token, clientID, consent, teamID, opened.AgreementID)
+    if err != nil {
+        t.Fatalf("GetAgreementDetails: %v", err)
+    }
+    if details.ProductID != product || details.Status != "active" {
+        t.Errorf("GetAgreementDetails = %+v", details)
+    }
+
+    closed, err := e.adapter.CloseDeposit(e.ctx, token, clientID, consent, teamID, opened.AgreementID)
+    if err != nil {
+        t.Fatalf("CloseDeposit: %v", err)
+    }
+    // Closed the same day: no interest and nothing to lose
+    if closed.ReturnedAmount != money.FromMajor(20000) || closed.ClosedAt.IsZero() {
+        t.Errorf("CloseDeposit = %+v, want 20000 back", closed)
+    }
+    if acc, _ := e.bank.Account(source); acc.Balance != money.FromMajor(50000) {
+        t.Errorf("source balance after CloseDeposit = %s, want 50000", acc.Balance)
+    }
+
+    // Closing twice is refused
+    _, err = e.adapter.CloseDeposit(e.ctx, token, clientID, consent, teamID, opened.AgreementID)
+    requireBankError(t, err, http.StatusConflict)
+}
+
+func testDepositInsufficientFunds(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.productConsent(token, clientID)
+    source := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(500)})
+    product := e.bank.AddProduct(fakebank.Product{Type: "deposit", Name: "Deposit", InterestRate: 8})
+
+    _, err := e.adapter.OpenDeposit(e.ctx, token, clientID, consent, teamID, bankadapter.DepositRequest{
+        ProductID:       product,
+        Amount:          money.FromMajor(1000),
+        TermMonths:      3,
+        SourceAccountID: source,
+    })
+    bankErr := requireBankError(t, err, http.StatusUnprocessableEntity)
+    if bankErr.Code != bankadapter.ErrCodeInsufficientFunds {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeInsufficientFunds)
+    }
+    if bankadapter.IsTransient(err) {
+        t.Error("insufficient funds reported as transient")
+    }
+}
+
+func testPayments(t *testing.T, e *env) {
+    token := e.token()
+    debtor := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(1000)})
+    creditor := e.bank.AddAccount(fakebank.Account{ClientID: otherClient, Balance: 0})
+    debtorAcc, _ := e.bank.Account(debtor)
+    creditorAcc, _ := e.bank.Account(creditor)
+
+    consent, err := e.adapter.CreatePaymentConsent(e.ctx, token, clientID, teamID, bankadapter.PaymentConsentRequest{
+        ConsentType:   "single_use",
+        Amount:        money.FromMajor(300),
+        Currency:      "RUB",
+        DebtorAccount: debtorAcc.Identification,
+        ValidUntil:    time.Now().Add(time.Hour),
+    })
+    if err != nil {
+        t.Fatalf("CreatePaymentConsent: %v", err)
+    }
+    if e.manual {
+        e.bank.Approve(consent.ConsentID)
+    }
+
+    payment, err := e.adapter.CreatePayment(e.ctx, token, clientID, teamID, bankadapter.PaymentRequest{
+        DebtorAccountID:   debtorAcc.Identification,
+        CreditorAccountID: creditorAcc.Identification,
+        Amount:            money.FromMajor(300),
+        Currency:          "RUB",
+        Description:       "loan payment",
+        ConsentID:         consent.ConsentID,
+        IdempotencyKey:    "loan-payment-1",
+    })
+    if err != nil {
+        t.Fatalf("CreatePayment: %v", err)
+    }
+    if payment.PaymentID == "" || payment.Amount != money.FromMajor(300) || payment.Status != "AcceptedSettlementCompleted" {
+        t.Errorf("CreatePayment = %+v", payment)
+    }
+
+    // Sending the same payment again, as after a lost response, pays once
+    repeated, err := e.adapter.CreatePayment(e.ctx, token, clientID, teamID, bankadapter.PaymentRequest{
+        DebtorAccountID:   debtorAcc.Identification,
+        CreditorAccountID: creditorAcc.Identification,
+        Amount:            money.FromMajor(300),
+        Currency:          "RUB",
+        ConsentID:         consent.ConsentID,
+        IdempotencyKey:    "loan-payment-1",
+    })
+    if err != nil {
+        t.Fatalf("CreatePayment with a repeated key: %v", err)
+    }
+    if repeated.PaymentID != payment.PaymentID {
+        t.Errorf("repeated payment ID = %s, want %s", repeated.PaymentID, payment.PaymentID)
+    }
+
+    debtorAcc, _ = e.bank.Account(debtor)
+    creditorAcc, _ = e.bank.Account(creditor)
+    if debtorAcc.Balance != money.FromMajor(700) || creditorAcc.Balance != money.FromMajor(300) {
+        t.Errorf("balances after payment: debtor %s, creditor %s; want 700 and 300", debtorAcc.Balance, creditorAcc.Balance)
+    }
+
+    e.bank.SetPaymentStatus(payment.PaymentID, "Rejected")
+    status, err := e.adapter.GetPaymentStatus(e.ctx, token, clientID, payment.PaymentID)
+    if err != nil {
+        t.Fatalf("GetPaymentStatus: %v", err)
+    }
+    if status.Status != "Rejected" || status.PaymentID != payment.PaymentID {
+        t.Errorf("GetPaymentStatus = %+v, want Rejected", status)
+    }
+
+    _, err = e.adapter.CreatePayment(e.ctx, token, clientID, teamID, bankadapter.PaymentRequest{
+        DebtorAccountID:   debtorAcc.Identification,
+        CreditorAccountID: creditorAcc.Identification,
+        Amount:            money.FromMajor(5000),
+        Currency:          "RUB",
+    })
+    bankErr := requireBankError(t, err, http.StatusUnprocessableEntity)
+    if bankErr.Code != bankadapter.ErrCodeInsufficientFunds {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeInsufficientFunds)
+    }
+}
+
+func testCards(t *testing.T, e *env) {
+    token := e.token()
+    consent := e.accountConsent(token, clientID)
+    account := e.bank.AddAccount(fakebank.Account{ClientID: clientID})
+    acc, _ := e.bank.Account(account)
+
+    card, err := e.adapter.CreateCard(e.ctx, token, clientID, consent, teamID, bankadapter.CreateCardRequest{
+        AccountNumber: acc.Identification,
+        CardType:      "debit",
+        DailyLimit:    money.FromMajor(5000),
+        MonthlyLimit:  money.FromMajor(50000),
+    })
+    if err != nil {
+        t.Fatalf("CreateCard: %v", err)
+    }
+    if card.CardID == "" || card.AccountID != account || card.DailyLimit != money.FromMajor(5000) || card.CardStatus != "active" {
+        t.Errorf("CreateCard = %+v", card)
+    }
+
+    cards, err := e.adapter.GetCards(e.ctx, token, clientID, consent, teamID)
+    if err != nil {
+        t.Fatalf("GetCards: %v", err)
+    }
+    if len(cards) != 1 || cards[0].CardID != card.CardID || cards[0].IssuedDate.IsZero() {
+        t.Errorf("GetCards = %+v, want the created card", cards)
+    }
+}
+
+func testRetryTransient(t *testing.T, e *env) {
+    token := e.token()
+    e.bank.AddProduct(fakebank.Product{Type: "deposit", Name: "Deposit"})
+    e.bank.Inject(fakebank.Fault{Method: "GET", Path: "/products", Status: http.StatusServiceUnavailable, Times: 1})
+    e.bank.Inject(fakebank.Fault{Method: "GET", Path: "/products", Status: http.StatusTooManyRequests, Times: 1})
+
+    products, err := e.adapter.GetProducts(e.ctx, token, "")
+    if err != nil {
+        t.Fatalf("GetProducts after a 503 and a 429: %v", err)
+    }
+    if len(products) != 1 {
+        t.Errorf("GetProducts returned %d products, want 1", len(products))
+    }
+    if calls := e.bank.Calls("GET", "/products"); calls != 3 {
+        t.Errorf("product requests = %d, want 3", calls)
+    }
+}
+
+func testNoRetryClientError(t *testing.T, e *env) {
+    token := e.token()
+
+    _, err := e.adapter.GetProductDetails(e.ctx, token, "prod-missing")
+    requireBankError(t, err, http.StatusNotFound)
+    if calls := e.bank.Calls("GET", "/products/*"); calls != 1 {
+        t.Errorf("product requests = %d, want 1", calls)
+    }
+}
+
+func testNoRetryPost(t *testing.T, e *env) {
+    token := e.token()
+    debtor := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(1000)})
+    e.bank.Inject(fakebank.Fault{Method: "POST", Path: "/payments", Status: http.StatusBadGateway})
+
+    _, err := e.adapter.CreatePayment(e.ctx, token, clientID, teamID, bankadapter.PaymentRequest{
+        DebtorAccountID:   debtor,
+        CreditorAccountID: "40817810000000000999",
+        Amount:            money.FromMajor(100),
+        Currency:          "RUB",
+    })
+    requireBankError(t, err, http.StatusBadGateway)
+    if !bankadapter.IsTransient(err) {
+        t.Error("502 not reported as transient")
+    }
+    // A payment may have gone through, sending it again could pay twice
+    if calls := e.bank.Calls("POST", "/payments"); calls != 1 {
+        t.Errorf("payment requests = %d, want 1", calls)
+    }
+}
+
+func testNoRetryClose(t *testing.T, e *env) {
+    token := e.token()
+    main := e.bank.AddAccount(fakebank.Account{ClientID: clientID})
+    account := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(100)})
+    e.bank.Inject(fakebank.Fault{Method: "PUT", Path: "/accounts/*/close", Status: http.StatusBadGateway})
+
+    err := e.adapter.CloseAccount(e.ctx, token, clientID, account, bankadapter.AccountCloseRequest{
+        Action:               "transfer",
+        DestinationAccountID: main,
+    })
+    requireBankError(t, err, http.StatusBadGateway)
+    // The bank may have moved the money already
+    if calls := e.bank.Calls("PUT", "/accounts/*/close"); calls != 1 {
+        t.Errorf("close requests = %d, want 1", calls)
+    }
+}
+
+func testRetryKeyedPayment(t *testing.T, e *env) {
+    token := e.token()
+    debtor := e.bank.AddAccount(fakebank.Account{ClientID: clientID, Balance: money.FromMajor(1000)})
+    debtorAcc, _ := e.bank.Account(debtor)
+    e.bank.Inject(fakebank.Fault{Method: "POST", Path: "/payments", Status: http.StatusBadGateway, Times: 1})
+
+    _, err := e.adapter.CreatePayment(e.ctx, token, clientID, teamID, bankadapter.PaymentRequest{
+        DebtorAccountID:   debtorAcc.Identification,
+        CreditorAccountID: "40817810000000000999",
+        Amount:            money.FromMajor(100),
+        Currency:          "RUB",
+        IdempotencyKey:    "retry-1",
+    })
+    if err != nil {
+        t.Fatalf("CreatePayment after a 502: %v", err)
+    }
+    if calls := e.bank.Calls("POST", "/payments"); calls != 2 {
+        t.Errorf("payment requests = %d, want 2", calls)
+    }
+    if debtorAcc, _ = e.bank.Account(debtor); debtorAcc.Balance != money.FromMajor(900) {
+        t.Errorf("debtor balance = %s, want 900", debtorAcc.Balance)
+    }
+}
+
+func testRetryAfterTooLong(t *testing.T, e *env) {
+    token := e.token()
+    e.bank.Inject(fakebank.Fault{
+        Path:       "/products",
+        Status:     http.StatusTooManyRequests,
+        RetryAfter: 30 * time.Second,
+        Times:      1,
+    })
+
+    began := time.Now()
+    _, err := e.adapter.GetProducts(e.ctx, token, "")
+    bankErr := requireBankError(t, err, http.StatusTooManyRequests)
+    if bankErr.RetryAfter != 30*time.Second {
+        t.Errorf("RetryAfter = %v, want 30s", bankErr.RetryAfter)
+    }
+    if time.Since(began) > 5*time.Second {
+        t.Error("adapter waited for a Retry-After longer than its policy allows")
+    }
+    if calls := e.bank.Calls("GET", "/products"); calls != 1 {
+        t.Errorf("product requests = %d, want 1", calls)
+    }
+}
+
+func testCircuitBreaker(t *testing.T, e *env) {
+    token := e.token()
+    e.bank.Inject(fakebank.Fault{Path: "/products", Status: http.StatusInternalServerError})
+
+    // Three failed attempts open the breaker
+    _, err := e.adapter.GetProducts(e.ctx, token, "")
+    requireBankError(t, err, http.StatusInternalServerError)
+    if !e.breaker.Open() {
+        t.Fatal("breaker still closed after three failures")
+    }
+
+    // Further calls are refused without reaching the bank
+    before := e.bank.Calls("", "/*")
+    _, err = e.adapter.GetProducts(e.ctx, token, "")
+    bankErr := requireBankError(t, err, http.StatusServiceUnavailable)
+    if bankErr.Code != bankadapter.ErrCodeBankUnavailable {
+        t.Errorf("code = %s, want %s", bankErr.Code, bankadapter.ErrCodeBankUnavailable)
+    }
+    if after := e.bank.Calls("", "/*"); after != before {
+        t.Errorf("open breaker let %d requests through", after-before)
+    }
+}
+
+func testContextCancelled(t *testing.T, e *env) {
+    token := e.token()
+    e.bank.Inject(fakebank.Fault{Path: "/products", Delay: 5 * time.Second})
+
+    ctx, cancel := context.WithTimeout(e.ctx, 50*time.Millisecond)
+    defer cancel()
+
+    began := time.Now()
+    _, err := e.adapter.GetProducts(ctx, token, "")
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("GetProducts error = %v, want deadline exceeded", err)
+    }
+    if elapsed := time.Since(began); elapsed > 2*time.Second {
+        t.Errorf("GetProducts returned after %v, want it to stop at the deadline", elapsed.Round(time.Millisecond))
+    }
+    // The caller gave up, the bank is not to blame
+    if e.breaker.Open() {
+        t.Error("cancelled request opened the breaker")
+    }
+}
+
+// requireBankError fails the test unless err is a *BankError with status
+func requireBankError(t *testing.T, err error, status int) *bankadapter.BankError {
+    t.Helper()
+
+    if err == nil {
+        t.Fatalf("got no error, want status %d", status)
+    }
+    var bankErr *bankadapter.BankError
+    if !errors.As(err, &bankErr) {
+        t.Fatalf("error %v is not a *BankError", err)
+    }
+    if bankErr.Status != status {
+        t.Fatalf("status = %d, want %d (%v)", bankErr.Status, status, err)
+    }
+    return bankErr
+}
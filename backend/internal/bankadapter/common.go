@@ -2,13 +2,17 @@ package bankadapter
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "fmt"
     "io"
     "net/http"
     "net/url"
+    "strings"
     "time"
     
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    
     "github.com/rs/zerolog"
 )
 
@@ -20,6 +24,10 @@ type BaseAdapter struct {
     TeamID       string
     HTTPClient   *http.Client
     Logger       *zerolog.Logger
+    // Retry applies to idempotent requests only
+    Retry        RetryPolicy
+    // Breaker is shared by all adapters of one bank, nil disables it
+    Breaker      *CircuitBreaker
 }
 
 // NewBaseAdapter creates a new base adapter
@@ -33,23 +41,121 @@ func NewBaseAdapter(clientID, clientSecret, baseURL, teamID string, logger *zero
             Timeout: 30 * time.Second,
         },
         Logger: logger,
+        Retry:  DefaultRetryPolicy,
     }
 }
 
-// DoRequest performs an HTTP request with common error handling
-func (b *BaseAdapter) DoRequest(method, path string, headers map[string]string, body interface{}) (*http.Response, error) {
-    fullURL := b.BaseURL + path
+// DoRequest performs an HTTP request with common error handling. The request
+// is bound to ctx, the client timeout is only an upper limit. GET and requests
+// with an X-Idempotency-Key header are retried on network errors, 429 and
+// 5xx; others are sent once. Error statuses are returned as *BankError.
+func (b *BaseAdapter) DoRequest(ctx context.Context, method, path string, headers map[string]string, body interface{}) (*http.Response, error) {
+    return b.do(ctx, method, path, headers, body, isIdempotent(method, headers))
+}
+
+// DoIdempotentRequest is DoRequest for a POST that is safe to repeat, such as
+// a token request
+func (b *BaseAdapter) DoIdempotentRequest(ctx context.Context, method, path string, headers map[string]string, body interface{}) (*http.Response, error) {
+    return b.do(ctx, method, path, headers, body, true)
+}
+
+func (b *BaseAdapter) do(ctx context.Context, method, path string, headers map[string]string, body interface{}, retry bool) (*http.Response, error) {
+    // Adapters pass either a path or a full URL built with BuildURL
+    fullURL := path
+    if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+        fullURL = b.BaseURL + path
+    }
     
-    var bodyReader io.Reader
+    var payload []byte
     if body != nil {
         jsonBody, err := json.Marshal(body)
         if err != nil {
             return nil, fmt.Errorf("failed to marshal request body: %w", err)
         }
-        bodyReader = bytes.NewReader(jsonBody)
+        payload = jsonBody
+    }
+    
+    attempts := 1
+    if retry && b.Retry.MaxAttempts > 1 {
+        attempts = b.Retry.MaxAttempts
+    }
+    
+    for attempt := 1; ; attempt++ {
+        if !b.Breaker.Allow() {
+            return nil, &BankError{
+                Code:    ErrCodeBankUnavailable,
+                Message: "Bank is unavailable, requests are paused",
+                Status:  http.StatusServiceUnavailable,
+            }
+        }
+        
+        resp, err := b.send(ctx, method, fullURL, headers, payload)
+        
+        var bankErr *BankError
+        switch {
+        case err != nil:
+            // A cancelled caller says nothing about the bank
+            if ctx.Err() != nil {
+                b.Breaker.Abort()
+                return nil, fmt.Errorf("request failed: %w", ctx.Err())
+            }
+            b.Breaker.Failure()
+            bankErr = &BankError{
+                Code:    ErrCodeBankUnavailable,
+                Message: fmt.Sprintf("request failed: %v", err),
+                Err:     err,
+            }
+        case resp.StatusCode >= 400:
+            if resp.StatusCode >= 500 {
+                b.Breaker.Failure()
+            } else {
+                b.Breaker.Success()
+            }
+            bankErr = responseError(resp)
+        default:
+            b.Breaker.Success()
+            return resp, nil
+        }
+        
+        if attempt >= attempts || !bankErr.Temporary() {
+            return nil, bankErr
+        }
+        
+        delay := b.Retry.backoff(attempt)
+        if bankErr.RetryAfter > 0 {
+            // Waiting longer than the policy allows is left to the caller
+            if bankErr.RetryAfter > b.Retry.MaxDelay {
+                return nil, bankErr
+            }
+            delay = bankErr.RetryAfter
+        }
+        
+        b.Logger.Warn().
+            Str("method", method).
+            Str("url", fullURL).
+            Int("attempt", attempt).
+            Dur("delay", delay).
+            Str("error", bankErr.Message).
+            Msg("Retrying bank API request")
+        
+        timer := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return nil, fmt.Errorf("request failed: %w", ctx.Err())
+        case <-timer.C:
+        }
+    }
+}
+
+// send makes one attempt
+func (b *BaseAdapter) send(ctx context.Context, method, fullURL string, headers map[string]string, payload []byte) (*http.Response, error) {
+    var bodyReader io.Reader
+    if payload != nil {
+        bodyReader = bytes.NewReader(payload)
     }
     
-    req, err := http.NewRequest(method, fullURL, bodyReader)
+    req, err := http.NewRequestWithContext(ctx, method, fullURL, bodyReader)
     if err != nil {
         return nil, fmt.Errorf("failed to create request: %w", err)
     }
@@ -72,7 +178,7 @@ func (b *BaseAdapter) DoRequest(method, path string, headers map[string]string,
     
     resp, err := b.HTTPClient.Do(req)
     if err != nil {
-        return nil, fmt.Errorf("request failed: %w", err)
+        return nil, err
     }
     
     // Log response
@@ -84,6 +190,26 @@ func (b *BaseAdapter) DoRequest(method, path string, headers map[string]string,
     return resp, nil
 }
 
+// responseError reads an error response into a classified BankError and
+// closes the body
+func responseError(resp *http.Response) *BankError {
+    defer resp.Body.Close()
+    
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+    
+    bankErr := &BankError{}
+    if err := json.Unmarshal(body, bankErr); err != nil || bankErr.Message == "" {
+        bankErr.Message = fmt.Sprintf("API returned status %d: %s", resp.StatusCode, string(body))
+    }
+    if bankErr.Code == "" {
+        bankErr.Code = codeForStatus(resp.StatusCode)
+    }
+    bankErr.Status = resp.StatusCode
+    bankErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+    
+    return bankErr
+}
+
 // ParseResponse reads and unmarshals response body
 func (b *BaseAdapter) ParseResponse(resp *http.Response, target interface{}) error {
     defer resp.Body.Close()
@@ -174,9 +300,9 @@ func (b *BaseAdapter) ParseClientID(clientID string) (int, error) {
 }
 
 // CalculateInterest calculates interest for deposit
-func CalculateInterest(principal float64, rate float64, days int) float64 {
+func CalculateInterest(principal money.Amount, rate float64, days int) money.Amount {
     // Simple interest calculation: P * R * T / 365
-    return principal * (rate / 100) * float64(days) / 365
+    return principal.ProratedPercent(rate, int64(days), 365)
 }
 
 // CalculateMaturityDate calculates maturity date for deposit
@@ -185,13 +311,16 @@ func CalculateMaturityDate(openDate time.Time, termMonths int) time.Time {
 }
 
 // CalculatePenalty calculates early withdrawal penalty
-func CalculatePenalty(accruedInterest float64, daysHeld, totalDays int) float64 {
+func CalculatePenalty(accruedInterest money.Amount, daysHeld, totalDays int) money.Amount {
     if daysHeld < 30 {
         return accruedInterest // Lose all interest if withdrawn within 30 days
     }
+    if daysHeld >= totalDays {
+        return money.Zero
+    }
     
     // Proportional penalty based on time held
-    penaltyRate := 1.0 - (float64(daysHeld) / float64(totalDays))
-    return accruedInterest * penaltyRate * 0.5 // 50% of proportional interest
+    // 50% of proportional interest: accrued * (1 - held/total) / 2
+    return accruedInterest.MulFrac(int64(totalDays-daysHeld), int64(2*totalDays))
 }
 
@@ -0,0 +1,1079 @@
+package fakebank
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+func (s *Server) routes() {
+    s.mux.HandleFunc("GET /health", s.health)
+    s.mux.HandleFunc("POST /auth/bank-token", s.issueToken)
+
+    s.mux.HandleFunc("POST /account-consents/request", s.authed(s.createAccountConsent))
+    s.mux.HandleFunc("GET /account-consents/{id}", s.authed(s.getConsent))
+    s.mux.HandleFunc("DELETE /account-consents/{id}", s.authed(s.deleteConsent))
+    s.mux.HandleFunc("POST /product-agreement-consents/request", s.authed(s.createProductConsent))
+    s.mux.HandleFunc("GET /product-agreement-consents/{id}", s.authed(s.getConsent))
+    s.mux.HandleFunc("DELETE /product-agreement-consents/{id}", s.authed(s.deleteConsent))
+    s.mux.HandleFunc("POST /payment-consents/request", s.authed(s.createPaymentConsent))
+    s.mux.HandleFunc("GET /payment-consents/{id}", s.authed(s.getConsent))
+    s.mux.HandleFunc("DELETE /payment-consents/{id}", s.authed(s.deleteConsent))
+
+    s.mux.HandleFunc("GET /accounts", s.authed(s.listAccounts))
+    s.mux.HandleFunc("POST /accounts", s.authed(s.createAccount))
+    s.mux.HandleFunc("GET /accounts/{id}", s.authed(s.getAccount))
+    s.mux.HandleFunc("GET /accounts/{id}/balances", s.authed(s.getBalances))
+    s.mux.HandleFunc("GET /accounts/{id}/transactions", s.authed(s.listTransactions))
+    s.mux.HandleFunc("PUT /accounts/{id}/close", s.authed(s.closeAccount))
+
+    s.mux.HandleFunc("GET /products", s.authed(s.listProducts))
+    s.mux.HandleFunc("GET /products/{id}", s.authed(s.getProduct))
+    s.mux.HandleFunc("GET /product-agreements", s.authed(s.listAgreements))
+    s.mux.HandleFunc("POST /product-agreements", s.authed(s.openAgreement))
+    s.mux.HandleFunc("GET /product-agreements/{id}", s.authed(s.getAgreement))
+    s.mux.HandleFunc("DELETE /product-agreements/{id}", s.authed(s.closeAgreement))
+
+    s.mux.HandleFunc("POST /payments", s.authed(s.createPayment))
+    s.mux.HandleFunc("GET /payments/{id}", s.authed(s.getPayment))
+
+    s.mux.HandleFunc("GET /cards", s.authed(s.listCards))
+    s.mux.HandleFunc("POST /cards", s.authed(s.createCard))
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Auth
+
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+    clientID := r.URL.Query().Get("client_id")
+    secret := r.URL.Query().Get("client_secret")
+    if clientID == "" || (s.opts.ClientID != "" && (clientID != s.opts.ClientID || secret != s.opts.ClientSecret)) {
+        writeError(w, http.StatusUnauthorized, bankadapter.ErrCodeUnauthorized, "Invalid client credentials")
+        return
+    }
+
+    s.mu.Lock()
+    token := s.nextID("token")
+    s.tokens[token] = s.opts.Now().Add(s.opts.TokenTTL)
+    s.mu.Unlock()
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "access_token": token,
+        "token_type":   "bearer",
+        "expires_in":   int(s.opts.TokenTTL.Seconds()),
+        "client_id":    clientID,
+    })
+}
+
+// authed rejects requests without a live bank token
+func (s *Server) authed(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+        s.mu.Lock()
+        expiresAt, ok := s.tokens[token]
+        s.mu.Unlock()
+
+        if !ok || !s.opts.Now().Before(expiresAt) {
+            writeError(w, http.StatusUnauthorized, bankadapter.ErrCodeUnauthorized, "Invalid or expired token")
+            return
+        }
+        next(w, r)
+    }
+}
+
+// Consents
+
+func (s *Server) newConsent(kind, clientID string, permissions []string, ttl time.Duration) *consent {
+    now := s.opts.Now()
+    c := &consent{
+        id:          s.nextID("consent"),
+        kind:        kind,
+        clientID:    clientID,
+        permissions: permissions,
+        status:      ConsentAuthorised,
+        createdAt:   now,
+        expiresAt:   now.Add(ttl),
+    }
+    if s.opts.ManualApproval {
+        c.status = ConsentAwaitingAuthorisation
+    }
+    s.consents[c.id] = c
+    return c
+}
+
+func (s *Server) createAccountConsent(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        ClientID    string   `json:"client_id"`
+        Permissions []string `json:"permissions"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+    if req.ClientID == "" || len(req.Permissions) == 0 {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "client_id and permissions are required")
+        return
+    }
+
+    s.mu.Lock()
+    c := s.newConsent(consentAccount, req.ClientID, req.Permissions, 90*24*time.Hour)
+    s.mu.Unlock()
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "status":        c.status,
+        "consent_id":    c.id,
+        "permissions":   c.permissions,
+        "expires_at":    c.expiresAt.Format(time.RFC3339),
+        "auto_approved": c.status == ConsentAuthorised,
+    })
+}
+
+func (s *Server) createProductConsent(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        ClientID string `json:"client_id"`
+        Read     bool   `json:"read_product_agreements"`
+        Open     bool   `json:"open_product_agreements"`
+        Close    bool   `json:"close_product_agreements"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+    if req.ClientID == "" {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "client_id is required")
+        return
+    }
+
+    var permissions []string
+    if req.Read {
+        permissions = append(permissions, "read_product_agreements")
+    }
+    if req.Open {
+        permissions = append(permissions, "open_product_agreements")
+    }
+    if req.Close {
+        permissions = append(permissions, "close_product_agreements")
+    }
+
+    s.mu.Lock()
+    c := s.newConsent(consentProduct, req.ClientID, permissions, 365*24*time.Hour)
+    s.mu.Unlock()
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "consent_id":    c.id,
+        "status":        c.status,
+        "auto_approved": c.status == ConsentAuthorised,
+        "valid_until":   c.expiresAt.Format(time.RFC3339),
+    })
+}
+
+func (s *Server) createPaymentConsent(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        ClientID      string `json:"client_id"`
+        DebtorAccount string `json:"debtor_account"`
+        ValidUntil    string `json:"valid_until"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+    if req.ClientID == "" || req.DebtorAccount == "" {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "client_id and debtor_account are required")
+        return
+    }
+
+    ttl := 24 * time.Hour
+    if validUntil, err := time.Parse(time.RFC3339, req.ValidUntil); err == nil {
+        ttl = validUntil.Sub(s.opts.Now())
+    }
+
+    s.mu.Lock()
+    c := s.newConsent(consentPayment, req.ClientID, nil, ttl)
+    s.mu.Unlock()
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "consent_id": c.id,
+        "status":     c.status,
+    })
+}
+
+func (s *Server) getConsent(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.consents[r.PathValue("id")]
+    if !ok {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Consent not found")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "data": map[string]interface{}{
+            "consentId":          c.id,
+            "status":             s.consentStatus(c),
+            "permissions":        c.permissions,
+            "expirationDateTime": c.expiresAt.Format(time.RFC3339),
+            "creationDateTime":   c.createdAt.Format(time.RFC3339),
+        },
+    })
+}
+
+func (s *Server) deleteConsent(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.consents[r.PathValue("id")]
+    if !ok {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Consent not found")
+        return
+    }
+    c.status = ConsentRevoked
+    w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) consentStatus(c *consent) string {
+    if c.status == ConsentAuthorised && !s.opts.Now().Before(c.expiresAt) {
+        return "Expired"
+    }
+    return c.status
+}
+
+// requireConsent checks the consent in header is usable for kind and
+// permission, s.mu must be held. It writes the error response when it is not.
+func (s *Server) requireConsent(w http.ResponseWriter, r *http.Request, header, kind, permission string) (*consent, bool) {
+    id := r.Header.Get(header)
+    c, ok := s.consents[id]
+    if id == "" || !ok || c.kind != kind {
+        writeError(w, http.StatusForbidden, bankadapter.ErrCodeConsentRequired, "A valid consent is required")
+        return nil, false
+    }
+
+    switch s.consentStatus(c) {
+    case ConsentAuthorised:
+    case "Expired":
+        writeError(w, http.StatusForbidden, bankadapter.ErrCodeConsentExpired, "Consent has expired")
+        return nil, false
+    default:
+        writeError(w, http.StatusForbidden, bankadapter.ErrCodeConsentRequired, fmt.Sprintf("Consent is %s", c.status))
+        return nil, false
+    }
+
+    if permission != "" && !hasPermission(c.permissions, permission) {
+        writeError(w, http.StatusForbidden, bankadapter.ErrCodeForbidden, fmt.Sprintf("Consent does not grant %s", permission))
+        return nil, false
+    }
+    return c, true
+}
+
+// consentAccount finds an account the consent in the request gives access
+// to, s.mu must be held
+func (s *Server) consentAccount(w http.ResponseWriter, r *http.Request, permission string) (*Account, bool) {
+    c, ok := s.requireConsent(w, r, "X-Consent-Id", consentAccount, permission)
+    if !ok {
+        return nil, false
+    }
+
+    acc, ok := s.accounts[r.PathValue("id")]
+    if !ok || acc.ClientID != c.clientID {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Account not found")
+        return nil, false
+    }
+    return acc, true
+}
+
+// Accounts
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.requireConsent(w, r, "X-Consent-Id", consentAccount, "ReadAccountsDetail")
+    if !ok {
+        return
+    }
+
+    accounts := make([]interface{}, 0)
+    for _, acc := range s.clientAccounts(c.clientID) {
+        accounts = append(accounts, accountJSON(acc))
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "data": map[string]interface{}{"account": accounts},
+    })
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    acc, ok := s.consentAccount(w, r, "ReadAccountsDetail")
+    if !ok {
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "data": map[string]interface{}{"account": accountJSON(acc)},
+    })
+}
+
+func (s *Server) getBalances(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    acc, ok := s.consentAccount(w, r, "ReadBalances")
+    if !ok {
+        return
+    }
+
+    indicator := "Credit"
+    if acc.Balance < 0 {
+        indicator = "Debit"
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "data": map[string]interface{}{
+            "balance": []interface{}{
+                map[string]interface{}{
+                    "amount":               money.New(acc.Balance.Abs(), acc.Currency),
+                    "creditDebitIndicator": indicator,
+                    "type":                 "InterimAvailable",
+                    "dateTime":             s.opts.Now().Format(time.RFC3339),
+                },
+            },
+        },
+    })
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    from, errFrom := parseTimeParam(query.Get("from_booking_date_time"))
+    to, errTo := parseTimeParam(query.Get("to_booking_date_time"))
+    page, errPage := parseIntParam(query.Get("page"), 1)
+    limit, errLimit := parseIntParam(query.Get("limit"), 50)
+    if errFrom != nil || errTo != nil || errPage != nil || errLimit != nil || page < 1 || limit < 1 {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "Invalid query parameters")
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    acc, ok := s.consentAccount(w, r, "ReadTransactionsDetail")
+    if !ok {
+        return
+    }
+
+    all := s.sortedTransactions(acc.ID)
+    matched := make([]Transaction, 0, len(all))
+    for _, tx := range all {
+        if !from.IsZero() && tx.Booked.Before(from) {
+            continue
+        }
+        if !to.IsZero() && tx.Booked.After(to) {
+            continue
+        }
+        matched = append(matched, tx)
+    }
+
+    totalPages := (len(matched) + limit - 1) / limit
+    start := (page - 1) * limit
+    end := start + limit
+    if start > len(matched) {
+        start = len(matched)
+    }
+    if end > len(matched) {
+        end = len(matched)
+    }
+
+    items := make([]interface{}, 0, end-start)
+    for _, tx := range matched[start:end] {
+        items = append(items, transactionJSON(tx, acc.Currency))
+    }
+
+    links := map[string]string{"self": pageLink(r.URL, page)}
+    if page < totalPages {
+        links["next"] = pageLink(r.URL, page+1)
+    }
+
+    meta := map[string]interface{}{"totalPages": totalPages}
+    if len(all) > 0 {
+        meta["firstAvailableDateTime"] = all[len(all)-1].Booked.Format(time.RFC3339)
+        meta["lastAvailableDateTime"] = all[0].Booked.Format(time.RFC3339)
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "data":  map[string]interface{}{"transaction": items},
+        "links": links,
+        "meta":  meta,
+    })
+}
+
+func (s *Server) createAccount(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        AccountType    string       `json:"account_type"`
+        InitialBalance money.Amount `json:"initial_balance"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+    clientID := r.URL.Query().Get("client_id")
+    if clientID == "" || req.InitialBalance < 0 {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "client_id is required and initial_balance can not be negative")
+        return
+    }
+
+    id := s.AddAccount(Account{ClientID: clientID, AccountType: req.AccountType, Balance: req.InitialBalance})
+    acc, _ := s.Account(id)
+
+    writeJSON(w, http.StatusCreated, map[string]interface{}{
+        "account_id":     acc.ID,
+        "identification": acc.Identification,
+        "account_type":   acc.AccountType,
+        "currency":       acc.Currency,
+        "balance":        acc.Balance,
+    })
+}
+
+func (s *Server) closeAccount(w http.ResponseWriter, r *http.Request) {
+    var req bankadapter.AccountCloseRequest
+    if !decode(w, r, &req) {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    acc, ok := s.accounts[r.PathValue("id")]
+    if !ok || acc.Closed || acc.ClientID != r.URL.Query().Get("client_id") {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Account not found")
+        return
+    }
+
+    if acc.Balance > 0 {
+        dest, ok := s.accounts[req.DestinationAccountID]
+        if req.Action != "transfer" || !ok || dest.Closed || dest.ID == acc.ID {
+            writeError(w, http.StatusUnprocessableEntity, bankadapter.ErrCodeInvalidRequest, "Account has a balance, a destination account is required")
+            return
+        }
+        s.transfer(acc, dest, acc.Balance, "Перевод при закрытии счёта")
+    }
+    acc.Closed = true
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "account_id": acc.ID,
+        "status":     "closed",
+    })
+}
+
+func (s *Server) clientAccounts(clientID string) []*Account {
+    accounts := make([]*Account, 0)
+    for _, acc := range s.accounts {
+        if acc.ClientID == clientID && !acc.Closed {
+            accounts = append(accounts, acc)
+        }
+    }
+    sortAccounts(accounts)
+    return accounts
+}
+
+// transfer moves amount between accounts and books it on both, a nil to is
+// an account outside the bank. s.mu must be held.
+func (s *Server) transfer(from, to *Account, amount money.Amount, description string) {
+    now := s.opts.Now()
+    counterparty := ""
+    if to != nil {
+        counterparty = to.Identification
+    }
+    from.Balance -= amount
+    s.transactions[from.ID] = append(s.transactions[from.ID], Transaction{
+        ID:           s.nextID("tx"),
+        AccountID:    from.ID,
+        Amount:       -amount,
+        Description:  description,
+        Counterparty: counterparty,
+        Booked:       now,
+    })
+    if to != nil {
+        to.Balance += amount
+        s.transactions[to.ID] = append(s.transactions[to.ID], Transaction{
+            ID:           s.nextID("tx"),
+            AccountID:    to.ID,
+            Amount:       amount,
+            Description:  description,
+            Counterparty: from.Identification,
+            Booked:       now,
+        })
+    }
+}
+
+// Products
+
+func (s *Server) listProducts(w http.ResponseWriter, r *http.Request) {
+    productType := r.URL.Query().Get("product_type")
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    products := make([]interface{}, 0, len(s.products))
+    for _, p := range s.products {
+        if productType == "" || p.Type == productType {
+            products = append(products, productJSON(p))
+        }
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{"products": products})
+}
+
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    p, ok := s.product(r.PathValue("id"))
+    if !ok {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Product not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, productJSON(*p))
+}
+
+func (s *Server) product(id string) (*Product, bool) {
+    for i := range s.products {
+        if s.products[i].ID == id {
+            return &s.products[i], true
+        }
+    }
+    return nil, false
+}
+
+// Product agreements
+
+func (s *Server) listAgreements(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.requireConsent(w, r, "X-Product-Agreement-Consent-Id", consentProduct, "read_product_agreements")
+    if !ok {
+        return
+    }
+
+    agreements := make([]*Agreement, 0)
+    for _, agr := range s.agreements {
+        if agr.ClientID == c.clientID {
+            agreements = append(agreements, agr)
+        }
+    }
+    sortAgreements(agreements)
+
+    items := make([]interface{}, 0, len(agreements))
+    for _, agr := range agreements {
+        items = append(items, s.agreementJSON(agr))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"agreements": items})
+}
+
+func (s *Server) openAgreement(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        ProductID       string       `json:"product_id"`
+        Amount          money.Amount `json:"amount"`
+        TermMonths      int          `json:"term_months"`
+        SourceAccountID string       `json:"source_account_id"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.requireConsent(w, r, "X-Product-Agreement-Consent-Id", consentProduct, "open_product_agreements")
+    if !ok {
+        return
+    }
+
+    p, ok := s.product(req.ProductID)
+    if !ok || p.Type != "deposit" {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Deposit product not found")
+        return
+    }
+    if req.Amount <= 0 || (p.MinAmount > 0 && req.Amount < p.MinAmount) || (p.MaxAmount > 0 && req.Amount > p.MaxAmount) {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "Amount is outside the product limits")
+        return
+    }
+    if len(p.TermMonths) > 0 && !containsInt(p.TermMonths, req.TermMonths) {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "Term is not offered for this product")
+        return
+    }
+
+    source, ok := s.accounts[req.SourceAccountID]
+    if !ok || source.Closed || source.ClientID != c.clientID {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Source account not found")
+        return
+    }
+    if source.Balance < req.Amount {
+        writeError(w, http.StatusUnprocessableEntity, bankadapter.ErrCodeInsufficientFunds, "Insufficient funds")
+        return
+    }
+
+    now := s.opts.Now()
+    agr := &Agreement{
+        ID:         s.nextID("agr"),
+        ClientID:   c.clientID,
+        ProductID:  p.ID,
+        AccountID:  source.ID,
+        Amount:     req.Amount,
+        Status:     "active",
+        OpenedAt:   now,
+        MaturityAt: bankadapter.CalculateMaturityDate(now, req.TermMonths),
+        TermMonths: req.TermMonths,
+    }
+    s.agreements[agr.ID] = agr
+    s.transfer(source, nil, req.Amount, "Открытие вклада "+p.Name)
+
+    writeJSON(w, http.StatusCreated, s.agreementJSON(agr))
+}
+
+func (s *Server) getAgreement(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    agr, ok := s.consentAgreement(w, r, "read_product_agreements")
+    if !ok {
+        return
+    }
+    writeJSON(w, http.StatusOK, s.agreementJSON(agr))
+}
+
+func (s *Server) closeAgreement(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    agr, ok := s.consentAgreement(w, r, "close_product_agreements")
+    if !ok {
+        return
+    }
+    if agr.Status != "active" {
+        writeError(w, http.StatusConflict, bankadapter.ErrCodeInvalidRequest, "Agreement is already closed")
+        return
+    }
+
+    now := s.opts.Now()
+    accrued, penalty := s.interest(agr, now)
+    returned := agr.Amount + accrued - penalty
+    agr.Status = "closed"
+
+    if dest, ok := s.accounts[agr.AccountID]; ok {
+        dest.Balance += returned
+        s.transactions[dest.ID] = append(s.transactions[dest.ID], Transaction{
+            ID:          s.nextID("tx"),
+            AccountID:   dest.ID,
+            Amount:      returned,
+            Description: "Закрытие вклада",
+            Booked:      now,
+        })
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "agreementId":     agr.ID,
+        "closedAt":        now.Format(time.RFC3339),
+        "returnedAmount":  returned,
+        "accruedInterest": accrued,
+        "penaltyAmount":   penalty,
+    })
+}
+
+func (s *Server) consentAgreement(w http.ResponseWriter, r *http.Request, permission string) (*Agreement, bool) {
+    c, ok := s.requireConsent(w, r, "X-Product-Agreement-Consent-Id", consentProduct, permission)
+    if !ok {
+        return nil, false
+    }
+
+    agr, ok := s.agreements[r.PathValue("id")]
+    if !ok || agr.ClientID != c.clientID {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Agreement not found")
+        return nil, false
+    }
+    return agr, true
+}
+
+// interest is what the deposit earned by now and what closing it early costs
+func (s *Server) interest(agr *Agreement, now time.Time) (accrued, penalty money.Amount) {
+    p, ok := s.product(agr.ProductID)
+    if !ok {
+        return 0, 0
+    }
+
+    daysHeld := int(now.Sub(agr.OpenedAt).Hours() / 24)
+    totalDays := int(agr.MaturityAt.Sub(agr.OpenedAt).Hours() / 24)
+    accrued = bankadapter.CalculateInterest(agr.Amount, p.InterestRate, daysHeld)
+    if now.Before(agr.MaturityAt) && totalDays > 0 {
+        penalty = bankadapter.CalculatePenalty(accrued, daysHeld, totalDays)
+    }
+    return accrued, penalty
+}
+
+// Payments
+
+func (s *Server) createPayment(w http.ResponseWriter, r *http.Request) {
+    var req struct {
+        Data struct {
+            Initiation struct {
+                InstructedAmount money.Money `json:"instructedAmount"`
+                DebtorAccount struct {
+                    Identification string `json:"identification"`
+                } `json:"debtorAccount"`
+                CreditorAccount struct {
+                    Identification string `json:"identification"`
+                } `json:"creditorAccount"`
+            } `json:"initiation"`
+        } `json:"data"`
+    }
+    if !decode(w, r, &req) {
+        return
+    }
+    initiation := req.Data.Initiation
+
+    amount := initiation.InstructedAmount.Amount
+    if amount <= 0 || initiation.CreditorAccount.Identification == "" {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "Amount and creditor account are required")
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // A repeated idempotency key gets the first payment back
+    key := r.Header.Get(bankadapter.IdempotencyKeyHeader)
+    if id, ok := s.paymentKeys[key]; ok && key != "" {
+        writeJSON(w, http.StatusCreated, map[string]interface{}{"data": paymentJSON(s.payments[id])})
+        return
+    }
+
+    // Payments from another bank's client go through a payment consent
+    if id := r.Header.Get("X-Payment-Consent-Id"); id != "" {
+        if _, ok := s.requireConsent(w, r, "X-Payment-Consent-Id", consentPayment, ""); !ok {
+            return
+        }
+    }
+
+    debtor := s.accountByIdentification(initiation.DebtorAccount.Identification)
+    if debtor == nil || debtor.Closed || debtor.ClientID != r.URL.Query().Get("client_id") {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Debtor account not found")
+        return
+    }
+    if debtor.Balance < amount {
+        writeError(w, http.StatusUnprocessableEntity, bankadapter.ErrCodeInsufficientFunds, "Insufficient funds")
+        return
+    }
+
+    // A creditor outside this bank is settled elsewhere
+    creditor := s.accountByIdentification(initiation.CreditorAccount.Identification)
+    s.transfer(debtor, creditor, amount, "Платёж")
+
+    now := s.opts.Now()
+    payment := &Payment{
+        ID:                s.nextID("pay"),
+        DebtorAccountID:   debtor.ID,
+        CreditorAccountID: initiation.CreditorAccount.Identification,
+        Amount:            amount,
+        Currency:          debtor.Currency,
+        Status:            "AcceptedSettlementCompleted",
+        CreatedAt:         now,
+        UpdatedAt:         now,
+    }
+    s.payments[payment.ID] = payment
+    if key != "" {
+        s.paymentKeys[key] = payment.ID
+    }
+
+    writeJSON(w, http.StatusCreated, map[string]interface{}{"data": paymentJSON(payment)})
+}
+
+func (s *Server) getPayment(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    payment, ok := s.payments[r.PathValue("id")]
+    if !ok {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Payment not found")
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"data": paymentJSON(payment)})
+}
+
+func (s *Server) accountByIdentification(identification string) *Account {
+    if acc, ok := s.accounts[identification]; ok {
+        return acc
+    }
+    for _, acc := range s.accounts {
+        if acc.Identification == identification {
+            return acc
+        }
+    }
+    return nil
+}
+
+// Cards
+
+func (s *Server) listCards(w http.ResponseWriter, r *http.Request) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.requireConsent(w, r, "X-Consent-Id", consentAccount, "")
+    if !ok {
+        return
+    }
+
+    cards := make([]*Card, 0)
+    for _, card := range s.cards {
+        if acc, ok := s.accounts[card.AccountID]; ok && acc.ClientID == c.clientID {
+            cards = append(cards, card)
+        }
+    }
+    sortCards(cards)
+
+    items := make([]interface{}, 0, len(cards))
+    for _, card := range cards {
+        items = append(items, cardJSON(card))
+    }
+    writeJSON(w, http.StatusOK, map[string]interface{}{"cards": items})
+}
+
+func (s *Server) createCard(w http.ResponseWriter, r *http.Request) {
+    var req bankadapter.CreateCardRequest
+    if !decode(w, r, &req) {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.requireConsent(w, r, "X-Consent-Id", consentAccount, "")
+    if !ok {
+        return
+    }
+
+    acc := s.accountByIdentification(req.AccountNumber)
+    if acc == nil || acc.Closed || acc.ClientID != c.clientID {
+        writeError(w, http.StatusNotFound, bankadapter.ErrCodeNotFound, "Account not found")
+        return
+    }
+
+    cardType := req.CardType
+    if cardType == "" {
+        cardType = "debit"
+    }
+    card := &Card{
+        ID:           s.nextID("card"),
+        AccountID:    acc.ID,
+        Type:         cardType,
+        Status:       "active",
+        DailyLimit:   req.DailyLimit,
+        MonthlyLimit: req.MonthlyLimit,
+        IssuedAt:     s.opts.Now(),
+    }
+    card.Number = fmt.Sprintf("2200 **** **** %04d", s.seq%10000)
+    s.cards[card.ID] = card
+
+    writeJSON(w, http.StatusCreated, cardJSON(card))
+}
+
+// Response bodies
+
+func accountJSON(acc *Account) map[string]interface{} {
+    return map[string]interface{}{
+        "accountId":      acc.ID,
+        "currency":       acc.Currency,
+        "accountType":    acc.AccountType,
+        "accountSubType": "CurrentAccount",
+        "nickname":       acc.Nickname,
+        "account": map[string]string{
+            "schemeName":     "RU.CBR.PAN",
+            "identification": acc.Identification,
+            "name":           acc.Nickname,
+        },
+        "servicer": map[string]string{
+            "schemeName":     "RU.CBR.BIK",
+            "identification": "044525000",
+            "name":           "Fake Bank",
+        },
+    }
+}
+
+func transactionJSON(tx Transaction, currency string) map[string]interface{} {
+    indicator := "Credit"
+    if tx.Amount < 0 {
+        indicator = "Debit"
+    }
+    return map[string]interface{}{
+        "transactionId":        tx.ID,
+        "accountId":            tx.AccountID,
+        "amount":               money.New(tx.Amount.Abs(), currency),
+        "creditDebitIndicator": indicator,
+        "status":               "Booked",
+        "bookingDateTime":      tx.Booked.Format(time.RFC3339),
+        "valueDateTime":        tx.Booked.Format(time.RFC3339),
+        "transactionInformation": map[string]string{
+            "description":          tx.Description,
+            "transactionReference": tx.ID,
+        },
+        "counterpartyName": tx.Counterparty,
+        "category":         tx.Category,
+    }
+}
+
+func productJSON(p Product) map[string]interface{} {
+    return map[string]interface{}{
+        "productId":    p.ID,
+        "productType":  p.Type,
+        "productName":  p.Name,
+        "description":  p.Description,
+        "interestRate": p.InterestRate,
+        "minAmount":    p.MinAmount,
+        "maxAmount":    p.MaxAmount,
+        "termMonths":   p.TermMonths,
+        "currency":     p.Currency,
+    }
+}
+
+func (s *Server) agreementJSON(agr *Agreement) map[string]interface{} {
+    body := map[string]interface{}{
+        "agreementId":  agr.ID,
+        "productId":    agr.ProductID,
+        "amount":       agr.Amount,
+        "termMonths":   agr.TermMonths,
+        "status":       agr.Status,
+        "openedDate":   agr.OpenedAt.Format(time.RFC3339),
+        "maturityDate": agr.MaturityAt.Format(time.RFC3339),
+        "accountId":    agr.AccountID,
+    }
+    if p, ok := s.product(agr.ProductID); ok {
+        body["productType"] = p.Type
+        body["productName"] = p.Name
+        body["currency"] = p.Currency
+        body["interestRate"] = p.InterestRate
+    }
+    if agr.Status == "active" {
+        accrued, _ := s.interest(agr, s.opts.Now())
+        body["accruedInterest"] = accrued
+    }
+    return body
+}
+
+func paymentJSON(p *Payment) map[string]interface{} {
+    return map[string]interface{}{
+        "paymentId":            p.ID,
+        "status":               p.Status,
+        "creationDateTime":     p.CreatedAt.Format(time.RFC3339),
+        "statusUpdateDateTime": p.UpdatedAt.Format(time.RFC3339),
+        "amount":               p.Amount.String(),
+        "currency":             p.Currency,
+    }
+}
+
+func cardJSON(card *Card) map[string]interface{} {
+    return map[string]interface{}{
+        "cardId":       card.ID,
+        "cardNumber":   card.Number,
+        "cardType":     card.Type,
+        "cardBrand":    "MIR",
+        "cardStatus":   card.Status,
+        "accountId":    card.AccountID,
+        "expiryDate":   card.IssuedAt.AddDate(4, 0, 0).Format("01/06"),
+        "dailyLimit":   card.DailyLimit,
+        "monthlyLimit": card.MonthlyLimit,
+        "issuedDate":   card.IssuedAt.Format(time.RFC3339),
+    }
+}
+
+func pageLink(u *url.URL, page int) string {
+    query := u.Query()
+    query.Set("page", strconv.Itoa(page))
+    return u.Path + "?" + query.Encode()
+}
+
+// Helpers
+
+func decode(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+    if r.Body == nil || r.ContentLength == 0 {
+        return true
+    }
+    if err := json.NewDecoder(r.Body).Decode(target); err != nil {
+        writeError(w, http.StatusBadRequest, bankadapter.ErrCodeInvalidRequest, "Invalid request body")
+        return false
+    }
+    return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+    writeJSON(w, status, map[string]string{
+        "code":    code,
+        "message": message,
+    })
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+    if value == "" {
+        return time.Time{}, nil
+    }
+    return time.Parse(time.RFC3339, value)
+}
+
+func parseIntParam(value string, def int) (int, error) {
+    if value == "" {
+        return def, nil
+    }
+    return strconv.Atoi(value)
+}
+
+func hasPermission(permissions []string, permission string) bool {
+    for _, p := range permissions {
+        if p == permission {
+            return true
+        }
+    }
+    return false
+}
+
+func containsInt(values []int, value int) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// idLess orders generated ids such as acc-2 and acc-10 by creation
+func idLess(a, b string) bool {
+    if len(a) != len(b) {
+        return len(a) < len(b)
+    }
+    return a < b
+}
+
+func sortAccounts(accounts []*Account) {
+    sort.Slice(accounts, func(i, j int) bool { return idLess(accounts[i].ID, accounts[j].ID) })
+}
+
+func sortAgreements(agreements []*Agreement) {
+    sort.Slice(agreements, func(i, j int) bool { return idLess(agreements[i].ID, agreements[j].ID) })
+}
+
+func sortCards(cards []*Card) {
+    sort.Slice(cards, func(i, j int) bool { return idLess(cards[i].ID, cards[j].ID) })
+}
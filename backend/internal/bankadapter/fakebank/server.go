@@ -0,0 +1,469 @@
+// Package fakebank is an in-memory Open Banking server speaking the API of
+// the VBank, ABank and SBank sandboxes. It runs under httptest for adapter
+// tests or standalone through cmd/fakebank for local development.
+package fakebank
+
+import (
+    "fmt"
+    "net/http"
+    "path"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// Options configures a fake bank
+type Options struct {
+    // ClientID and ClientSecret are the team credentials the bank accepts,
+    // empty accepts any
+    ClientID     string
+    ClientSecret string
+    // ManualApproval leaves new consents AwaitingAuthorisation until Approve
+    // is called, like SBank does
+    ManualApproval bool
+    // TokenTTL is how long bank tokens live, 24 hours by default
+    TokenTTL time.Duration
+    // Now is the bank clock, time.Now by default
+    Now func() time.Time
+}
+
+// Account is a client account held by the bank
+type Account struct {
+    ID             string
+    ClientID       string
+    Identification string
+    Currency       string
+    AccountType    string
+    Nickname       string
+    Balance        money.Amount
+    Closed         bool
+}
+
+// Transaction is a booked account transaction, Amount is negative for debits
+type Transaction struct {
+    ID           string
+    AccountID    string
+    Amount       money.Amount
+    Description  string
+    Counterparty string
+    Category     string
+    Booked       time.Time
+}
+
+// Product is a product from the bank catalogue
+type Product struct {
+    ID           string
+    Type         string
+    Name         string
+    Description  string
+    InterestRate float64
+    MinAmount    money.Amount
+    MaxAmount    money.Amount
+    TermMonths   []int
+    Currency     string
+}
+
+// Agreement is an opened product, a deposit for now
+type Agreement struct {
+    ID           string
+    ClientID     string
+    ProductID    string
+    AccountID    string
+    Amount       money.Amount
+    Status       string
+    OpenedAt     time.Time
+    MaturityAt   time.Time
+    TermMonths   int
+}
+
+// Payment is a payment made from a client account
+type Payment struct {
+    ID                string
+    DebtorAccountID   string
+    CreditorAccountID string
+    Amount            money.Amount
+    Currency          string
+    Status            string
+    CreatedAt         time.Time
+    UpdatedAt         time.Time
+}
+
+// Card is a card issued to an account
+type Card struct {
+    ID           string
+    AccountID    string
+    Number       string
+    Type         string
+    Status       string
+    DailyLimit   money.Amount
+    MonthlyLimit money.Amount
+    IssuedAt     time.Time
+}
+
+// Fault makes the bank fail matching requests. Method and Path narrow the
+// requests it applies to, Path is a path.Match pattern such as
+// "/accounts/*/transactions".
+type Fault struct {
+    Method     string
+    Path       string
+    Status     int
+    Code       string
+    Message    string
+    RetryAfter time.Duration
+    // Delay holds the response back, with Status 0 the request is then
+    // served normally
+    Delay      time.Duration
+    // Times is how many requests fail, 0 fails all of them
+    Times      int
+}
+
+// Consent statuses as the sandboxes report them
+const (
+    ConsentAuthorised            = "Authorised"
+    ConsentAwaitingAuthorisation = "AwaitingAuthorisation"
+    ConsentRevoked               = "Revoked"
+)
+
+const (
+    consentAccount = "account"
+    consentProduct = "product"
+    consentPayment = "payment"
+)
+
+type consent struct {
+    id          string
+    kind        string
+    clientID    string
+    permissions []string
+    status      string
+    createdAt   time.Time
+    expiresAt   time.Time
+}
+
+type request struct {
+    method string
+    path   string
+}
+
+// Server is a fake bank, safe for concurrent use
+type Server struct {
+    opts Options
+    mux  *http.ServeMux
+
+    mu           sync.Mutex
+    seq          int
+    tokens       map[string]time.Time
+    consents     map[string]*consent
+    accounts     map[string]*Account
+    transactions map[string][]Transaction
+    products     []Product
+    agreements   map[string]*Agreement
+    payments     map[string]*Payment
+    paymentKeys  map[string]string
+    cards        map[string]*Card
+    faults       []*Fault
+    requests     []request
+}
+
+// New creates an empty bank
+func New(opts Options) *Server {
+    if opts.TokenTTL <= 0 {
+        opts.TokenTTL = 24 * time.Hour
+    }
+    if opts.Now == nil {
+        opts.Now = time.Now
+    }
+
+    s := &Server{
+        opts:         opts,
+        mux:          http.NewServeMux(),
+        tokens:       make(map[string]time.Time),
+        consents:     make(map[string]*consent),
+        accounts:     make(map[string]*Account),
+        transactions: make(map[string][]Transaction),
+        agreements:   make(map[string]*Agreement),
+        payments:     make(map[string]*Payment),
+        paymentKeys:  make(map[string]string),
+        cards:        make(map[string]*Card),
+    }
+    s.routes()
+    return s
+}
+
+// ServeHTTP records the request, applies injected faults and serves it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    fault := s.record(r)
+    if fault != nil {
+        if fault.Delay > 0 {
+            select {
+            case <-time.After(fault.Delay):
+            case <-r.Context().Done():
+                return
+            }
+        }
+        if fault.Status != 0 {
+            if seconds := int(fault.RetryAfter.Seconds()); seconds > 0 {
+                w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+            }
+            code, message := fault.Code, fault.Message
+            if message == "" {
+                message = http.StatusText(fault.Status)
+            }
+            writeError(w, fault.Status, code, message)
+            return
+        }
+    }
+
+    s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) record(r *http.Request) *Fault {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.requests = append(s.requests, request{method: r.Method, path: r.URL.Path})
+
+    for i, f := range s.faults {
+        if f.Method != "" && f.Method != r.Method {
+            continue
+        }
+        if f.Path != "" {
+            if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+                continue
+            }
+        }
+
+        fault := *f
+        if f.Times > 0 {
+            f.Times--
+            if f.Times == 0 {
+                s.faults = append(s.faults[:i], s.faults[i+1:]...)
+            }
+        }
+        return &fault
+    }
+    return nil
+}
+
+// Inject adds a fault, faults are matched in the order they were added
+func (s *Server) Inject(f Fault) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.faults = nil
+}
+
+// Calls counts requests received with method, empty for any, and a path
+// matching pattern
+func (s *Server) Calls(method, pattern string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    count := 0
+    for _, r := range s.requests {
+        if method != "" && method != r.method {
+            continue
+        }
+        if ok, _ := path.Match(pattern, r.path); ok {
+            count++
+        }
+    }
+    return count
+}
+
+// AddAccount opens an account, ID and Identification are generated when empty
+func (s *Server) AddAccount(acc Account) string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if acc.ID == "" {
+        acc.ID = s.nextID("acc")
+    }
+    if acc.Identification == "" {
+        acc.Identification = fmt.Sprintf("40817810%012d", s.seq)
+    }
+    if acc.Currency == "" {
+        acc.Currency = "RUB"
+    }
+    if acc.AccountType == "" {
+        acc.AccountType = "Personal"
+    }
+    s.accounts[acc.ID] = &acc
+    return acc.ID
+}
+
+// AddTransaction books a transaction without touching the balance
+func (s *Server) AddTransaction(tx Transaction) string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if tx.ID == "" {
+        tx.ID = s.nextID("tx")
+    }
+    s.transactions[tx.AccountID] = append(s.transactions[tx.AccountID], tx)
+    return tx.ID
+}
+
+// AddProduct adds a product to the catalogue
+func (s *Server) AddProduct(p Product) string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if p.ID == "" {
+        p.ID = s.nextID("prod")
+    }
+    if p.Currency == "" {
+        p.Currency = "RUB"
+    }
+    s.products = append(s.products, p)
+    return p.ID
+}
+
+// Account returns the current state of an account
+func (s *Server) Account(id string) (Account, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    acc, ok := s.accounts[id]
+    if !ok {
+        return Account{}, false
+    }
+    return *acc, true
+}
+
+// Agreement returns the current state of an agreement
+func (s *Server) Agreement(id string) (Agreement, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    agr, ok := s.agreements[id]
+    if !ok {
+        return Agreement{}, false
+    }
+    return *agr, true
+}
+
+// Transactions returns the transactions of an account, newest first
+func (s *Server) Transactions(accountID string) []Transaction {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.sortedTransactions(accountID)
+}
+
+// Approve authorises a consent waiting for the client, as the client would
+// in the bank app
+func (s *Server) Approve(consentID string) bool {
+    return s.setConsentStatus(consentID, ConsentAuthorised)
+}
+
+// Revoke revokes a consent on the client's behalf
+func (s *Server) Revoke(consentID string) bool {
+    return s.setConsentStatus(consentID, ConsentRevoked)
+}
+
+func (s *Server) setConsentStatus(consentID, status string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    c, ok := s.consents[consentID]
+    if !ok {
+        return false
+    }
+    c.status = status
+    return true
+}
+
+// SetPaymentStatus moves a payment to status, for payments the bank settles
+// later
+func (s *Server) SetPaymentStatus(paymentID, status string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    p, ok := s.payments[paymentID]
+    if !ok {
+        return false
+    }
+    p.Status = status
+    p.UpdatedAt = s.opts.Now()
+    return true
+}
+
+// ExpireTokens invalidates every issued token
+func (s *Server) ExpireTokens() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.tokens = make(map[string]time.Time)
+}
+
+// SeedDemo fills the bank with a catalogue and two accounts of clientID with
+// a year of salary and spending
+func (s *Server) SeedDemo(clientID string) {
+    s.AddProduct(Product{
+        Type:         "deposit",
+        Name:         "Накопительный вклад",
+        Description:  "Вклад с ежемесячной капитализацией",
+        InterestRate: 8.5,
+        MinAmount:    money.FromMajor(1000),
+        MaxAmount:    money.FromMajor(10000000),
+        TermMonths:   []int{3, 6, 12},
+    })
+    s.AddProduct(Product{
+        Type:         "card",
+        Name:         "Дебетовая карта",
+        Description:  "Бесплатное обслуживание",
+    })
+
+    current := s.AddAccount(Account{ClientID: clientID, Nickname: "Текущий счёт", Balance: money.FromMajor(150000)})
+    s.AddAccount(Account{ClientID: clientID, Nickname: "Накопительный счёт", AccountType: "Savings", Balance: money.FromMajor(50000)})
+
+    now := s.opts.Now()
+    for month := 0; month < 12; month++ {
+        payday := time.Date(now.Year(), now.Month(), 5, 10, 0, 0, 0, time.UTC).AddDate(0, -month, 0)
+        if payday.After(now) {
+            continue
+        }
+        s.AddTransaction(Transaction{
+            AccountID:    current,
+            Amount:       money.FromMajor(120000),
+            Description:  "Заработная плата",
+            Counterparty: "ООО Работодатель",
+            Category:     "salary",
+            Booked:       payday,
+        })
+        for day := 1; day <= 4; day++ {
+            s.AddTransaction(Transaction{
+                AccountID:    current,
+                Amount:       -money.FromMajor(int64(2000 * day)),
+                Description:  "Покупка",
+                Counterparty: "Супермаркет",
+                Category:     "groceries",
+                Booked:       payday.AddDate(0, 0, day*5),
+            })
+        }
+    }
+}
+
+func (s *Server) nextID(prefix string) string {
+    s.seq++
+    return fmt.Sprintf("%s-%d", prefix, s.seq)
+}
+
+func (s *Server) sortedTransactions(accountID string) []Transaction {
+    txs := append([]Transaction(nil), s.transactions[accountID]...)
+    sort.SliceStable(txs, func(i, j int) bool {
+        return txs[i].Booked.After(txs[j].Booked)
+    })
+    return txs
+}
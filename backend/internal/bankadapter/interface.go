@@ -1,53 +1,57 @@
 package bankadapter
 
 import (
+    "context"
     "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
-// BankAdapter is the common interface for all bank integrations
+// BankAdapter is the common interface for all bank integrations. Every call
+// to the bank takes a context, cancelling it aborts the HTTP request.
 type BankAdapter interface {
     // Authentication
-    GetBankToken() (*TokenResponse, error)
-    RefreshToken(refreshToken string) (*TokenResponse, error)
+    GetBankToken(ctx context.Context) (*TokenResponse, error)
+    RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error)
     
     // Consents
-    CreateAccountConsent(token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error)
-    CreateProductConsent(token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error)
-    CreatePaymentConsent(token, clientID, requestingBank string, consent PaymentConsentRequest) (*ConsentResponse, error)
-    GetConsent(token, consentID string) (*ConsentResponse, error)
-    DeleteConsent(token, consentID string) error
+    CreateAccountConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error)
+    CreateProductConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error)
+    CreatePaymentConsent(ctx context.Context, token, clientID, requestingBank string, consent PaymentConsentRequest) (*ConsentResponse, error)
+    GetConsent(ctx context.Context, token, consentID string) (*ConsentResponse, error)
+    DeleteConsent(ctx context.Context, token, consentID string) error
     
     // Accounts
-    GetAccounts(token, clientID, consentID, requestingBank string) ([]Account, error)
-    GetAccountDetails(token, accountID, consentID, requestingBank string) (*Account, error)
-    GetAccountBalance(token, accountID, consentID, requestingBank string) (*Balance, error)
-    CreateAccount(token, clientID string, accountType string, initialBalance float64) (*Account, error)
-    CloseAccount(token, clientID, accountID string, closeRequest AccountCloseRequest) error
+    GetAccounts(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Account, error)
+    GetAccountDetails(ctx context.Context, token, accountID, consentID, requestingBank string) (*Account, error)
+    GetAccountBalance(ctx context.Context, token, accountID, consentID, requestingBank string) (*Balance, error)
+    CreateAccount(ctx context.Context, token, clientID string, accountType string, initialBalance money.Amount) (*Account, error)
+    CloseAccount(ctx context.Context, token, clientID, accountID string, closeRequest AccountCloseRequest) error
     
     // Transactions
-    GetTransactions(token, accountID, consentID, requestingBank string, from, to time.Time, limit int) ([]Transaction, error)
+    GetTransactions(ctx context.Context, token, accountID, consentID, requestingBank string, from, to time.Time, page, limit int) (*TransactionPage, error)
     
     // Products
-    GetProducts(token string, productType string) ([]Product, error)
-    GetProductDetails(token, productID string) (*Product, error)
+    GetProducts(ctx context.Context, token string, productType string) ([]Product, error)
+    GetProductDetails(ctx context.Context, token, productID string) (*Product, error)
     
     // Product Agreements (Deposits, Loans, Cards)
-    GetAgreements(token, clientID, consentID, requestingBank string) ([]Agreement, error)
-    OpenDeposit(token, clientID, consentID, requestingBank string, request DepositRequest) (*Agreement, error)
-    CloseDeposit(token, clientID, consentID, requestingBank, agreementID string) (*CloseDepositResponse, error)
-    GetAgreementDetails(token, clientID, consentID, requestingBank, agreementID string) (*Agreement, error)
+    GetAgreements(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Agreement, error)
+    OpenDeposit(ctx context.Context, token, clientID, consentID, requestingBank string, request DepositRequest) (*Agreement, error)
+    CloseDeposit(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*CloseDepositResponse, error)
+    GetAgreementDetails(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*Agreement, error)
     
     // Payments
-    CreatePayment(token, clientID, requestingBank string, payment PaymentRequest) (*PaymentResponse, error)
-    GetPaymentStatus(token, clientID, paymentID string) (*PaymentResponse, error)
+    CreatePayment(ctx context.Context, token, clientID, requestingBank string, payment PaymentRequest) (*PaymentResponse, error)
+    GetPaymentStatus(ctx context.Context, token, clientID, paymentID string) (*PaymentResponse, error)
     
     // Cards
-    GetCards(token, clientID, consentID, requestingBank string) ([]Card, error)
-    CreateCard(token, clientID, consentID, requestingBank string, request CreateCardRequest) (*Card, error)
+    GetCards(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Card, error)
+    CreateCard(ctx context.Context, token, clientID, consentID, requestingBank string, request CreateCardRequest) (*Card, error)
     
     // Utility
     GetBankInfo() BankInfo
-    IsHealthy() bool
+    IsHealthy(ctx context.Context) bool
 }
 
 // BankInfo contains static information about the bank
@@ -100,26 +104,38 @@ type Servicer struct {
 
 // Balance of an account
 type Balance struct {
-    Amount   float64   `json:"amount"`
-    Currency string    `json:"currency"`
-    Type     string    `json:"type"`
-    DateTime time.Time `json:"date_time"`
+    Amount   money.Amount `json:"amount"`
+    Currency string       `json:"currency"`
+    Type     string       `json:"type"`
+    DateTime time.Time    `json:"date_time"`
 }
 
 // Transaction from bank API
 type Transaction struct {
-    TransactionID        string    `json:"transaction_id"`
-    AccountID            string    `json:"account_id"`
-    Amount               float64   `json:"amount"`
-    Currency             string    `json:"currency"`
-    CreditDebitIndicator string    `json:"credit_debit_indicator"`
-    Status               string    `json:"status"`
-    BookingDateTime      time.Time `json:"booking_date_time"`
-    ValueDateTime        time.Time `json:"value_date_time"`
-    TransactionInfo      TransInfo `json:"transaction_information"`
-    CounterpartyName     string    `json:"counterparty_name,omitempty"`
-    CounterpartyAccount  string    `json:"counterparty_account,omitempty"`
-    Category             string    `json:"category,omitempty"`
+    TransactionID        string       `json:"transaction_id"`
+    AccountID            string       `json:"account_id"`
+    Amount               money.Amount `json:"amount"`
+    Currency             string       `json:"currency"`
+    CreditDebitIndicator string       `json:"credit_debit_indicator"`
+    Status               string       `json:"status"`
+    BookingDateTime      time.Time    `json:"booking_date_time"`
+    ValueDateTime        time.Time    `json:"value_date_time"`
+    TransactionInfo      TransInfo    `json:"transaction_information"`
+    CounterpartyName     string       `json:"counterparty_name,omitempty"`
+    CounterpartyAccount  string       `json:"counterparty_account,omitempty"`
+    Category             string       `json:"category,omitempty"`
+}
+
+// TransactionPage is one page of account transactions. Pages are numbered
+// from 1, HasMore tells there is a next one.
+type TransactionPage struct {
+    Transactions   []Transaction
+    Page           int
+    TotalPages     int
+    HasMore        bool
+    // FirstAvailable is the earliest booking date the consent gives access to,
+    // zero when the bank does not report it
+    FirstAvailable time.Time
 }
 
 // TransInfo contains transaction details
@@ -135,8 +151,8 @@ type Product struct {
     ProductName        string            `json:"product_name"`
     Description        string            `json:"description"`
     InterestRate       float64           `json:"interest_rate,omitempty"`
-    MinAmount          float64           `json:"min_amount,omitempty"`
-    MaxAmount          float64           `json:"max_amount,omitempty"`
+    MinAmount          money.Amount      `json:"min_amount,omitempty"`
+    MaxAmount          money.Amount      `json:"max_amount,omitempty"`
     TermMonths         []int             `json:"term_months,omitempty"`
     Currency           string            `json:"currency"`
     Features           []string          `json:"features,omitempty"`
@@ -146,51 +162,51 @@ type Product struct {
 
 // Agreement (deposit, loan, card contract)
 type Agreement struct {
-    AgreementID   string    `json:"agreement_id"`
-    ProductID     string    `json:"product_id"`
-    ProductType   string    `json:"product_type"`
-    ProductName   string    `json:"product_name"`
-    Amount        float64   `json:"amount"`
-    Currency      string    `json:"currency"`
-    InterestRate  float64   `json:"interest_rate,omitempty"`
-    TermMonths    int       `json:"term_months,omitempty"`
-    Status        string    `json:"status"`
-    OpenedDate    time.Time `json:"opened_date"`
-    MaturityDate  time.Time `json:"maturity_date,omitempty"`
-    ClosedDate    time.Time `json:"closed_date,omitempty"`
-    NextPayment   time.Time `json:"next_payment,omitempty"`
-    CurrentDebt   float64   `json:"current_debt,omitempty"`
-    AccruedInterest float64 `json:"accrued_interest,omitempty"`
+    AgreementID     string       `json:"agreement_id"`
+    ProductID       string       `json:"product_id"`
+    ProductType     string       `json:"product_type"`
+    ProductName     string       `json:"product_name"`
+    Amount          money.Amount `json:"amount"`
+    Currency        string       `json:"currency"`
+    InterestRate    float64      `json:"interest_rate,omitempty"`
+    TermMonths      int          `json:"term_months,omitempty"`
+    Status          string       `json:"status"`
+    OpenedDate      time.Time    `json:"opened_date"`
+    MaturityDate    time.Time    `json:"maturity_date,omitempty"`
+    ClosedDate      time.Time    `json:"closed_date,omitempty"`
+    NextPayment     time.Time    `json:"next_payment,omitempty"`
+    CurrentDebt     money.Amount `json:"current_debt,omitempty"`
+    AccruedInterest money.Amount `json:"accrued_interest,omitempty"`
 }
 
 // Card information
 type Card struct {
-    CardID         string    `json:"card_id"`
-    CardNumber     string    `json:"card_number"`
-    CardType       string    `json:"card_type"`
-    CardBrand      string    `json:"card_brand"`
-    CardStatus     string    `json:"card_status"`
-    AccountID      string    `json:"account_id"`
-    ExpiryDate     string    `json:"expiry_date"`
-    DailyLimit     float64   `json:"daily_limit"`
-    MonthlyLimit   float64   `json:"monthly_limit"`
-    IssuedDate     time.Time `json:"issued_date"`
+    CardID         string       `json:"card_id"`
+    CardNumber     string       `json:"card_number"`
+    CardType       string       `json:"card_type"`
+    CardBrand      string       `json:"card_brand"`
+    CardStatus     string       `json:"card_status"`
+    AccountID      string       `json:"account_id"`
+    ExpiryDate     string       `json:"expiry_date"`
+    DailyLimit     money.Amount `json:"daily_limit"`
+    MonthlyLimit   money.Amount `json:"monthly_limit"`
+    IssuedDate     time.Time    `json:"issued_date"`
 }
 
 // Request types
 
 // PaymentConsentRequest for creating payment consent
 type PaymentConsentRequest struct {
-    ConsentType        string   `json:"consent_type"`
-    Amount             float64  `json:"amount,omitempty"`
-    Currency           string   `json:"currency"`
-    DebtorAccount      string   `json:"debtor_account"`
-    CreditorAccount    string   `json:"creditor_account,omitempty"`
-    CreditorName       string   `json:"creditor_name,omitempty"`
-    Reference          string   `json:"reference,omitempty"`
-    MaxUses            int      `json:"max_uses,omitempty"`
-    MaxAmountPerPayment float64 `json:"max_amount_per_payment,omitempty"`
-    ValidUntil         time.Time `json:"valid_until,omitempty"`
+    ConsentType         string       `json:"consent_type"`
+    Amount              money.Amount `json:"amount,omitempty"`
+    Currency            string       `json:"currency"`
+    DebtorAccount       string       `json:"debtor_account"`
+    CreditorAccount     string       `json:"creditor_account,omitempty"`
+    CreditorName        string       `json:"creditor_name,omitempty"`
+    Reference           string       `json:"reference,omitempty"`
+    MaxUses             int          `json:"max_uses,omitempty"`
+    MaxAmountPerPayment money.Amount `json:"max_amount_per_payment,omitempty"`
+    ValidUntil          time.Time    `json:"valid_until,omitempty"`
 }
 
 // AccountCloseRequest for closing account
@@ -201,51 +217,55 @@ type AccountCloseRequest struct {
 
 // DepositRequest for opening deposit
 type DepositRequest struct {
-    ProductID        string  `json:"product_id"`
-    Amount           float64 `json:"amount"`
-    TermMonths       int     `json:"term_months"`
-    SourceAccountID  string  `json:"source_account_id"`
-    AutoRenewal      bool    `json:"auto_renewal"`
+    ProductID        string       `json:"product_id"`
+    Amount           money.Amount `json:"amount"`
+    TermMonths       int          `json:"term_months"`
+    SourceAccountID  string       `json:"source_account_id"`
+    AutoRenewal      bool         `json:"auto_renewal"`
 }
 
 // CloseDepositResponse when closing deposit
 type CloseDepositResponse struct {
-    AgreementID      string  `json:"agreement_id"`
-    ClosedAt         time.Time `json:"closed_at"`
-    ReturnedAmount   float64 `json:"returned_amount"`
-    AccruedInterest  float64 `json:"accrued_interest"`
-    PenaltyAmount    float64 `json:"penalty_amount,omitempty"`
+    AgreementID      string       `json:"agreement_id"`
+    ClosedAt         time.Time    `json:"closed_at"`
+    ReturnedAmount   money.Amount `json:"returned_amount"`
+    AccruedInterest  money.Amount `json:"accrued_interest"`
+    PenaltyAmount    money.Amount `json:"penalty_amount,omitempty"`
 }
 
 // PaymentRequest for making payment
 type PaymentRequest struct {
-    DebtorAccountID   string  `json:"debtor_account_id"`
-    CreditorAccountID string  `json:"creditor_account_id"`
-    CreditorBankCode  string  `json:"creditor_bank_code,omitempty"`
-    Amount            float64 `json:"amount"`
-    Currency          string  `json:"currency"`
-    Reference         string  `json:"reference"`
-    Description       string  `json:"description,omitempty"`
+    DebtorAccountID   string       `json:"debtor_account_id"`
+    CreditorAccountID string       `json:"creditor_account_id"`
+    CreditorBankCode  string       `json:"creditor_bank_code,omitempty"`
+    Amount            money.Amount `json:"amount"`
+    Currency          string       `json:"currency"`
+    Reference         string       `json:"reference"`
+    Description       string       `json:"description,omitempty"`
+    ConsentID         string       `json:"-"`
+    // IdempotencyKey makes the bank return the first payment when the same
+    // request is sent again
+    IdempotencyKey    string       `json:"-"`
 }
 
 // PaymentResponse for payment status
 type PaymentResponse struct {
-    PaymentID   string    `json:"payment_id"`
-    Status      string    `json:"status"`
-    Amount      float64   `json:"amount"`
-    Currency    string    `json:"currency"`
-    CreatedAt   time.Time `json:"created_at"`
-    CompletedAt time.Time `json:"completed_at,omitempty"`
-    Error       string    `json:"error,omitempty"`
+    PaymentID   string       `json:"payment_id"`
+    Status      string       `json:"status"`
+    Amount      money.Amount `json:"amount"`
+    Currency    string       `json:"currency"`
+    CreatedAt   time.Time    `json:"created_at"`
+    CompletedAt time.Time    `json:"completed_at,omitempty"`
+    Error       string       `json:"error,omitempty"`
 }
 
 // CreateCardRequest for creating new card
 type CreateCardRequest struct {
-    AccountNumber string `json:"account_number"`
-    CardName      string `json:"card_name,omitempty"`
-    CardType      string `json:"card_type"`
-    DailyLimit    float64 `json:"daily_limit,omitempty"`
-    MonthlyLimit  float64 `json:"monthly_limit,omitempty"`
+    AccountNumber string       `json:"account_number"`
+    CardName      string       `json:"card_name,omitempty"`
+    CardType      string       `json:"card_type"`
+    DailyLimit    money.Amount `json:"daily_limit,omitempty"`
+    MonthlyLimit  money.Amount `json:"monthly_limit,omitempty"`
 }
 
 // Error types
@@ -256,12 +276,29 @@ type BankError struct {
     Message string `json:"message"`
     Details string `json:"details,omitempty"`
     Status  int    `json:"status"`
+    // RetryAfter is how long the bank asked to wait before the next call
+    RetryAfter time.Duration `json:"-"`
+    // Err is the network error when the bank did not answer
+    Err error `json:"-"`
 }
 
 func (e *BankError) Error() string {
     return e.Message
 }
 
+func (e *BankError) Unwrap() error {
+    return e.Err
+}
+
+// Temporary reports whether the call may succeed when made again later
+func (e *BankError) Temporary() bool {
+    switch e.Code {
+    case ErrCodeBankUnavailable, ErrCodeRateLimited:
+        return true
+    }
+    return e.Status == 429 || e.Status >= 500
+}
+
 // Common error codes
 const (
     ErrCodeUnauthorized      = "UNAUTHORIZED"
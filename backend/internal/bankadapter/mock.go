@@ -1,9 +1,13 @@
 package bankadapter
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
+
+	"github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 // MockAdapter implements BankAdapter for testing
@@ -36,7 +40,7 @@ func NewMockAdapter(bankID string) *MockAdapter {
 	}
 }
 
-func (m *MockAdapter) GetBankToken() (*TokenResponse, error) {
+func (m *MockAdapter) GetBankToken(ctx context.Context) (*TokenResponse, error) {
 	if !m.Healthy {
 		return nil, &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable"}
 	}
@@ -46,16 +50,16 @@ func (m *MockAdapter) GetBankToken() (*TokenResponse, error) {
 		TokenType:    "Bearer",
 		ExpiresIn:    86400,
 		RefreshToken: fmt.Sprintf("mock_refresh_%s_%d", m.BankID, time.Now().Unix()),
-		ClientID:     clientID,
+		ClientID:     m.BankID,
 		IssuedAt:     time.Now(),
 	}, nil
 }
 
-func (m *MockAdapter) RefreshToken(refreshToken string) (*TokenResponse, error) {
-	return m.GetBankToken()
+func (m *MockAdapter) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	return m.GetBankToken(ctx)
 }
 
-func (m *MockAdapter) CreateAccountConsent(token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error) {
+func (m *MockAdapter) CreateAccountConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error) {
 	if !m.Healthy {
 		return nil, &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable"}
 	}
@@ -70,15 +74,15 @@ func (m *MockAdapter) CreateAccountConsent(token, clientID, requestingBank strin
 	}, nil
 }
 
-func (m *MockAdapter) CreateProductConsent(token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error) {
-	return m.CreateAccountConsent(token, clientID, requestingBank, permissions)
+func (m *MockAdapter) CreateProductConsent(ctx context.Context, token, clientID, requestingBank string, permissions []string) (*ConsentResponse, error) {
+	return m.CreateAccountConsent(ctx, token, clientID, requestingBank, permissions)
 }
 
-func (m *MockAdapter) CreatePaymentConsent(token, clientID, requestingBank string, consent PaymentConsentRequest) (*ConsentResponse, error) {
-	return m.CreateAccountConsent(token, clientID, requestingBank, []string{"payments"})
+func (m *MockAdapter) CreatePaymentConsent(ctx context.Context, token, clientID, requestingBank string, consent PaymentConsentRequest) (*ConsentResponse, error) {
+	return m.CreateAccountConsent(ctx, token, clientID, requestingBank, []string{"payments"})
 }
 
-func (m *MockAdapter) GetConsent(token, consentID string) (*ConsentResponse, error) {
+func (m *MockAdapter) GetConsent(ctx context.Context, token, consentID string) (*ConsentResponse, error) {
 	return &ConsentResponse{
 		ConsentID:   consentID,
 		Status:      "approved",
@@ -88,11 +92,11 @@ func (m *MockAdapter) GetConsent(token, consentID string) (*ConsentResponse, err
 	}, nil
 }
 
-func (m *MockAdapter) DeleteConsent(token, consentID string) error {
+func (m *MockAdapter) DeleteConsent(ctx context.Context, token, consentID string) error {
 	return nil
 }
 
-func (m *MockAdapter) GetAccounts(token, clientID, consentID, requestingBank string) ([]Account, error) {
+func (m *MockAdapter) GetAccounts(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Account, error) {
 	if !m.Healthy {
 		return nil, &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable"}
 	}
@@ -111,7 +115,7 @@ func (m *MockAdapter) GetAccounts(token, clientID, consentID, requestingBank str
 				Name:           m.BankName,
 			},
 			Balance: Balance{
-				Amount:   150000.00,
+				Amount:   money.FromMajor(150000),
 				Currency: "RUB",
 				Type:     "InterimAvailable",
 				DateTime: time.Now(),
@@ -130,7 +134,7 @@ func (m *MockAdapter) GetAccounts(token, clientID, consentID, requestingBank str
 				Name:           m.BankName,
 			},
 			Balance: Balance{
-				Amount:   50000.00,
+				Amount:   money.FromMajor(50000),
 				Currency: "RUB",
 				Type:     "InterimAvailable",
 				DateTime: time.Now(),
@@ -141,8 +145,8 @@ func (m *MockAdapter) GetAccounts(token, clientID, consentID, requestingBank str
 	return accounts, nil
 }
 
-func (m *MockAdapter) GetAccountDetails(token, accountID, consentID, requestingBank string) (*Account, error) {
-	accounts, err := m.GetAccounts(token, "", consentID, requestingBank)
+func (m *MockAdapter) GetAccountDetails(ctx context.Context, token, accountID, consentID, requestingBank string) (*Account, error) {
+	accounts, err := m.GetAccounts(ctx, token, "", consentID, requestingBank)
 	if err != nil {
 		return nil, err
 	}
@@ -156,15 +160,15 @@ func (m *MockAdapter) GetAccountDetails(token, accountID, consentID, requestingB
 	return nil, &BankError{Code: ErrCodeNotFound, Message: "Account not found"}
 }
 
-func (m *MockAdapter) GetAccountBalance(token, accountID, consentID, requestingBank string) (*Balance, error) {
-	account, err := m.GetAccountDetails(token, accountID, consentID, requestingBank)
+func (m *MockAdapter) GetAccountBalance(ctx context.Context, token, accountID, consentID, requestingBank string) (*Balance, error) {
+	account, err := m.GetAccountDetails(ctx, token, accountID, consentID, requestingBank)
 	if err != nil {
 		return nil, err
 	}
 	return &account.Balance, nil
 }
 
-func (m *MockAdapter) CreateAccount(token, clientID string, accountType string, initialBalance float64) (*Account, error) {
+func (m *MockAdapter) CreateAccount(ctx context.Context, token, clientID string, accountType string, initialBalance money.Amount) (*Account, error) {
 	return &Account{
 		ID:             fmt.Sprintf("acc_%s_%d", m.BankID, time.Now().UnixNano()),
 		Identification: fmt.Sprintf("408178100999100%05d", rand.Intn(99999)),
@@ -180,11 +184,11 @@ func (m *MockAdapter) CreateAccount(token, clientID string, accountType string,
 	}, nil
 }
 
-func (m *MockAdapter) CloseAccount(token, clientID, accountID string, closeRequest AccountCloseRequest) error {
+func (m *MockAdapter) CloseAccount(ctx context.Context, token, clientID, accountID string, closeRequest AccountCloseRequest) error {
 	return nil
 }
 
-func (m *MockAdapter) GetTransactions(token, accountID, consentID, requestingBank string, from, to time.Time, limit int) ([]Transaction, error) {
+func (m *MockAdapter) GetTransactions(ctx context.Context, token, accountID, consentID, requestingBank string, from, to time.Time, page, limit int) (*TransactionPage, error) {
 	if !m.Healthy {
 		return nil, &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable"}
 	}
@@ -198,7 +202,7 @@ func (m *MockAdapter) GetTransactions(token, accountID, consentID, requestingBan
 			transactions = append(transactions, Transaction{
 				TransactionID:        fmt.Sprintf("tx_%d", salaryDate.Unix()),
 				AccountID:            accountID,
-				Amount:               85000.00,
+				Amount:               money.FromMajor(85000),
 				Currency:             "RUB",
 				CreditDebitIndicator: "Credit",
 				Status:               "Booked",
@@ -226,10 +230,13 @@ func (m *MockAdapter) GetTransactions(token, accountID, consentID, requestingBan
 		{"utilities", "���", 3000, 8000},
 	}
 
+	// Seeded by the window so every page of one sync sees the same history
+	rnd := rand.New(rand.NewSource(from.Unix()))
+
 	current := from
 	for i := 0; i < 30 && current.Before(to); i++ {
-		cat := categories[rand.Intn(len(categories))]
-		amount := cat.min + rand.Float64()*(cat.max-cat.min)
+		cat := categories[rnd.Intn(len(categories))]
+		amount := money.FromFloat(cat.min + rnd.Float64()*(cat.max-cat.min))
 
 		transactions = append(transactions, Transaction{
 			TransactionID:        fmt.Sprintf("tx_%d_%d", current.Unix(), i),
@@ -246,17 +253,36 @@ func (m *MockAdapter) GetTransactions(token, accountID, consentID, requestingBan
 			Category: cat.name,
 		})
 
-		current = current.AddDate(0, 0, rand.Intn(3)+1)
+		current = current.AddDate(0, 0, rnd.Intn(3)+1)
 	}
 
-	if limit > 0 && len(transactions) > limit {
-		transactions = transactions[:limit]
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].BookingDateTime.Before(transactions[j].BookingDateTime)
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	result := &TransactionPage{Page: page, TotalPages: 1}
+	if limit > 0 {
+		result.TotalPages = (len(transactions) + limit - 1) / limit
+		start := (page - 1) * limit
+		if start > len(transactions) {
+			start = len(transactions)
+		}
+		end := start + limit
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+		transactions = transactions[start:end]
 	}
+	result.Transactions = transactions
+	result.HasMore = page < result.TotalPages
 
-	return transactions, nil
+	return result, nil
 }
 
-func (m *MockAdapter) GetProducts(token string, productType string) ([]Product, error) {
+func (m *MockAdapter) GetProducts(ctx context.Context, token string, productType string) ([]Product, error) {
 	products := []Product{
 		{
 			ProductID:    fmt.Sprintf("prod-%s-deposit-001", m.BankID),
@@ -264,8 +290,8 @@ func (m *MockAdapter) GetProducts(token string, productType string) ([]Product,
 			ProductName:  "����� ��������",
 			Description:  "������������ ������� �����",
 			InterestRate: m.DepositRate,
-			MinAmount:    10000,
-			MaxAmount:    10000000,
+			MinAmount:    money.FromMajor(10000),
+			MaxAmount:    money.FromMajor(10000000),
 			TermMonths:   []int{3, 6, 12, 24},
 			Currency:     "RUB",
 		},
@@ -275,8 +301,8 @@ func (m *MockAdapter) GetProducts(token string, productType string) ([]Product,
 			ProductName:  "����� �������������",
 			Description:  "����� � ������������ ����������",
 			InterestRate: m.DepositRate - 0.5,
-			MinAmount:    1000,
-			MaxAmount:    5000000,
+			MinAmount:    money.FromMajor(1000),
+			MaxAmount:    money.FromMajor(5000000),
 			TermMonths:   []int{6, 12},
 			Currency:     "RUB",
 		},
@@ -295,8 +321,8 @@ func (m *MockAdapter) GetProducts(token string, productType string) ([]Product,
 	return products, nil
 }
 
-func (m *MockAdapter) GetProductDetails(token, productID string) (*Product, error) {
-	products, err := m.GetProducts(token, "")
+func (m *MockAdapter) GetProductDetails(ctx context.Context, token, productID string) (*Product, error) {
+	products, err := m.GetProducts(ctx, token, "")
 	if err != nil {
 		return nil, err
 	}
@@ -310,11 +336,11 @@ func (m *MockAdapter) GetProductDetails(token, productID string) (*Product, erro
 	return nil, &BankError{Code: ErrCodeNotFound, Message: "Product not found"}
 }
 
-func (m *MockAdapter) GetAgreements(token, clientID, consentID, requestingBank string) ([]Agreement, error) {
+func (m *MockAdapter) GetAgreements(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Agreement, error) {
 	return []Agreement{}, nil
 }
 
-func (m *MockAdapter) OpenDeposit(token, clientID, consentID, requestingBank string, request DepositRequest) (*Agreement, error) {
+func (m *MockAdapter) OpenDeposit(ctx context.Context, token, clientID, consentID, requestingBank string, request DepositRequest) (*Agreement, error) {
 	if !m.Healthy {
 		return nil, &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable"}
 	}
@@ -334,34 +360,34 @@ func (m *MockAdapter) OpenDeposit(token, clientID, consentID, requestingBank str
 	}, nil
 }
 
-func (m *MockAdapter) CloseDeposit(token, clientID, consentID, requestingBank, agreementID string) (*CloseDepositResponse, error) {
-	accruedInterest := 1500.0 // Mock interest
+func (m *MockAdapter) CloseDeposit(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*CloseDepositResponse, error) {
+	accruedInterest := money.FromMajor(1500) // Mock interest
 
 	return &CloseDepositResponse{
 		AgreementID:     agreementID,
 		ClosedAt:        time.Now(),
-		ReturnedAmount:  50000 + accruedInterest,
+		ReturnedAmount:  money.FromMajor(50000) + accruedInterest,
 		AccruedInterest: accruedInterest,
-		PenaltyAmount:   100,
+		PenaltyAmount:   money.FromMajor(100),
 	}, nil
 }
 
-func (m *MockAdapter) GetAgreementDetails(token, clientID, consentID, requestingBank, agreementID string) (*Agreement, error) {
+func (m *MockAdapter) GetAgreementDetails(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*Agreement, error) {
 	return &Agreement{
 		AgreementID:     agreementID,
 		ProductID:       fmt.Sprintf("prod-%s-deposit-001", m.BankID),
 		ProductType:     "deposit",
-		Amount:          50000,
+		Amount:          money.FromMajor(50000),
 		Currency:        "RUB",
 		InterestRate:    m.DepositRate,
 		Status:          "active",
 		OpenedDate:      time.Now().AddDate(0, -3, 0),
 		MaturityDate:    time.Now().AddDate(0, 9, 0),
-		AccruedInterest: 1000,
+		AccruedInterest: money.FromMajor(1000),
 	}, nil
 }
 
-func (m *MockAdapter) CreatePayment(token, clientID, requestingBank string, payment PaymentRequest) (*PaymentResponse, error) {
+func (m *MockAdapter) CreatePayment(ctx context.Context, token, clientID, requestingBank string, payment PaymentRequest) (*PaymentResponse, error) {
 	return &PaymentResponse{
 		PaymentID:   fmt.Sprintf("pay_%s_%d", m.BankID, time.Now().UnixNano()),
 		Status:      "completed",
@@ -372,22 +398,22 @@ func (m *MockAdapter) CreatePayment(token, clientID, requestingBank string, paym
 	}, nil
 }
 
-func (m *MockAdapter) GetPaymentStatus(token, clientID, paymentID string) (*PaymentResponse, error) {
+func (m *MockAdapter) GetPaymentStatus(ctx context.Context, token, clientID, paymentID string) (*PaymentResponse, error) {
 	return &PaymentResponse{
 		PaymentID:   paymentID,
 		Status:      "completed",
-		Amount:      10000,
+		Amount:      money.FromMajor(10000),
 		Currency:    "RUB",
 		CreatedAt:   time.Now().Add(-1 * time.Hour),
 		CompletedAt: time.Now().Add(-30 * time.Minute),
 	}, nil
 }
 
-func (m *MockAdapter) GetCards(token, clientID, consentID, requestingBank string) ([]Card, error) {
+func (m *MockAdapter) GetCards(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Card, error) {
 	return []Card{}, nil
 }
 
-func (m *MockAdapter) CreateCard(token, clientID, consentID, requestingBank string, request CreateCardRequest) (*Card, error) {
+func (m *MockAdapter) CreateCard(ctx context.Context, token, clientID, consentID, requestingBank string, request CreateCardRequest) (*Card, error) {
 	return &Card{
 		CardID:       fmt.Sprintf("card_%s_%d", m.BankID, time.Now().UnixNano()),
 		CardNumber:   "****1234",
@@ -396,8 +422,8 @@ func (m *MockAdapter) CreateCard(token, clientID, consentID, requestingBank stri
 		CardStatus:   "active",
 		AccountID:    request.AccountNumber,
 		ExpiryDate:   time.Now().AddDate(3, 0, 0).Format("01/06"),
-		DailyLimit:   100000,
-		MonthlyLimit: 1000000,
+		DailyLimit:   money.FromMajor(100000),
+		MonthlyLimit: money.FromMajor(1000000),
 		IssuedDate:   time.Now(),
 	}, nil
 }
@@ -411,7 +437,7 @@ func (m *MockAdapter) GetBankInfo() BankInfo {
 	}
 }
 
-func (m *MockAdapter) IsHealthy() bool {
+func (m *MockAdapter) IsHealthy(ctx context.Context) bool {
 	return m.Healthy
 }
 
@@ -0,0 +1,747 @@
+package bankadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// MockStore keeps the state of mock banks: accounts, balances, agreements
+// and payments of every client. With a path the state is saved after each
+// change and survives restarts.
+type MockStore struct {
+	path string
+
+	mu    sync.Mutex
+	banks map[string]*mockBank
+}
+
+// mockBank is the state of one mock bank, exported fields are saved
+type mockBank struct {
+	Seq          int                         `json:"seq"`
+	Accounts     map[string]*mockAccount     `json:"accounts"`
+	Transactions map[string][]Transaction    `json:"transactions"`
+	Agreements   map[string]*mockAgreement   `json:"agreements"`
+	Payments     map[string]*PaymentResponse `json:"payments"`
+	Cards        map[string]*Card            `json:"cards"`
+}
+
+type mockAccount struct {
+	Account
+	ClientID string `json:"client_id"`
+	Closed   bool   `json:"closed"`
+}
+
+type mockAgreement struct {
+	Agreement
+	ClientID        string `json:"client_id"`
+	SourceAccountID string `json:"source_account_id"`
+}
+
+// NewMockStore creates a store, loading the state saved at path when the
+// file exists. An empty path keeps the state in memory only.
+func NewMockStore(path string) (*MockStore, error) {
+	store := &MockStore{
+		path:  path,
+		banks: make(map[string]*mockBank),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock bank state: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.banks); err != nil {
+		return nil, fmt.Errorf("failed to parse mock bank state %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// bank returns the state of bankID, mu must be held
+func (s *MockStore) bank(bankID string) *mockBank {
+	b, ok := s.banks[bankID]
+	if !ok {
+		b = &mockBank{}
+		s.banks[bankID] = b
+	}
+	if b.Accounts == nil {
+		b.Accounts = make(map[string]*mockAccount)
+	}
+	if b.Transactions == nil {
+		b.Transactions = make(map[string][]Transaction)
+	}
+	if b.Agreements == nil {
+		b.Agreements = make(map[string]*mockAgreement)
+	}
+	if b.Payments == nil {
+		b.Payments = make(map[string]*PaymentResponse)
+	}
+	if b.Cards == nil {
+		b.Cards = make(map[string]*Card)
+	}
+	return b
+}
+
+// save writes the state to the file, mu must be held
+func (s *MockStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.banks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode mock bank state: %w", err)
+	}
+
+	// Write next to the file and rename, a crash never leaves half a state
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save mock bank state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save mock bank state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save mock bank state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save mock bank state: %w", err)
+	}
+	return nil
+}
+
+// StatefulMockAdapter is a mock bank that remembers what clients do: opened
+// deposits show up in agreements and payments move money between accounts.
+// Every client starts with two accounts and a year of salary and spending.
+type StatefulMockAdapter struct {
+	*MockAdapter
+	store *MockStore
+}
+
+// NewStatefulMockAdapter creates a mock of bankID keeping its state in store
+func NewStatefulMockAdapter(bankID string, store *MockStore) *StatefulMockAdapter {
+	return &StatefulMockAdapter{
+		MockAdapter: NewMockAdapter(bankID),
+		store:       store,
+	}
+}
+
+func (m *StatefulMockAdapter) unavailable() error {
+	if m.Healthy {
+		return nil
+	}
+	return &BankError{Code: ErrCodeBankUnavailable, Message: "Bank is unavailable", Status: 503}
+}
+
+func (m *StatefulMockAdapter) nextID(b *mockBank, prefix string) string {
+	b.Seq++
+	return fmt.Sprintf("%s_%s_%d", prefix, m.BankID, b.Seq)
+}
+
+// clientAccounts returns the open accounts of a client, opening the starting
+// ones for a new client. mu must be held.
+func (m *StatefulMockAdapter) clientAccounts(b *mockBank, clientID string) ([]*mockAccount, error) {
+	var accounts []*mockAccount
+	known := false
+	for _, acc := range b.Accounts {
+		if acc.ClientID != clientID {
+			continue
+		}
+		known = true
+		if !acc.Closed {
+			accounts = append(accounts, acc)
+		}
+	}
+
+	if !known {
+		current := m.openAccount(b, clientID, "Основной счёт", money.FromMajor(150000))
+		savings := m.openAccount(b, clientID, "Накопительный", money.FromMajor(50000))
+		m.seedHistory(b, current.ID, clientID)
+		accounts = append(accounts, current, savings)
+		if err := m.store.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Balance.Amount > accounts[j].Balance.Amount
+	})
+	return accounts, nil
+}
+
+func (m *StatefulMockAdapter) openAccount(b *mockBank, clientID, nickname string, balance money.Amount) *mockAccount {
+	id := m.nextID(b, "acc")
+	acc := &mockAccount{
+		Account: Account{
+			ID:             id,
+			Identification: fmt.Sprintf("40817810%012d", b.Seq),
+			Currency:       "RUB",
+			AccountType:    "Personal",
+			Nickname:       nickname,
+			SchemeName:     "RU.CBR.PAN",
+			Servicer: Servicer{
+				SchemeName:     "RU.CBR.BIK",
+				Identification: "044525225",
+				Name:           m.BankName,
+			},
+			Balance: Balance{
+				Amount:   balance,
+				Currency: "RUB",
+				Type:     "InterimAvailable",
+				DateTime: time.Now(),
+			},
+		},
+		ClientID: clientID,
+	}
+	b.Accounts[id] = acc
+	return acc
+}
+
+// seedHistory books a year of monthly salary and spending on an account.
+// The history depends only on the bank and the client.
+func (m *StatefulMockAdapter) seedHistory(b *mockBank, accountID, clientID string) {
+	h := fnv.New64a()
+	h.Write([]byte(m.BankID + "/" + clientID))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	categories := []struct {
+		name string
+		desc string
+		min  float64
+		max  float64
+	}{
+		{"groceries", "Супермаркет", 1000, 5000},
+		{"transport", "Транспорт", 200, 1000},
+		{"restaurants", "Ресторан", 500, 3000},
+		{"utilities", "Коммунальные услуги", 3000, 8000},
+	}
+
+	now := time.Now()
+	salary := money.FromMajor(70000 + int64(rnd.Intn(6))*5000)
+	payDay := 5 + rnd.Intn(20)
+
+	for month := 12; month >= 0; month-- {
+		first := time.Date(now.Year(), now.Month(), 1, 10, 0, 0, 0, time.UTC).AddDate(0, -month, 0)
+		paidAt := first.AddDate(0, 0, payDay-1)
+		if paidAt.Before(now) {
+			m.book(b, Transaction{
+				AccountID:        accountID,
+				Amount:           salary,
+				Category:         "salary",
+				CounterpartyName: "ООО Работодатель",
+				BookingDateTime:  paidAt,
+				TransactionInfo:  TransInfo{Description: "Заработная плата"},
+			})
+		}
+
+		for day := rnd.Intn(3); day < 28; day += 1 + rnd.Intn(3) {
+			spentAt := first.AddDate(0, 0, day).Add(time.Duration(rnd.Intn(10)) * time.Hour)
+			if spentAt.After(now) {
+				break
+			}
+			cat := categories[rnd.Intn(len(categories))]
+			m.book(b, Transaction{
+				AccountID:       accountID,
+				Amount:          -money.FromFloat(cat.min + rnd.Float64()*(cat.max-cat.min)),
+				Category:        cat.name,
+				BookingDateTime: spentAt,
+				TransactionInfo: TransInfo{Description: cat.desc},
+			})
+		}
+	}
+}
+
+// book records a transaction on its account without touching the balance
+func (m *StatefulMockAdapter) book(b *mockBank, tx Transaction) {
+	tx.TransactionID = m.nextID(b, "tx")
+	tx.Currency = "RUB"
+	tx.Status = "Booked"
+	tx.ValueDateTime = tx.BookingDateTime
+	tx.CreditDebitIndicator = "Credit"
+	if tx.Amount < 0 {
+		tx.CreditDebitIndicator = "Debit"
+	}
+	b.Transactions[tx.AccountID] = append(b.Transactions[tx.AccountID], tx)
+}
+
+// move changes the balance of an account and books the change
+func (m *StatefulMockAdapter) move(b *mockBank, acc *mockAccount, amount money.Amount, description, counterparty string) {
+	acc.Balance.Amount += amount
+	acc.Balance.DateTime = time.Now()
+	m.book(b, Transaction{
+		AccountID:        acc.ID,
+		Amount:           amount,
+		CounterpartyName: counterparty,
+		BookingDateTime:  time.Now(),
+		TransactionInfo:  TransInfo{Description: description},
+	})
+}
+
+func (m *StatefulMockAdapter) account(b *mockBank, accountID string) (*mockAccount, error) {
+	acc, ok := b.Accounts[accountID]
+	if !ok || acc.Closed {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Account not found", Status: 404}
+	}
+	return acc, nil
+}
+
+// accountByIdentification finds an account by id or by account number
+func (m *StatefulMockAdapter) accountByIdentification(b *mockBank, identification string) *mockAccount {
+	if acc, ok := b.Accounts[identification]; ok && !acc.Closed {
+		return acc
+	}
+	for _, acc := range b.Accounts {
+		if acc.Identification == identification && !acc.Closed {
+			return acc
+		}
+	}
+	return nil
+}
+
+func (m *StatefulMockAdapter) GetAccounts(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Account, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	accounts, err := m.clientAccounts(m.store.bank(m.BankID), clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Account, 0, len(accounts))
+	for _, acc := range accounts {
+		result = append(result, acc.Account)
+	}
+	return result, nil
+}
+
+func (m *StatefulMockAdapter) GetAccountDetails(ctx context.Context, token, accountID, consentID, requestingBank string) (*Account, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	acc, err := m.account(m.store.bank(m.BankID), accountID)
+	if err != nil {
+		return nil, err
+	}
+	account := acc.Account
+	return &account, nil
+}
+
+func (m *StatefulMockAdapter) GetAccountBalance(ctx context.Context, token, accountID, consentID, requestingBank string) (*Balance, error) {
+	account, err := m.GetAccountDetails(ctx, token, accountID, consentID, requestingBank)
+	if err != nil {
+		return nil, err
+	}
+	return &account.Balance, nil
+}
+
+func (m *StatefulMockAdapter) CreateAccount(ctx context.Context, token, clientID string, accountType string, initialBalance money.Amount) (*Account, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	if _, err := m.clientAccounts(b, clientID); err != nil {
+		return nil, err
+	}
+
+	acc := m.openAccount(b, clientID, "Новый счёт", initialBalance)
+	if accountType != "" {
+		acc.AccountType = accountType
+	}
+	if err := m.store.save(); err != nil {
+		return nil, err
+	}
+
+	account := acc.Account
+	return &account, nil
+}
+
+func (m *StatefulMockAdapter) CloseAccount(ctx context.Context, token, clientID, accountID string, closeRequest AccountCloseRequest) error {
+	if err := m.unavailable(); err != nil {
+		return err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	acc, err := m.account(b, accountID)
+	if err != nil || acc.ClientID != clientID {
+		return &BankError{Code: ErrCodeNotFound, Message: "Account not found", Status: 404}
+	}
+
+	if acc.Balance.Amount > 0 {
+		dest, err := m.account(b, closeRequest.DestinationAccountID)
+		if err != nil || dest.ID == acc.ID {
+			return &BankError{Code: ErrCodeInvalidRequest, Message: "Account has a balance, a destination account is required", Status: 422}
+		}
+		amount := acc.Balance.Amount
+		m.move(b, acc, -amount, "Перевод при закрытии счёта", dest.Identification)
+		m.move(b, dest, amount, "Перевод при закрытии счёта", acc.Identification)
+	}
+	acc.Closed = true
+
+	return m.store.save()
+}
+
+func (m *StatefulMockAdapter) GetTransactions(ctx context.Context, token, accountID, consentID, requestingBank string, from, to time.Time, page, limit int) (*TransactionPage, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	if _, ok := b.Accounts[accountID]; !ok {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Account not found", Status: 404}
+	}
+
+	all := b.Transactions[accountID]
+	transactions := make([]Transaction, 0, len(all))
+	var first time.Time
+	for _, tx := range all {
+		if first.IsZero() || tx.BookingDateTime.Before(first) {
+			first = tx.BookingDateTime
+		}
+		if tx.BookingDateTime.Before(from) || tx.BookingDateTime.After(to) {
+			continue
+		}
+		transactions = append(transactions, tx)
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].BookingDateTime.Before(transactions[j].BookingDateTime)
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	result := &TransactionPage{Page: page, TotalPages: 1, FirstAvailable: first}
+	if limit > 0 {
+		result.TotalPages = (len(transactions) + limit - 1) / limit
+		start := (page - 1) * limit
+		if start > len(transactions) {
+			start = len(transactions)
+		}
+		end := start + limit
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+		transactions = transactions[start:end]
+	}
+	result.Transactions = transactions
+	result.HasMore = page < result.TotalPages
+
+	return result, nil
+}
+
+func (m *StatefulMockAdapter) GetAgreements(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Agreement, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	agreements := []Agreement{}
+	for _, agr := range m.store.bank(m.BankID).Agreements {
+		if agr.ClientID == clientID {
+			agreements = append(agreements, m.withInterest(agr))
+		}
+	}
+	sort.Slice(agreements, func(i, j int) bool {
+		return agreements[i].OpenedDate.Before(agreements[j].OpenedDate)
+	})
+	return agreements, nil
+}
+
+func (m *StatefulMockAdapter) GetAgreementDetails(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*Agreement, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	agr, ok := m.store.bank(m.BankID).Agreements[agreementID]
+	if !ok || agr.ClientID != clientID {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Agreement not found", Status: 404}
+	}
+	agreement := m.withInterest(agr)
+	return &agreement, nil
+}
+
+// withInterest is the agreement with the interest accrued by now
+func (m *StatefulMockAdapter) withInterest(agr *mockAgreement) Agreement {
+	agreement := agr.Agreement
+	if agreement.Status == "active" {
+		days := int(time.Since(agreement.OpenedDate).Hours() / 24)
+		agreement.AccruedInterest = CalculateInterest(agreement.Amount, agreement.InterestRate, days)
+	}
+	return agreement
+}
+
+func (m *StatefulMockAdapter) OpenDeposit(ctx context.Context, token, clientID, consentID, requestingBank string, request DepositRequest) (*Agreement, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	product, err := m.GetProductDetails(ctx, token, request.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Amount < product.MinAmount || request.Amount > product.MaxAmount {
+		return nil, &BankError{Code: ErrCodeInvalidRequest, Message: "Amount is outside the product limits", Status: 400}
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	source, err := m.account(b, request.SourceAccountID)
+	if err != nil || source.ClientID != clientID {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Source account not found", Status: 404}
+	}
+	if source.Balance.Amount < request.Amount {
+		return nil, &BankError{Code: ErrCodeInsufficientFunds, Message: "Insufficient funds", Status: 422}
+	}
+
+	now := time.Now()
+	agr := &mockAgreement{
+		Agreement: Agreement{
+			AgreementID:  m.nextID(b, "agr"),
+			ProductID:    product.ProductID,
+			ProductType:  product.ProductType,
+			ProductName:  product.ProductName,
+			Amount:       request.Amount,
+			Currency:     product.Currency,
+			InterestRate: product.InterestRate,
+			TermMonths:   request.TermMonths,
+			Status:       "active",
+			OpenedDate:   now,
+			MaturityDate: CalculateMaturityDate(now, request.TermMonths),
+		},
+		ClientID:        clientID,
+		SourceAccountID: source.ID,
+	}
+	b.Agreements[agr.AgreementID] = agr
+	m.move(b, source, -request.Amount, "Открытие вклада "+product.ProductName, "")
+
+	if err := m.store.save(); err != nil {
+		return nil, err
+	}
+
+	agreement := agr.Agreement
+	return &agreement, nil
+}
+
+func (m *StatefulMockAdapter) CloseDeposit(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*CloseDepositResponse, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	agr, ok := b.Agreements[agreementID]
+	if !ok || agr.ClientID != clientID {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Agreement not found", Status: 404}
+	}
+	if agr.Status != "active" {
+		return nil, &BankError{Code: ErrCodeInvalidRequest, Message: "Agreement is already closed", Status: 409}
+	}
+
+	now := time.Now()
+	daysHeld := int(now.Sub(agr.OpenedDate).Hours() / 24)
+	totalDays := int(agr.MaturityDate.Sub(agr.OpenedDate).Hours() / 24)
+	accrued := CalculateInterest(agr.Amount, agr.InterestRate, daysHeld)
+	penalty := money.Zero
+	if now.Before(agr.MaturityDate) && totalDays > 0 {
+		penalty = CalculatePenalty(accrued, daysHeld, totalDays)
+	}
+	returned := agr.Amount + accrued - penalty
+
+	agr.Status = "closed"
+	agr.ClosedDate = now
+
+	// The money goes back where it came from, or to the richest open account
+	dest, err := m.account(b, agr.SourceAccountID)
+	if err != nil {
+		accounts, err := m.clientAccounts(b, clientID)
+		if err != nil {
+			return nil, err
+		}
+		if len(accounts) > 0 {
+			dest = accounts[0]
+		}
+	}
+	if dest != nil {
+		m.move(b, dest, returned, "Закрытие вклада "+agr.ProductName, "")
+	}
+
+	if err := m.store.save(); err != nil {
+		return nil, err
+	}
+
+	return &CloseDepositResponse{
+		AgreementID:     agr.AgreementID,
+		ClosedAt:        now,
+		ReturnedAmount:  returned,
+		AccruedInterest: accrued,
+		PenaltyAmount:   penalty,
+	}, nil
+}
+
+func (m *StatefulMockAdapter) CreatePayment(ctx context.Context, token, clientID, requestingBank string, payment PaymentRequest) (*PaymentResponse, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+	if payment.Amount <= 0 {
+		return nil, &BankError{Code: ErrCodeInvalidRequest, Message: "Amount must be positive", Status: 400}
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	debtor := m.accountByIdentification(b, payment.DebtorAccountID)
+	if debtor == nil || debtor.ClientID != clientID {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Debtor account not found", Status: 404}
+	}
+	if debtor.Balance.Amount < payment.Amount {
+		return nil, &BankError{Code: ErrCodeInsufficientFunds, Message: "Insufficient funds", Status: 422}
+	}
+
+	description := payment.Description
+	if description == "" {
+		description = "Платёж"
+	}
+	m.move(b, debtor, -payment.Amount, description, payment.CreditorAccountID)
+	// A creditor in another bank is paid outside the mock
+	if creditor := m.accountByIdentification(b, payment.CreditorAccountID); creditor != nil {
+		m.move(b, creditor, payment.Amount, description, debtor.Identification)
+	}
+
+	now := time.Now()
+	resp := &PaymentResponse{
+		PaymentID:   m.nextID(b, "pay"),
+		Status:      "completed",
+		Amount:      payment.Amount,
+		Currency:    debtor.Currency,
+		CreatedAt:   now,
+		CompletedAt: now,
+	}
+	b.Payments[resp.PaymentID] = resp
+
+	if err := m.store.save(); err != nil {
+		return nil, err
+	}
+
+	paid := *resp
+	return &paid, nil
+}
+
+func (m *StatefulMockAdapter) GetPaymentStatus(ctx context.Context, token, clientID, paymentID string) (*PaymentResponse, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	payment, ok := m.store.bank(m.BankID).Payments[paymentID]
+	if !ok {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Payment not found", Status: 404}
+	}
+	paid := *payment
+	return &paid, nil
+}
+
+func (m *StatefulMockAdapter) GetCards(ctx context.Context, token, clientID, consentID, requestingBank string) ([]Card, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	cards := []Card{}
+	for _, card := range b.Cards {
+		if acc, ok := b.Accounts[card.AccountID]; ok && acc.ClientID == clientID {
+			cards = append(cards, *card)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].IssuedDate.Before(cards[j].IssuedDate)
+	})
+	return cards, nil
+}
+
+func (m *StatefulMockAdapter) CreateCard(ctx context.Context, token, clientID, consentID, requestingBank string, request CreateCardRequest) (*Card, error) {
+	if err := m.unavailable(); err != nil {
+		return nil, err
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	b := m.store.bank(m.BankID)
+	acc := m.accountByIdentification(b, request.AccountNumber)
+	if acc == nil || acc.ClientID != clientID {
+		return nil, &BankError{Code: ErrCodeNotFound, Message: "Account not found", Status: 404}
+	}
+
+	now := time.Now()
+	card := &Card{
+		CardID:       m.nextID(b, "card"),
+		CardType:     request.CardType,
+		CardBrand:    "MIR",
+		CardStatus:   "active",
+		AccountID:    acc.ID,
+		ExpiryDate:   now.AddDate(4, 0, 0).Format("01/06"),
+		DailyLimit:   request.DailyLimit,
+		MonthlyLimit: request.MonthlyLimit,
+		IssuedDate:   now,
+	}
+	card.CardNumber = fmt.Sprintf("2200 **** **** %04d", b.Seq%10000)
+	b.Cards[card.CardID] = card
+
+	if err := m.store.save(); err != nil {
+		return nil, err
+	}
+
+	issued := *card
+	return &issued, nil
+}
@@ -0,0 +1,82 @@
+package bankadapter
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+
+    "github.com/rs/zerolog"
+)
+
+// DriverConfig describes one bank instance: which bank it is and how to
+// reach it. Several instances may use the same driver.
+type DriverConfig struct {
+    BankID       string
+    Name         string
+    BaseURL      string
+    ClientID     string
+    ClientSecret string
+    TeamID       string
+    DepositRate  float64
+    Logger       *zerolog.Logger
+    Retry        RetryPolicy
+    Breaker      *CircuitBreaker
+}
+
+// Driver creates an adapter for a bank instance
+type Driver func(cfg DriverConfig) BankAdapter
+
+var (
+    driversMu sync.RWMutex
+    drivers   = make(map[string]Driver)
+)
+
+// RegisterDriver makes a driver available under name. Adapter packages call
+// it from init, registering a name twice is a programming error.
+func RegisterDriver(name string, driver Driver) {
+    driversMu.Lock()
+    defer driversMu.Unlock()
+
+    if driver == nil {
+        panic("bankadapter: RegisterDriver driver is nil")
+    }
+    if _, dup := drivers[name]; dup {
+        panic(fmt.Sprintf("bankadapter: RegisterDriver called twice for driver %s", name))
+    }
+    drivers[name] = driver
+}
+
+// LookupDriver returns the driver registered under name
+func LookupDriver(name string) (Driver, bool) {
+    driversMu.RLock()
+    defer driversMu.RUnlock()
+
+    driver, ok := drivers[name]
+    return driver, ok
+}
+
+// Drivers returns the names of registered drivers, sorted
+func Drivers() []string {
+    driversMu.RLock()
+    defer driversMu.RUnlock()
+
+    names := make([]string, 0, len(drivers))
+    for name := range drivers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func init() {
+    RegisterDriver("mock", func(cfg DriverConfig) BankAdapter {
+        mock := NewMockAdapter(cfg.BankID)
+        if cfg.Name != "" {
+            mock.BankName = cfg.Name
+        }
+        if cfg.DepositRate > 0 {
+            mock.DepositRate = cfg.DepositRate
+        }
+        return mock
+    })
+}
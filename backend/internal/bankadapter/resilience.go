@@ -0,0 +1,225 @@
+package bankadapter
+
+import (
+    "context"
+    "errors"
+    "math/rand"
+    "net"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// RetryPolicy controls how idempotent bank calls are retried
+type RetryPolicy struct {
+    MaxAttempts int
+    BaseDelay   time.Duration
+    MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes three attempts over about a second
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 3,
+    BaseDelay:   200 * time.Millisecond,
+    MaxDelay:    5 * time.Second,
+}
+
+// backoff is the delay before the next attempt: exponential with full jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+    delay := p.BaseDelay << uint(attempt-1)
+    if delay <= 0 || delay > p.MaxDelay {
+        delay = p.MaxDelay
+    }
+    return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// IdempotencyKeyHeader carries a key the bank uses to recognise a request
+// sent again
+const IdempotencyKeyHeader = "X-Idempotency-Key"
+
+// isIdempotent reports whether a request can be sent again without side
+// effects: reads, and writes carrying an idempotency key. Other writes move
+// money (a PUT closes an account, a DELETE closes a deposit) and the bank may
+// have acted on a request that timed out, so they are never retried.
+func isIdempotent(method string, headers map[string]string) bool {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return true
+    default:
+        return headers[IdempotencyKeyHeader] != ""
+    }
+}
+
+type breakerState int
+
+const (
+    breakerClosed breakerState = iota
+    breakerOpen
+    breakerHalfOpen
+)
+
+// CircuitBreaker stops calls to a bank after threshold failures in a row.
+// After cooldown one probe call is let through, its result closes the
+// breaker or opens it again. A nil breaker lets every call through.
+type CircuitBreaker struct {
+    threshold int
+    cooldown  time.Duration
+
+    mu       sync.Mutex
+    state    breakerState
+    failures int
+    openedAt time.Time
+    probing  bool
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+    return &CircuitBreaker{
+        threshold: threshold,
+        cooldown:  cooldown,
+    }
+}
+
+// Allow reports whether a call may be made now
+func (cb *CircuitBreaker) Allow() bool {
+    if cb == nil {
+        return true
+    }
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    switch cb.state {
+    case breakerOpen:
+        if time.Since(cb.openedAt) < cb.cooldown {
+            return false
+        }
+        cb.state = breakerHalfOpen
+        cb.probing = true
+        return true
+    case breakerHalfOpen:
+        // Only one probe at a time
+        if cb.probing {
+            return false
+        }
+        cb.probing = true
+        return true
+    default:
+        return true
+    }
+}
+
+// Success records a call the bank answered
+func (cb *CircuitBreaker) Success() {
+    if cb == nil {
+        return
+    }
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    cb.state = breakerClosed
+    cb.failures = 0
+    cb.probing = false
+}
+
+// Failure records a call the bank did not answer or answered with 5xx
+func (cb *CircuitBreaker) Failure() {
+    if cb == nil {
+        return
+    }
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    cb.failures++
+    cb.probing = false
+    if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+        cb.state = breakerOpen
+        cb.openedAt = time.Now()
+    }
+}
+
+// Abort releases a call the caller gave up on. It says nothing about the
+// bank, but a half-open breaker must let the next probe through.
+func (cb *CircuitBreaker) Abort() {
+    if cb == nil {
+        return
+    }
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    cb.probing = false
+}
+
+// Open reports whether calls are currently refused
+func (cb *CircuitBreaker) Open() bool {
+    if cb == nil {
+        return false
+    }
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    return cb.state == breakerOpen && time.Since(cb.openedAt) < cb.cooldown
+}
+
+// parseRetryAfter reads Retry-After given either in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+    if value == "" {
+        return 0
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+        return time.Duration(seconds) * time.Second
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        if d := time.Until(at); d > 0 {
+            return d
+        }
+    }
+    return 0
+}
+
+// codeForStatus classifies an HTTP error status
+func codeForStatus(status int) string {
+    switch {
+    case status == http.StatusUnauthorized:
+        return ErrCodeUnauthorized
+    case status == http.StatusForbidden:
+        return ErrCodeForbidden
+    case status == http.StatusNotFound:
+        return ErrCodeNotFound
+    case status == http.StatusTooManyRequests:
+        return ErrCodeRateLimited
+    case status >= 500:
+        return ErrCodeBankUnavailable
+    default:
+        return ErrCodeInvalidRequest
+    }
+}
+
+// IsTransient reports whether a failed bank call may succeed when made again
+// later: the bank was down, overloaded or slow. Errors caused by the request
+// itself, like a rejected payment or an expired consent, are permanent.
+func IsTransient(err error) bool {
+    if err == nil {
+        return false
+    }
+
+    var bankErr *BankError
+    if errors.As(err, &bankErr) {
+        return bankErr.Temporary()
+    }
+
+    if errors.Is(err, context.DeadlineExceeded) {
+        return true
+    }
+    if errors.Is(err, context.Canceled) {
+        return false
+    }
+
+    var netErr net.Error
+    return errors.As(err, &netErr)
+}
@@ -0,0 +1,64 @@
+package bankadapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCancelledProbeReleasesBreaker(t *testing.T) {
+	// The first request fails, the second hangs until the caller gives up,
+	// later ones succeed
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 2:
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	adapter := NewBaseAdapter("client", "secret", server.URL, "team", &logger)
+	adapter.Retry = RetryPolicy{MaxAttempts: 1}
+	adapter.Breaker = NewCircuitBreaker(1, 10*time.Millisecond)
+
+	if _, err := adapter.DoRequest(context.Background(), http.MethodGet, "/", nil, nil); err == nil {
+		t.Fatal("first request succeeded, want 500")
+	}
+	if !adapter.Breaker.Open() {
+		t.Fatal("breaker still closed after a failure")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// The half-open probe is cancelled by its caller
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := adapter.DoRequest(ctx, http.MethodGet, "/", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("probe error = %v, want deadline exceeded", err)
+	}
+
+	// The next call is let through as a new probe and closes the breaker
+	resp, err := adapter.DoRequest(context.Background(), http.MethodGet, "/", nil, nil)
+	if err != nil {
+		t.Fatalf("request after cancelled probe: %v", err)
+	}
+	resp.Body.Close()
+	if adapter.Breaker.Open() {
+		t.Error("breaker open after a successful probe")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("bank calls = %d, want 3", got)
+	}
+}
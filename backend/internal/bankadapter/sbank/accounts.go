@@ -1,16 +1,18 @@
 package sbank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "net/url"
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 // GetAccounts retrieves all accounts for a client
-func (a *Adapter) GetAccounts(token, clientID, consentID, requestingBank string) ([]bankadapter.Account, error) {
+func (a *Adapter) GetAccounts(ctx context.Context, token, clientID, consentID, requestingBank string) ([]bankadapter.Account, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -20,7 +22,7 @@ func (a *Adapter) GetAccounts(token, clientID, consentID, requestingBank string)
     
     fullURL := a.BuildURL(endpointAccounts, map[string]string{"client_id": clientID})
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get accounts: %w", err)
     }
@@ -55,7 +57,7 @@ func (a *Adapter) GetAccounts(token, clientID, consentID, requestingBank string)
     accounts := make([]bankadapter.Account, 0, len(response.Data.Account))
     for _, acc := range response.Data.Account {
         // Get balance for each account
-        balance, _ := a.GetAccountBalance(token, acc.AccountID, consentID, requestingBank)
+        balance, _ := a.GetAccountBalance(ctx, token, acc.AccountID, consentID, requestingBank)
         if balance == nil {
             balance = &bankadapter.Balance{
                 Amount:   0,
@@ -86,11 +88,11 @@ func (a *Adapter) GetAccounts(token, clientID, consentID, requestingBank string)
 }
 
 // GetAccountDetails retrieves details of a specific account
-func (a *Adapter) GetAccountDetails(token, accountID, consentID, requestingBank string) (*bankadapter.Account, error) {
+func (a *Adapter) GetAccountDetails(ctx context.Context, token, accountID, consentID, requestingBank string) (*bankadapter.Account, error) {
     path := fmt.Sprintf(endpointAccountDetails, accountID)
     headers := a.GetConsentHeaders(token, consentID, requestingBank)
     
-    resp, err := a.DoRequest("GET", path, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", path, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get account details: %w", err)
     }
@@ -121,7 +123,7 @@ func (a *Adapter) GetAccountDetails(token, accountID, consentID, requestingBank
         return nil, fmt.Errorf("failed to decode account details: %w", err)
     }
     
-    balance, _ := a.GetAccountBalance(token, accountID, consentID, requestingBank)
+    balance, _ := a.GetAccountBalance(ctx, token, accountID, consentID, requestingBank)
     if balance == nil {
         balance = &bankadapter.Balance{
             Amount:   0,
@@ -149,11 +151,11 @@ func (a *Adapter) GetAccountDetails(token, accountID, consentID, requestingBank
 }
 
 // GetAccountBalance retrieves account balance
-func (a *Adapter) GetAccountBalance(token, accountID, consentID, requestingBank string) (*bankadapter.Balance, error) {
+func (a *Adapter) GetAccountBalance(ctx context.Context, token, accountID, consentID, requestingBank string) (*bankadapter.Balance, error) {
     path := fmt.Sprintf(endpointAccountBalances, accountID)
     headers := a.GetConsentHeaders(token, consentID, requestingBank)
     
-    resp, err := a.DoRequest("GET", path, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", path, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get account balance: %w", err)
     }
@@ -162,13 +164,10 @@ func (a *Adapter) GetAccountBalance(token, accountID, consentID, requestingBank
     var response struct {
         Data struct {
             Balance []struct {
-                Amount struct {
-                    Amount   string `json:"amount"`
-                    Currency string `json:"currency"`
-                } `json:"amount"`
-                CreditDebitIndicator string `json:"creditDebitIndicator"`
-                Type                 string `json:"type"`
-                DateTime             string `json:"dateTime"`
+                Amount               money.Money `json:"amount"`
+                CreditDebitIndicator string      `json:"creditDebitIndicator"`
+                Type                 string      `json:"type"`
+                DateTime             string      `json:"dateTime"`
             } `json:"balance"`
         } `json:"data"`
     }
@@ -182,13 +181,10 @@ func (a *Adapter) GetAccountBalance(token, accountID, consentID, requestingBank
     }
     
     bal := response.Data.Balance[0]
-    amount := 0.0
-    fmt.Sscanf(bal.Amount.Amount, "%f", &amount)
-    
     dateTime, _ := time.Parse(time.RFC3339, bal.DateTime)
     
     return &bankadapter.Balance{
-        Amount:   amount,
+        Amount:   bal.Amount.Amount,
         Currency: bal.Amount.Currency,
         Type:     bal.Type,
         DateTime: dateTime,
@@ -196,7 +192,7 @@ func (a *Adapter) GetAccountBalance(token, accountID, consentID, requestingBank
 }
 
 // CreateAccount creates a new account
-func (a *Adapter) CreateAccount(token, clientID string, accountType string, initialBalance float64) (*bankadapter.Account, error) {
+func (a *Adapter) CreateAccount(ctx context.Context, token, clientID string, accountType string, initialBalance money.Amount) (*bankadapter.Account, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -210,18 +206,18 @@ func (a *Adapter) CreateAccount(token, clientID string, accountType string, init
     headers := a.GetAuthHeaders(token)
     fullURL := a.BuildURL(endpointAccounts, map[string]string{"client_id": clientID})
     
-    resp, err := a.DoRequest("POST", fullURL, headers, request)
+    resp, err := a.DoRequest(ctx, "POST", fullURL, headers, request)
     if err != nil {
         return nil, fmt.Errorf("failed to create account: %w", err)
     }
     defer resp.Body.Close()
     
     var response struct {
-        AccountID      string `json:"account_id"`
-        Identification string `json:"identification"`
-        AccountType    string `json:"account_type"`
-        Currency       string `json:"currency"`
-        Balance        float64 `json:"balance"`
+        AccountID      string       `json:"account_id"`
+        Identification string       `json:"identification"`
+        AccountType    string       `json:"account_type"`
+        Currency       string       `json:"currency"`
+        Balance        money.Amount `json:"balance"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -243,7 +239,7 @@ func (a *Adapter) CreateAccount(token, clientID string, accountType string, init
 }
 
 // CloseAccount closes an account
-func (a *Adapter) CloseAccount(token, clientID, accountID string, closeRequest bankadapter.AccountCloseRequest) error {
+func (a *Adapter) CloseAccount(ctx context.Context, token, clientID, accountID string, closeRequest bankadapter.AccountCloseRequest) error {
     path := fmt.Sprintf(endpointAccountClose, accountID)
     params := url.Values{}
     if clientID != "" {
@@ -253,7 +249,7 @@ func (a *Adapter) CloseAccount(token, clientID, accountID string, closeRequest b
     headers := a.GetAuthHeaders(token)
     fullURL := a.BuildURL(path, map[string]string{"client_id": clientID})
     
-    resp, err := a.DoRequest("PUT", fullURL, headers, closeRequest)
+    resp, err := a.DoRequest(ctx, "PUT", fullURL, headers, closeRequest)
     if err != nil {
         return fmt.Errorf("failed to close account: %w", err)
     }
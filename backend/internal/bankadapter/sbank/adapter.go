@@ -1,17 +1,26 @@
 package sbank
 
 import (
+	"context"
+	
 	"github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
 	"github.com/rs/zerolog"
 )
 
 // Config for SBank adapter
 type Config struct {
+	// BankID, Name and DepositRate default to the SBank sandbox
+	BankID       string
+	Name         string
+	DepositRate  float64
 	ClientID     string
 	ClientSecret string
 	BaseURL      string
 	TeamID       string
 	Logger       *zerolog.Logger
+	// Retry overrides the default policy when MaxAttempts is set
+	Retry        bankadapter.RetryPolicy
+	Breaker      *bankadapter.CircuitBreaker
 }
 
 // Adapter implements BankAdapter for SBank
@@ -21,34 +30,67 @@ type Adapter struct {
 	logger zerolog.Logger
 }
 
+func init() {
+	bankadapter.RegisterDriver("sbank", func(cfg bankadapter.DriverConfig) bankadapter.BankAdapter {
+		return NewAdapter(Config{
+			BankID:       cfg.BankID,
+			Name:         cfg.Name,
+			DepositRate:  cfg.DepositRate,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			BaseURL:      cfg.BaseURL,
+			TeamID:       cfg.TeamID,
+			Logger:       cfg.Logger,
+			Retry:        cfg.Retry,
+			Breaker:      cfg.Breaker,
+		})
+	})
+}
+
 // NewAdapter creates new SBank adapter
 func NewAdapter(cfg Config) bankadapter.BankAdapter {
+	base := bankadapter.NewBaseAdapter(
+		cfg.ClientID,
+		cfg.ClientSecret,
+		cfg.BaseURL,
+		cfg.TeamID,
+		cfg.Logger,
+	)
+	if cfg.Retry.MaxAttempts > 0 {
+		base.Retry = cfg.Retry
+	}
+	base.Breaker = cfg.Breaker
+
 	return &Adapter{
-		BaseAdapter: bankadapter.NewBaseAdapter(
-			cfg.ClientID,
-			cfg.ClientSecret,
-			cfg.BaseURL,
-			cfg.TeamID,
-			cfg.Logger,
-		),
-		config: cfg,
-		logger: cfg.Logger.With().Str("bank", "sbank").Logger(),
+		BaseAdapter: base,
+		config:      cfg,
+		logger:      cfg.Logger.With().Str("bank", "sbank").Logger(),
 	}
 }
 
 // GetBankInfo returns static bank information
 func (a *Adapter) GetBankInfo() bankadapter.BankInfo {
-	return bankadapter.BankInfo{
+	info := bankadapter.BankInfo{
 		ID:          "sbank",
 		Name:        "Smart Bank",
 		BaseURL:     a.config.BaseURL,
 		DepositRate: 9.0,
 	}
+	if a.config.BankID != "" {
+		info.ID = a.config.BankID
+	}
+	if a.config.Name != "" {
+		info.Name = a.config.Name
+	}
+	if a.config.DepositRate > 0 {
+		info.DepositRate = a.config.DepositRate
+	}
+	return info
 }
 
 // IsHealthy checks if bank API is available
-func (a *Adapter) IsHealthy() bool {
-	resp, err := a.DoRequest("GET", "/health", nil, nil)
+func (a *Adapter) IsHealthy(ctx context.Context) bool {
+	resp, err := a.DoRequest(ctx, "GET", "/health", nil, nil)
 	if err != nil {
 		a.logger.Error().Err(err).Msg("Health check failed")
 		return false
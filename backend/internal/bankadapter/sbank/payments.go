@@ -1,16 +1,18 @@
 package sbank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "net/url"
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 // CreatePayment creates a new payment
-func (a *Adapter) CreatePayment(token, clientID, requestingBank string, payment bankadapter.PaymentRequest) (*bankadapter.PaymentResponse, error) {
+func (a *Adapter) CreatePayment(ctx context.Context, token, clientID, requestingBank string, payment bankadapter.PaymentRequest) (*bankadapter.PaymentResponse, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -20,12 +22,18 @@ func (a *Adapter) CreatePayment(token, clientID, requestingBank string, payment
     if requestingBank != "" {
         headers["X-Requesting-Bank"] = requestingBank
     }
+    if payment.ConsentID != "" {
+        headers["X-Payment-Consent-Id"] = payment.ConsentID
+    }
+    if payment.IdempotencyKey != "" {
+        headers[bankadapter.IdempotencyKeyHeader] = payment.IdempotencyKey
+    }
     
     body := map[string]interface{}{
         "data": map[string]interface{}{
             "initiation": map[string]interface{}{
                 "instructedAmount": map[string]interface{}{
-                    "amount":   fmt.Sprintf("%.2f", payment.Amount),
+                    "amount":   payment.Amount.String(),
                     "currency": payment.Currency,
                 },
                 "debtorAccount": map[string]interface{}{
@@ -47,7 +55,7 @@ func (a *Adapter) CreatePayment(token, clientID, requestingBank string, payment
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("POST", fullURL, headers, body)
+    resp, err := a.DoRequest(ctx, "POST", fullURL, headers, body)
     if err != nil {
         return nil, fmt.Errorf("failed to create payment: %w", err)
     }
@@ -55,11 +63,11 @@ func (a *Adapter) CreatePayment(token, clientID, requestingBank string, payment
     
     var response struct {
         Data struct {
-            PaymentID    string `json:"paymentId"`
-            Status       string `json:"status"`
-            CreationDateTime string `json:"creationDateTime"`
-            Amount       string `json:"amount"`
-            Currency     string `json:"currency"`
+            PaymentID        string       `json:"paymentId"`
+            Status           string       `json:"status"`
+            CreationDateTime string       `json:"creationDateTime"`
+            Amount           money.Amount `json:"amount"`
+            Currency         string       `json:"currency"`
         } `json:"data"`
     }
     
@@ -67,22 +75,19 @@ func (a *Adapter) CreatePayment(token, clientID, requestingBank string, payment
         return nil, fmt.Errorf("failed to decode payment response: %w", err)
     }
     
-    amount := 0.0
-    fmt.Sscanf(response.Data.Amount, "%f", &amount)
-    
     createdAt, _ := time.Parse(time.RFC3339, response.Data.CreationDateTime)
     
     return &bankadapter.PaymentResponse{
         PaymentID: response.Data.PaymentID,
         Status:    response.Data.Status,
-        Amount:    amount,
+        Amount:    response.Data.Amount,
         Currency:  response.Data.Currency,
         CreatedAt: createdAt,
     }, nil
 }
 
 // GetPaymentStatus retrieves payment status
-func (a *Adapter) GetPaymentStatus(token, clientID, paymentID string) (*bankadapter.PaymentResponse, error) {
+func (a *Adapter) GetPaymentStatus(ctx context.Context, token, clientID, paymentID string) (*bankadapter.PaymentResponse, error) {
     path := fmt.Sprintf(endpointPayment, paymentID)
     params := url.Values{}
     if clientID != "" {
@@ -96,7 +101,7 @@ func (a *Adapter) GetPaymentStatus(token, clientID, paymentID string) (*bankadap
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get payment status: %w", err)
     }
@@ -104,12 +109,12 @@ func (a *Adapter) GetPaymentStatus(token, clientID, paymentID string) (*bankadap
     
     var response struct {
         Data struct {
-            PaymentID    string `json:"paymentId"`
-            Status       string `json:"status"`
-            CreationDateTime string `json:"creationDateTime"`
-            StatusUpdateDateTime string `json:"statusUpdateDateTime"`
-            Amount       string `json:"amount"`
-            Currency     string `json:"currency"`
+            PaymentID            string       `json:"paymentId"`
+            Status               string       `json:"status"`
+            CreationDateTime     string       `json:"creationDateTime"`
+            StatusUpdateDateTime string       `json:"statusUpdateDateTime"`
+            Amount               money.Amount `json:"amount"`
+            Currency             string       `json:"currency"`
         } `json:"data"`
     }
     
@@ -117,16 +122,13 @@ func (a *Adapter) GetPaymentStatus(token, clientID, paymentID string) (*bankadap
         return nil, fmt.Errorf("failed to decode payment status: %w", err)
     }
     
-    amount := 0.0
-    fmt.Sscanf(response.Data.Amount, "%f", &amount)
-    
     createdAt, _ := time.Parse(time.RFC3339, response.Data.CreationDateTime)
     completedAt, _ := time.Parse(time.RFC3339, response.Data.StatusUpdateDateTime)
     
     paymentResp := &bankadapter.PaymentResponse{
         PaymentID: response.Data.PaymentID,
         Status:    response.Data.Status,
-        Amount:    amount,
+        Amount:    response.Data.Amount,
         Currency:  response.Data.Currency,
         CreatedAt: createdAt,
     }
@@ -139,7 +141,7 @@ func (a *Adapter) GetPaymentStatus(token, clientID, paymentID string) (*bankadap
 }
 
 // GetCards retrieves all cards
-func (a *Adapter) GetCards(token, clientID, consentID, requestingBank string) ([]bankadapter.Card, error) {
+func (a *Adapter) GetCards(ctx context.Context, token, clientID, consentID, requestingBank string) ([]bankadapter.Card, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -152,7 +154,7 @@ func (a *Adapter) GetCards(token, clientID, consentID, requestingBank string) ([
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get cards: %w", err)
     }
@@ -160,16 +162,16 @@ func (a *Adapter) GetCards(token, clientID, consentID, requestingBank string) ([
     
     var response struct {
         Cards []struct {
-            CardID       string  `json:"cardId"`
-            CardNumber   string  `json:"cardNumber"`
-            CardType     string  `json:"cardType"`
-            CardBrand    string  `json:"cardBrand"`
-            CardStatus   string  `json:"cardStatus"`
-            AccountID    string  `json:"accountId"`
-            ExpiryDate   string  `json:"expiryDate"`
-            DailyLimit   float64 `json:"dailyLimit"`
-            MonthlyLimit float64 `json:"monthlyLimit"`
-            IssuedDate   string  `json:"issuedDate"`
+            CardID       string       `json:"cardId"`
+            CardNumber   string       `json:"cardNumber"`
+            CardType     string       `json:"cardType"`
+            CardBrand    string       `json:"cardBrand"`
+            CardStatus   string       `json:"cardStatus"`
+            AccountID    string       `json:"accountId"`
+            ExpiryDate   string       `json:"expiryDate"`
+            DailyLimit   money.Amount `json:"dailyLimit"`
+            MonthlyLimit money.Amount `json:"monthlyLimit"`
+            IssuedDate   string       `json:"issuedDate"`
         } `json:"cards"`
     }
     
@@ -199,7 +201,7 @@ func (a *Adapter) GetCards(token, clientID, consentID, requestingBank string) ([
 }
 
 // CreateCard creates a new card
-func (a *Adapter) CreateCard(token, clientID, consentID, requestingBank string, request bankadapter.CreateCardRequest) (*bankadapter.Card, error) {
+func (a *Adapter) CreateCard(ctx context.Context, token, clientID, consentID, requestingBank string, request bankadapter.CreateCardRequest) (*bankadapter.Card, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -212,23 +214,23 @@ func (a *Adapter) CreateCard(token, clientID, consentID, requestingBank string,
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("POST", fullURL, headers, request)
+    resp, err := a.DoRequest(ctx, "POST", fullURL, headers, request)
     if err != nil {
         return nil, fmt.Errorf("failed to create card: %w", err)
     }
     defer resp.Body.Close()
     
     var card struct {
-        CardID       string  `json:"cardId"`
-        CardNumber   string  `json:"cardNumber"`
-        CardType     string  `json:"cardType"`
-        CardBrand    string  `json:"cardBrand"`
-        CardStatus   string  `json:"cardStatus"`
-        AccountID    string  `json:"accountId"`
-        ExpiryDate   string  `json:"expiryDate"`
-        DailyLimit   float64 `json:"dailyLimit"`
-        MonthlyLimit float64 `json:"monthlyLimit"`
-        IssuedDate   string  `json:"issuedDate"`
+        CardID       string       `json:"cardId"`
+        CardNumber   string       `json:"cardNumber"`
+        CardType     string       `json:"cardType"`
+        CardBrand    string       `json:"cardBrand"`
+        CardStatus   string       `json:"cardStatus"`
+        AccountID    string       `json:"accountId"`
+        ExpiryDate   string       `json:"expiryDate"`
+        DailyLimit   money.Amount `json:"dailyLimit"`
+        MonthlyLimit money.Amount `json:"monthlyLimit"`
+        IssuedDate   string       `json:"issuedDate"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
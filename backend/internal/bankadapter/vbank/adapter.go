@@ -1,6 +1,7 @@
 package vbank
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
@@ -14,34 +15,67 @@ type Adapter struct {
 	logger zerolog.Logger
 }
 
+func init() {
+	bankadapter.RegisterDriver("vbank", func(cfg bankadapter.DriverConfig) bankadapter.BankAdapter {
+		return NewAdapter(Config{
+			BankID:       cfg.BankID,
+			Name:         cfg.Name,
+			DepositRate:  cfg.DepositRate,
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			BaseURL:      cfg.BaseURL,
+			TeamID:       cfg.TeamID,
+			Logger:       cfg.Logger,
+			Retry:        cfg.Retry,
+			Breaker:      cfg.Breaker,
+		})
+	})
+}
+
 // NewAdapter creates new VBank adapter
 func NewAdapter(cfg Config) bankadapter.BankAdapter {
+	base := bankadapter.NewBaseAdapter(
+		cfg.ClientID,
+		cfg.ClientSecret,
+		cfg.BaseURL,
+		cfg.TeamID,
+		cfg.Logger,
+	)
+	if cfg.Retry.MaxAttempts > 0 {
+		base.Retry = cfg.Retry
+	}
+	base.Breaker = cfg.Breaker
+
 	return &Adapter{
-		BaseAdapter: bankadapter.NewBaseAdapter(
-			cfg.ClientID,
-			cfg.ClientSecret,
-			cfg.BaseURL,
-			cfg.TeamID,
-			cfg.Logger,
-		),
-		config: cfg,
-		logger: cfg.Logger.With().Str("bank", "vbank").Logger(),
+		BaseAdapter: base,
+		config:      cfg,
+		logger:      cfg.Logger.With().Str("bank", "vbank").Logger(),
 	}
 }
 
 // GetBankInfo returns static bank information
 func (a *Adapter) GetBankInfo() bankadapter.BankInfo {
-	return bankadapter.BankInfo{
+	info := bankadapter.BankInfo{
 		ID:          "vbank",
 		Name:        "Virtual Bank",
 		BaseURL:     a.config.BaseURL,
 		DepositRate: 8.0,
 	}
+	if a.config.BankID != "" {
+		info.ID = a.config.BankID
+	}
+	if a.config.Name != "" {
+		info.Name = a.config.Name
+	}
+	if a.config.DepositRate > 0 {
+		info.DepositRate = a.config.DepositRate
+	}
+	return info
 }
 
 // IsHealthy checks if bank API is available
-func (a *Adapter) IsHealthy() bool {
-	resp, err := a.DoRequest("GET", "/health", nil, nil)
+func (a *Adapter) IsHealthy(ctx context.Context) bool {
+	resp, err := a.DoRequest(ctx, "GET", "/health", nil, nil)
 	if err != nil {
 		a.logger.Error().Err(err).Msg("Health check failed")
 		return false
@@ -0,0 +1,27 @@
+package vbank
+
+import (
+	"testing"
+
+	"github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+	"github.com/KotovBoris/AutoSave/backend/internal/bankadapter/adaptertest"
+)
+
+func TestAdapter(t *testing.T) {
+	adaptertest.Run(t, adaptertest.Suite{
+		New: func(cfg bankadapter.DriverConfig) bankadapter.BankAdapter {
+			return NewAdapter(Config{
+				BankID:       cfg.BankID,
+				Name:         cfg.Name,
+				DepositRate:  cfg.DepositRate,
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				BaseURL:      cfg.BaseURL,
+				TeamID:       cfg.TeamID,
+				Logger:       cfg.Logger,
+				Retry:        cfg.Retry,
+				Breaker:      cfg.Breaker,
+			})
+		},
+	})
+}
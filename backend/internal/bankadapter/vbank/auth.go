@@ -1,6 +1,7 @@
 package vbank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "net/url"
@@ -10,14 +11,16 @@ import (
 )
 
 // GetBankToken obtains bank access token
-func (a *Adapter) GetBankToken() (*bankadapter.TokenResponse, error) {
+func (a *Adapter) GetBankToken(ctx context.Context) (*bankadapter.TokenResponse, error) {
     params := url.Values{}
     params.Set("client_id", a.config.ClientID)
     params.Set("client_secret", a.config.ClientSecret)
     
     fullURL := a.BaseURL + endpointAuthToken + "?" + params.Encode()
     
-    resp, err := a.HTTPClient.Post(fullURL, "application/x-www-form-urlencoded", nil)
+    // Asking for a token has no side effects, safe to retry
+    headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+    resp, err := a.DoIdempotentRequest(ctx, "POST", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get bank token: %w", err)
     }
@@ -54,10 +57,10 @@ func (a *Adapter) GetBankToken() (*bankadapter.TokenResponse, error) {
 }
 
 // RefreshToken refreshes access token
-func (a *Adapter) RefreshToken(refreshToken string) (*bankadapter.TokenResponse, error) {
+func (a *Adapter) RefreshToken(ctx context.Context, refreshToken string) (*bankadapter.TokenResponse, error) {
     // VBank uses same endpoint for refresh
     // In production, this would use refresh_token grant type
-    return a.GetBankToken(a.ClientID, a.ClientSecret)
+    return a.GetBankToken(ctx)
 }
 
 
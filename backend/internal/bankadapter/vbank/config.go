@@ -1,16 +1,24 @@
 package vbank
 
 import (
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
     "github.com/rs/zerolog"
 )
 
 // Config for VBank adapter
 type Config struct {
+    // BankID, Name and DepositRate default to the VBank sandbox
+    BankID       string
+    Name         string
+    DepositRate  float64
     ClientID     string
     ClientSecret string
     BaseURL      string
     TeamID       string
     Logger       *zerolog.Logger
+    // Retry overrides the default policy when MaxAttempts is set
+    Retry        bankadapter.RetryPolicy
+    Breaker      *bankadapter.CircuitBreaker
 }
 
 // API endpoints
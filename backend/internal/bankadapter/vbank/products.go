@@ -1,16 +1,18 @@
 package vbank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "net/url"
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 // GetProducts retrieves available products
-func (a *Adapter) GetProducts(token string, productType string) ([]bankadapter.Product, error) {
+func (a *Adapter) GetProducts(ctx context.Context, token string, productType string) ([]bankadapter.Product, error) {
     params := url.Values{}
     if productType != "" {
         params.Set("product_type", productType)
@@ -22,7 +24,7 @@ func (a *Adapter) GetProducts(token string, productType string) ([]bankadapter.P
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get products: %w", err)
     }
@@ -30,16 +32,16 @@ func (a *Adapter) GetProducts(token string, productType string) ([]bankadapter.P
     
     var response struct {
         Products []struct {
-            ProductID    string   `json:"productId"`
-            ProductType  string   `json:"productType"`
-            ProductName  string   `json:"productName"`
-            Description  string   `json:"description"`
-            InterestRate float64  `json:"interestRate"`
-            MinAmount    float64  `json:"minAmount"`
-            MaxAmount    float64  `json:"maxAmount"`
-            TermMonths   []int    `json:"termMonths"`
-            Currency     string   `json:"currency"`
-            Features     []string `json:"features"`
+            ProductID    string       `json:"productId"`
+            ProductType  string       `json:"productType"`
+            ProductName  string       `json:"productName"`
+            Description  string       `json:"description"`
+            InterestRate float64      `json:"interestRate"`
+            MinAmount    money.Amount `json:"minAmount"`
+            MaxAmount    money.Amount `json:"maxAmount"`
+            TermMonths   []int        `json:"termMonths"`
+            Currency     string       `json:"currency"`
+            Features     []string     `json:"features"`
         } `json:"products"`
     }
     
@@ -67,27 +69,27 @@ func (a *Adapter) GetProducts(token string, productType string) ([]bankadapter.P
 }
 
 // GetProductDetails retrieves details of a specific product
-func (a *Adapter) GetProductDetails(token, productID string) (*bankadapter.Product, error) {
+func (a *Adapter) GetProductDetails(ctx context.Context, token, productID string) (*bankadapter.Product, error) {
     path := fmt.Sprintf(endpointProduct, productID)
     headers := a.GetAuthHeaders(token)
     
-    resp, err := a.DoRequest("GET", path, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", path, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get product details: %w", err)
     }
     defer resp.Body.Close()
     
     var product struct {
-        ProductID    string   `json:"productId"`
-        ProductType  string   `json:"productType"`
-        ProductName  string   `json:"productName"`
-        Description  string   `json:"description"`
-        InterestRate float64  `json:"interestRate"`
-        MinAmount    float64  `json:"minAmount"`
-        MaxAmount    float64  `json:"maxAmount"`
-        TermMonths   []int    `json:"termMonths"`
-        Currency     string   `json:"currency"`
-        Features     []string `json:"features"`
+        ProductID    string       `json:"productId"`
+        ProductType  string       `json:"productType"`
+        ProductName  string       `json:"productName"`
+        Description  string       `json:"description"`
+        InterestRate float64      `json:"interestRate"`
+        MinAmount    money.Amount `json:"minAmount"`
+        MaxAmount    money.Amount `json:"maxAmount"`
+        TermMonths   []int        `json:"termMonths"`
+        Currency     string       `json:"currency"`
+        Features     []string     `json:"features"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
@@ -109,7 +111,7 @@ func (a *Adapter) GetProductDetails(token, productID string) (*bankadapter.Produ
 }
 
 // GetAgreements retrieves all product agreements
-func (a *Adapter) GetAgreements(token, clientID, consentID, requestingBank string) ([]bankadapter.Agreement, error) {
+func (a *Adapter) GetAgreements(ctx context.Context, token, clientID, consentID, requestingBank string) ([]bankadapter.Agreement, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -123,7 +125,7 @@ func (a *Adapter) GetAgreements(token, clientID, consentID, requestingBank strin
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get agreements: %w", err)
     }
@@ -131,18 +133,18 @@ func (a *Adapter) GetAgreements(token, clientID, consentID, requestingBank strin
     
     var response struct {
         Agreements []struct {
-            AgreementID     string  `json:"agreementId"`
-            ProductID       string  `json:"productId"`
-            ProductType     string  `json:"productType"`
-            ProductName     string  `json:"productName"`
-            Amount          float64 `json:"amount"`
-            Currency        string  `json:"currency"`
-            InterestRate    float64 `json:"interestRate"`
-            TermMonths      int     `json:"termMonths"`
-            Status          string  `json:"status"`
-            OpenedDate      string  `json:"openedDate"`
-            MaturityDate    string  `json:"maturityDate"`
-            AccruedInterest float64 `json:"accruedInterest"`
+            AgreementID     string       `json:"agreementId"`
+            ProductID       string       `json:"productId"`
+            ProductType     string       `json:"productType"`
+            ProductName     string       `json:"productName"`
+            Amount          money.Amount `json:"amount"`
+            Currency        string       `json:"currency"`
+            InterestRate    float64      `json:"interestRate"`
+            TermMonths      int          `json:"termMonths"`
+            Status          string       `json:"status"`
+            OpenedDate      string       `json:"openedDate"`
+            MaturityDate    string       `json:"maturityDate"`
+            AccruedInterest money.Amount `json:"accruedInterest"`
         } `json:"agreements"`
     }
     
@@ -175,7 +177,7 @@ func (a *Adapter) GetAgreements(token, clientID, consentID, requestingBank strin
 }
 
 // OpenDeposit opens a new deposit
-func (a *Adapter) OpenDeposit(token, clientID, consentID, requestingBank string, request bankadapter.DepositRequest) (*bankadapter.Agreement, error) {
+func (a *Adapter) OpenDeposit(ctx context.Context, token, clientID, consentID, requestingBank string, request bankadapter.DepositRequest) (*bankadapter.Agreement, error) {
     params := url.Values{}
     if clientID != "" {
         params.Set("client_id", clientID)
@@ -196,25 +198,25 @@ func (a *Adapter) OpenDeposit(token, clientID, consentID, requestingBank string,
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("POST", fullURL, headers, body)
+    resp, err := a.DoRequest(ctx, "POST", fullURL, headers, body)
     if err != nil {
         return nil, fmt.Errorf("failed to open deposit: %w", err)
     }
     defer resp.Body.Close()
     
     var response struct {
-        AgreementID     string  `json:"agreementId"`
-        ProductID       string  `json:"productId"`
-        ProductType     string  `json:"productType"`
-        ProductName     string  `json:"productName"`
-        Amount          float64 `json:"amount"`
-        Currency        string  `json:"currency"`
-        InterestRate    float64 `json:"interestRate"`
-        TermMonths      int     `json:"termMonths"`
-        Status          string  `json:"status"`
-        OpenedDate      string  `json:"openedDate"`
-        MaturityDate    string  `json:"maturityDate"`
-        AccountID       string  `json:"accountId"`
+        AgreementID     string       `json:"agreementId"`
+        ProductID       string       `json:"productId"`
+        ProductType     string       `json:"productType"`
+        ProductName     string       `json:"productName"`
+        Amount          money.Amount `json:"amount"`
+        Currency        string       `json:"currency"`
+        InterestRate    float64      `json:"interestRate"`
+        TermMonths      int          `json:"termMonths"`
+        Status          string       `json:"status"`
+        OpenedDate      string       `json:"openedDate"`
+        MaturityDate    string       `json:"maturityDate"`
+        AccountID       string       `json:"accountId"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -240,7 +242,7 @@ func (a *Adapter) OpenDeposit(token, clientID, consentID, requestingBank string,
 }
 
 // CloseDeposit closes an existing deposit
-func (a *Adapter) CloseDeposit(token, clientID, consentID, requestingBank, agreementID string) (*bankadapter.CloseDepositResponse, error) {
+func (a *Adapter) CloseDeposit(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*bankadapter.CloseDepositResponse, error) {
     path := fmt.Sprintf(endpointProductAgreement, agreementID)
     params := url.Values{}
     if clientID != "" {
@@ -255,18 +257,18 @@ func (a *Adapter) CloseDeposit(token, clientID, consentID, requestingBank, agree
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("DELETE", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "DELETE", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to close deposit: %w", err)
     }
     defer resp.Body.Close()
     
     var response struct {
-        AgreementID     string  `json:"agreementId"`
-        ClosedAt        string  `json:"closedAt"`
-        ReturnedAmount  float64 `json:"returnedAmount"`
-        AccruedInterest float64 `json:"accruedInterest"`
-        PenaltyAmount   float64 `json:"penaltyAmount"`
+        AgreementID     string       `json:"agreementId"`
+        ClosedAt        string       `json:"closedAt"`
+        ReturnedAmount  money.Amount `json:"returnedAmount"`
+        AccruedInterest money.Amount `json:"accruedInterest"`
+        PenaltyAmount   money.Amount `json:"penaltyAmount"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -285,7 +287,7 @@ func (a *Adapter) CloseDeposit(token, clientID, consentID, requestingBank, agree
 }
 
 // GetAgreementDetails retrieves details of specific agreement
-func (a *Adapter) GetAgreementDetails(token, clientID, consentID, requestingBank, agreementID string) (*bankadapter.Agreement, error) {
+func (a *Adapter) GetAgreementDetails(ctx context.Context, token, clientID, consentID, requestingBank, agreementID string) (*bankadapter.Agreement, error) {
     path := fmt.Sprintf(endpointProductAgreement, agreementID)
     params := url.Values{}
     if clientID != "" {
@@ -300,25 +302,25 @@ func (a *Adapter) GetAgreementDetails(token, clientID, consentID, requestingBank
         fullURL += "?" + params.Encode()
     }
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get agreement details: %w", err)
     }
     defer resp.Body.Close()
     
     var response struct {
-        AgreementID     string  `json:"agreementId"`
-        ProductID       string  `json:"productId"`
-        ProductType     string  `json:"productType"`
-        ProductName     string  `json:"productName"`
-        Amount          float64 `json:"amount"`
-        Currency        string  `json:"currency"`
-        InterestRate    float64 `json:"interestRate"`
-        TermMonths      int     `json:"termMonths"`
-        Status          string  `json:"status"`
-        OpenedDate      string  `json:"openedDate"`
-        MaturityDate    string  `json:"maturityDate"`
-        AccruedInterest float64 `json:"accruedInterest"`
+        AgreementID     string       `json:"agreementId"`
+        ProductID       string       `json:"productId"`
+        ProductType     string       `json:"productType"`
+        ProductName     string       `json:"productName"`
+        Amount          money.Amount `json:"amount"`
+        Currency        string       `json:"currency"`
+        InterestRate    float64      `json:"interestRate"`
+        TermMonths      int          `json:"termMonths"`
+        Status          string       `json:"status"`
+        OpenedDate      string       `json:"openedDate"`
+        MaturityDate    string       `json:"maturityDate"`
+        AccruedInterest money.Amount `json:"accruedInterest"`
     }
     
     if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
@@ -1,6 +1,7 @@
 package vbank
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "net/url"
@@ -8,15 +9,20 @@ import (
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
-// GetTransactions retrieves account transactions
-func (a *Adapter) GetTransactions(token, accountID, consentID, requestingBank string, from, to time.Time, limit int) ([]bankadapter.Transaction, error) {
+// GetTransactions retrieves one page of account transactions
+func (a *Adapter) GetTransactions(ctx context.Context, token, accountID, consentID, requestingBank string, from, to time.Time, page, limit int) (*bankadapter.TransactionPage, error) {
     path := fmt.Sprintf(endpointAccountTransactions, accountID)
     
     params := url.Values{}
     params.Set("from_booking_date_time", from.Format(time.RFC3339))
     params.Set("to_booking_date_time", to.Format(time.RFC3339))
+    if page < 1 {
+        page = 1
+    }
+    params.Set("page", strconv.Itoa(page))
     if limit > 0 {
         params.Set("limit", strconv.Itoa(limit))
     }
@@ -24,7 +30,7 @@ func (a *Adapter) GetTransactions(token, accountID, consentID, requestingBank st
     headers := a.GetConsentHeaders(token, consentID, requestingBank)
     fullURL := a.BaseURL + path + "?" + params.Encode()
     
-    resp, err := a.DoRequest("GET", fullURL, headers, nil)
+    resp, err := a.DoRequest(ctx, "GET", fullURL, headers, nil)
     if err != nil {
         return nil, fmt.Errorf("failed to get transactions: %w", err)
     }
@@ -33,16 +39,13 @@ func (a *Adapter) GetTransactions(token, accountID, consentID, requestingBank st
     var response struct {
         Data struct {
             Transaction []struct {
-                TransactionID        string `json:"transactionId"`
-                AccountID            string `json:"accountId"`
-                Amount               struct {
-                    Amount   string `json:"amount"`
-                    Currency string `json:"currency"`
-                } `json:"amount"`
-                CreditDebitIndicator string `json:"creditDebitIndicator"`
-                Status               string `json:"status"`
-                BookingDateTime      string `json:"bookingDateTime"`
-                ValueDateTime        string `json:"valueDateTime"`
+                TransactionID        string      `json:"transactionId"`
+                AccountID            string      `json:"accountId"`
+                Amount               money.Money `json:"amount"`
+                CreditDebitIndicator string      `json:"creditDebitIndicator"`
+                Status               string      `json:"status"`
+                BookingDateTime      string      `json:"bookingDateTime"`
+                ValueDateTime        string      `json:"valueDateTime"`
                 TransactionInfo      struct {
                     Description          string `json:"description"`
                     TransactionReference string `json:"transactionReference"`
@@ -71,8 +74,7 @@ func (a *Adapter) GetTransactions(token, accountID, consentID, requestingBank st
     
     transactions := make([]bankadapter.Transaction, 0, len(response.Data.Transaction))
     for _, tx := range response.Data.Transaction {
-        amount := 0.0
-        fmt.Sscanf(tx.Amount.Amount, "%f", &amount)
+        amount := tx.Amount.Amount
         
         // Make amount negative for debits
         if tx.CreditDebitIndicator == "Debit" {
@@ -101,6 +103,24 @@ func (a *Adapter) GetTransactions(token, accountID, consentID, requestingBank st
         })
     }
     
-    return transactions, nil
+    result := &bankadapter.TransactionPage{
+        Transactions: transactions,
+        Page:         page,
+        TotalPages:   response.Meta.TotalPages,
+    }
+    
+    // Banks report the next page either as a link or by the page count.
+    // Without either a full page is the only hint that more is left.
+    if response.Links.Next != "" || response.Meta.TotalPages > 0 {
+        result.HasMore = response.Links.Next != "" || page < response.Meta.TotalPages
+    } else {
+        result.HasMore = limit > 0 && len(transactions) >= limit
+    }
+    
+    if response.Meta.FirstAvailableDateTime != "" {
+        result.FirstAvailable, _ = time.Parse(time.RFC3339, response.Meta.FirstAvailableDateTime)
+    }
+    
+    return result, nil
 }
 
@@ -1,83 +1,227 @@
 package banks
 
 import (
+    "context"
     "fmt"
+    "sort"
+    "sync"
     
     "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
-    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/vbank"
-    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/abank"
-    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/sbank"
     "github.com/KotovBoris/AutoSave/backend/internal/config"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
     "github.com/rs/zerolog"
+    
+    // Bank drivers register themselves
+    _ "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/abank"
+    _ "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/sbank"
+    _ "github.com/KotovBoris/AutoSave/backend/internal/bankadapter/vbank"
 )
 
-// Factory creates bank adapters
+// Factory creates bank adapters through the driver registered for each bank.
+// Adapters are created per call, the circuit breaker of each bank and the
+// state of mock banks live here so they outlast them.
 type Factory struct {
     config *config.Config
     logger *zerolog.Logger
+
+    mu        sync.Mutex
+    instances map[string]Instance
+    breakers  map[string]*bankadapter.CircuitBreaker
+    mockStore *bankadapter.MockStore
 }
 
 // NewFactory creates a new bank adapter factory
 func NewFactory(cfg *config.Config, logger *zerolog.Logger) *Factory {
     return &Factory{
-        config: cfg,
-        logger: logger,
+        config:    cfg,
+        logger:    logger,
+        instances: make(map[string]Instance),
+        breakers:  make(map[string]*bankadapter.CircuitBreaker),
+    }
+}
+
+// Breaker returns the circuit breaker of a bank
+func (f *Factory) Breaker(bankID string) *bankadapter.CircuitBreaker {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    
+    breaker, ok := f.breakers[bankID]
+    if !ok {
+        breaker = bankadapter.NewCircuitBreaker(f.config.BankBreakerThreshold, f.config.BankBreakerCooldown)
+        f.breakers[bankID] = breaker
+    }
+    return breaker
+}
+
+func (f *Factory) retryPolicy() bankadapter.RetryPolicy {
+    policy := bankadapter.DefaultRetryPolicy
+    if f.config.BankRetryAttempts > 0 {
+        policy.MaxAttempts = f.config.BankRetryAttempts
+    }
+    return policy
+}
+
+// Load reads the banks to work with from the banks table. Banks from the
+// config file are saved to the table first, so connections can refer to them.
+// Banks whose driver is not registered are skipped.
+func (f *Factory) Load(ctx context.Context, bankRepo repository.BankRepository) error {
+    fileBanks := map[string]Instance{}
+    if f.config.BanksConfigFile != "" {
+        instances, err := LoadFile(f.config.BanksConfigFile)
+        if err != nil {
+            return err
+        }
+        for _, inst := range instances {
+            bank := inst.toModel()
+            if err := bankRepo.Upsert(ctx, &bank); err != nil {
+                return err
+            }
+            fileBanks[inst.ID] = inst
+        }
     }
+    
+    if f.config.IsMockBanks() {
+        store, err := bankadapter.NewMockStore(f.config.BankMockStateFile)
+        if err != nil {
+            return err
+        }
+        f.mu.Lock()
+        f.mockStore = store
+        f.mu.Unlock()
+    }
+    
+    rows, err := bankRepo.GetAll(ctx)
+    if err != nil {
+        return err
+    }
+    
+    instances := make(map[string]Instance, len(rows))
+    for _, bank := range rows {
+        inst := Instance{
+            ID:          bank.ID,
+            Name:        bank.Name,
+            Driver:      bank.Driver,
+            BaseURL:     bank.APIBaseURL,
+            DepositRate: bank.DepositRate,
+        }
+        if fromFile, ok := fileBanks[bank.ID]; ok {
+            inst.ClientID = fromFile.ClientID
+            inst.ClientSecret = fromFile.ClientSecret
+            inst.fromFile = true
+        }
+        
+        if _, ok := bankadapter.LookupDriver(inst.Driver); !ok {
+            f.logger.Warn().Str("bankId", bank.ID).Str("driver", bank.Driver).Msg("Skipping bank with unknown driver")
+            continue
+        }
+        instances[bank.ID] = inst
+    }
+    
+    f.mu.Lock()
+    f.instances = instances
+    f.mu.Unlock()
+    
+    f.logger.Info().Strs("banks", f.GetSupportedBanks()).Str("mode", f.config.BankMode).Msg("Banks loaded")
+    
+    return nil
 }
 
 // CreateAdapter creates a bank adapter for the specified bank
 func (f *Factory) CreateAdapter(bankID string) (bankadapter.BankAdapter, error) {
-    clientID, clientSecret, apiURL := f.config.GetBankConfig(bankID)
+    inst, ok := f.instance(bankID)
+    if !ok {
+        return nil, fmt.Errorf("unknown bank: %s", bankID)
+    }
     
-    if clientID == "" || clientSecret == "" || apiURL == "" {
+    // Mock banks need no credentials
+    if f.config.IsMockBanks() || inst.Driver == "mock" {
+        return f.mockAdapter(inst), nil
+    }
+    
+    driver, ok := bankadapter.LookupDriver(inst.Driver)
+    if !ok {
+        return nil, fmt.Errorf("unknown driver %s for bank %s", inst.Driver, bankID)
+    }
+    
+    clientID, clientSecret, baseURL := bankConfig(f.config, inst)
+    if clientID == "" || clientSecret == "" || baseURL == "" {
         return nil, fmt.Errorf("bank configuration not found for %s", bankID)
     }
     
-    switch bankID {
-    case "vbank":
-        return vbank.NewAdapter(vbank.Config{
-            ClientID:     clientID,
-            ClientSecret: clientSecret,
-            BaseURL:      apiURL,
-            TeamID:       f.config.TeamID,
-            Logger:       f.logger,
-        }), nil
-        
-    case "abank":
-        return abank.NewAdapter(abank.Config{
-            ClientID:     clientID,
-            ClientSecret: clientSecret,
-            BaseURL:      apiURL,
-            TeamID:       f.config.TeamID,
-            Logger:       f.logger,
-        }), nil
-        
-    case "sbank":
-        return sbank.NewAdapter(sbank.Config{
-            ClientID:     clientID,
-            ClientSecret: clientSecret,
-            BaseURL:      apiURL,
-            TeamID:       f.config.TeamID,
-            Logger:       f.logger,
-        }), nil
-        
-    default:
-        return nil, fmt.Errorf("unknown bank: %s", bankID)
+    return driver(bankadapter.DriverConfig{
+        BankID:       inst.ID,
+        Name:         inst.Name,
+        BaseURL:      baseURL,
+        ClientID:     clientID,
+        ClientSecret: clientSecret,
+        TeamID:       f.config.TeamID,
+        DepositRate:  inst.DepositRate,
+        Logger:       f.logger,
+        Retry:        f.retryPolicy(),
+        Breaker:      f.Breaker(bankID),
+    }), nil
+}
+
+// bankConfig picks the credentials and API URL of a bank. Each value set in
+// the config file wins over the environment, which wins over the team
+// credentials and the URL in the banks table.
+func bankConfig(cfg *config.Config, inst Instance) (clientID, clientSecret, baseURL string) {
+    clientID, clientSecret, baseURL = cfg.GetBankConfig(inst.ID)
+    if inst.ClientID != "" {
+        clientID = inst.ClientID
+    }
+    if inst.ClientSecret != "" {
+        clientSecret = inst.ClientSecret
+    }
+    if (inst.fromFile && inst.BaseURL != "") || baseURL == "" {
+        baseURL = inst.BaseURL
     }
+    return clientID, clientSecret, baseURL
+}
+
+// mockAdapter creates a stateful mock of a bank. All mock banks share one
+// store and so one state file.
+func (f *Factory) mockAdapter(inst Instance) bankadapter.BankAdapter {
+    f.mu.Lock()
+    if f.mockStore == nil {
+        f.mockStore, _ = bankadapter.NewMockStore("")
+    }
+    store := f.mockStore
+    f.mu.Unlock()
+    
+    mock := bankadapter.NewStatefulMockAdapter(inst.ID, store)
+    if inst.Name != "" {
+        mock.BankName = inst.Name
+    }
+    if inst.DepositRate > 0 {
+        mock.DepositRate = inst.DepositRate
+    }
+    return mock
 }
 
 // GetSupportedBanks returns list of supported bank IDs
 func (f *Factory) GetSupportedBanks() []string {
-    return []string{"vbank", "abank", "sbank"}
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    
+    ids := make([]string, 0, len(f.instances))
+    for id := range f.instances {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+    return ids
 }
 
 // ValidateBankID checks if bank ID is supported
 func (f *Factory) ValidateBankID(bankID string) bool {
-    switch bankID {
-    case "vbank", "abank", "sbank":
-        return true
-    default:
-        return false
-    }
+    _, ok := f.instance(bankID)
+    return ok
 }
 
+func (f *Factory) instance(bankID string) (Instance, bool) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    
+    inst, ok := f.instances[bankID]
+    return inst, ok
+}
@@ -0,0 +1,101 @@
+package banks
+
+import (
+    "testing"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/config"
+)
+
+func TestBankConfigPrecedence(t *testing.T) {
+    cfg := &config.Config{TeamID: "team", TeamSecret: "team-secret"}
+
+    tableURL := "https://table.example.com"
+    fileURL := "https://file.example.com"
+    envURL := "https://env.example.com"
+
+    tests := []struct {
+        name       string
+        env        map[string]string
+        inst       Instance
+        wantID     string
+        wantSecret string
+        wantURL    string
+    }{
+        {
+            name:       "table only",
+            inst:       Instance{ID: "testbank", BaseURL: tableURL},
+            wantID:     "team",
+            wantSecret: "team-secret",
+            wantURL:    tableURL,
+        },
+        {
+            name: "env over table",
+            env: map[string]string{
+                "TESTBANK_CLIENT_ID":     "env-id",
+                "TESTBANK_CLIENT_SECRET": "env-secret",
+                "TESTBANK_API_URL":       envURL,
+            },
+            inst:       Instance{ID: "testbank", BaseURL: tableURL},
+            wantID:     "env-id",
+            wantSecret: "env-secret",
+            wantURL:    envURL,
+        },
+        {
+            name: "file over env",
+            env: map[string]string{
+                "TESTBANK_CLIENT_ID":     "env-id",
+                "TESTBANK_CLIENT_SECRET": "env-secret",
+                "TESTBANK_API_URL":       envURL,
+            },
+            inst: Instance{
+                ID:           "testbank",
+                BaseURL:      fileURL,
+                ClientID:     "file-id",
+                ClientSecret: "file-secret",
+                fromFile:     true,
+            },
+            wantID:     "file-id",
+            wantSecret: "file-secret",
+            wantURL:    fileURL,
+        },
+        {
+            name: "file without credentials",
+            env: map[string]string{
+                "TESTBANK_CLIENT_ID":     "env-id",
+                "TESTBANK_CLIENT_SECRET": "env-secret",
+                "TESTBANK_API_URL":       envURL,
+            },
+            inst:       Instance{ID: "testbank", BaseURL: fileURL, fromFile: true},
+            wantID:     "env-id",
+            wantSecret: "env-secret",
+            wantURL:    fileURL,
+        },
+        {
+            name:       "dashes in bank ID",
+            env:        map[string]string{"TEST_BANK_API_URL": envURL},
+            inst:       Instance{ID: "test-bank", BaseURL: tableURL},
+            wantID:     "team",
+            wantSecret: "team-secret",
+            wantURL:    envURL,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            for key, value := range tt.env {
+                t.Setenv(key, value)
+            }
+
+            clientID, clientSecret, baseURL := bankConfig(cfg, tt.inst)
+            if clientID != tt.wantID {
+                t.Errorf("client ID = %q, want %q", clientID, tt.wantID)
+            }
+            if clientSecret != tt.wantSecret {
+                t.Errorf("client secret = %q, want %q", clientSecret, tt.wantSecret)
+            }
+            if baseURL != tt.wantURL {
+                t.Errorf("URL = %q, want %q", baseURL, tt.wantURL)
+            }
+        })
+    }
+}
@@ -0,0 +1,69 @@
+package banks
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+)
+
+// Instance is one bank the platform works with: which driver talks to it,
+// where its API is and, when set in the config file, its credentials
+type Instance struct {
+    ID           string  `json:"id"`
+    Name         string  `json:"name"`
+    Driver       string  `json:"driver"`
+    BaseURL      string  `json:"baseUrl"`
+    ClientID     string  `json:"clientId,omitempty"`
+    ClientSecret string  `json:"clientSecret,omitempty"`
+    DepositRate  float64 `json:"depositRate"`
+    // Active defaults to true, false hides the bank without deleting it
+    Active       *bool   `json:"active,omitempty"`
+
+    // fromFile marks a bank listed in the config file, whose URL wins
+    // over the environment
+    fromFile bool
+}
+
+// LoadFile reads bank instances from a JSON file holding a list of them
+func LoadFile(path string) ([]Instance, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read banks config: %w", err)
+    }
+
+    var instances []Instance
+    if err := json.Unmarshal(data, &instances); err != nil {
+        return nil, fmt.Errorf("failed to parse banks config %s: %w", path, err)
+    }
+
+    seen := make(map[string]bool)
+    for _, inst := range instances {
+        if inst.ID == "" || inst.Name == "" {
+            return nil, fmt.Errorf("banks config %s: every bank needs an id and a name", path)
+        }
+        if seen[inst.ID] {
+            return nil, fmt.Errorf("banks config %s: bank %s is listed twice", path, inst.ID)
+        }
+        seen[inst.ID] = true
+
+        if _, ok := bankadapter.LookupDriver(inst.Driver); !ok {
+            return nil, fmt.Errorf("banks config %s: bank %s uses unknown driver %q", path, inst.ID, inst.Driver)
+        }
+    }
+
+    return instances, nil
+}
+
+func (i Instance) toModel() models.Bank {
+    return models.Bank{
+        ID:          i.ID,
+        Name:        i.Name,
+        Driver:      i.Driver,
+        APIBaseURL:  i.BaseURL,
+        DepositRate: i.DepositRate,
+        IsActive:    i.Active == nil || *i.Active,
+    }
+}
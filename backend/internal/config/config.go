@@ -2,11 +2,13 @@ package config
 
 import (
     "fmt"
+    "net"
     "os"
     "strconv"
     "strings"
     "time"
 
+    "github.com/KotovBoris/AutoSave/backend/internal/ratelimit"
     "github.com/joho/godotenv"
     "github.com/rs/zerolog"
 )
@@ -17,7 +19,27 @@ type Config struct {
     AppPort    string
     AppHost    string
     JWTSecret  string
-    JWTExpiry  time.Duration
+
+    // Access tokens are short-lived, refresh tokens outlive them and each
+    // of them can be used once
+    JWTExpiry        time.Duration
+    JWTRefreshExpiry time.Duration
+
+    // TOTPIssuer names the account in authenticator apps
+    TOTPIssuer string
+
+    // AppURL is where the frontend lives, mailed links point there
+    AppURL string
+
+    // Mail goes out through MailDriver: smtp, file (one .eml per message in
+    // MailDir) or log
+    MailDriver   string
+    MailFrom     string
+    MailDir      string
+    SMTPHost     string
+    SMTPPort     int
+    SMTPUsername string
+    SMTPPassword string
 
     // Database
     DBHost     string
@@ -30,24 +52,38 @@ type Config struct {
     // Redis
     RedisURL string
 
+    // Rate limits, as <requests>/<period> per key. Buckets are kept in
+    // memory or, with RateLimitStore redis, in Redis shared by instances.
+    RateLimitEnabled   bool
+    RateLimitStore     string
+    RateLimitAPI       ratelimit.Rule
+    RateLimitAuth      ratelimit.Rule
+    RateLimitLogin     ratelimit.Rule
+    RateLimitExpensive ratelimit.Rule
+    // TrustedProxies may set X-Forwarded-For, the client address of other
+    // requests is the connection's. Empty trusts no proxy.
+    TrustedProxies     []string
+
+    // LoginLockoutThreshold failed logins in a row lock an account for
+    // LoginLockoutBase, doubling with each further failure up to the max
+    LoginLockoutThreshold int
+    LoginLockoutBase      time.Duration
+    LoginLockoutMax       time.Duration
+
     // Team credentials
     TeamID     string
     TeamSecret string
 
-    // VBank
-    VBankClientID     string
-    VBankClientSecret string
-    VBankAPIURL       string
+    // Banks come from the banks table, this JSON file adds or overrides
+    // them. Credentials and API URL of bank <id> not set in the file are
+    // read from <ID>_CLIENT_ID, <ID>_CLIENT_SECRET and <ID>_API_URL,
+    // falling back to the team credentials and the banks table.
+    BanksConfigFile string
 
-    // ABank
-    ABankClientID     string
-    ABankClientSecret string
-    ABankAPIURL       string
-
-    // SBank
-    SBankClientID     string
-    SBankClientSecret string
-    SBankAPIURL       string
+    // BankMode is live or mock. Mock banks need no credentials and keep
+    // their state in memory, or in BankMockStateFile when it is set.
+    BankMode          string
+    BankMockStateFile string
 
     // Logging
     LogLevel  string
@@ -55,6 +91,29 @@ type Config struct {
 
     // CORS
     CORSAllowedOrigins []string
+
+    // Scheduler
+    SchedulerEnabled  bool
+    SchedulerInterval time.Duration
+
+    // Analysis
+    SalaryLookbackMonths int
+
+    // Currency of new users. Exchange rates are read from FXRatesFile or,
+    // when it is empty, fetched from FXRatesURL and reloaded by the scheduler.
+    DefaultCurrency string
+    FXRatesFile     string
+    FXRatesURL      string
+
+    // Bank API resilience
+    BankRetryAttempts    int
+    BankBreakerThreshold int
+    BankBreakerCooldown  time.Duration
+
+    // Transaction sync
+    SyncPageSize       int
+    SyncOverlap        time.Duration
+    SyncBackfillMonths int
 }
 
 func Load() (*Config, error) {
@@ -72,6 +131,17 @@ func Load() (*Config, error) {
         AppPort:    getEnv("APP_PORT", "8080"),
         AppHost:    getEnv("APP_HOST", "localhost"),
         JWTSecret:  getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+        TOTPIssuer: getEnv("TOTP_ISSUER", "AutoSave"),
+        AppURL:     strings.TrimRight(getEnv("APP_URL", "http://localhost:3000"), "/"),
+
+        // Mail
+        MailDriver:   getEnv("MAIL_DRIVER", "log"),
+        MailFrom:     getEnv("MAIL_FROM", "AutoSave <no-reply@autosave.local>"),
+        MailDir:      getEnv("MAIL_DIR", "./mail"),
+        SMTPHost:     getEnv("SMTP_HOST", ""),
+        SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+        SMTPUsername: getEnv("SMTP_USERNAME", ""),
+        SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 
         // Database
         DBHost:     getEnv("DB_HOST", "localhost"),
@@ -84,38 +154,164 @@ func Load() (*Config, error) {
         // Redis
         RedisURL: getEnv("REDIS_URL", "redis://localhost:6379"),
 
+        // Rate limiting
+        RateLimitEnabled:      getEnvAsBool("RATE_LIMIT_ENABLED", true),
+        RateLimitStore:        getEnv("RATE_LIMIT_STORE", "memory"),
+        LoginLockoutThreshold: getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+
         // Team
         TeamID:     getEnv("TEAM_ID", "team242"),
         TeamSecret: getEnv("TEAM_SECRET", ""),
 
-        // VBank
-        VBankClientID:     getEnv("VBANK_CLIENT_ID", ""),
-        VBankClientSecret: getEnv("VBANK_CLIENT_SECRET", ""),
-        VBankAPIURL:       getEnv("VBANK_API_URL", "https://vbank.open.bankingapi.ru"),
-
-        // ABank
-        ABankClientID:     getEnv("ABANK_CLIENT_ID", ""),
-        ABankClientSecret: getEnv("ABANK_CLIENT_SECRET", ""),
-        ABankAPIURL:       getEnv("ABANK_API_URL", "https://abank.open.bankingapi.ru"),
-
-        // SBank
-        SBankClientID:     getEnv("SBANK_CLIENT_ID", ""),
-        SBankClientSecret: getEnv("SBANK_CLIENT_SECRET", ""),
-        SBankAPIURL:       getEnv("SBANK_API_URL", "https://sbank.open.bankingapi.ru"),
+        // Banks
+        BanksConfigFile:   getEnv("BANKS_CONFIG_FILE", ""),
+        BankMode:          getEnv("BANK_MODE", "live"),
+        BankMockStateFile: getEnv("BANK_MOCK_STATE_FILE", ""),
 
         // Logging
         LogLevel:  getEnv("LOG_LEVEL", "debug"),
         LogFormat: getEnv("LOG_FORMAT", "console"),
+
+        // Scheduler
+        SchedulerEnabled: getEnvAsBool("SCHEDULER_ENABLED", true),
+
+        // Analysis
+        SalaryLookbackMonths: getEnvAsInt("SALARY_LOOKBACK_MONTHS", 3),
+
+        // Currencies
+        DefaultCurrency: strings.ToUpper(getEnv("DEFAULT_CURRENCY", "RUB")),
+        FXRatesFile:     getEnv("FX_RATES_FILE", ""),
+        FXRatesURL:      getEnv("FX_RATES_URL", ""),
+
+        // Bank API resilience
+        BankRetryAttempts:    getEnvAsInt("BANK_RETRY_ATTEMPTS", 3),
+        BankBreakerThreshold: getEnvAsInt("BANK_BREAKER_THRESHOLD", 5),
+
+        // Transaction sync
+        SyncPageSize:       getEnvAsInt("SYNC_PAGE_SIZE", 100),
+        SyncBackfillMonths: getEnvAsInt("SYNC_BACKFILL_MONTHS", 12),
     }
 
     // Parse JWT expiry
-    expiryStr := getEnv("JWT_EXPIRY", "168h")
+    expiryStr := getEnv("JWT_EXPIRY", "15m")
     expiry, err := time.ParseDuration(expiryStr)
     if err != nil {
         return nil, fmt.Errorf("invalid JWT_EXPIRY format: %w", err)
     }
     cfg.JWTExpiry = expiry
 
+    // Parse refresh token expiry
+    refreshStr := getEnv("JWT_REFRESH_EXPIRY", "720h")
+    refreshExpiry, err := time.ParseDuration(refreshStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid JWT_REFRESH_EXPIRY format: %w", err)
+    }
+    cfg.JWTRefreshExpiry = refreshExpiry
+
+    // Parse rate limits
+    rules := []struct {
+        key   string
+        value string
+        rule  *ratelimit.Rule
+    }{
+        {"RATE_LIMIT_API", "300/1m", &cfg.RateLimitAPI},
+        {"RATE_LIMIT_AUTH", "30/1m", &cfg.RateLimitAuth},
+        {"RATE_LIMIT_LOGIN", "5/1m", &cfg.RateLimitLogin},
+        {"RATE_LIMIT_EXPENSIVE", "6/1m", &cfg.RateLimitExpensive},
+    }
+    for _, r := range rules {
+        rule, err := ratelimit.ParseRule(getEnv(r.key, r.value))
+        if err != nil {
+            return nil, fmt.Errorf("invalid %s format: %w", r.key, err)
+        }
+        *r.rule = rule
+    }
+
+    // Parse login lockout
+    lockoutStr := getEnv("LOGIN_LOCKOUT_BASE", "1m")
+    lockoutBase, err := time.ParseDuration(lockoutStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_BASE format: %w", err)
+    }
+    cfg.LoginLockoutBase = lockoutBase
+
+    lockoutMaxStr := getEnv("LOGIN_LOCKOUT_MAX", "1h")
+    lockoutMax, err := time.ParseDuration(lockoutMaxStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_MAX format: %w", err)
+    }
+    cfg.LoginLockoutMax = lockoutMax
+
+    // Parse scheduler interval
+    intervalStr := getEnv("SCHEDULER_INTERVAL", "1h")
+    interval, err := time.ParseDuration(intervalStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid SCHEDULER_INTERVAL format: %w", err)
+    }
+    cfg.SchedulerInterval = interval
+
+    // Parse breaker cooldown
+    cooldownStr := getEnv("BANK_BREAKER_COOLDOWN", "30s")
+    cooldown, err := time.ParseDuration(cooldownStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid BANK_BREAKER_COOLDOWN format: %w", err)
+    }
+    cfg.BankBreakerCooldown = cooldown
+
+    // Parse sync overlap
+    overlapStr := getEnv("SYNC_OVERLAP", "72h")
+    overlap, err := time.ParseDuration(overlapStr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid SYNC_OVERLAP format: %w", err)
+    }
+    cfg.SyncOverlap = overlap
+
+    if cfg.SyncPageSize < 1 || cfg.SyncBackfillMonths < 1 {
+        return nil, fmt.Errorf("invalid SYNC_PAGE_SIZE or SYNC_BACKFILL_MONTHS: must be positive")
+    }
+
+    if cfg.BankMode != "live" && cfg.BankMode != "mock" {
+        return nil, fmt.Errorf("invalid BANK_MODE: must be live or mock")
+    }
+
+    if cfg.SalaryLookbackMonths < 1 || cfg.SalaryLookbackMonths > 24 {
+        return nil, fmt.Errorf("invalid SALARY_LOOKBACK_MONTHS: must be between 1 and 24")
+    }
+
+    if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "redis" {
+        return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: must be memory or redis")
+    }
+
+    if cfg.LoginLockoutBase > cfg.LoginLockoutMax {
+        return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_BASE: must not exceed LOGIN_LOCKOUT_MAX")
+    }
+
+    switch cfg.MailDriver {
+    case "smtp", "file", "log":
+    default:
+        return nil, fmt.Errorf("invalid MAIL_DRIVER: must be smtp, file or log")
+    }
+
+    if cfg.MailDriver == "smtp" && cfg.SMTPHost == "" {
+        return nil, fmt.Errorf("SMTP_HOST is required with MAIL_DRIVER=smtp")
+    }
+
+    if len(cfg.DefaultCurrency) != 3 {
+        return nil, fmt.Errorf("invalid DEFAULT_CURRENCY: must be a three-letter currency code")
+    }
+
+    // Parse trusted proxies, addresses or CIDR ranges
+    for _, proxy := range strings.Split(getEnv("TRUSTED_PROXIES", ""), ",") {
+        proxy = strings.TrimSpace(proxy)
+        if proxy == "" {
+            continue
+        }
+        if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+            return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %q is not an address or CIDR range", proxy)
+        }
+        cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+    }
+
     // Parse CORS origins
     origins := getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173")
     cfg.CORSAllowedOrigins = strings.Split(origins, ",")
@@ -163,18 +359,22 @@ func (c *Config) IsTest() bool {
     return c.AppEnv == "test"
 }
 
-// GetBankConfig returns config for specific bank
+// IsMockBanks reports whether banks are replaced by stateful mocks
+func (c *Config) IsMockBanks() bool {
+    return c.BankMode == "mock"
+}
+
+// GetBankConfig returns credentials and API URL override for a bank from
+// <ID>_CLIENT_ID, <ID>_CLIENT_SECRET and <ID>_API_URL. Values in the banks
+// config file win over these; an empty apiURL keeps the URL of the bank.
 func (c *Config) GetBankConfig(bankID string) (clientID, clientSecret, apiURL string) {
-    switch bankID {
-    case "vbank":
-        return c.VBankClientID, c.VBankClientSecret, c.VBankAPIURL
-    case "abank":
-        return c.ABankClientID, c.ABankClientSecret, c.ABankAPIURL
-    case "sbank":
-        return c.SBankClientID, c.SBankClientSecret, c.SBankAPIURL
-    default:
-        return "", "", ""
-    }
+    prefix := strings.ToUpper(strings.ReplaceAll(bankID, "-", "_"))
+
+    clientID = getEnv(prefix+"_CLIENT_ID", c.TeamID)
+    clientSecret = getEnv(prefix+"_CLIENT_SECRET", c.TeamSecret)
+    apiURL = getEnv(prefix+"_API_URL", "")
+
+    return clientID, clientSecret, apiURL
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,190 @@
+package fx
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/big"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+// ErrNoRate is returned when a currency is missing from the loaded rates
+var ErrNoRate = errors.New("no exchange rate")
+
+// Table is the format of the rates file and feed: how many units of each
+// currency one unit of Base buys, e.g. {"base":"RUB","rates":{"USD":"0.0108"}}.
+// Rates may be JSON numbers or strings.
+type Table struct {
+    Base  string                 `json:"base"`
+    Date  string                 `json:"date,omitempty"`
+    Rates map[string]json.Number `json:"rates"`
+}
+
+// Rates converts amounts between currencies. Rates come from a JSON file or
+// a feed URL and are reloaded by Refresh. Until anything is loaded only
+// same-currency conversions succeed.
+type Rates struct {
+    file   string
+    url    string
+    client *http.Client
+    logger *zerolog.Logger
+
+    mu    sync.RWMutex
+    rates map[string]*big.Rat
+}
+
+// NewRates creates a rates store reading file or, when file is empty, url.
+// Both empty leaves it without rates.
+func NewRates(file, url string, logger *zerolog.Logger) *Rates {
+    return &Rates{
+        file:   file,
+        url:    url,
+        client: &http.Client{Timeout: 10 * time.Second},
+        logger: logger,
+        rates:  map[string]*big.Rat{},
+    }
+}
+
+// Configured reports whether rates have a source to load from
+func (r *Rates) Configured() bool {
+    return r.file != "" || r.url != ""
+}
+
+// Load reads rates from the configured source and replaces the current ones
+func (r *Rates) Load(ctx context.Context) error {
+    if !r.Configured() {
+        return nil
+    }
+
+    var (
+        data []byte
+        err  error
+    )
+    if r.file != "" {
+        data, err = os.ReadFile(r.file)
+        if err != nil {
+            return fmt.Errorf("failed to read fx rates: %w", err)
+        }
+    } else {
+        data, err = r.fetch(ctx)
+        if err != nil {
+            return err
+        }
+    }
+
+    var table Table
+    if err := json.Unmarshal(data, &table); err != nil {
+        return fmt.Errorf("failed to parse fx rates: %w", err)
+    }
+
+    if err := r.Set(table); err != nil {
+        return err
+    }
+
+    r.logger.Info().Str("base", table.Base).Int("currencies", len(table.Rates)).Str("date", table.Date).Msg("FX rates loaded")
+
+    return nil
+}
+
+// Refresh reloads rates, it is run by the scheduler. Old rates are kept
+// when the source is unavailable.
+func (r *Rates) Refresh(ctx context.Context, now time.Time) error {
+    return r.Load(ctx)
+}
+
+func (r *Rates) fetch(ctx context.Context) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create fx rates request: %w", err)
+    }
+
+    resp, err := r.client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch fx rates: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("failed to fetch fx rates: status %d", resp.StatusCode)
+    }
+
+    data, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read fx rates: %w", err)
+    }
+
+    return data, nil
+}
+
+// Set replaces the current rates with the ones in table
+func (r *Rates) Set(table Table) error {
+    base := normalize(table.Base)
+    if base == "" {
+        return fmt.Errorf("fx rates have no base currency")
+    }
+
+    rates := map[string]*big.Rat{base: big.NewRat(1, 1)}
+    for currency, value := range table.Rates {
+        rate, ok := new(big.Rat).SetString(value.String())
+        if !ok || rate.Sign() <= 0 {
+            return fmt.Errorf("invalid fx rate %q for %s", value, currency)
+        }
+        rates[normalize(currency)] = rate
+    }
+
+    r.mu.Lock()
+    r.rates = rates
+    r.mu.Unlock()
+
+    return nil
+}
+
+// Rate returns how many units of to one unit of from buys
+func (r *Rates) Rate(from, to string) (*big.Rat, error) {
+    from, to = normalize(from), normalize(to)
+    if from == to {
+        return big.NewRat(1, 1), nil
+    }
+
+    r.mu.RLock()
+    fromRate, fromOK := r.rates[from]
+    toRate, toOK := r.rates[to]
+    r.mu.RUnlock()
+
+    if !fromOK {
+        return nil, fmt.Errorf("%w for %s", ErrNoRate, from)
+    }
+    if !toOK {
+        return nil, fmt.Errorf("%w for %s", ErrNoRate, to)
+    }
+
+    return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// Convert converts amount in from currency to currency to, rounding to
+// the minor unit once
+func (r *Rates) Convert(amount money.Amount, from, to string) (money.Amount, error) {
+    if normalize(from) == normalize(to) {
+        return amount, nil
+    }
+
+    rate, err := r.Rate(from, to)
+    if err != nil {
+        return 0, err
+    }
+
+    return amount.MulRat(rate), nil
+}
+
+func normalize(currency string) string {
+    return strings.ToUpper(strings.TrimSpace(currency))
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
     "net/http"
+    "strconv"
+    "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/middleware"
     "github.com/KotovBoris/AutoSave/backend/internal/models"
@@ -22,7 +24,23 @@ func NewAnalysisHandler(analysisService *services.AnalysisService) *AnalysisHand
 func (h *AnalysisHandler) DetectSalaries(c *gin.Context) {
     userID, _ := middleware.GetUserID(c)
     
-    detections, err := h.analysisService.DetectSalaries(c.Request.Context(), userID)
+    // 0 keeps the configured lookback
+    lookbackMonths := 0
+    if v := c.Query("lookbackMonths"); v != "" {
+        months, err := strconv.Atoi(v)
+        if err != nil || months < 1 || months > 24 {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "VALIDATION_ERROR",
+                    "message": "lookbackMonths must be between 1 and 24",
+                },
+            })
+            return
+        }
+        lookbackMonths = months
+    }
+    
+    detections, err := h.analysisService.DetectSalaries(c.Request.Context(), userID, lookbackMonths)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
             "error": gin.H{
@@ -66,3 +84,58 @@ func (h *AnalysisHandler) ConfirmSalaries(c *gin.Context) {
     c.JSON(http.StatusOK, analysis)
 }
 
+// GetSummary returns income and expenses by bucket. Defaults to the last
+// three months grouped by month.
+func (h *AnalysisHandler) GetSummary(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    
+    to := time.Now()
+    if v := c.Query("to"); v != "" {
+        t, err := parseFilterTime(v, true)
+        if err != nil {
+            summaryValidationError(c, "invalid to date")
+            return
+        }
+        to = t
+    }
+    
+    from := to.AddDate(0, -3, 0)
+    if v := c.Query("from"); v != "" {
+        t, err := parseFilterTime(v, false)
+        if err != nil {
+            summaryValidationError(c, "invalid from date")
+            return
+        }
+        from = t
+    }
+    
+    groupBy := models.SummaryGroupBy(c.DefaultQuery("groupBy", string(models.SummaryByMonth)))
+    switch groupBy {
+    case models.SummaryByMonth, models.SummaryByCategory, models.SummaryByCounterparty, models.SummaryByAccount:
+    default:
+        summaryValidationError(c, "groupBy must be one of month, category, counterparty, account")
+        return
+    }
+    
+    summary, err := h.analysisService.GetSummary(c.Request.Context(), userID, from, to, groupBy)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "SUMMARY_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, summary)
+}
+
+func summaryValidationError(c *gin.Context, message string) {
+    c.JSON(http.StatusBadRequest, gin.H{
+        "error": gin.H{
+            "code":    "VALIDATION_ERROR",
+            "message": message,
+        },
+    })
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+    "errors"
     "net/http"
     
     "github.com/KotovBoris/AutoSave/backend/internal/middleware"
@@ -45,7 +46,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
         return
     }
     
-    user, token, err := h.authService.Register(c.Request.Context(), req)
+    resp, err := h.authService.Register(c.Request.Context(), req, sessionClient(c))
     if err != nil {
         c.JSON(http.StatusConflict, gin.H{
             "error": gin.H{
@@ -56,10 +57,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
         return
     }
     
-    c.JSON(http.StatusCreated, gin.H{
-        "user":  user.ToResponse(),
-        "token": token,
-    })
+    c.JSON(http.StatusCreated, resp)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -86,7 +84,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
         return
     }
     
-    user, token, err := h.authService.Login(c.Request.Context(), req)
+    resp, err := h.authService.Login(c.Request.Context(), req, sessionClient(c))
     if err != nil {
         c.JSON(http.StatusUnauthorized, gin.H{
             "error": gin.H{
@@ -97,9 +95,89 @@ func (h *AuthHandler) Login(c *gin.Context) {
         return
     }
     
+    c.JSON(http.StatusOK, resp)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (h *AuthHandler) Refresh(c *gin.Context) {
+    var req models.RefreshRequest
+    
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+    
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    resp, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+    if err != nil {
+        if errors.Is(err, services.ErrInvalidRefreshToken) {
+            c.JSON(http.StatusUnauthorized, gin.H{
+                "error": gin.H{
+                    "code":    "INVALID_REFRESH_TOKEN",
+                    "message": err.Error(),
+                },
+            })
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to refresh token",
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, resp)
+}
+
+// Logout ends the current session, or every session of the user
+func (h *AuthHandler) Logout(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    sessionID, _ := middleware.GetSessionID(c)
+    
+    // The body is optional, an empty one logs out the current session
+    var req models.LogoutRequest
+    if c.Request.ContentLength != 0 {
+        if err := c.ShouldBindJSON(&req); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "VALIDATION_ERROR",
+                    "message": "Invalid request body",
+                },
+            })
+            return
+        }
+    }
+    
+    if err := h.authService.Logout(c.Request.Context(), userID, sessionID, req.Everywhere); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to log out",
+            },
+        })
+        return
+    }
+    
     c.JSON(http.StatusOK, gin.H{
-        "user":  user.ToResponse(),
-        "token": token,
+        "success": true,
+        "message": "Logged out",
     })
 }
 
@@ -129,3 +207,248 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
     c.JSON(http.StatusOK, user.ToResponse())
 }
 
+// UpdateMe changes the salary, salary dates or base currency of the current
+// user, fields left out are kept
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+    userID, exists := middleware.GetUserID(c)
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{
+            "error": gin.H{
+                "code":    "UNAUTHORIZED",
+                "message": "User not authenticated",
+            },
+        })
+        return
+    }
+    
+    var req models.UpdateProfileRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    user, err := h.authService.UpdateProfile(c.Request.Context(), userID, req)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// UpdateAutopilot turns automatic saving on or off
+func (h *AuthHandler) UpdateAutopilot(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    
+    var req models.UpdateAutopilotRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+    
+    if err := h.authService.UpdateAutopilot(c.Request.Context(), userID, req.Enabled); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, models.AutopilotResponse{AutopilotEnabled: req.Enabled})
+}
+
+// VerifyEmail confirms the email with the token from the verification link
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+    var req models.VerifyEmailRequest
+    
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+    
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    user, err := h.authService.VerifyEmail(c.Request.Context(), req.Token)
+    if err != nil {
+        if errors.Is(err, services.ErrInvalidUserToken) {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "INVALID_TOKEN",
+                    "message": err.Error(),
+                },
+            })
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to verify email",
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// ResendVerification mails a new verification link to the current user
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    
+    if err := h.authService.ResendVerification(c.Request.Context(), userID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to send verification email",
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "Verification email sent",
+    })
+}
+
+// ForgotPassword mails a reset link. The answer is the same whether the
+// email has an account or not.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+    var req models.ForgotPasswordRequest
+    
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+    
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to send password reset email",
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "If the email is registered, a reset link has been sent",
+    })
+}
+
+// ResetPassword sets a new password with the token from the reset link
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+    var req models.ResetPasswordRequest
+    
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+    
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+    
+    if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+        if errors.Is(err, services.ErrInvalidUserToken) {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "INVALID_TOKEN",
+                    "message": err.Error(),
+                },
+            })
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to reset password",
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "Password has been reset, please log in again",
+    })
+}
+
+func sessionClient(c *gin.Context) models.SessionClient {
+    return models.SessionClient{
+        UserAgent: c.Request.UserAgent(),
+        IPAddress: c.ClientIP(),
+    }
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/KotovBoris/AutoSave/backend/pkg/validator"
+    "github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+    categoryService *services.CategoryService
+}
+
+func NewCategoryHandler(categoryService *services.CategoryService) *CategoryHandler {
+    return &CategoryHandler{
+        categoryService: categoryService,
+    }
+}
+
+func (h *CategoryHandler) GetRules(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    rules, err := h.categoryService.GetRules(c.Request.Context(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, rules)
+}
+
+func (h *CategoryHandler) CreateRule(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    req, ok := bindCategoryRule(c)
+    if !ok {
+        return
+    }
+
+    rule, err := h.categoryService.CreateRule(c.Request.Context(), userID, req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "CREATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusCreated, rule)
+}
+
+func (h *CategoryHandler) UpdateRule(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    ruleID, err := strconv.Atoi(c.Param("ruleId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid rule ID",
+            },
+        })
+        return
+    }
+
+    req, ok := bindCategoryRule(c)
+    if !ok {
+        return
+    }
+
+    rule, err := h.categoryService.UpdateRule(c.Request.Context(), userID, ruleID, req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, rule)
+}
+
+func (h *CategoryHandler) DeleteRule(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    ruleID, err := strconv.Atoi(c.Param("ruleId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid rule ID",
+            },
+        })
+        return
+    }
+
+    if err := h.categoryService.DeleteRule(c.Request.Context(), userID, ruleID); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "DELETE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "Rule deleted",
+    })
+}
+
+// ApplyRules re-categorises existing transactions with the current rules
+func (h *CategoryHandler) ApplyRules(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    response, err := h.categoryService.ApplyRules(c.Request.Context(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "APPLY_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, response)
+}
+
+func (h *CategoryHandler) UpdateTransactionCategory(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    transactionID, err := strconv.Atoi(c.Param("transactionId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid transaction ID",
+            },
+        })
+        return
+    }
+
+    var req models.UpdateTransactionCategoryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    tx, err := h.categoryService.SetTransactionCategory(c.Request.Context(), userID, transactionID, req.Category)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, tx)
+}
+
+func bindCategoryRule(c *gin.Context) (models.CategoryRuleRequest, bool) {
+    var req models.CategoryRuleRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return req, false
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return req, false
+    }
+
+    return req, true
+}
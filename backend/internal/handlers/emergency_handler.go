@@ -0,0 +1,99 @@
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/KotovBoris/AutoSave/backend/pkg/validator"
+    "github.com/gin-gonic/gin"
+)
+
+type EmergencyHandler struct {
+    emergencyService *services.EmergencyService
+}
+
+func NewEmergencyHandler(emergencyService *services.EmergencyService) *EmergencyHandler {
+    return &EmergencyHandler{
+        emergencyService: emergencyService,
+    }
+}
+
+func (h *EmergencyHandler) Plan(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    var req models.EmergencyWithdrawRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    plan, err := h.emergencyService.PlanWithdraw(c.Request.Context(), userID, req.Amount)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "PLAN_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, plan)
+}
+
+func (h *EmergencyHandler) Confirm(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    var req models.EmergencyWithdrawConfirm
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    result, err := h.emergencyService.ConfirmWithdraw(c.Request.Context(), userID, req.DepositIDs)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "WITHDRAW_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
@@ -117,6 +117,37 @@ func (h *GoalHandler) UpdateGoal(c *gin.Context) {
     })
 }
 
+// ResumeGoal restarts autopilot deposits for a goal paused by an emergency
+// withdrawal
+func (h *GoalHandler) ResumeGoal(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    goalID, err := strconv.Atoi(c.Param("goalId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid goal ID",
+            },
+        })
+        return
+    }
+    
+    if err := h.goalService.ResumeGoal(c.Request.Context(), userID, goalID); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "RESUME_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "Goal resumed",
+    })
+}
+
 func (h *GoalHandler) DeleteGoal(c *gin.Context) {
     userID, _ := middleware.GetUserID(c)
     goalID, err := strconv.Atoi(c.Param("goalId"))
@@ -130,7 +161,8 @@ func (h *GoalHandler) DeleteGoal(c *gin.Context) {
         return
     }
     
-    if err := h.goalService.DeleteGoal(c.Request.Context(), userID, goalID); err != nil {
+    response, err := h.goalService.DeleteGoal(c.Request.Context(), userID, goalID)
+    if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{
             "error": gin.H{
                 "code":    "DELETE_FAILED",
@@ -140,7 +172,7 @@ func (h *GoalHandler) DeleteGoal(c *gin.Context) {
         return
     }
     
-    c.JSON(http.StatusNoContent, nil)
+    c.JSON(http.StatusOK, response)
 }
 
 func (h *GoalHandler) ReorderGoals(c *gin.Context) {
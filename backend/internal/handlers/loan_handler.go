@@ -0,0 +1,222 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/KotovBoris/AutoSave/backend/pkg/validator"
+    "github.com/gin-gonic/gin"
+)
+
+type LoanHandler struct {
+    loanService *services.LoanService
+}
+
+func NewLoanHandler(loanService *services.LoanService) *LoanHandler {
+    return &LoanHandler{
+        loanService: loanService,
+    }
+}
+
+func (h *LoanHandler) GetLoans(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    loans, err := h.loanService.GetUserLoans(c.Request.Context(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, loans)
+}
+
+func (h *LoanHandler) CreateLoan(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    var req models.CreateLoanRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    loan, err := h.loanService.CreateLoan(c.Request.Context(), userID, req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "CREATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusCreated, loan)
+}
+
+func (h *LoanHandler) UpdateLoan(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    loanID, ok := parseLoanID(c)
+    if !ok {
+        return
+    }
+
+    var req models.UpdateLoanRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    loan, err := h.loanService.UpdateLoan(c.Request.Context(), userID, loanID, req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, loan)
+}
+
+func (h *LoanHandler) DeleteLoan(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    loanID, ok := parseLoanID(c)
+    if !ok {
+        return
+    }
+
+    if err := h.loanService.DeleteLoan(c.Request.Context(), userID, loanID); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "DELETE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *LoanHandler) GetPayments(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    loanID, ok := parseLoanID(c)
+    if !ok {
+        return
+    }
+
+    payments, err := h.loanService.GetPayments(c.Request.Context(), userID, loanID)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "FETCH_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "loanId":   loanID,
+        "payments": payments,
+    })
+}
+
+func (h *LoanHandler) CreatePayment(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+    loanID, ok := parseLoanID(c)
+    if !ok {
+        return
+    }
+
+    var req models.CreateLoanPaymentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return
+    }
+
+    payment, err := h.loanService.RecordPayment(c.Request.Context(), userID, loanID, req)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "PAYMENT_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusCreated, payment)
+}
+
+func parseLoanID(c *gin.Context) (int, bool) {
+    loanID, err := strconv.Atoi(c.Param("loanId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid loan ID",
+            },
+        })
+        return 0, false
+    }
+    return loanID, true
+}
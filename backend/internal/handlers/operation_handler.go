@@ -0,0 +1,119 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/gin-gonic/gin"
+)
+
+type OperationHandler struct {
+    operationService *services.OperationService
+}
+
+func NewOperationHandler(operationService *services.OperationService) *OperationHandler {
+    return &OperationHandler{
+        operationService: operationService,
+    }
+}
+
+func (h *OperationHandler) GetOperations(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    filter, err := parseOperationFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+    filter.UserID = userID
+
+    response, err := h.operationService.GetOperations(c.Request.Context(), filter, c.Query("cursor"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "FETCH_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, response)
+}
+
+func parseOperationFilter(c *gin.Context) (models.OperationFilter, error) {
+    var filter models.OperationFilter
+
+    if v := c.Query("type"); v != "" {
+        filter.Type = &v
+    }
+    if v := c.Query("status"); v != "" {
+        filter.Status = &v
+    }
+
+    if v := c.Query("from"); v != "" {
+        from, err := parseFilterTime(v, false)
+        if err != nil {
+            return filter, fmt.Errorf("invalid from date")
+        }
+        filter.FromDate = &from
+    }
+    if v := c.Query("to"); v != "" {
+        to, err := parseFilterTime(v, true)
+        if err != nil {
+            return filter, fmt.Errorf("invalid to date")
+        }
+        filter.ToDate = &to
+    }
+
+    if v := c.Query("goalId"); v != "" {
+        goalID, err := strconv.Atoi(v)
+        if err != nil {
+            return filter, fmt.Errorf("invalid goal ID")
+        }
+        filter.GoalID = &goalID
+    }
+    if v := c.Query("loanId"); v != "" {
+        loanID, err := strconv.Atoi(v)
+        if err != nil {
+            return filter, fmt.Errorf("invalid loan ID")
+        }
+        filter.LoanID = &loanID
+    }
+
+    if v := c.Query("limit"); v != "" {
+        if l, err := strconv.Atoi(v); err == nil && l > 0 && l <= 100 {
+            filter.Limit = l
+        }
+    }
+
+    return filter, nil
+}
+
+// parseFilterTime accepts RFC3339 or a plain date. A plain "to" date
+// covers the whole day.
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+
+    t, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        return time.Time{}, err
+    }
+    if endOfDay {
+        t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+    }
+
+    return t, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/gin-gonic/gin"
+)
+
+type ProductHandler struct {
+    productCatalog *services.ProductCatalog
+}
+
+func NewProductHandler(productCatalog *services.ProductCatalog) *ProductHandler {
+    return &ProductHandler{
+        productCatalog: productCatalog,
+    }
+}
+
+func (h *ProductHandler) GetProducts(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    productType := c.DefaultQuery("type", "deposit")
+    if productType != "deposit" && productType != "loan" && productType != "card" {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "type must be one of deposit, loan, card",
+            },
+        })
+        return
+    }
+
+    response, err := h.productCatalog.GetOffers(c.Request.Context(), userID, productType)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "FETCH_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/gin-gonic/gin"
+)
+
+const defaultUpcomingDays = 30
+
+type RecurringHandler struct {
+    recurringService *services.RecurringService
+}
+
+func NewRecurringHandler(recurringService *services.RecurringService) *RecurringHandler {
+    return &RecurringHandler{
+        recurringService: recurringService,
+    }
+}
+
+func (h *RecurringHandler) GetRecurring(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    status := c.Query("status")
+    switch models.RecurringStatus(status) {
+    case "", models.RecurringStatusDetected, models.RecurringStatusConfirmed, models.RecurringStatusDismissed:
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "status must be one of detected, confirmed, dismissed",
+            },
+        })
+        return
+    }
+
+    payments, err := h.recurringService.GetRecurring(c.Request.Context(), userID, status)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "recurringPayments": payments,
+    })
+}
+
+func (h *RecurringHandler) DetectRecurring(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    payments, err := h.recurringService.DetectRecurring(c.Request.Context(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "recurringPayments": payments,
+    })
+}
+
+// GetUpcoming lists payments due within the next days (30 by default)
+func (h *RecurringHandler) GetUpcoming(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    days := defaultUpcomingDays
+    if v := c.Query("days"); v != "" {
+        d, err := strconv.Atoi(v)
+        if err != nil || d <= 0 || d > 366 {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "VALIDATION_ERROR",
+                    "message": "days must be between 1 and 366",
+                },
+            })
+            return
+        }
+        days = d
+    }
+
+    upcoming, err := h.recurringService.Upcoming(c.Request.Context(), userID, time.Now().AddDate(0, 0, days))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, upcoming)
+}
+
+func (h *RecurringHandler) Confirm(c *gin.Context) {
+    h.setStatus(c, models.RecurringStatusConfirmed)
+}
+
+func (h *RecurringHandler) Dismiss(c *gin.Context) {
+    h.setStatus(c, models.RecurringStatusDismissed)
+}
+
+func (h *RecurringHandler) setStatus(c *gin.Context, status models.RecurringStatus) {
+    userID, _ := middleware.GetUserID(c)
+    paymentID, err := strconv.Atoi(c.Param("recurringId"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid recurring payment ID",
+            },
+        })
+        return
+    }
+
+    payment, err := h.recurringService.SetStatus(c.Request.Context(), userID, paymentID, status)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "UPDATE_FAILED",
+                "message": err.Error(),
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, payment)
+}
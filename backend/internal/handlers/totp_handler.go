@@ -0,0 +1,162 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/services"
+    "github.com/KotovBoris/AutoSave/backend/pkg/validator"
+    "github.com/gin-gonic/gin"
+)
+
+type TOTPHandler struct {
+    totpService *services.TOTPService
+}
+
+func NewTOTPHandler(totpService *services.TOTPService) *TOTPHandler {
+    return &TOTPHandler{
+        totpService: totpService,
+    }
+}
+
+func (h *TOTPHandler) GetStatus(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    status, err := h.totpService.Status(c.Request.Context(), userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to get two-factor status",
+            },
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, status)
+}
+
+// Setup returns a new secret for the authenticator app
+func (h *TOTPHandler) Setup(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    resp, err := h.totpService.Setup(c.Request.Context(), userID)
+    if err != nil {
+        respondTOTPError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, resp)
+}
+
+// Enable confirms setup with a first code and returns recovery codes
+func (h *TOTPHandler) Enable(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    req, ok := bindTOTPCode(c)
+    if !ok {
+        return
+    }
+
+    codes, err := h.totpService.Enable(c.Request.Context(), userID, req.Code)
+    if err != nil {
+        respondTOTPError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, models.TOTPRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *TOTPHandler) Disable(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    req, ok := bindTOTPCode(c)
+    if !ok {
+        return
+    }
+
+    if err := h.totpService.Disable(c.Request.Context(), userID, req.Code); err != nil {
+        respondTOTPError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "success": true,
+        "message": "Two-factor authentication disabled",
+    })
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, old ones stop working
+func (h *TOTPHandler) RegenerateRecoveryCodes(c *gin.Context) {
+    userID, _ := middleware.GetUserID(c)
+
+    req, ok := bindTOTPCode(c)
+    if !ok {
+        return
+    }
+
+    codes, err := h.totpService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+    if err != nil {
+        respondTOTPError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, models.TOTPRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func bindTOTPCode(c *gin.Context) (models.TOTPCodeRequest, bool) {
+    var req models.TOTPCodeRequest
+
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid request body",
+            },
+        })
+        return req, false
+    }
+
+    if err := validator.Validate(req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Validation failed",
+                "details": err.Error(),
+            },
+        })
+        return req, false
+    }
+
+    return req, true
+}
+
+func respondTOTPError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, services.ErrInvalidTOTPCode):
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "INVALID_TOTP_CODE",
+                "message": err.Error(),
+            },
+        })
+    case errors.Is(err, services.ErrTOTPAlreadyEnabled),
+        errors.Is(err, services.ErrTOTPNotEnabled),
+        errors.Is(err, services.ErrTOTPNotSetUp):
+        c.JSON(http.StatusConflict, gin.H{
+            "error": gin.H{
+                "code":    "TOTP_STATE_CONFLICT",
+                "message": err.Error(),
+            },
+        })
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Two-factor authentication request failed",
+            },
+        })
+    }
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// FileMailer writes every message to an .eml file in a directory
+type FileMailer struct {
+    dir  string
+    from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+    if dir == "" {
+        return nil, fmt.Errorf("file mailer needs a directory")
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create mail directory: %w", err)
+    }
+    return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+    recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+    name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), recipient)
+
+    if err := os.WriteFile(filepath.Join(m.dir, name), compose(m.from, msg), 0o644); err != nil {
+        return fmt.Errorf("failed to write mail: %w", err)
+    }
+
+    return nil
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+    "context"
+
+    "github.com/rs/zerolog"
+)
+
+// LogMailer writes messages to the log, links in them included, which is
+// only fit for development
+type LogMailer struct {
+    logger *zerolog.Logger
+}
+
+func NewLogMailer(logger *zerolog.Logger) *LogMailer {
+    return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+    m.logger.Info().
+        Str("to", msg.To).
+        Str("subject", msg.Subject).
+        Str("body", msg.Body).
+        Msg("Mail not sent, log mailer")
+    return nil
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+    "context"
+    "fmt"
+    "mime"
+    "strings"
+
+    "github.com/rs/zerolog"
+)
+
+// Message is a plain text email
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Mailer sends emails. SMTP sends them for real, the file and log mailers
+// keep them local so flows can be tried without a mail server.
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and sets up a mailer
+type Config struct {
+    // Driver is smtp, file or log
+    Driver string
+    From   string
+
+    SMTPHost     string
+    SMTPPort     int
+    SMTPUsername string
+    SMTPPassword string
+
+    // Dir receives one .eml file per message with the file driver
+    Dir string
+}
+
+// New creates the mailer named by cfg.Driver
+func New(cfg Config, logger *zerolog.Logger) (Mailer, error) {
+    switch cfg.Driver {
+    case "smtp":
+        if cfg.SMTPHost == "" {
+            return nil, fmt.Errorf("smtp mailer needs a host")
+        }
+        return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+    case "file":
+        return NewFileMailer(cfg.Dir, cfg.From)
+    case "log", "":
+        return NewLogMailer(logger), nil
+    default:
+        return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+    }
+}
+
+// compose renders msg as an RFC 5322 message
+func compose(from string, msg Message) []byte {
+    return []byte(fmt.Sprintf(
+        "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+        from, msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), strings.ReplaceAll(msg.Body, "\n", "\r\n"),
+    ))
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "strconv"
+)
+
+// SMTPMailer sends mail through an SMTP server, authenticating when a
+// username is set
+type SMTPMailer struct {
+    addr     string
+    host     string
+    username string
+    password string
+    from     string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+    return &SMTPMailer{
+        addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+        host:     host,
+        username: username,
+        password: password,
+        from:     from,
+    }
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+    var auth smtp.Auth
+    if m.username != "" {
+        auth = smtp.PlainAuth("", m.username, m.password, m.host)
+    }
+
+    // net/smtp takes no context, at least do not start after cancellation
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
+    // The envelope takes the bare address of "Name <address>"
+    sender, err := mail.ParseAddress(m.from)
+    if err != nil {
+        return fmt.Errorf("invalid sender address: %w", err)
+    }
+
+    if err := smtp.SendMail(m.addr, auth, sender.Address, []string{msg.To}, compose(m.from, msg)); err != nil {
+        return fmt.Errorf("failed to send mail: %w", err)
+    }
+
+    return nil
+}
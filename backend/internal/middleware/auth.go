@@ -1,6 +1,7 @@
 package middleware
 
 import (
+    "context"
     "net/http"
     "strings"
     
@@ -8,7 +9,19 @@ import (
     "github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(jwtUtil *jwt.JWTUtil) gin.HandlerFunc {
+// SessionChecker tells whether the login session of an access token is
+// still open
+type SessionChecker interface {
+    SessionActive(ctx context.Context, userID, sessionID int) (bool, error)
+}
+
+// EmailVerificationChecker tells whether a user has verified their email
+type EmailVerificationChecker interface {
+    EmailVerified(ctx context.Context, userID int) (bool, error)
+}
+
+// AuthMiddleware accepts valid access tokens whose session is not revoked
+func AuthMiddleware(jwtUtil *jwt.JWTUtil, sessions SessionChecker) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
         
@@ -48,8 +61,123 @@ func AuthMiddleware(jwtUtil *jwt.JWTUtil) gin.HandlerFunc {
             return
         }
         
+        active, err := sessions.SessionActive(c.Request.Context(), claims.UserID, claims.SessionID)
+        if err != nil || !active {
+            c.JSON(http.StatusUnauthorized, gin.H{
+                "error": gin.H{
+                    "code":    "UNAUTHORIZED",
+                    "message": "Session has ended",
+                },
+            })
+            c.Abort()
+            return
+        }
+        
         c.Set("user_id", claims.UserID)
         c.Set("user_email", claims.Email)
+        c.Set("session_id", claims.SessionID)
+        
+        c.Next()
+    }
+}
+
+// RequireVerifiedEmail lets through only users with a verified email, it
+// goes after AuthMiddleware on routes that move money
+func RequireVerifiedEmail(checker EmailVerificationChecker) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        userID, _ := GetUserID(c)
+        
+        verified, err := checker.EmailVerified(c.Request.Context(), userID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "error": gin.H{
+                    "code":    "INTERNAL_ERROR",
+                    "message": "Failed to check email verification",
+                },
+            })
+            c.Abort()
+            return
+        }
+        if !verified {
+            c.JSON(http.StatusForbidden, gin.H{
+                "error": gin.H{
+                    "code":    "EMAIL_NOT_VERIFIED",
+                    "message": "Verify your email to move money",
+                },
+            })
+            c.Abort()
+            return
+        }
+        
+        c.Next()
+    }
+}
+
+// StepUpVerifier checks second factor codes of users who enrolled TOTP
+type StepUpVerifier interface {
+    TOTPEnabled(ctx context.Context, userID int) (bool, error)
+    VerifyTOTP(ctx context.Context, userID int, code string) (bool, error)
+}
+
+// StepUpHeader carries the TOTP or recovery code of a step-up request
+const StepUpHeader = "X-TOTP-Code"
+
+// RequireStepUp asks users with TOTP enabled for a fresh code in the
+// X-TOTP-Code header, a code is accepted once. Users without TOTP pass.
+// It goes after AuthMiddleware.
+func RequireStepUp(verifier StepUpVerifier) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        userID, _ := GetUserID(c)
+        
+        enabled, err := verifier.TOTPEnabled(c.Request.Context(), userID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "error": gin.H{
+                    "code":    "INTERNAL_ERROR",
+                    "message": "Failed to check two-factor authentication",
+                },
+            })
+            c.Abort()
+            return
+        }
+        if !enabled {
+            c.Next()
+            return
+        }
+        
+        code := c.GetHeader(StepUpHeader)
+        if code == "" {
+            c.JSON(http.StatusForbidden, gin.H{
+                "error": gin.H{
+                    "code":    "TOTP_REQUIRED",
+                    "message": "Confirm with a code from your authenticator app in the " + StepUpHeader + " header",
+                },
+            })
+            c.Abort()
+            return
+        }
+        
+        valid, err := verifier.VerifyTOTP(c.Request.Context(), userID, code)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "error": gin.H{
+                    "code":    "INTERNAL_ERROR",
+                    "message": "Failed to check two-factor authentication",
+                },
+            })
+            c.Abort()
+            return
+        }
+        if !valid {
+            c.JSON(http.StatusForbidden, gin.H{
+                "error": gin.H{
+                    "code":    "INVALID_TOTP_CODE",
+                    "message": "Invalid or already used code",
+                },
+            })
+            c.Abort()
+            return
+        }
         
         c.Next()
     }
@@ -65,3 +193,12 @@ func GetUserID(c *gin.Context) (int, bool) {
     return id, ok
 }
 
+func GetSessionID(c *gin.Context) (int, bool) {
+    sessionID, exists := c.Get("session_id")
+    if !exists {
+        return 0, false
+    }
+    
+    id, ok := sessionID.(int)
+    return id, ok
+}
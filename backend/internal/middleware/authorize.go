@@ -0,0 +1,121 @@
+package middleware
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/authz"
+    "github.com/gin-gonic/gin"
+)
+
+// ResourceAuthorizer decides whether a user may act on a resource
+type ResourceAuthorizer interface {
+    Authorize(ctx context.Context, userID int, resource authz.Resource, id string) error
+}
+
+// Authorize lets a request through only when the resource named by path
+// parameter param belongs to the user. It goes after AuthMiddleware.
+func Authorize(authorizer ResourceAuthorizer, resource authz.Resource, param string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        userID, _ := GetUserID(c)
+        
+        if err := authorizer.Authorize(c.Request.Context(), userID, resource, c.Param(param)); err != nil {
+            abortUnauthorized(c, resource, err)
+            return
+        }
+        c.Next()
+    }
+}
+
+// AuthorizeBody is Authorize for resources named in the JSON body: field
+// holds an array of IDs, all of which must belong to the user. The body is
+// decoded the way the handler binds it, so keys match whatever the handler
+// accepts, and left for the handler to read.
+func AuthorizeBody(authorizer ResourceAuthorizer, resource authz.Resource, field string) gin.HandlerFunc {
+    bodyType := reflect.StructOf([]reflect.StructField{{
+        Name: "IDs",
+        Type: reflect.TypeOf([]int(nil)),
+        Tag:  reflect.StructTag(`json:"` + field + `"`),
+    }})
+    
+    return func(c *gin.Context) {
+        userID, _ := GetUserID(c)
+        
+        body, err := io.ReadAll(c.Request.Body)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "VALIDATION_ERROR",
+                    "message": "Invalid request body",
+                },
+            })
+            c.Abort()
+            return
+        }
+        c.Request.Body = io.NopCloser(bytes.NewReader(body))
+        
+        req := reflect.New(bodyType)
+        if err := json.Unmarshal(body, req.Interface()); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": gin.H{
+                    "code":    "VALIDATION_ERROR",
+                    "message": "Invalid request body",
+                },
+            })
+            c.Abort()
+            return
+        }
+        
+        for _, id := range req.Elem().Field(0).Interface().([]int) {
+            if err := authorizer.Authorize(c.Request.Context(), userID, resource, strconv.Itoa(id)); err != nil {
+                abortUnauthorized(c, resource, err)
+                return
+            }
+        }
+        c.Next()
+    }
+}
+
+// abortUnauthorized answers a request refused by the authorizer
+func abortUnauthorized(c *gin.Context, resource authz.Resource, err error) {
+    name := strings.ToUpper(string(resource[:1])) + string(resource[1:])
+    
+    switch {
+    case errors.Is(err, authz.ErrInvalidID):
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": gin.H{
+                "code":    "VALIDATION_ERROR",
+                "message": "Invalid " + string(resource) + " ID",
+            },
+        })
+    case errors.Is(err, authz.ErrNotFound):
+        c.JSON(http.StatusNotFound, gin.H{
+            "error": gin.H{
+                "code":    "NOT_FOUND",
+                "message": name + " not found",
+            },
+        })
+    case errors.Is(err, authz.ErrForbidden):
+        c.JSON(http.StatusForbidden, gin.H{
+            "error": gin.H{
+                "code":    "FORBIDDEN",
+                "message": "Access to this " + string(resource) + " is forbidden",
+            },
+        })
+    default:
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "error": gin.H{
+                "code":    "INTERNAL_ERROR",
+                "message": "Failed to check access",
+            },
+        })
+    }
+    c.Abort()
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// SummaryGroupBy names how GET /analysis/summary splits transactions
+type SummaryGroupBy string
+
+const (
+    SummaryByMonth        SummaryGroupBy = "month"
+    SummaryByCategory     SummaryGroupBy = "category"
+    SummaryByCounterparty SummaryGroupBy = "counterparty"
+    SummaryByAccount      SummaryGroupBy = "account"
+)
+
+// SummaryAmounts are money flows of a period. Savings is money moved to
+// deposits minus money returned from them and is not part of Expenses.
+type SummaryAmounts struct {
+    Income   money.Amount `db:"income" json:"income"`
+    Expenses money.Amount `db:"expenses" json:"expenses"`
+    Savings  money.Amount `db:"savings" json:"savings"`
+    Net      money.Amount `db:"-" json:"net"`
+    Count    int          `db:"count" json:"count"`
+}
+
+type SummaryBucket struct {
+    Key   string `db:"key" json:"key"`
+    Label string `db:"label" json:"label"`
+    // Currency of the amounts as read from the database, the summary
+    // converts all of them to the base currency of the user
+    Currency string `db:"currency" json:"-"`
+    SummaryAmounts
+    TopCounterparties []CounterpartyTotal `db:"-" json:"topCounterparties,omitempty"`
+    // Previous holds the same bucket in the previous period, months have none
+    Previous *SummaryAmounts `db:"-" json:"previous,omitempty"`
+}
+
+type CounterpartyTotal struct {
+    Key          string       `db:"key" json:"-"`
+    Counterparty string       `db:"counterparty" json:"counterparty"`
+    Currency     string       `db:"currency" json:"-"`
+    Income       money.Amount `db:"income" json:"income"`
+    Expenses     money.Amount `db:"expenses" json:"expenses"`
+    Count        int          `db:"count" json:"count"`
+}
+
+type SummaryPeriod struct {
+    From   time.Time      `json:"from"`
+    To     time.Time      `json:"to"`
+    Totals SummaryAmounts `json:"totals"`
+}
+
+// SummaryTrend is the change against the previous period in percent,
+// nil when the previous value is zero
+type SummaryTrend struct {
+    IncomeChange   *float64 `json:"incomeChange"`
+    ExpensesChange *float64 `json:"expensesChange"`
+    NetChange      *float64 `json:"netChange"`
+}
+
+// AnalysisSummary amounts are in the base currency of the user
+type AnalysisSummary struct {
+    Currency       string          `json:"currency"`
+    From           time.Time       `json:"from"`
+    To             time.Time       `json:"to"`
+    GroupBy        SummaryGroupBy  `json:"groupBy"`
+    Buckets        []SummaryBucket `json:"buckets"`
+    Totals         SummaryAmounts  `json:"totals"`
+    PreviousPeriod SummaryPeriod   `json:"previousPeriod"`
+    Trend          SummaryTrend    `json:"trend"`
+}
@@ -5,6 +5,8 @@ import "time"
 type Bank struct {
     ID          string    `db:"id" json:"id"`
     Name        string    `db:"name" json:"name"`
+    // Driver names the registered adapter that talks to the bank API
+    Driver      string    `db:"driver" json:"driver"`
     APIBaseURL  string    `db:"api_base_url" json:"apiBaseUrl"`
     DepositRate float64   `db:"deposit_rate" json:"depositRate"`
     IsActive    bool      `db:"is_active" json:"isActive"`
@@ -12,24 +14,36 @@ type Bank struct {
 }
 
 type BankConnection struct {
-    ID                 int        `db:"id" json:"id"`
-    UserID             int        `db:"user_id" json:"userId"`
-    BankID             string     `db:"bank_id" json:"bankId"`
-    BankName           string     `db:"bank_name" json:"bankName,omitempty"`
-    ExternalClientID   string     `db:"external_client_id" json:"externalClientId"`
-    BankToken          string     `db:"bank_token" json:"-"`
-    TokenExpiresAt     *time.Time `db:"token_expires_at" json:"tokenExpiresAt,omitempty"`
-    AccountConsentID   *string    `db:"account_consent_id" json:"accountConsentId,omitempty"`
-    ProductConsentID   *string    `db:"product_consent_id" json:"productConsentId,omitempty"`
-    PaymentConsentID   *string    `db:"payment_consent_id" json:"paymentConsentId,omitempty"`
-    Connected          bool       `db:"connected" json:"connected"`
-    ConnectedAt        time.Time  `db:"connected_at" json:"connectedAt"`
-    LastSyncAt         *time.Time `db:"last_sync_at" json:"lastSyncAt,omitempty"`
-    Error              *string    `db:"error" json:"error,omitempty"`
+    ID                      int        `db:"id" json:"id"`
+    UserID                  int        `db:"user_id" json:"userId"`
+    BankID                  string     `db:"bank_id" json:"bankId"`
+    BankName                string     `db:"bank_name" json:"bankName,omitempty"`
+    ExternalClientID        string     `db:"external_client_id" json:"externalClientId"`
+    BankToken               string     `db:"bank_token" json:"-"`
+    TokenExpiresAt          *time.Time `db:"token_expires_at" json:"tokenExpiresAt,omitempty"`
+    AccountConsentID        *string    `db:"account_consent_id" json:"accountConsentId,omitempty"`
+    ProductConsentID        *string    `db:"product_consent_id" json:"productConsentId,omitempty"`
+    PaymentConsentID        *string    `db:"payment_consent_id" json:"paymentConsentId,omitempty"`
+    AccountConsentExpiresAt *time.Time `db:"account_consent_expires_at" json:"accountConsentExpiresAt,omitempty"`
+    ProductConsentExpiresAt *time.Time `db:"product_consent_expires_at" json:"productConsentExpiresAt,omitempty"`
+    ConsentsCheckedAt       *time.Time `db:"consents_checked_at" json:"-"`
+    Status                  string     `db:"status" json:"status"`
+    Connected               bool       `db:"connected" json:"connected"`
+    ConnectedAt             time.Time  `db:"connected_at" json:"connectedAt"`
+    LastSyncAt              *time.Time `db:"last_sync_at" json:"lastSyncAt,omitempty"`
+    Error                   *string    `db:"error" json:"error,omitempty"`
 }
 
+type ConnectionStatus string
+
+const (
+    ConnectionStatusActive         ConnectionStatus = "active"
+    ConnectionStatusConsentPending ConnectionStatus = "consent_pending"
+    ConnectionStatusReauthRequired ConnectionStatus = "reauth_required"
+)
+
 type ConnectBankRequest struct {
-    BankID string `json:"bankId" validate:"required,oneof=vbank abank sbank"`
+    BankID string `json:"bankId" validate:"required,bank"`
 }
 
 type SyncBankResponse struct {
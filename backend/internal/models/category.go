@@ -0,0 +1,68 @@
+package models
+
+import (
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// CategoryRule assigns Category to transactions matching all of its set
+// conditions. Rules with higher Priority are tried first.
+type CategoryRule struct {
+    ID                 int           `db:"id" json:"id"`
+    UserID             int           `db:"user_id" json:"userId"`
+    Category           string        `db:"category" json:"category"`
+    Counterparty       *string       `db:"counterparty" json:"counterparty,omitempty"`
+    DescriptionPattern *string       `db:"description_pattern" json:"descriptionPattern,omitempty"`
+    MinAmount          *money.Amount `db:"min_amount" json:"minAmount,omitempty"`
+    MaxAmount          *money.Amount `db:"max_amount" json:"maxAmount,omitempty"`
+    AccountID          *int          `db:"account_id" json:"accountId,omitempty"`
+    Priority           int           `db:"priority" json:"priority"`
+    CreatedAt          time.Time     `db:"created_at" json:"createdAt"`
+    UpdatedAt          time.Time     `db:"updated_at" json:"updatedAt"`
+}
+
+// CategoryRuleRequest creates a rule or replaces all fields of an existing one
+type CategoryRuleRequest struct {
+    Category           string        `json:"category" validate:"required,min=1,max=50"`
+    Counterparty       *string       `json:"counterparty,omitempty" validate:"omitempty,min=1,max=255"`
+    DescriptionPattern *string       `json:"descriptionPattern,omitempty" validate:"omitempty,min=1,max=500"`
+    MinAmount          *money.Amount `json:"minAmount,omitempty" validate:"omitempty,min=0"`
+    MaxAmount          *money.Amount `json:"maxAmount,omitempty" validate:"omitempty,min=0"`
+    AccountID          *int          `json:"accountId,omitempty"`
+    Priority           int           `json:"priority"`
+}
+
+type UpdateTransactionCategoryRequest struct {
+    Category string `json:"category" validate:"required,min=1,max=50"`
+}
+
+type ApplyCategoryRulesResponse struct {
+    Updated int `json:"updated"`
+}
+
+type CategorySource string
+
+const (
+    CategorySourceBank       CategorySource = "bank"
+    CategorySourceRule       CategorySource = "rule"
+    CategorySourceDictionary CategorySource = "dictionary"
+    CategorySourceManual     CategorySource = "manual"
+)
+
+// Categories the built-in dictionary assigns
+const (
+    CategoryGroceries     = "groceries"
+    CategoryRestaurants   = "restaurants"
+    CategoryTransport     = "transport"
+    CategoryUtilities     = "utilities"
+    CategoryHealth        = "health"
+    CategoryShopping      = "shopping"
+    CategoryEntertainment = "entertainment"
+    CategoryCommunication = "communication"
+    CategoryLoans         = "loans"
+    CategorySavings       = "savings"
+    CategoryTransfers     = "transfers"
+    CategorySalary        = "salary"
+    CategoryOther         = "other"
+)
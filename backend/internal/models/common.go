@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
 // Pagination for list endpoints
 type Pagination struct {
 	Page       int `json:"page"`
@@ -59,16 +65,21 @@ const (
 	ErrCodeConsentRequired   = "CONSENT_REQUIRED"
 )
 
-// Auth response types
+// Auth response types. Token is the short-lived access token, the refresh
+// token is exchanged for a new pair at /auth/refresh and works only once.
 type AuthResponse struct {
-	User  UserResponse `json:"user"`
-	Token string       `json:"token"`
+	User                  UserResponse `json:"user"`
+	Token                 string       `json:"token"`
+	ExpiresAt             time.Time    `json:"expiresAt"`
+	RefreshToken          string       `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time    `json:"refreshTokenExpiresAt"`
 }
 
-// Settings types
+// Settings types, omitted fields are kept
 type UpdateProfileRequest struct {
-	AvgSalary   *float64 `json:"avgSalary,omitempty"`
-	SalaryDates []int    `json:"salaryDates,omitempty"`
+	AvgSalary    *money.Amount `json:"avgSalary,omitempty" validate:"omitempty,min=0"`
+	SalaryDates  []int         `json:"salaryDates,omitempty" validate:"omitempty,dive,min=1,max=31"`
+	BaseCurrency *string       `json:"baseCurrency,omitempty" validate:"omitempty,currency"`
 }
 
 type UpdateAutopilotRequest struct {
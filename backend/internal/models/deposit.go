@@ -1,31 +1,39 @@
 package models
 
-import "time"
+import (
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
 
 type Deposit struct {
-    ID               int        `db:"id" json:"id"`
-    GoalID           int        `db:"goal_id" json:"goalId"`
-    UserID           int        `db:"user_id" json:"userId"`
-    BankID           string     `db:"bank_id" json:"bankId"`
-    ProductID        *string    `db:"product_id" json:"productId,omitempty"`
-    AgreementID      *string    `db:"agreement_id" json:"agreementId,omitempty"`
-    Amount           float64    `db:"amount" json:"amount"`
-    Rate             float64    `db:"rate" json:"rate"`
-    TermMonths       int        `db:"term_months" json:"termMonths"`
-    Status           string     `db:"status" json:"status"`
-    OpenedAt         *time.Time `db:"opened_at" json:"openedAt,omitempty"`
-    MaturesAt        *time.Time `db:"matures_at" json:"maturesAt,omitempty"`
-    ClosedAt         *time.Time `db:"closed_at" json:"closedAt,omitempty"`
-    AccruedInterest  float64    `db:"accrued_interest" json:"accruedInterest"`
-    Error            *string    `db:"error" json:"error,omitempty"`
-    CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
-    UpdatedAt        time.Time  `db:"updated_at" json:"updatedAt"`
+    ID               int          `db:"id" json:"id"`
+    GoalID           int          `db:"goal_id" json:"goalId"`
+    UserID           int          `db:"user_id" json:"userId"`
+    BankID           string       `db:"bank_id" json:"bankId"`
+    ProductID        *string      `db:"product_id" json:"productId,omitempty"`
+    AgreementID      *string      `db:"agreement_id" json:"agreementId,omitempty"`
+    Amount           money.Amount `db:"amount" json:"amount"`
+    Currency         string       `db:"currency" json:"currency"`
+    Rate             float64      `db:"rate" json:"rate"`
+    TermMonths       int          `db:"term_months" json:"termMonths"`
+    Status           string       `db:"status" json:"status"`
+    OpenedAt         *time.Time   `db:"opened_at" json:"openedAt,omitempty"`
+    MaturesAt        *time.Time   `db:"matures_at" json:"maturesAt,omitempty"`
+    ClosedAt         *time.Time   `db:"closed_at" json:"closedAt,omitempty"`
+    AccruedInterest  money.Amount `db:"accrued_interest" json:"accruedInterest"`
+    Error            *string      `db:"error" json:"error,omitempty"`
+    ScheduledFor     *time.Time   `db:"scheduled_for" json:"scheduledFor,omitempty"`
+    Attempts         int          `db:"attempts" json:"attempts"`
+    NextAttemptAt    *time.Time   `db:"next_attempt_at" json:"nextAttemptAt,omitempty"`
+    CreatedAt        time.Time    `db:"created_at" json:"createdAt"`
+    UpdatedAt        time.Time    `db:"updated_at" json:"updatedAt"`
 }
 
 type CreateDepositRequest struct {
-    GoalID        int     `json:"goalId" validate:"required"`
-    Amount        float64 `json:"amount" validate:"required,min=1000"`
-    SourceAccountID string `json:"sourceAccountId" validate:"required"`
+    GoalID          int          `json:"goalId" validate:"required"`
+    Amount          money.Amount `json:"amount" validate:"required,min=1000"`
+    SourceAccountID string       `json:"sourceAccountId" validate:"required"`
 }
 
 type DepositStatus string
@@ -5,20 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 type Operation struct {
-	ID               int       `db:"id" json:"id"`
-	UserID           int       `db:"user_id" json:"userId"`
-	Type             string    `db:"type" json:"type"`
-	Amount           *float64  `db:"amount" json:"amount,omitempty"`
-	RelatedGoalID    *int      `db:"related_goal_id" json:"relatedGoalId,omitempty"`
-	RelatedLoanID    *int      `db:"related_loan_id" json:"relatedLoanId,omitempty"`
-	RelatedDepositID *int      `db:"related_deposit_id" json:"relatedDepositId,omitempty"`
-	Status           string    `db:"status" json:"status"`
-	Error            *string   `db:"error" json:"error,omitempty"`
-	Metadata         JSONB     `db:"metadata" json:"metadata,omitempty"`
-	CreatedAt        time.Time `db:"created_at" json:"createdAt"`
+	ID               int           `db:"id" json:"id"`
+	UserID           int           `db:"user_id" json:"userId"`
+	Type             string        `db:"type" json:"type"`
+	Amount           *money.Amount `db:"amount" json:"amount,omitempty"`
+	RelatedGoalID    *int          `db:"related_goal_id" json:"relatedGoalId,omitempty"`
+	RelatedLoanID    *int          `db:"related_loan_id" json:"relatedLoanId,omitempty"`
+	RelatedDepositID *int          `db:"related_deposit_id" json:"relatedDepositId,omitempty"`
+	Status           string        `db:"status" json:"status"`
+	Error            *string       `db:"error" json:"error,omitempty"`
+	Metadata         JSONB         `db:"metadata" json:"metadata,omitempty"`
+	CreatedAt        time.Time     `db:"created_at" json:"createdAt"`
 
 	// Joined fields
 	Goal    *Goal    `json:"goal,omitempty"`
@@ -45,36 +47,57 @@ const (
 	OperationStatusPending OperationStatus = "pending"
 )
 
+type OperationFilter struct {
+	UserID   int
+	Type     *string
+	Status   *string
+	FromDate *time.Time
+	ToDate   *time.Time
+	GoalID   *int
+	LoanID   *int
+	BeforeID int
+	Limit    int
+}
+
+type OperationsResponse struct {
+	Operations []Operation `json:"operations"`
+	NextCursor *string     `json:"nextCursor,omitempty"`
+}
+
 type EmergencyWithdrawRequest struct {
-	Amount float64 `json:"amount" validate:"required,min=1"`
+	Amount money.Amount `json:"amount" validate:"required,min=1"`
 }
 
+// EmergencyWithdrawPlan totals are in the base currency of the user,
+// deposits to close keep their own currency
 type EmergencyWithdrawPlan struct {
-	RequestedAmount      float64          `json:"requestedAmount"`
+	Currency             string           `json:"currency"`
+	RequestedAmount      money.Amount     `json:"requestedAmount"`
 	DepositsToClose      []DepositToClose `json:"depositsToClose"`
-	TotalAmount          float64          `json:"totalAmount"`
-	TotalAccruedInterest float64          `json:"totalAccruedInterest"`
-	TotalLostInterest    float64          `json:"totalLostInterest"`
-	TotalReturned        float64          `json:"totalReturned"`
+	TotalAmount          money.Amount     `json:"totalAmount"`
+	TotalAccruedInterest money.Amount     `json:"totalAccruedInterest"`
+	TotalLostInterest    money.Amount     `json:"totalLostInterest"`
+	TotalReturned        money.Amount     `json:"totalReturned"`
 	AffectedGoals        []AffectedGoal   `json:"affectedGoals"`
 }
 
 type DepositToClose struct {
-	DepositID       int     `json:"depositId"`
-	GoalID          int     `json:"goalId"`
-	GoalName        string  `json:"goalName"`
-	Amount          float64 `json:"amount"`
-	AccruedInterest float64 `json:"accruedInterest"`
-	LostInterest    float64 `json:"lostInterest"`
-	BankID          string  `json:"bankId"`
+	DepositID       int          `json:"depositId"`
+	GoalID          int          `json:"goalId"`
+	GoalName        string       `json:"goalName"`
+	Amount          money.Amount `json:"amount"`
+	Currency        string       `json:"currency"`
+	AccruedInterest money.Amount `json:"accruedInterest"`
+	LostInterest    money.Amount `json:"lostInterest"`
+	BankID          string       `json:"bankId"`
 }
 
 type AffectedGoal struct {
-	GoalID        int     `json:"goalId"`
-	GoalName      string  `json:"goalName"`
-	CurrentAmount float64 `json:"currentAmount"`
-	AfterWithdraw float64 `json:"afterWithdraw"`
-	WillBePaused  bool    `json:"willBePaused"`
+	GoalID        int          `json:"goalId"`
+	GoalName      string       `json:"goalName"`
+	CurrentAmount money.Amount `json:"currentAmount"`
+	AfterWithdraw money.Amount `json:"afterWithdraw"`
+	WillBePaused  bool         `json:"willBePaused"`
 }
 
 type EmergencyWithdrawConfirm struct {
@@ -82,11 +105,12 @@ type EmergencyWithdrawConfirm struct {
 }
 
 type EmergencyWithdrawResult struct {
-	Success           bool        `json:"success"`
-	ClosedDeposits    []int       `json:"closedDeposits"`
-	TotalReturned     float64     `json:"totalReturned"`
-	TotalLostInterest float64     `json:"totalLostInterest"`
-	Operations        []Operation `json:"operations"`
+	Success           bool         `json:"success"`
+	ClosedDeposits    []int        `json:"closedDeposits"`
+	Currency          string       `json:"currency"`
+	TotalReturned     money.Amount `json:"totalReturned"`
+	TotalLostInterest money.Amount `json:"totalLostInterest"`
+	Operations        []Operation  `json:"operations"`
 }
 
 // JSONB type for PostgreSQL jsonb columns
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/KotovBoris/AutoSave/backend/pkg/money"
+
+// ProductOffer is a bank product as shown to the user for comparison
+type ProductOffer struct {
+    BankID       string       `json:"bankId"`
+    BankName     string       `json:"bankName"`
+    ProductID    string       `json:"productId"`
+    ProductType  string       `json:"productType"`
+    ProductName  string       `json:"productName"`
+    Description  string       `json:"description,omitempty"`
+    InterestRate float64      `json:"interestRate"`
+    MinAmount    money.Amount `json:"minAmount,omitempty"`
+    MaxAmount    money.Amount `json:"maxAmount,omitempty"`
+    TermMonths   []int        `json:"termMonths,omitempty"`
+    Currency     string       `json:"currency"`
+}
+
+// BankProductsError tells which bank catalog could not be loaded
+type BankProductsError struct {
+    BankID  string `json:"bankId"`
+    Message string `json:"message"`
+}
+
+type ProductsResponse struct {
+    Products []ProductOffer      `json:"products"`
+    Errors   []BankProductsError `json:"errors,omitempty"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// RecurringPayment is an outgoing payment repeating with a stable amount
+type RecurringPayment struct {
+    ID           int          `db:"id" json:"id"`
+    UserID       int          `db:"user_id" json:"userId"`
+    Counterparty string       `db:"counterparty" json:"counterparty"`
+    AccountID    *int         `db:"account_id" json:"accountId,omitempty"`
+    Category     *string      `db:"category" json:"category,omitempty"`
+    Kind         string       `db:"kind" json:"kind"`
+    Period       string       `db:"period" json:"period"`
+    AvgAmount    money.Amount `db:"avg_amount" json:"avgAmount"`
+    LastAmount   money.Amount `db:"last_amount" json:"lastAmount"`
+    Occurrences  int          `db:"occurrences" json:"occurrences"`
+    Confidence   string       `db:"confidence" json:"confidence"`
+    LastPaidAt   time.Time    `db:"last_paid_at" json:"lastPaidAt"`
+    NextDueDate  time.Time    `db:"next_due_date" json:"nextDueDate"`
+    Status       string       `db:"status" json:"status"`
+    CreatedAt    time.Time    `db:"created_at" json:"createdAt"`
+    UpdatedAt    time.Time    `db:"updated_at" json:"updatedAt"`
+}
+
+type RecurringPeriod string
+
+const (
+    RecurringWeekly  RecurringPeriod = "weekly"
+    RecurringMonthly RecurringPeriod = "monthly"
+    RecurringYearly  RecurringPeriod = "yearly"
+)
+
+type RecurringKind string
+
+const (
+    RecurringSubscription RecurringKind = "subscription"
+    RecurringRent         RecurringKind = "rent"
+    RecurringUtilities    RecurringKind = "utilities"
+    RecurringLoan         RecurringKind = "loan"
+    RecurringOther        RecurringKind = "other"
+)
+
+type RecurringStatus string
+
+const (
+    RecurringStatusDetected  RecurringStatus = "detected"
+    RecurringStatusConfirmed RecurringStatus = "confirmed"
+    RecurringStatusDismissed RecurringStatus = "dismissed"
+)
+
+// UpcomingPayments are recurring payments expected before a date
+type UpcomingPayments struct {
+    Until    time.Time          `json:"until"`
+    Total    money.Amount       `json:"total"`
+    Payments []RecurringPayment `json:"payments"`
+}
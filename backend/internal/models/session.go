@@ -0,0 +1,53 @@
+package models
+
+import (
+    "time"
+)
+
+// Session is one login and the family of refresh tokens issued for it.
+// Access tokens carry the session ID, revoking the session ends them too.
+type Session struct {
+    ID            int        `db:"id" json:"id"`
+    UserID        int        `db:"user_id" json:"userId"`
+    UserAgent     *string    `db:"user_agent" json:"userAgent,omitempty"`
+    IPAddress     *string    `db:"ip_address" json:"ipAddress,omitempty"`
+    CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+    LastUsedAt    time.Time  `db:"last_used_at" json:"lastUsedAt"`
+    RevokedAt     *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+    RevokedReason *string    `db:"revoked_reason" json:"revokedReason,omitempty"`
+}
+
+// RefreshToken is stored by hash only. UsedAt is set when the token is
+// exchanged for a new one, a used token must never come back.
+type RefreshToken struct {
+    ID        int        `db:"id"`
+    SessionID int        `db:"session_id"`
+    TokenHash string     `db:"token_hash"`
+    ExpiresAt time.Time  `db:"expires_at"`
+    UsedAt    *time.Time `db:"used_at"`
+    CreatedAt time.Time  `db:"created_at"`
+}
+
+type SessionRevokeReason string
+
+const (
+    SessionRevokedLogout     SessionRevokeReason = "logout"
+    SessionRevokedLogoutAll  SessionRevokeReason = "logout_all"
+    SessionRevokedTokenReuse SessionRevokeReason = "token_reuse"
+)
+
+// SessionClient tells where a login comes from
+type SessionClient struct {
+    UserAgent string
+    IPAddress string
+}
+
+type RefreshRequest struct {
+    RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// LogoutRequest ends the current session, or with Everywhere all sessions
+// of the user
+type LogoutRequest struct {
+    Everywhere bool `json:"everywhere"`
+}
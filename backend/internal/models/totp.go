@@ -0,0 +1,44 @@
+package models
+
+import (
+    "time"
+)
+
+// UserTOTP is the TOTP enrolment of a user. It protects nothing until
+// ConfirmedAt is set.
+type UserTOTP struct {
+    UserID       int        `db:"user_id"`
+    Secret       string     `db:"secret"`
+    ConfirmedAt  *time.Time `db:"confirmed_at"`
+    LastUsedStep int64      `db:"last_used_step"`
+    CreatedAt    time.Time  `db:"created_at"`
+}
+
+// Enabled reports whether the enrolment was confirmed with a code
+func (t *UserTOTP) Enabled() bool {
+    return t != nil && t.ConfirmedAt != nil
+}
+
+type TOTPStatusResponse struct {
+    Enabled           bool       `json:"enabled"`
+    EnabledAt         *time.Time `json:"enabledAt,omitempty"`
+    RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+}
+
+// TOTPSetupResponse carries the new secret, to be typed in or scanned from
+// OTPAuthURL as a QR code
+type TOTPSetupResponse struct {
+    Secret     string `json:"secret"`
+    OTPAuthURL string `json:"otpauthUrl"`
+}
+
+// TOTPCodeRequest carries a code from the authenticator app or, where
+// accepted, a recovery code
+type TOTPCodeRequest struct {
+    Code string `json:"code" validate:"required"`
+}
+
+// TOTPRecoveryCodesResponse is the only time recovery codes are shown
+type TOTPRecoveryCodesResponse struct {
+    RecoveryCodes []string `json:"recoveryCodes"`
+}
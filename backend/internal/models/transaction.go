@@ -1,22 +1,27 @@
 package models
 
-import "time"
+import (
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
 
 type Transaction struct {
-    ID                   int        `db:"id" json:"id"`
-    AccountID            int        `db:"account_id" json:"accountId"`
-    ExternalID           string     `db:"external_id" json:"externalId"`
-    BookingDateTime      time.Time  `db:"booking_date_time" json:"bookingDateTime"`
-    ValueDateTime        *time.Time `db:"value_date_time" json:"valueDateTime,omitempty"`
-    Amount               float64    `db:"amount" json:"amount"`
-    Currency             string     `db:"currency" json:"currency"`
-    Description          *string    `db:"description" json:"description,omitempty"`
-    CreditDebitIndicator *string    `db:"credit_debit_indicator" json:"creditDebitIndicator,omitempty"`
-    CounterpartyName     *string    `db:"counterparty_name" json:"counterpartyName,omitempty"`
-    CounterpartyAccount  *string    `db:"counterparty_account" json:"counterpartyAccount,omitempty"`
-    Category             *string    `db:"category" json:"category,omitempty"`
-    IsSalary             bool       `db:"is_salary" json:"isSalary"`
-    CreatedAt            time.Time  `db:"created_at" json:"createdAt"`
+    ID                   int          `db:"id" json:"id"`
+    AccountID            int          `db:"account_id" json:"accountId"`
+    ExternalID           string       `db:"external_id" json:"externalId"`
+    BookingDateTime      time.Time    `db:"booking_date_time" json:"bookingDateTime"`
+    ValueDateTime        *time.Time   `db:"value_date_time" json:"valueDateTime,omitempty"`
+    Amount               money.Amount `db:"amount" json:"amount"`
+    Currency             string       `db:"currency" json:"currency"`
+    Description          *string      `db:"description" json:"description,omitempty"`
+    CreditDebitIndicator *string      `db:"credit_debit_indicator" json:"creditDebitIndicator,omitempty"`
+    CounterpartyName     *string      `db:"counterparty_name" json:"counterpartyName,omitempty"`
+    CounterpartyAccount  *string      `db:"counterparty_account" json:"counterpartyAccount,omitempty"`
+    Category             *string      `db:"category" json:"category,omitempty"`
+    CategorySource       *string      `db:"category_source" json:"categorySource,omitempty"`
+    IsSalary             bool         `db:"is_salary" json:"isSalary"`
+    CreatedAt            time.Time    `db:"created_at" json:"createdAt"`
 }
 
 type TransactionFilter struct {
@@ -35,30 +40,49 @@ type TransactionsResponse struct {
 }
 
 type SalaryDetection struct {
-    TransactionID    int     `json:"transactionId"`
-    Date            string  `json:"date"`
-    Amount          float64 `json:"amount"`
-    Counterparty    string  `json:"counterparty"`
-    AccountID       int     `json:"accountId"`
-    Confidence      string  `json:"confidence"`
-    AutoSelected    bool    `json:"autoSelected"`
+    TransactionID int          `json:"transactionId"`
+    Date          string       `json:"date"`
+    Amount        money.Amount `json:"amount"`
+    Currency      string       `json:"currency"`
+    Counterparty  string       `json:"counterparty"`
+    AccountID     int          `json:"accountId"`
+    Confidence    string       `json:"confidence"`
+    AutoSelected  bool         `json:"autoSelected"`
+    // Schedule is monthly, semi-monthly or irregular for the employer
+    Schedule      string       `json:"schedule"`
+    // PayType tells an advance from the main salary in a semi-monthly schedule
+    PayType       string       `json:"payType,omitempty"`
+    PayDay        int          `json:"payDay"`
+    Reason        string       `json:"reason"`
 }
 
+const (
+    SalaryScheduleMonthly     = "monthly"
+    SalaryScheduleSemiMonthly = "semi-monthly"
+    SalaryScheduleIrregular   = "irregular"
+
+    PayTypeSalary  = "salary"
+    PayTypeAdvance = "advance"
+)
+
 type ConfirmSalariesRequest struct {
     SalaryTransactionIDs []int `json:"salaryTransactionIds" validate:"required,min=1"`
 }
 
+// SalaryAnalysis amounts are in the base currency of the user
 type SalaryAnalysis struct {
-    AvgSalary       float64      `json:"avgSalary"`
-    AvgExpenses     float64      `json:"avgExpenses"`
-    SavingsCapacity float64      `json:"savingsCapacity"`
+    Currency        string       `json:"currency"`
+    AvgSalary       money.Amount `json:"avgSalary"`
+    AvgExpenses     money.Amount `json:"avgExpenses"`
+    SavingsCapacity money.Amount `json:"savingsCapacity"`
     SalaryDates     []int        `json:"salaryDates"`
     Analysis        AnalysisData `json:"analysis"`
 }
 
 type AnalysisData struct {
-    TotalIncome    float64 `json:"totalIncome"`
-    TotalExpenses  float64 `json:"totalExpenses"`
-    PeriodMonths   int     `json:"periodMonths"`
+    TotalIncome        money.Amount            `json:"totalIncome"`
+    TotalExpenses      money.Amount            `json:"totalExpenses"`
+    ExpensesByCategory map[string]money.Amount `json:"expensesByCategory"`
+    PeriodMonths       int                     `json:"periodMonths"`
 }
 
@@ -4,19 +4,30 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"time"
+
+	"github.com/KotovBoris/AutoSave/backend/pkg/money"
 )
 
 type User struct {
-	ID               int       `db:"id" json:"id"`
-	Email            string    `db:"email" json:"email"`
-	PasswordHash     string    `db:"password_hash" json:"-"`
-	AvgSalary        *float64  `db:"avg_salary" json:"avgSalary"`
-	AvgExpenses      *float64  `db:"avg_expenses" json:"avgExpenses"`
-	SavingsCapacity  *float64  `db:"savings_capacity" json:"savingsCapacity"`
-	SalaryDates      IntArray  `db:"salary_dates" json:"salaryDates"`
-	AutopilotEnabled bool      `db:"autopilot_enabled" json:"autopilotEnabled"`
-	CreatedAt        time.Time `db:"created_at" json:"createdAt"`
-	UpdatedAt        time.Time `db:"updated_at" json:"updatedAt"`
+	ID               int           `db:"id" json:"id"`
+	Email            string        `db:"email" json:"email"`
+	PasswordHash     string        `db:"password_hash" json:"-"`
+	AvgSalary        *money.Amount `db:"avg_salary" json:"avgSalary"`
+	AvgExpenses      *money.Amount `db:"avg_expenses" json:"avgExpenses"`
+	SavingsCapacity  *money.Amount `db:"savings_capacity" json:"savingsCapacity"`
+	SalaryDates      IntArray      `db:"salary_dates" json:"salaryDates"`
+	AutopilotEnabled bool          `db:"autopilot_enabled" json:"autopilotEnabled"`
+	BaseCurrency     string        `db:"base_currency" json:"baseCurrency"`
+	EmailVerifiedAt  *time.Time    `db:"email_verified_at" json:"emailVerifiedAt"`
+	FailedLogins     int           `db:"failed_logins" json:"-"`
+	LockedUntil      *time.Time    `db:"locked_until" json:"-"`
+	CreatedAt        time.Time     `db:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time     `db:"updated_at" json:"updatedAt"`
+}
+
+// EmailVerified reports whether the user followed the verification link
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // IntArray for PostgreSQL integer[] type
@@ -101,8 +112,10 @@ func splitComma(s string) []string {
 }
 
 type UserRegistration struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email        string `json:"email" validate:"required,email"`
+	Password     string `json:"password" validate:"required,min=6"`
+	// BaseCurrency defaults to the configured currency
+	BaseCurrency string `json:"baseCurrency,omitempty" validate:"omitempty,currency"`
 }
 
 type UserLogin struct {
@@ -111,14 +124,16 @@ type UserLogin struct {
 }
 
 type UserResponse struct {
-	ID               int       `json:"id"`
-	Email            string    `json:"email"`
-	AvgSalary        *float64  `json:"avgSalary"`
-	AvgExpenses      *float64  `json:"avgExpenses"`
-	SavingsCapacity  *float64  `json:"savingsCapacity"`
-	SalaryDates      []int     `json:"salaryDates"`
-	AutopilotEnabled bool      `json:"autopilotEnabled"`
-	CreatedAt        time.Time `json:"createdAt"`
+	ID               int           `json:"id"`
+	Email            string        `json:"email"`
+	AvgSalary        *money.Amount `json:"avgSalary"`
+	AvgExpenses      *money.Amount `json:"avgExpenses"`
+	SavingsCapacity  *money.Amount `json:"savingsCapacity"`
+	SalaryDates      []int         `json:"salaryDates"`
+	AutopilotEnabled bool          `json:"autopilotEnabled"`
+	BaseCurrency     string        `json:"baseCurrency"`
+	EmailVerified    bool          `json:"emailVerified"`
+	CreatedAt        time.Time     `json:"createdAt"`
 }
 
 func (u *User) ToResponse() UserResponse {
@@ -135,7 +150,39 @@ func (u *User) ToResponse() UserResponse {
 		SavingsCapacity:  u.SavingsCapacity,
 		SalaryDates:      salaryDates,
 		AutopilotEnabled: u.AutopilotEnabled,
+		BaseCurrency:     u.BaseCurrency,
+		EmailVerified:    u.EmailVerified(),
 		CreatedAt:        u.CreatedAt,
 	}
 }
 
+// UserToken is a single-use token mailed to a user, stored by hash only
+type UserToken struct {
+	ID        int              `db:"id"`
+	UserID    int              `db:"user_id"`
+	Purpose   UserTokenPurpose `db:"purpose"`
+	TokenHash string           `db:"token_hash"`
+	ExpiresAt time.Time        `db:"expires_at"`
+	UsedAt    *time.Time       `db:"used_at"`
+	CreatedAt time.Time        `db:"created_at"`
+}
+
+type UserTokenPurpose string
+
+const (
+	UserTokenVerifyEmail   UserTokenPurpose = "verify_email"
+	UserTokenResetPassword UserTokenPurpose = "reset_password"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
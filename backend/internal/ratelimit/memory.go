@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+    "context"
+    "math"
+    "sync"
+    "time"
+)
+
+// sweepEvery requests the memory limiter drops buckets that refilled
+const sweepEvery = 1000
+
+type bucket struct {
+    tokens float64
+    last   time.Time
+    full   time.Time
+}
+
+// MemoryLimiter keeps buckets in process memory
+type MemoryLimiter struct {
+    mu      sync.Mutex
+    buckets map[string]*bucket
+    calls   int
+    now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+    return &MemoryLimiter{
+        buckets: map[string]*bucket{},
+        now:     time.Now,
+    }
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := l.now()
+    l.calls++
+    if l.calls%sweepEvery == 0 {
+        l.sweep(now)
+    }
+
+    b, ok := l.buckets[key]
+    if !ok {
+        b = &bucket{tokens: float64(rule.Limit), last: now}
+        l.buckets[key] = b
+    }
+
+    rate := rule.rate()
+    b.tokens = math.Min(float64(rule.Limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+    b.last = now
+
+    var result Result
+    if b.tokens >= 1 {
+        b.tokens--
+        result = Result{Allowed: true, Remaining: int(b.tokens)}
+    } else {
+        result = Result{RetryAfter: time.Duration((1 - b.tokens) / rate * float64(time.Second))}
+    }
+
+    // A bucket left alone until full is the same as no bucket
+    b.full = now.Add(time.Duration((float64(rule.Limit) - b.tokens) / rate * float64(time.Second)))
+
+    return result, nil
+}
+
+func (l *MemoryLimiter) sweep(now time.Time) {
+    for key, b := range l.buckets {
+        if !now.Before(b.full) {
+            delete(l.buckets, key)
+        }
+    }
+}
@@ -0,0 +1,71 @@
+// Package ratelimit limits how often a key, such as an IP address or a
+// user, may do something. Limits are token buckets: a bucket holds up to
+// Limit tokens, refills evenly over Per and every request takes one.
+package ratelimit
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Rule lets Limit requests through per Per, in bursts of up to Limit
+type Rule struct {
+    Limit int
+    Per   time.Duration
+}
+
+// ParseRule reads a rule written as "10/1m"
+func ParseRule(s string) (Rule, error) {
+    limitStr, perStr, ok := strings.Cut(strings.TrimSpace(s), "/")
+    if !ok {
+        return Rule{}, fmt.Errorf("invalid rate limit %q, want <limit>/<duration>", s)
+    }
+
+    limit, err := strconv.Atoi(limitStr)
+    if err != nil || limit < 1 {
+        return Rule{}, fmt.Errorf("invalid rate limit %q: limit must be a positive number", s)
+    }
+
+    per, err := time.ParseDuration(perStr)
+    if err != nil || per <= 0 {
+        return Rule{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+    }
+
+    return Rule{Limit: limit, Per: per}, nil
+}
+
+
+// rate is how many tokens a bucket gains per second
+func (r Rule) rate() float64 {
+    return float64(r.Limit) / r.Per.Seconds()
+}
+
+// Result is the outcome of one request against a bucket
+type Result struct {
+    Allowed bool
+    // Remaining is how many more requests would pass right now
+    Remaining int
+    // RetryAfter is how long a refused request has to wait
+    RetryAfter time.Duration
+}
+
+// Limiter keeps the buckets. The memory limiter suits a single instance,
+// the Redis one shares limits between instances.
+type Limiter interface {
+    Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// New creates the limiter for store, memory or redis
+func New(store, redisURL string) (Limiter, error) {
+    switch store {
+    case "memory", "":
+        return NewMemoryLimiter(), nil
+    case "redis":
+        return NewRedisLimiter(redisURL)
+    default:
+        return nil, fmt.Errorf("unknown rate limit store %q", store)
+    }
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestParseRule(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    Rule
+        wantErr bool
+    }{
+        {"10/1m", Rule{Limit: 10, Per: time.Minute}, false},
+        {" 5/30s ", Rule{Limit: 5, Per: 30 * time.Second}, false},
+        {"1/1h", Rule{Limit: 1, Per: time.Hour}, false},
+        {"10", Rule{}, true},
+        {"10/", Rule{}, true},
+        {"/1m", Rule{}, true},
+        {"0/1m", Rule{}, true},
+        {"-1/1m", Rule{}, true},
+        {"ten/1m", Rule{}, true},
+        {"10/0s", Rule{}, true},
+        {"10/-1m", Rule{}, true},
+        {"10/minute", Rule{}, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.in, func(t *testing.T) {
+            got, err := ParseRule(tt.in)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("ParseRule(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("ParseRule(%q) = %+v, want %+v", tt.in, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMemoryLimiter(t *testing.T) {
+    rule := Rule{Limit: 3, Per: 3 * time.Second}
+
+    // Each step advances the clock by wait, then makes a request
+    type step struct {
+        wait          time.Duration
+        wantAllowed   bool
+        wantRemaining int
+        wantRetry     time.Duration
+    }
+
+    tests := []struct {
+        name  string
+        steps []step
+    }{
+        {
+            name: "burst up to the limit",
+            steps: []step{
+                {0, true, 2, 0},
+                {0, true, 1, 0},
+                {0, true, 0, 0},
+                {0, false, 0, time.Second},
+            },
+        },
+        {
+            name: "refills one token per period share",
+            steps: []step{
+                {0, true, 2, 0},
+                {0, true, 1, 0},
+                {0, true, 0, 0},
+                {500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+                {500 * time.Millisecond, true, 0, 0},
+                {0, false, 0, time.Second},
+            },
+        },
+        {
+            name: "refill stops at the limit",
+            steps: []step{
+                {0, true, 2, 0},
+                {time.Hour, true, 2, 0},
+                {0, true, 1, 0},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            now := time.Unix(1700000000, 0)
+            limiter := NewMemoryLimiter()
+            limiter.now = func() time.Time { return now }
+
+            for i, s := range tt.steps {
+                now = now.Add(s.wait)
+                got, err := limiter.Allow(context.Background(), "key", rule)
+                if err != nil {
+                    t.Fatalf("step %d: %v", i, err)
+                }
+                if got.Allowed != s.wantAllowed || got.Remaining != s.wantRemaining {
+                    t.Fatalf("step %d: allowed %v remaining %d, want %v %d", i, got.Allowed, got.Remaining, s.wantAllowed, s.wantRemaining)
+                }
+                if diff := got.RetryAfter - s.wantRetry; diff < -time.Millisecond || diff > time.Millisecond {
+                    t.Fatalf("step %d: retry after %v, want %v", i, got.RetryAfter, s.wantRetry)
+                }
+            }
+        })
+    }
+}
+
+func TestMemoryLimiterKeys(t *testing.T) {
+    limiter := NewMemoryLimiter()
+    rule := Rule{Limit: 1, Per: time.Minute}
+    ctx := context.Background()
+
+    if got, _ := limiter.Allow(ctx, "a", rule); !got.Allowed {
+        t.Fatal("first request of a refused")
+    }
+    if got, _ := limiter.Allow(ctx, "a", rule); got.Allowed {
+        t.Fatal("second request of a allowed")
+    }
+    if got, _ := limiter.Allow(ctx, "b", rule); !got.Allowed {
+        t.Fatal("b limited by a's bucket")
+    }
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+    now := time.Unix(1700000000, 0)
+    limiter := NewMemoryLimiter()
+    limiter.now = func() time.Time { return now }
+    rule := Rule{Limit: 2, Per: time.Second}
+    ctx := context.Background()
+
+    limiter.Allow(ctx, "idle", rule)
+
+    // Once the idle bucket refilled it is dropped on the next sweep
+    now = now.Add(time.Second)
+    for i := 1; i < sweepEvery; i++ {
+        limiter.Allow(ctx, "busy", rule)
+    }
+
+    if _, ok := limiter.buckets["idle"]; ok {
+        t.Error("refilled bucket kept after sweep")
+    }
+    if _, ok := limiter.buckets["busy"]; !ok {
+        t.Error("bucket in use dropped by sweep")
+    }
+}
+
+func TestNew(t *testing.T) {
+    tests := []struct {
+        store   string
+        wantErr bool
+    }{
+        {"", false},
+        {"memory", false},
+        {"memcached", true},
+    }
+
+    for _, tt := range tests {
+        _, err := New(tt.store, "")
+        if (err != nil) != tt.wantErr {
+            t.Errorf("New(%q) error = %v, want error %v", tt.store, err, tt.wantErr)
+        }
+    }
+}
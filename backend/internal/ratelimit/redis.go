@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// tokenBucket updates one bucket atomically. Time comes from the Redis
+// server so instances with skewed clocks agree. Returns whether the request
+// is allowed, the wait in milliseconds and the tokens left.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+else
+    wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+
+return {allowed, wait, math.floor(tokens)}
+`)
+
+// RedisLimiter keeps buckets in Redis, shared by every API instance
+type RedisLimiter struct {
+    client *redis.Client
+}
+
+func NewRedisLimiter(redisURL string) (*RedisLimiter, error) {
+    opts, err := redis.ParseURL(redisURL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid redis url: %w", err)
+    }
+    return &RedisLimiter{client: redis.NewClient(opts)}, nil
+}
+
+// Ping checks that Redis is reachable
+func (l *RedisLimiter) Ping(ctx context.Context) error {
+    return l.client.Ping(ctx).Err()
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+    // The script works in milliseconds
+    rate := rule.rate() / 1000
+
+    values, err := tokenBucket.Run(ctx, l.client, []string{"ratelimit:" + key}, rule.Limit, rate).Int64Slice()
+    if err != nil {
+        return Result{}, fmt.Errorf("failed to check rate limit: %w", err)
+    }
+    if len(values) != 3 {
+        return Result{}, fmt.Errorf("failed to check rate limit: unexpected reply %v", values)
+    }
+
+    return Result{
+        Allowed:    values[0] == 1,
+        RetryAfter: time.Duration(values[1]) * time.Millisecond,
+        Remaining:  int(values[2]),
+    }, nil
+}
+
+func (l *RedisLimiter) Close() error {
+    return l.client.Close()
+}
@@ -5,8 +5,10 @@ import (
     "database/sql"
     "fmt"
     "strings"
+    "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/jmoiron/sqlx"
 )
 
@@ -97,7 +99,7 @@ func (r *accountRepository) GetByID(ctx context.Context, id int) (*models.Accoun
     err := r.db.GetContext(ctx, &account, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("account not found")
+            return nil, fmt.Errorf("account %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get account: %w", err)
     }
@@ -175,7 +177,7 @@ func (r *accountRepository) Update(ctx context.Context, account *models.Account)
     return nil
 }
 
-func (r *accountRepository) UpdateBalance(ctx context.Context, id int, balance float64) error {
+func (r *accountRepository) UpdateBalance(ctx context.Context, id int, balance money.Amount) error {
     query := `UPDATE accounts SET balance = $2, updated_at = NOW() WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query, id, balance)
@@ -186,6 +188,29 @@ func (r *accountRepository) UpdateBalance(ctx context.Context, id int, balance f
     return nil
 }
 
+// UpdateSyncCursor saves the next page to load of a sync window
+func (r *accountRepository) UpdateSyncCursor(ctx context.Context, id int, from, to time.Time, page int) error {
+    query := `UPDATE accounts SET sync_from = $2, sync_to = $3, sync_page = $4 WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, id, from, to, page)
+    if err != nil {
+        return fmt.Errorf("failed to update sync cursor: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *accountRepository) ClearSyncCursor(ctx context.Context, id int) error {
+    query := `UPDATE accounts SET sync_from = NULL, sync_to = NULL, sync_page = NULL WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, id)
+    if err != nil {
+        return fmt.Errorf("failed to clear sync cursor: %w", err)
+    }
+    
+    return nil
+}
+
 func (r *accountRepository) Delete(ctx context.Context, id int) error {
     query := `UPDATE accounts SET is_active = false, updated_at = NOW() WHERE id = $1`
     
@@ -19,7 +19,7 @@ func NewBankRepository(db *sqlx.DB) BankRepository {
 
 func (r *bankRepository) GetAll(ctx context.Context) ([]models.Bank, error) {
     var banks []models.Bank
-    query := `SELECT id, name, api_base_url, deposit_rate, is_active, created_at FROM banks WHERE is_active = true ORDER BY name`
+    query := `SELECT id, name, driver, api_base_url, deposit_rate, is_active, created_at FROM banks WHERE is_active = true ORDER BY name`
     
     err := r.db.SelectContext(ctx, &banks, query)
     if err != nil {
@@ -31,12 +31,12 @@ func (r *bankRepository) GetAll(ctx context.Context) ([]models.Bank, error) {
 
 func (r *bankRepository) GetByID(ctx context.Context, id string) (*models.Bank, error) {
     var bank models.Bank
-    query := `SELECT id, name, api_base_url, deposit_rate, is_active, created_at FROM banks WHERE id = $1`
+    query := `SELECT id, name, driver, api_base_url, deposit_rate, is_active, created_at FROM banks WHERE id = $1`
     
     err := r.db.GetContext(ctx, &bank, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("bank not found")
+            return nil, fmt.Errorf("bank %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get bank: %w", err)
     }
@@ -44,20 +44,46 @@ func (r *bankRepository) GetByID(ctx context.Context, id string) (*models.Bank,
     return &bank, nil
 }
 
+// Upsert saves a bank from the banks config file, keeping user connections
+func (r *bankRepository) Upsert(ctx context.Context, bank *models.Bank) error {
+    query := `
+        INSERT INTO banks (id, name, driver, api_base_url, deposit_rate, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (id) DO UPDATE SET
+            name = EXCLUDED.name,
+            driver = EXCLUDED.driver,
+            api_base_url = EXCLUDED.api_base_url,
+            deposit_rate = EXCLUDED.deposit_rate,
+            is_active = EXCLUDED.is_active
+        RETURNING created_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        bank.ID, bank.Name, bank.Driver, bank.APIBaseURL, bank.DepositRate, bank.IsActive,
+    ).Scan(&bank.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to save bank: %w", err)
+    }
+    
+    return nil
+}
+
 func (r *bankRepository) CreateConnection(ctx context.Context, conn *models.BankConnection) error {
     query := `
         INSERT INTO user_banks (
             user_id, bank_id, external_client_id, bank_token,
             token_expires_at, account_consent_id, product_consent_id,
-            payment_consent_id, connected, connected_at
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
-        RETURNING id, connected_at`
+            payment_consent_id, account_consent_expires_at,
+            product_consent_expires_at, consents_checked_at, status,
+            connected, connected_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'active', $12, NOW())
+        RETURNING id, connected_at, status`
     
     err := r.db.QueryRowxContext(ctx, query,
         conn.UserID, conn.BankID, conn.ExternalClientID, conn.BankToken,
         conn.TokenExpiresAt, conn.AccountConsentID, conn.ProductConsentID,
-        conn.PaymentConsentID, conn.Connected,
-    ).Scan(&conn.ID, &conn.ConnectedAt)
+        conn.PaymentConsentID, conn.AccountConsentExpiresAt,
+        conn.ProductConsentExpiresAt, conn.ConsentsCheckedAt, conn.Connected,
+    ).Scan(&conn.ID, &conn.ConnectedAt, &conn.Status)
     
     if err != nil {
         return fmt.Errorf("failed to create bank connection: %w", err)
@@ -73,6 +99,8 @@ func (r *bankRepository) GetUserConnections(ctx context.Context, userID int) ([]
             ub.id, ub.user_id, ub.bank_id, b.name as bank_name,
             ub.external_client_id, ub.bank_token, ub.token_expires_at,
             ub.account_consent_id, ub.product_consent_id, ub.payment_consent_id,
+            ub.account_consent_expires_at, ub.product_consent_expires_at,
+            ub.consents_checked_at, ub.status,
             ub.connected, ub.connected_at, ub.last_sync_at, ub.error
         FROM user_banks ub
         JOIN banks b ON ub.bank_id = b.id
@@ -94,6 +122,8 @@ func (r *bankRepository) GetConnection(ctx context.Context, userID int, bankID s
             ub.id, ub.user_id, ub.bank_id, b.name as bank_name,
             ub.external_client_id, ub.bank_token, ub.token_expires_at,
             ub.account_consent_id, ub.product_consent_id, ub.payment_consent_id,
+            ub.account_consent_expires_at, ub.product_consent_expires_at,
+            ub.consents_checked_at, ub.status,
             ub.connected, ub.connected_at, ub.last_sync_at, ub.error
         FROM user_banks ub
         JOIN banks b ON ub.bank_id = b.id
@@ -116,14 +146,16 @@ func (r *bankRepository) GetConnectionByID(ctx context.Context, id int) (*models
         SELECT 
             id, user_id, bank_id, external_client_id, bank_token,
             token_expires_at, account_consent_id, product_consent_id,
-            payment_consent_id, connected, connected_at, last_sync_at, error
+            payment_consent_id, account_consent_expires_at,
+            product_consent_expires_at, consents_checked_at, status,
+            connected, connected_at, last_sync_at, error
         FROM user_banks
         WHERE id = $1`
     
     err := r.db.GetContext(ctx, &conn, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("connection not found")
+            return nil, fmt.Errorf("connection %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get connection: %w", err)
     }
@@ -136,13 +168,17 @@ func (r *bankRepository) UpdateConnection(ctx context.Context, conn *models.Bank
         UPDATE user_banks 
         SET bank_token = $2, token_expires_at = $3, 
             account_consent_id = $4, product_consent_id = $5,
-            payment_consent_id = $6, last_sync_at = $7, error = $8
+            payment_consent_id = $6, last_sync_at = $7, error = $8,
+            account_consent_expires_at = $9, product_consent_expires_at = $10,
+            consents_checked_at = $11, status = $12, connected = $13
         WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query,
         conn.ID, conn.BankToken, conn.TokenExpiresAt,
         conn.AccountConsentID, conn.ProductConsentID,
         conn.PaymentConsentID, conn.LastSyncAt, conn.Error,
+        conn.AccountConsentExpiresAt, conn.ProductConsentExpiresAt,
+        conn.ConsentsCheckedAt, conn.Status, conn.Connected,
     )
     
     if err != nil {
@@ -0,0 +1,100 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/jmoiron/sqlx"
+)
+
+type categoryRuleRepository struct {
+    db *sqlx.DB
+}
+
+func NewCategoryRuleRepository(db *sqlx.DB) CategoryRuleRepository {
+    return &categoryRuleRepository{db: db}
+}
+
+func (r *categoryRuleRepository) Create(ctx context.Context, rule *models.CategoryRule) error {
+    query := `
+        INSERT INTO category_rules (
+            user_id, category, counterparty, description_pattern,
+            min_amount, max_amount, account_id, priority
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, updated_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        rule.UserID, rule.Category, rule.Counterparty, rule.DescriptionPattern,
+        rule.MinAmount, rule.MaxAmount, rule.AccountID, rule.Priority,
+    ).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to create category rule: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *categoryRuleRepository) GetByID(ctx context.Context, id int) (*models.CategoryRule, error) {
+    var rule models.CategoryRule
+    query := `SELECT * FROM category_rules WHERE id = $1`
+    
+    err := r.db.GetContext(ctx, &rule, query, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, fmt.Errorf("category rule %w", ErrNotFound)
+        }
+        return nil, fmt.Errorf("failed to get category rule: %w", err)
+    }
+    
+    return &rule, nil
+}
+
+// GetUserRules returns rules in the order they are tried
+func (r *categoryRuleRepository) GetUserRules(ctx context.Context, userID int) ([]models.CategoryRule, error) {
+    var rules []models.CategoryRule
+    query := `
+        SELECT * FROM category_rules
+        WHERE user_id = $1
+        ORDER BY priority DESC, id`
+    
+    err := r.db.SelectContext(ctx, &rules, query, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get category rules: %w", err)
+    }
+    
+    return rules, nil
+}
+
+func (r *categoryRuleRepository) Update(ctx context.Context, rule *models.CategoryRule) error {
+    query := `
+        UPDATE category_rules
+        SET category = $2, counterparty = $3, description_pattern = $4,
+            min_amount = $5, max_amount = $6, account_id = $7, priority = $8
+        WHERE id = $1
+        RETURNING updated_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        rule.ID, rule.Category, rule.Counterparty, rule.DescriptionPattern,
+        rule.MinAmount, rule.MaxAmount, rule.AccountID, rule.Priority,
+    ).Scan(&rule.UpdatedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to update category rule: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *categoryRuleRepository) Delete(ctx context.Context, id int) error {
+    query := `DELETE FROM category_rules WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, id)
+    if err != nil {
+        return fmt.Errorf("failed to delete category rule: %w", err)
+    }
+    
+    return nil
+}
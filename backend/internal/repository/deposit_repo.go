@@ -22,16 +22,16 @@ func (r *depositRepository) Create(ctx context.Context, deposit *models.Deposit)
     query := `
         INSERT INTO deposits (
             goal_id, user_id, bank_id, product_id, agreement_id,
-            amount, rate, term_months, status, opened_at,
-            matures_at, accrued_interest, error
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+            amount, currency, rate, term_months, status, opened_at,
+            matures_at, accrued_interest, error, scheduled_for
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
         RETURNING id, created_at, updated_at`
     
     err := r.db.QueryRowxContext(ctx, query,
         deposit.GoalID, deposit.UserID, deposit.BankID, deposit.ProductID,
-        deposit.AgreementID, deposit.Amount, deposit.Rate, deposit.TermMonths,
-        deposit.Status, deposit.OpenedAt, deposit.MaturesAt,
-        deposit.AccruedInterest, deposit.Error,
+        deposit.AgreementID, deposit.Amount, deposit.Currency, deposit.Rate,
+        deposit.TermMonths, deposit.Status, deposit.OpenedAt, deposit.MaturesAt,
+        deposit.AccruedInterest, deposit.Error, deposit.ScheduledFor,
     ).Scan(&deposit.ID, &deposit.CreatedAt, &deposit.UpdatedAt)
     
     if err != nil {
@@ -41,6 +41,54 @@ func (r *depositRepository) Create(ctx context.Context, deposit *models.Deposit)
     return nil
 }
 
+// ClaimScheduled reserves the autopilot deposit slot for goal and date.
+// Returns false if the slot is already taken by a pending or opened deposit,
+// or by a failed one whose retry is not due at now. A failed deposit that is
+// due is reset to pending for another attempt.
+func (r *depositRepository) ClaimScheduled(ctx context.Context, deposit *models.Deposit, now time.Time) (bool, error) {
+    query := `
+        INSERT INTO deposits (
+            goal_id, user_id, bank_id, amount, currency, rate,
+            term_months, status, scheduled_for, attempts
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', $8, 1)
+        ON CONFLICT (goal_id, scheduled_for) WHERE scheduled_for IS NOT NULL
+        DO UPDATE SET
+            status = 'pending', amount = EXCLUDED.amount,
+            currency = EXCLUDED.currency, rate = EXCLUDED.rate, error = NULL,
+            attempts = deposits.attempts + 1, next_attempt_at = NULL
+        WHERE deposits.status = 'failed' AND deposits.next_attempt_at <= $9
+        RETURNING id, status, attempts, created_at, updated_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        deposit.GoalID, deposit.UserID, deposit.BankID, deposit.Amount,
+        deposit.Currency, deposit.Rate, deposit.TermMonths, deposit.ScheduledFor, now,
+    ).Scan(&deposit.ID, &deposit.Status, &deposit.Attempts, &deposit.CreatedAt, &deposit.UpdatedAt)
+    
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return false, nil
+        }
+        return false, fmt.Errorf("failed to claim scheduled deposit: %w", err)
+    }
+    
+    return true, nil
+}
+
+func (r *depositRepository) GetScheduled(ctx context.Context, goalID int, scheduledFor time.Time) (*models.Deposit, error) {
+    var deposit models.Deposit
+    query := `SELECT * FROM deposits WHERE goal_id = $1 AND scheduled_for = $2`
+    
+    err := r.db.GetContext(ctx, &deposit, query, goalID, scheduledFor)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to get scheduled deposit: %w", err)
+    }
+    
+    return &deposit, nil
+}
+
 func (r *depositRepository) GetByID(ctx context.Context, id int) (*models.Deposit, error) {
     var deposit models.Deposit
     query := `SELECT * FROM deposits WHERE id = $1`
@@ -48,7 +96,7 @@ func (r *depositRepository) GetByID(ctx context.Context, id int) (*models.Deposi
     err := r.db.GetContext(ctx, &deposit, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("deposit not found")
+            return nil, fmt.Errorf("deposit %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get deposit: %w", err)
     }
@@ -119,11 +167,17 @@ func (r *depositRepository) GetActiveDeposits(ctx context.Context, userID int) (
 func (r *depositRepository) Update(ctx context.Context, deposit *models.Deposit) error {
     query := `
         UPDATE deposits 
-        SET status = $2, accrued_interest = $3, error = $4
+        SET status = $2, accrued_interest = $3, error = $4,
+            product_id = $5, agreement_id = $6, rate = $7,
+            term_months = $8, opened_at = $9, matures_at = $10,
+            next_attempt_at = $11
         WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query,
         deposit.ID, deposit.Status, deposit.AccruedInterest, deposit.Error,
+        deposit.ProductID, deposit.AgreementID, deposit.Rate,
+        deposit.TermMonths, deposit.OpenedAt, deposit.MaturesAt,
+        deposit.NextAttemptAt,
     )
     
     if err != nil {
@@ -4,8 +4,10 @@ import (
     "context"
     "database/sql"
     "fmt"
+    "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/jmoiron/sqlx"
 )
 
@@ -21,15 +23,15 @@ func (r *goalRepository) Create(ctx context.Context, goal *models.Goal) error {
     query := `
         INSERT INTO goals (
             user_id, name, target_amount, current_amount,
-            monthly_amount, bank_id, deposit_rate, position,
-            status, next_deposit_date
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            monthly_amount, currency, bank_id, deposit_rate,
+            position, status, next_deposit_date
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
         RETURNING id, created_at, updated_at`
     
     err := r.db.QueryRowxContext(ctx, query,
         goal.UserID, goal.Name, goal.TargetAmount, goal.CurrentAmount,
-        goal.MonthlyAmount, goal.BankID, goal.DepositRate, goal.Position,
-        goal.Status, goal.NextDepositDate,
+        goal.MonthlyAmount, goal.Currency, goal.BankID, goal.DepositRate,
+        goal.Position, goal.Status, goal.NextDepositDate,
     ).Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
     
     if err != nil {
@@ -50,7 +52,7 @@ func (r *goalRepository) GetByID(ctx context.Context, id int) (*models.Goal, err
     err := r.db.GetContext(ctx, &goal, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("goal not found")
+            return nil, fmt.Errorf("goal %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get goal: %w", err)
     }
@@ -137,7 +139,7 @@ func (r *goalRepository) UpdateStatus(ctx context.Context, goalID int, status st
     return nil
 }
 
-func (r *goalRepository) UpdateCurrentAmount(ctx context.Context, goalID int, amount float64) error {
+func (r *goalRepository) UpdateCurrentAmount(ctx context.Context, goalID int, amount money.Amount) error {
     query := `UPDATE goals SET current_amount = $2 WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query, goalID, amount)
@@ -174,3 +176,26 @@ func (r *goalRepository) GetMaxPosition(ctx context.Context, userID int) (int, e
     return 0, nil
 }
 
+
+// GetDueGoals returns active goals of verified autopilot users whose next
+// deposit date is on or before date
+func (r *goalRepository) GetDueGoals(ctx context.Context, date time.Time) ([]models.Goal, error) {
+    var goals []models.Goal
+    query := `
+        SELECT g.*, b.name as bank_name
+        FROM goals g
+        JOIN banks b ON g.bank_id = b.id
+        JOIN users u ON g.user_id = u.id
+        WHERE g.status = 'active'
+          AND u.autopilot_enabled = true
+          AND u.email_verified_at IS NOT NULL
+          AND (g.next_deposit_date IS NULL OR g.next_deposit_date <= $1)
+        ORDER BY g.user_id, g.position`
+    
+    err := r.db.SelectContext(ctx, &goals, query, date)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get due goals: %w", err)
+    }
+    
+    return goals, nil
+}
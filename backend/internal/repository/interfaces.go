@@ -2,33 +2,49 @@ package repository
 
 import (
     "context"
+    "errors"
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/jmoiron/sqlx"
 )
 
+// ErrNotFound is wrapped by lookups of a single row that does not exist,
+// as in "goal not found"
+var ErrNotFound = errors.New("not found")
+
 type Repositories struct {
-    User        UserRepository
-    Bank        BankRepository
-    Account     AccountRepository
-    Transaction TransactionRepository
-    Goal        GoalRepository
-    Deposit     DepositRepository
-    Loan        LoanRepository
-    Operation   OperationRepository
+    User         UserRepository
+    Bank         BankRepository
+    Account      AccountRepository
+    Transaction  TransactionRepository
+    Goal         GoalRepository
+    Deposit      DepositRepository
+    Loan         LoanRepository
+    Operation    OperationRepository
+    CategoryRule CategoryRuleRepository
+    Recurring    RecurringRepository
+    Session      SessionRepository
+    UserToken    UserTokenRepository
+    TOTP         TOTPRepository
 }
 
 func NewRepositories(db *sqlx.DB) *Repositories {
     return &Repositories{
-        User:        NewUserRepository(db),
-        Bank:        NewBankRepository(db),
-        Account:     NewAccountRepository(db),
-        Transaction: NewTransactionRepository(db),
-        Goal:        NewGoalRepository(db),
-        Deposit:     NewDepositRepository(db),
-        Loan:        NewLoanRepository(db),
-        Operation:   NewOperationRepository(db),
+        User:         NewUserRepository(db),
+        Bank:         NewBankRepository(db),
+        Account:      NewAccountRepository(db),
+        Transaction:  NewTransactionRepository(db),
+        Goal:         NewGoalRepository(db),
+        Deposit:      NewDepositRepository(db),
+        Loan:         NewLoanRepository(db),
+        Operation:    NewOperationRepository(db),
+        CategoryRule: NewCategoryRuleRepository(db),
+        Recurring:    NewRecurringRepository(db),
+        Session:      NewSessionRepository(db),
+        UserToken:    NewUserTokenRepository(db),
+        TOTP:         NewTOTPRepository(db),
     }
 }
 
@@ -37,13 +53,48 @@ type UserRepository interface {
     GetByID(ctx context.Context, id int) (*models.User, error)
     GetByEmail(ctx context.Context, email string) (*models.User, error)
     Update(ctx context.Context, user *models.User) error
-    UpdateFinancialProfile(ctx context.Context, userID int, avgSalary, avgExpenses, savingsCapacity float64, salaryDates []int) error
+    UpdateFinancialProfile(ctx context.Context, userID int, avgSalary, avgExpenses, savingsCapacity money.Amount, salaryDates []int) error
     UpdateAutopilot(ctx context.Context, userID int, enabled bool) error
+    MarkEmailVerified(ctx context.Context, userID int) error
+    UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+    RecordFailedLogin(ctx context.Context, userID int) (int, error)
+    LockUntil(ctx context.Context, userID int, until time.Time) error
+    ResetFailedLogins(ctx context.Context, userID int) error
+}
+
+type SessionRepository interface {
+    Create(ctx context.Context, session *models.Session) error
+    GetByID(ctx context.Context, id int) (*models.Session, error)
+    Touch(ctx context.Context, id int) error
+    Revoke(ctx context.Context, id int, reason models.SessionRevokeReason) error
+    RevokeUserSessions(ctx context.Context, userID int, reason models.SessionRevokeReason) error
+    CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+    GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+    UseRefreshToken(ctx context.Context, id int) (bool, error)
+}
+
+type UserTokenRepository interface {
+    Create(ctx context.Context, token *models.UserToken) error
+    GetByHash(ctx context.Context, tokenHash string) (*models.UserToken, error)
+    Use(ctx context.Context, id int) (bool, error)
+    InvalidateUserTokens(ctx context.Context, userID int, purpose models.UserTokenPurpose) error
+}
+
+type TOTPRepository interface {
+    Get(ctx context.Context, userID int) (*models.UserTOTP, error)
+    SavePending(ctx context.Context, userID int, secret string) error
+    Confirm(ctx context.Context, userID int) error
+    Delete(ctx context.Context, userID int) error
+    UseStep(ctx context.Context, userID int, step int64) (bool, error)
+    ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+    UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+    CountRecoveryCodes(ctx context.Context, userID int) (int, error)
 }
 
 type BankRepository interface {
     GetAll(ctx context.Context) ([]models.Bank, error)
     GetByID(ctx context.Context, id string) (*models.Bank, error)
+    Upsert(ctx context.Context, bank *models.Bank) error
     CreateConnection(ctx context.Context, conn *models.BankConnection) error
     GetUserConnections(ctx context.Context, userID int) ([]models.BankConnection, error)
     GetConnection(ctx context.Context, userID int, bankID string) (*models.BankConnection, error)
@@ -60,7 +111,9 @@ type AccountRepository interface {
     GetUserAccounts(ctx context.Context, userID int) ([]models.Account, error)
     GetBankAccounts(ctx context.Context, userID int, bankID string) ([]models.Account, error)
     Update(ctx context.Context, account *models.Account) error
-    UpdateBalance(ctx context.Context, id int, balance float64) error
+    UpdateBalance(ctx context.Context, id int, balance money.Amount) error
+    UpdateSyncCursor(ctx context.Context, id int, from, to time.Time, page int) error
+    ClearSyncCursor(ctx context.Context, id int) error
     Delete(ctx context.Context, id int) error
 }
 
@@ -72,8 +125,12 @@ type TransactionRepository interface {
     GetAccountTransactions(ctx context.Context, filter models.TransactionFilter) ([]models.Transaction, error)
     GetUserTransactions(ctx context.Context, userID int, fromDate, toDate time.Time) ([]models.Transaction, error)
     GetSalaryTransactions(ctx context.Context, userID int) ([]models.Transaction, error)
-    MarkAsSalary(ctx context.Context, transactionIDs []int) error
+    MarkAsSalary(ctx context.Context, userID int, transactionIDs []int) error
+    UpdateCategory(ctx context.Context, id int, category, source *string) error
+    Summarize(ctx context.Context, userID int, from, to time.Time, groupBy models.SummaryGroupBy) ([]models.SummaryBucket, error)
+    CounterpartyTotals(ctx context.Context, userID int, from, to time.Time, groupBy models.SummaryGroupBy) ([]models.CounterpartyTotal, error)
     CountAccountTransactions(ctx context.Context, accountID int) (int, error)
+    GetLatestBookingTime(ctx context.Context, accountID int) (*time.Time, error)
 }
 
 type GoalRepository interface {
@@ -84,13 +141,16 @@ type GoalRepository interface {
     Update(ctx context.Context, goal *models.Goal) error
     UpdatePosition(ctx context.Context, goalID int, position int) error
     UpdateStatus(ctx context.Context, goalID int, status string) error
-    UpdateCurrentAmount(ctx context.Context, goalID int, amount float64) error
+    UpdateCurrentAmount(ctx context.Context, goalID int, amount money.Amount) error
     Delete(ctx context.Context, id int) error
     GetMaxPosition(ctx context.Context, userID int) (int, error)
+    GetDueGoals(ctx context.Context, date time.Time) ([]models.Goal, error)
 }
 
 type DepositRepository interface {
     Create(ctx context.Context, deposit *models.Deposit) error
+    ClaimScheduled(ctx context.Context, deposit *models.Deposit, now time.Time) (bool, error)
+    GetScheduled(ctx context.Context, goalID int, scheduledFor time.Time) (*models.Deposit, error)
     GetByID(ctx context.Context, id int) (*models.Deposit, error)
     GetByAgreementID(ctx context.Context, agreementID string) (*models.Deposit, error)
     GetGoalDeposits(ctx context.Context, goalID int) ([]models.Deposit, error)
@@ -107,13 +167,34 @@ type LoanRepository interface {
     GetUserLoans(ctx context.Context, userID int) ([]models.Loan, error)
     GetActiveLoans(ctx context.Context, userID int) ([]models.Loan, error)
     Update(ctx context.Context, loan *models.Loan) error
-    UpdateDebt(ctx context.Context, id int, currentDebt float64) error
+    UpdateDebt(ctx context.Context, id int, currentDebt money.Amount) error
     UpdateStatus(ctx context.Context, id int, status string) error
     Delete(ctx context.Context, id int) error
     CreatePayment(ctx context.Context, payment *models.LoanPayment) error
     GetPayments(ctx context.Context, loanID int) ([]models.LoanPayment, error)
-    GetScheduledPayments(ctx context.Context, date time.Time) ([]models.LoanPayment, error)
+    GetScheduledPayments(ctx context.Context, date, now time.Time) ([]models.LoanPayment, error)
     UpdatePayment(ctx context.Context, payment *models.LoanPayment) error
+    GetDueAutopayLoans(ctx context.Context, date time.Time) ([]models.Loan, error)
+    CreateScheduledPayment(ctx context.Context, payment *models.LoanPayment) (bool, error)
+    ClaimPayment(ctx context.Context, id int) (bool, error)
+    GetProcessingPayments(ctx context.Context) ([]models.LoanPayment, error)
+    AdvancePaymentDate(ctx context.Context, loanID int, from, to time.Time) error
+}
+
+type CategoryRuleRepository interface {
+    Create(ctx context.Context, rule *models.CategoryRule) error
+    GetByID(ctx context.Context, id int) (*models.CategoryRule, error)
+    GetUserRules(ctx context.Context, userID int) ([]models.CategoryRule, error)
+    Update(ctx context.Context, rule *models.CategoryRule) error
+    Delete(ctx context.Context, id int) error
+}
+
+type RecurringRepository interface {
+    Upsert(ctx context.Context, payment *models.RecurringPayment) error
+    GetByID(ctx context.Context, id int) (*models.RecurringPayment, error)
+    GetUserPayments(ctx context.Context, userID int, status string) ([]models.RecurringPayment, error)
+    GetUpcoming(ctx context.Context, userID int, until time.Time) ([]models.RecurringPayment, error)
+    UpdateStatus(ctx context.Context, id int, status string) error
 }
 
 type OperationRepository interface {
@@ -121,5 +202,6 @@ type OperationRepository interface {
     GetByID(ctx context.Context, id int) (*models.Operation, error)
     GetUserOperations(ctx context.Context, userID int, limit int) ([]models.Operation, error)
     GetByType(ctx context.Context, userID int, operationType string) ([]models.Operation, error)
+    List(ctx context.Context, filter models.OperationFilter) ([]models.Operation, error)
 }
 
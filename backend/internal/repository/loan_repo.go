@@ -7,6 +7,7 @@ import (
     "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/jmoiron/sqlx"
 )
 
@@ -23,8 +24,9 @@ func (r *loanRepository) Create(ctx context.Context, loan *models.Loan) error {
         INSERT INTO loans (
             user_id, name, original_debt, current_debt, rate,
             monthly_payment, autopay_enabled, autopay_bank_id,
-            autopay_day, status, next_payment_date
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+            autopay_day, status, next_payment_date, creditor_account,
+            creditor_name, creditor_bank_code
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
         RETURNING id, created_at, updated_at`
     
     loan.OriginalDebt = loan.CurrentDebt
@@ -34,6 +36,7 @@ func (r *loanRepository) Create(ctx context.Context, loan *models.Loan) error {
         loan.UserID, loan.Name, loan.OriginalDebt, loan.CurrentDebt,
         loan.Rate, loan.MonthlyPayment, loan.AutopayEnabled,
         loan.AutopayBankID, loan.AutopayDay, loan.Status, loan.NextPaymentDate,
+        loan.CreditorAccount, loan.CreditorName, loan.CreditorBankCode,
     ).Scan(&loan.ID, &loan.CreatedAt, &loan.UpdatedAt)
     
     if err != nil {
@@ -54,7 +57,7 @@ func (r *loanRepository) GetByID(ctx context.Context, id int) (*models.Loan, err
     err := r.db.GetContext(ctx, &loan, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("loan not found")
+            return nil, fmt.Errorf("loan %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get loan: %w", err)
     }
@@ -68,7 +71,7 @@ func (r *loanRepository) GetUserLoans(ctx context.Context, userID int) ([]models
         SELECT l.*, b.name as autopay_bank_name
         FROM loans l
         LEFT JOIN banks b ON l.autopay_bank_id = b.id
-        WHERE l.user_id = $1
+        WHERE l.user_id = $1 AND l.status != 'cancelled'
         ORDER BY l.created_at DESC`
     
     err := r.db.SelectContext(ctx, &loans, query, userID)
@@ -100,12 +103,14 @@ func (r *loanRepository) Update(ctx context.Context, loan *models.Loan) error {
     query := `
         UPDATE loans 
         SET name = $2, monthly_payment = $3, autopay_enabled = $4,
-            autopay_bank_id = $5, autopay_day = $6, next_payment_date = $7
+            autopay_bank_id = $5, autopay_day = $6, next_payment_date = $7,
+            creditor_account = $8, creditor_name = $9, creditor_bank_code = $10
         WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query,
         loan.ID, loan.Name, loan.MonthlyPayment, loan.AutopayEnabled,
         loan.AutopayBankID, loan.AutopayDay, loan.NextPaymentDate,
+        loan.CreditorAccount, loan.CreditorName, loan.CreditorBankCode,
     )
     
     if err != nil {
@@ -115,7 +120,7 @@ func (r *loanRepository) Update(ctx context.Context, loan *models.Loan) error {
     return nil
 }
 
-func (r *loanRepository) UpdateDebt(ctx context.Context, id int, currentDebt float64) error {
+func (r *loanRepository) UpdateDebt(ctx context.Context, id int, currentDebt money.Amount) error {
     query := `UPDATE loans SET current_debt = $2 WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query, id, currentDebt)
@@ -189,13 +194,18 @@ func (r *loanRepository) GetPayments(ctx context.Context, loanID int) ([]models.
     return payments, nil
 }
 
-func (r *loanRepository) GetScheduledPayments(ctx context.Context, date time.Time) ([]models.LoanPayment, error) {
+// GetScheduledPayments returns payments of verified users due by date whose
+// retry, if any, is due by now
+func (r *loanRepository) GetScheduledPayments(ctx context.Context, date, now time.Time) ([]models.LoanPayment, error) {
     var payments []models.LoanPayment
     query := `
-        SELECT * FROM loan_payments 
-        WHERE status = 'scheduled' AND scheduled_date <= $1`
+        SELECT p.* FROM loan_payments p
+        JOIN users u ON p.user_id = u.id
+        WHERE p.status = 'scheduled' AND p.scheduled_date <= $1
+          AND (p.next_attempt_at IS NULL OR p.next_attempt_at <= $2)
+          AND u.email_verified_at IS NOT NULL`
     
-    err := r.db.SelectContext(ctx, &payments, query, date)
+    err := r.db.SelectContext(ctx, &payments, query, date, now)
     if err != nil {
         return nil, fmt.Errorf("failed to get scheduled payments: %w", err)
     }
@@ -206,12 +216,14 @@ func (r *loanRepository) GetScheduledPayments(ctx context.Context, date time.Tim
 func (r *loanRepository) UpdatePayment(ctx context.Context, payment *models.LoanPayment) error {
     query := `
         UPDATE loan_payments 
-        SET bank_payment_id = $2, status = $3, completed_at = $4, error = $5
+        SET bank_payment_id = $2, status = $3, completed_at = $4, error = $5,
+            next_attempt_at = $6, attempts = $7, source_account_id = $8
         WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query,
         payment.ID, payment.BankPaymentID, payment.Status,
-        payment.CompletedAt, payment.Error,
+        payment.CompletedAt, payment.Error, payment.NextAttemptAt,
+        payment.Attempts, payment.SourceAccountID,
     )
     
     if err != nil {
@@ -221,3 +233,101 @@ func (r *loanRepository) UpdatePayment(ctx context.Context, payment *models.Loan
     return nil
 }
 
+
+// GetDueAutopayLoans returns active autopay loans of verified users whose
+// payment date is on or before date
+func (r *loanRepository) GetDueAutopayLoans(ctx context.Context, date time.Time) ([]models.Loan, error) {
+    var loans []models.Loan
+    query := `
+        SELECT l.*, b.name as autopay_bank_name
+        FROM loans l
+        LEFT JOIN banks b ON l.autopay_bank_id = b.id
+        JOIN users u ON l.user_id = u.id
+        WHERE l.status = 'active'
+          AND l.autopay_enabled = true
+          AND u.email_verified_at IS NOT NULL
+          AND l.next_payment_date <= $1
+        ORDER BY l.next_payment_date`
+    
+    err := r.db.SelectContext(ctx, &loans, query, date)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get due autopay loans: %w", err)
+    }
+    
+    return loans, nil
+}
+
+// CreateScheduledPayment inserts an autopay payment unless one already exists
+// for the same loan and date. Returns false if it already existed.
+func (r *loanRepository) CreateScheduledPayment(ctx context.Context, payment *models.LoanPayment) (bool, error) {
+    query := `
+        INSERT INTO loan_payments (
+            loan_id, user_id, amount, is_autopay, status, scheduled_date
+        ) VALUES ($1, $2, $3, true, 'scheduled', $4)
+        ON CONFLICT (loan_id, scheduled_date) WHERE is_autopay = true
+        DO NOTHING
+        RETURNING id, created_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        payment.LoanID, payment.UserID, payment.Amount, payment.ScheduledDate,
+    ).Scan(&payment.ID, &payment.CreatedAt)
+    
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return false, nil
+        }
+        return false, fmt.Errorf("failed to schedule payment: %w", err)
+    }
+    
+    return true, nil
+}
+
+// ClaimPayment moves a scheduled payment to processing and counts the attempt.
+// Returns false if another run already picked it up.
+func (r *loanRepository) ClaimPayment(ctx context.Context, id int) (bool, error) {
+    query := `
+        UPDATE loan_payments SET status = 'processing', attempts = attempts + 1
+        WHERE id = $1 AND status = 'scheduled'`
+    
+    result, err := r.db.ExecContext(ctx, query, id)
+    if err != nil {
+        return false, fmt.Errorf("failed to claim payment: %w", err)
+    }
+    
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to claim payment: %w", err)
+    }
+    
+    return rows == 1, nil
+}
+
+// GetProcessingPayments returns claimed payments whose final status is unknown,
+// including those whose bank response was never saved
+func (r *loanRepository) GetProcessingPayments(ctx context.Context) ([]models.LoanPayment, error) {
+    var payments []models.LoanPayment
+    query := `
+        SELECT * FROM loan_payments 
+        WHERE status = 'processing'
+        ORDER BY scheduled_date`
+    
+    err := r.db.SelectContext(ctx, &payments, query)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get processing payments: %w", err)
+    }
+    
+    return payments, nil
+}
+
+// AdvancePaymentDate moves a loan's next payment date from one date to the
+// next. Does nothing if the date was already moved, by another run or the user.
+func (r *loanRepository) AdvancePaymentDate(ctx context.Context, loanID int, from, to time.Time) error {
+    query := `UPDATE loans SET next_payment_date = $3 WHERE id = $1 AND next_payment_date = $2::date`
+    
+    _, err := r.db.ExecContext(ctx, query, loanID, from, to)
+    if err != nil {
+        return fmt.Errorf("failed to advance payment date: %w", err)
+    }
+    
+    return nil
+}
@@ -46,7 +46,7 @@ func (r *operationRepository) GetByID(ctx context.Context, id int) (*models.Oper
     err := r.db.GetContext(ctx, &operation, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("operation not found")
+            return nil, fmt.Errorf("operation %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get operation: %w", err)
     }
@@ -85,3 +85,70 @@ func (r *operationRepository) GetByType(ctx context.Context, userID int, operati
     return operations, nil
 }
 
+// List returns user operations newest first. BeforeID continues a previous page.
+func (r *operationRepository) List(ctx context.Context, filter models.OperationFilter) ([]models.Operation, error) {
+    var operations []models.Operation
+    var args []interface{}
+    
+    query := `SELECT * FROM operations WHERE user_id = $1`
+    args = append(args, filter.UserID)
+    
+    argCount := 1
+    
+    if filter.Type != nil {
+        argCount++
+        query += fmt.Sprintf(" AND type = $%d", argCount)
+        args = append(args, *filter.Type)
+    }
+    
+    if filter.Status != nil {
+        argCount++
+        query += fmt.Sprintf(" AND status = $%d", argCount)
+        args = append(args, *filter.Status)
+    }
+    
+    if filter.FromDate != nil {
+        argCount++
+        query += fmt.Sprintf(" AND created_at >= $%d", argCount)
+        args = append(args, *filter.FromDate)
+    }
+    
+    if filter.ToDate != nil {
+        argCount++
+        query += fmt.Sprintf(" AND created_at <= $%d", argCount)
+        args = append(args, *filter.ToDate)
+    }
+    
+    if filter.GoalID != nil {
+        argCount++
+        query += fmt.Sprintf(" AND related_goal_id = $%d", argCount)
+        args = append(args, *filter.GoalID)
+    }
+    
+    if filter.LoanID != nil {
+        argCount++
+        query += fmt.Sprintf(" AND related_loan_id = $%d", argCount)
+        args = append(args, *filter.LoanID)
+    }
+    
+    if filter.BeforeID > 0 {
+        argCount++
+        query += fmt.Sprintf(" AND id < $%d", argCount)
+        args = append(args, filter.BeforeID)
+    }
+    
+    query += " ORDER BY id DESC"
+    
+    if filter.Limit > 0 {
+        argCount++
+        query += fmt.Sprintf(" LIMIT $%d", argCount)
+        args = append(args, filter.Limit)
+    }
+    
+    err := r.db.SelectContext(ctx, &operations, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list operations: %w", err)
+    }
+    
+    return operations, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/jmoiron/sqlx"
+)
+
+type recurringRepository struct {
+    db *sqlx.DB
+}
+
+func NewRecurringRepository(db *sqlx.DB) RecurringRepository {
+    return &recurringRepository{db: db}
+}
+
+// Upsert saves a detection. A payment detected again keeps the status the
+// user gave it, only its amounts and dates are refreshed.
+func (r *recurringRepository) Upsert(ctx context.Context, payment *models.RecurringPayment) error {
+    query := `
+        INSERT INTO recurring_payments (
+            user_id, counterparty, account_id, category, kind, period,
+            avg_amount, last_amount, occurrences, confidence,
+            last_paid_at, next_due_date
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (user_id, counterparty, period) DO UPDATE
+        SET account_id = EXCLUDED.account_id,
+            category = EXCLUDED.category,
+            kind = EXCLUDED.kind,
+            avg_amount = EXCLUDED.avg_amount,
+            last_amount = EXCLUDED.last_amount,
+            occurrences = EXCLUDED.occurrences,
+            confidence = EXCLUDED.confidence,
+            last_paid_at = EXCLUDED.last_paid_at,
+            next_due_date = EXCLUDED.next_due_date
+        RETURNING id, status, created_at, updated_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        payment.UserID, payment.Counterparty, payment.AccountID, payment.Category,
+        payment.Kind, payment.Period, payment.AvgAmount, payment.LastAmount,
+        payment.Occurrences, payment.Confidence, payment.LastPaidAt, payment.NextDueDate,
+    ).Scan(&payment.ID, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to save recurring payment: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *recurringRepository) GetByID(ctx context.Context, id int) (*models.RecurringPayment, error) {
+    var payment models.RecurringPayment
+    query := `SELECT * FROM recurring_payments WHERE id = $1`
+    
+    err := r.db.GetContext(ctx, &payment, query, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, fmt.Errorf("recurring payment %w", ErrNotFound)
+        }
+        return nil, fmt.Errorf("failed to get recurring payment: %w", err)
+    }
+    
+    return &payment, nil
+}
+
+// GetUserPayments returns payments with status, or all but dismissed ones
+// when status is empty
+func (r *recurringRepository) GetUserPayments(ctx context.Context, userID int, status string) ([]models.RecurringPayment, error) {
+    var payments []models.RecurringPayment
+    var err error
+    
+    if status == "" {
+        query := `
+            SELECT * FROM recurring_payments
+            WHERE user_id = $1 AND status != 'dismissed'
+            ORDER BY next_due_date`
+        err = r.db.SelectContext(ctx, &payments, query, userID)
+    } else {
+        query := `
+            SELECT * FROM recurring_payments
+            WHERE user_id = $1 AND status = $2
+            ORDER BY next_due_date`
+        err = r.db.SelectContext(ctx, &payments, query, userID, status)
+    }
+    
+    if err != nil {
+        return nil, fmt.Errorf("failed to get recurring payments: %w", err)
+    }
+    
+    return payments, nil
+}
+
+// GetUpcoming returns payments that are not dismissed and due up to until
+func (r *recurringRepository) GetUpcoming(ctx context.Context, userID int, until time.Time) ([]models.RecurringPayment, error) {
+    var payments []models.RecurringPayment
+    query := `
+        SELECT * FROM recurring_payments
+        WHERE user_id = $1 AND status != 'dismissed' AND next_due_date <= $2
+        ORDER BY next_due_date`
+    
+    err := r.db.SelectContext(ctx, &payments, query, userID, until)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get upcoming payments: %w", err)
+    }
+    
+    return payments, nil
+}
+
+func (r *recurringRepository) UpdateStatus(ctx context.Context, id int, status string) error {
+    query := `UPDATE recurring_payments SET status = $2 WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, id, status)
+    if err != nil {
+        return fmt.Errorf("failed to update recurring payment status: %w", err)
+    }
+    
+    return nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/jmoiron/sqlx"
+)
+
+type sessionRepository struct {
+    db *sqlx.DB
+}
+
+func NewSessionRepository(db *sqlx.DB) SessionRepository {
+    return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *models.Session) error {
+    query := `
+        INSERT INTO auth_sessions (user_id, user_agent, ip_address)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, last_used_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        session.UserID, session.UserAgent, session.IPAddress,
+    ).Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to create session: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, id int) (*models.Session, error) {
+    var session models.Session
+    query := `SELECT * FROM auth_sessions WHERE id = $1`
+    
+    err := r.db.GetContext(ctx, &session, query, id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, fmt.Errorf("session %w", ErrNotFound)
+        }
+        return nil, fmt.Errorf("failed to get session: %w", err)
+    }
+    
+    return &session, nil
+}
+
+// Touch records that the session was just refreshed
+func (r *sessionRepository) Touch(ctx context.Context, id int) error {
+    query := `UPDATE auth_sessions SET last_used_at = NOW() WHERE id = $1`
+    
+    if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+        return fmt.Errorf("failed to update session: %w", err)
+    }
+    
+    return nil
+}
+
+// Revoke ends a session. A session already revoked keeps its first reason.
+func (r *sessionRepository) Revoke(ctx context.Context, id int, reason models.SessionRevokeReason) error {
+    query := `
+        UPDATE auth_sessions
+        SET revoked_at = NOW(), revoked_reason = $2
+        WHERE id = $1 AND revoked_at IS NULL`
+    
+    if _, err := r.db.ExecContext(ctx, query, id, reason); err != nil {
+        return fmt.Errorf("failed to revoke session: %w", err)
+    }
+    
+    return nil
+}
+
+// RevokeUserSessions ends every open session of a user
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID int, reason models.SessionRevokeReason) error {
+    query := `
+        UPDATE auth_sessions
+        SET revoked_at = NOW(), revoked_reason = $2
+        WHERE user_id = $1 AND revoked_at IS NULL`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID, reason); err != nil {
+        return fmt.Errorf("failed to revoke user sessions: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *sessionRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+    query := `
+        INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        token.SessionID, token.TokenHash, token.ExpiresAt,
+    ).Scan(&token.ID, &token.CreatedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to create refresh token: %w", err)
+    }
+    
+    return nil
+}
+
+// GetRefreshToken finds a token by hash, nil when there is none
+func (r *sessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+    var token models.RefreshToken
+    query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+    
+    err := r.db.GetContext(ctx, &token, query, tokenHash)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to get refresh token: %w", err)
+    }
+    
+    return &token, nil
+}
+
+// UseRefreshToken marks a token used. Returns false if it already was, so
+// of two concurrent refreshes with one token only one succeeds.
+func (r *sessionRepository) UseRefreshToken(ctx context.Context, id int) (bool, error) {
+    query := `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+    
+    result, err := r.db.ExecContext(ctx, query, id)
+    if err != nil {
+        return false, fmt.Errorf("failed to use refresh token: %w", err)
+    }
+    
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to use refresh token: %w", err)
+    }
+    
+    return rows == 1, nil
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/jmoiron/sqlx"
+)
+
+type totpRepository struct {
+    db *sqlx.DB
+}
+
+func NewTOTPRepository(db *sqlx.DB) TOTPRepository {
+    return &totpRepository{db: db}
+}
+
+// Get returns the enrolment of a user, nil when there is none
+func (r *totpRepository) Get(ctx context.Context, userID int) (*models.UserTOTP, error) {
+    var totp models.UserTOTP
+    query := `SELECT * FROM user_totp WHERE user_id = $1`
+    
+    err := r.db.GetContext(ctx, &totp, query, userID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to get totp: %w", err)
+    }
+    
+    return &totp, nil
+}
+
+// SavePending stores a new unconfirmed secret, replacing an earlier
+// pending one
+func (r *totpRepository) SavePending(ctx context.Context, userID int, secret string) error {
+    query := `
+        INSERT INTO user_totp (user_id, secret)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE
+        SET secret = EXCLUDED.secret, confirmed_at = NULL,
+            last_used_step = 0, created_at = NOW()`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID, secret); err != nil {
+        return fmt.Errorf("failed to save totp: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *totpRepository) Confirm(ctx context.Context, userID int) error {
+    query := `UPDATE user_totp SET confirmed_at = NOW() WHERE user_id = $1`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+        return fmt.Errorf("failed to confirm totp: %w", err)
+    }
+    
+    return nil
+}
+
+// Delete removes the enrolment and its recovery codes
+func (r *totpRepository) Delete(ctx context.Context, userID int) error {
+    tx, err := r.db.BeginTxx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+    
+    if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+        return fmt.Errorf("failed to delete recovery codes: %w", err)
+    }
+    if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+        return fmt.Errorf("failed to delete totp: %w", err)
+    }
+    
+    return tx.Commit()
+}
+
+// UseStep records that the code of step was used. Returns false when that
+// step or a later one was used already, a code cannot be replayed.
+func (r *totpRepository) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+    query := `UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+    
+    result, err := r.db.ExecContext(ctx, query, userID, step)
+    if err != nil {
+        return false, fmt.Errorf("failed to use totp step: %w", err)
+    }
+    
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to use totp step: %w", err)
+    }
+    
+    return rows == 1, nil
+}
+
+// ReplaceRecoveryCodes drops the recovery codes of a user and stores new ones
+func (r *totpRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+    tx, err := r.db.BeginTxx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("failed to begin transaction: %w", err)
+    }
+    defer tx.Rollback()
+    
+    if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+        return fmt.Errorf("failed to delete recovery codes: %w", err)
+    }
+    
+    for _, hash := range codeHashes {
+        query := `INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+        if _, err := tx.ExecContext(ctx, query, userID, hash); err != nil {
+            return fmt.Errorf("failed to create recovery code: %w", err)
+        }
+    }
+    
+    return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused recovery code used, false when there is
+// no such code
+func (r *totpRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+    query := `
+        UPDATE totp_recovery_codes
+        SET used_at = NOW()
+        WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+    
+    result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+    if err != nil {
+        return false, fmt.Errorf("failed to use recovery code: %w", err)
+    }
+    
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to use recovery code: %w", err)
+    }
+    
+    return rows == 1, nil
+}
+
+func (r *totpRepository) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+    var count int
+    query := `SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+    
+    if err := r.db.GetContext(ctx, &count, query, userID); err != nil {
+        return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+    }
+    
+    return count, nil
+}
@@ -25,14 +25,16 @@ func (r *transactionRepository) Create(ctx context.Context, tx *models.Transacti
         INSERT INTO transactions (
             account_id, external_id, booking_date_time, value_date_time,
             amount, currency, description, credit_debit_indicator,
-            counterparty_name, counterparty_account, category, is_salary
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            counterparty_name, counterparty_account, category, category_source,
+            is_salary
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
         RETURNING id, created_at`
     
     err := r.db.QueryRowxContext(ctx, query,
         tx.AccountID, tx.ExternalID, tx.BookingDateTime, tx.ValueDateTime,
         tx.Amount, tx.Currency, tx.Description, tx.CreditDebitIndicator,
-        tx.CounterpartyName, tx.CounterpartyAccount, tx.Category, tx.IsSalary,
+        tx.CounterpartyName, tx.CounterpartyAccount, tx.Category, tx.CategorySource,
+        tx.IsSalary,
     ).Scan(&tx.ID, &tx.CreatedAt)
     
     if err != nil {
@@ -48,19 +50,20 @@ func (r *transactionRepository) CreateBatch(ctx context.Context, transactions []
     }
     
     valueStrings := make([]string, 0, len(transactions))
-    valueArgs := make([]interface{}, 0, len(transactions)*12)
+    valueArgs := make([]interface{}, 0, len(transactions)*13)
     
     for i, tx := range transactions {
         valueStrings = append(valueStrings, fmt.Sprintf(
-            "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-            i*12+1, i*12+2, i*12+3, i*12+4, i*12+5, i*12+6,
-            i*12+7, i*12+8, i*12+9, i*12+10, i*12+11, i*12+12,
+            "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+            i*13+1, i*13+2, i*13+3, i*13+4, i*13+5, i*13+6, i*13+7,
+            i*13+8, i*13+9, i*13+10, i*13+11, i*13+12, i*13+13,
         ))
         
         valueArgs = append(valueArgs,
             tx.AccountID, tx.ExternalID, tx.BookingDateTime, tx.ValueDateTime,
             tx.Amount, tx.Currency, tx.Description, tx.CreditDebitIndicator,
-            tx.CounterpartyName, tx.CounterpartyAccount, tx.Category, tx.IsSalary,
+            tx.CounterpartyName, tx.CounterpartyAccount, tx.Category, tx.CategorySource,
+            tx.IsSalary,
         )
     }
     
@@ -68,7 +71,8 @@ func (r *transactionRepository) CreateBatch(ctx context.Context, transactions []
         INSERT INTO transactions (
             account_id, external_id, booking_date_time, value_date_time,
             amount, currency, description, credit_debit_indicator,
-            counterparty_name, counterparty_account, category, is_salary
+            counterparty_name, counterparty_account, category, category_source,
+            is_salary
         ) VALUES %s
         ON CONFLICT (account_id, external_id) DO NOTHING`,
         strings.Join(valueStrings, ","),
@@ -89,7 +93,7 @@ func (r *transactionRepository) GetByID(ctx context.Context, id int) (*models.Tr
     err := r.db.GetContext(ctx, &tx, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("transaction not found")
+            return nil, fmt.Errorf("transaction %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get transaction: %w", err)
     }
@@ -199,10 +203,15 @@ func (r *transactionRepository) GetSalaryTransactions(ctx context.Context, userI
     return transactions, nil
 }
 
-func (r *transactionRepository) MarkAsSalary(ctx context.Context, transactionIDs []int) error {
-    query := `UPDATE transactions SET is_salary = true WHERE id = ANY($1)`
+// MarkAsSalary marks the user's transactions among transactionIDs as salary,
+// IDs of other users' transactions are ignored
+func (r *transactionRepository) MarkAsSalary(ctx context.Context, userID int, transactionIDs []int) error {
+    query := `
+        UPDATE transactions t SET is_salary = true
+        FROM accounts a
+        WHERE t.account_id = a.id AND a.user_id = $2 AND t.id = ANY($1)`
     
-    _, err := r.db.ExecContext(ctx, query, pq.Array(transactionIDs))
+    _, err := r.db.ExecContext(ctx, query, pq.Array(transactionIDs), userID)
     if err != nil {
         return fmt.Errorf("failed to mark as salary: %w", err)
     }
@@ -210,6 +219,111 @@ func (r *transactionRepository) MarkAsSalary(ctx context.Context, transactionIDs
     return nil
 }
 
+func (r *transactionRepository) UpdateCategory(ctx context.Context, id int, category, source *string) error {
+    query := `UPDATE transactions SET category = $2, category_source = $3 WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, id, category, source)
+    if err != nil {
+        return fmt.Errorf("failed to update transaction category: %w", err)
+    }
+    
+    return nil
+}
+
+// summaryBuckets maps a grouping to its SQL key and label expressions.
+// Queries using them join transactions t with accounts a.
+var summaryBuckets = map[models.SummaryGroupBy][2]string{
+    models.SummaryByMonth: {
+        `to_char(date_trunc('month', t.booking_date_time), 'YYYY-MM')`,
+        `to_char(date_trunc('month', t.booking_date_time), 'YYYY-MM')`,
+    },
+    models.SummaryByCategory: {
+        `COALESCE(NULLIF(t.category, ''), 'other')`,
+        `COALESCE(NULLIF(t.category, ''), 'other')`,
+    },
+    models.SummaryByCounterparty: {
+        `COALESCE(NULLIF(t.counterparty_name, ''), 'Unknown')`,
+        `COALESCE(NULLIF(t.counterparty_name, ''), 'Unknown')`,
+    },
+    models.SummaryByAccount: {
+        `a.id::text`,
+        `COALESCE(NULLIF(a.nickname, ''), a.identification)`,
+    },
+}
+
+// Summarize aggregates user transactions booked between from and to into
+// buckets, one row per bucket and currency. Deposit transfers (category
+// savings) are counted as savings only.
+func (r *transactionRepository) Summarize(ctx context.Context, userID int, from, to time.Time, groupBy models.SummaryGroupBy) ([]models.SummaryBucket, error) {
+    bucket, ok := summaryBuckets[groupBy]
+    if !ok {
+        return nil, fmt.Errorf("unknown grouping %q", groupBy)
+    }
+    
+    var buckets []models.SummaryBucket
+    query := fmt.Sprintf(`
+        SELECT
+            %s AS key,
+            %s AS label,
+            t.currency AS currency,
+            COALESCE(SUM(t.amount) FILTER (WHERE t.amount > 0 AND t.category IS DISTINCT FROM 'savings'), 0) AS income,
+            COALESCE(-SUM(t.amount) FILTER (WHERE t.amount < 0 AND t.category IS DISTINCT FROM 'savings'), 0) AS expenses,
+            COALESCE(-SUM(t.amount) FILTER (WHERE t.category = 'savings'), 0) AS savings,
+            COUNT(*) AS count
+        FROM transactions t
+        JOIN accounts a ON t.account_id = a.id
+        WHERE a.user_id = $1
+          AND t.booking_date_time >= $2
+          AND t.booking_date_time <= $3
+        GROUP BY 1, 2, 3
+        ORDER BY 1`,
+        bucket[0], bucket[1],
+    )
+    
+    err := r.db.SelectContext(ctx, &buckets, query, userID, from, to)
+    if err != nil {
+        return nil, fmt.Errorf("failed to summarize transactions: %w", err)
+    }
+    
+    return buckets, nil
+}
+
+// CounterpartyTotals returns the turnover of every counterparty in each
+// bucket, one row per counterparty and currency
+func (r *transactionRepository) CounterpartyTotals(ctx context.Context, userID int, from, to time.Time, groupBy models.SummaryGroupBy) ([]models.CounterpartyTotal, error) {
+    bucket, ok := summaryBuckets[groupBy]
+    if !ok {
+        return nil, fmt.Errorf("unknown grouping %q", groupBy)
+    }
+    
+    var totals []models.CounterpartyTotal
+    query := fmt.Sprintf(`
+        SELECT
+            %s AS key,
+            COALESCE(NULLIF(t.counterparty_name, ''), 'Unknown') AS counterparty,
+            t.currency AS currency,
+            COALESCE(SUM(t.amount) FILTER (WHERE t.amount > 0), 0) AS income,
+            COALESCE(-SUM(t.amount) FILTER (WHERE t.amount < 0), 0) AS expenses,
+            COUNT(*) AS count
+        FROM transactions t
+        JOIN accounts a ON t.account_id = a.id
+        WHERE a.user_id = $1
+          AND t.booking_date_time >= $2
+          AND t.booking_date_time <= $3
+          AND t.category IS DISTINCT FROM 'savings'
+        GROUP BY 1, 2, 3
+        ORDER BY 1, 2`,
+        bucket[0],
+    )
+    
+    err := r.db.SelectContext(ctx, &totals, query, userID, from, to)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get counterparty totals: %w", err)
+    }
+    
+    return totals, nil
+}
+
 func (r *transactionRepository) CountAccountTransactions(ctx context.Context, accountID int) (int, error) {
     var count int
     query := `SELECT COUNT(*) FROM transactions WHERE account_id = $1`
@@ -222,3 +336,19 @@ func (r *transactionRepository) CountAccountTransactions(ctx context.Context, ac
     return count, nil
 }
 
+// GetLatestBookingTime returns the booking time of the newest transaction of
+// the account, nil when it has none
+func (r *transactionRepository) GetLatestBookingTime(ctx context.Context, accountID int) (*time.Time, error) {
+    var latest sql.NullTime
+    query := `SELECT MAX(booking_date_time) FROM transactions WHERE account_id = $1`
+    
+    err := r.db.GetContext(ctx, &latest, query, accountID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get latest transaction: %w", err)
+    }
+    if !latest.Valid {
+        return nil, nil
+    }
+    
+    return &latest.Time, nil
+}
@@ -4,8 +4,10 @@ import (
     "context"
     "database/sql"
     "fmt"
+    "time"
     
     "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/jmoiron/sqlx"
     "github.com/lib/pq"
 )
@@ -20,13 +22,14 @@ func NewUserRepository(db *sqlx.DB) UserRepository {
 
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
     query := `
-        INSERT INTO users (email, password_hash, created_at, updated_at)
-        VALUES ($1, $2, NOW(), NOW())
+        INSERT INTO users (email, password_hash, base_currency, created_at, updated_at)
+        VALUES ($1, $2, $3, NOW(), NOW())
         RETURNING id, created_at, updated_at`
     
     err := r.db.QueryRowxContext(ctx, query, 
         user.Email, 
         user.PasswordHash,
+        user.BaseCurrency,
     ).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
     
     if err != nil {
@@ -44,6 +47,7 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, err
     query := `
         SELECT id, email, password_hash, avg_salary, avg_expenses, 
                savings_capacity, salary_dates, autopilot_enabled,
+               base_currency, email_verified_at, failed_logins, locked_until,
                created_at, updated_at
         FROM users 
         WHERE id = $1`
@@ -51,7 +55,7 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, err
     err := r.db.GetContext(ctx, &user, query, id)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
+            return nil, fmt.Errorf("user %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get user: %w", err)
     }
@@ -64,6 +68,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
     query := `
         SELECT id, email, password_hash, avg_salary, avg_expenses, 
                savings_capacity, salary_dates, autopilot_enabled,
+               base_currency, email_verified_at, failed_logins, locked_until,
                created_at, updated_at
         FROM users 
         WHERE email = $1`
@@ -71,7 +76,7 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
     err := r.db.GetContext(ctx, &user, query, email)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
+            return nil, fmt.Errorf("user %w", ErrNotFound)
         }
         return nil, fmt.Errorf("failed to get user: %w", err)
     }
@@ -84,13 +89,14 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
         UPDATE users 
         SET email = $2, avg_salary = $3, avg_expenses = $4,
             savings_capacity = $5, salary_dates = $6, 
-            autopilot_enabled = $7, updated_at = NOW()
+            autopilot_enabled = $7, base_currency = $8,
+            updated_at = NOW()
         WHERE id = $1`
     
     _, err := r.db.ExecContext(ctx, query,
         user.ID, user.Email, user.AvgSalary, user.AvgExpenses,
         user.SavingsCapacity, pq.Array(user.SalaryDates),
-        user.AutopilotEnabled,
+        user.AutopilotEnabled, user.BaseCurrency,
     )
     
     if err != nil {
@@ -100,7 +106,7 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
     return nil
 }
 
-func (r *userRepository) UpdateFinancialProfile(ctx context.Context, userID int, avgSalary, avgExpenses, savingsCapacity float64, salaryDates []int) error {
+func (r *userRepository) UpdateFinancialProfile(ctx context.Context, userID int, avgSalary, avgExpenses, savingsCapacity money.Amount, salaryDates []int) error {
     query := `
         UPDATE users 
         SET avg_salary = $2, avg_expenses = $3, 
@@ -130,3 +136,63 @@ func (r *userRepository) UpdateAutopilot(ctx context.Context, userID int, enable
     return nil
 }
 
+// MarkEmailVerified records that the user confirmed their email, the first
+// confirmation is kept
+func (r *userRepository) MarkEmailVerified(ctx context.Context, userID int) error {
+    query := `
+        UPDATE users
+        SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+        WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, userID)
+    if err != nil {
+        return fmt.Errorf("failed to mark email verified: %w", err)
+    }
+    
+    return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+    query := `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`
+    
+    _, err := r.db.ExecContext(ctx, query, userID, passwordHash)
+    if err != nil {
+        return fmt.Errorf("failed to update password: %w", err)
+    }
+    
+    return nil
+}
+
+// RecordFailedLogin counts a failed login and returns the failures in a row
+func (r *userRepository) RecordFailedLogin(ctx context.Context, userID int) (int, error) {
+    var failures int
+    query := `UPDATE users SET failed_logins = failed_logins + 1 WHERE id = $1 RETURNING failed_logins`
+    
+    if err := r.db.GetContext(ctx, &failures, query, userID); err != nil {
+        return 0, fmt.Errorf("failed to record failed login: %w", err)
+    }
+    
+    return failures, nil
+}
+
+func (r *userRepository) LockUntil(ctx context.Context, userID int, until time.Time) error {
+    query := `UPDATE users SET locked_until = $2 WHERE id = $1`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID, until); err != nil {
+        return fmt.Errorf("failed to lock user: %w", err)
+    }
+    
+    return nil
+}
+
+// ResetFailedLogins clears the failure count and lifts a lockout after a
+// successful login or a password reset
+func (r *userRepository) ResetFailedLogins(ctx context.Context, userID int) error {
+    query := `UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+        return fmt.Errorf("failed to reset failed logins: %w", err)
+    }
+    
+    return nil
+}
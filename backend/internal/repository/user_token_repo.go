@@ -0,0 +1,84 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/jmoiron/sqlx"
+)
+
+type userTokenRepository struct {
+    db *sqlx.DB
+}
+
+func NewUserTokenRepository(db *sqlx.DB) UserTokenRepository {
+    return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+    query := `
+        INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at`
+    
+    err := r.db.QueryRowxContext(ctx, query,
+        token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt,
+    ).Scan(&token.ID, &token.CreatedAt)
+    
+    if err != nil {
+        return fmt.Errorf("failed to create user token: %w", err)
+    }
+    
+    return nil
+}
+
+// GetByHash finds a token by hash, nil when there is none
+func (r *userTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.UserToken, error) {
+    var token models.UserToken
+    query := `SELECT * FROM user_tokens WHERE token_hash = $1`
+    
+    err := r.db.GetContext(ctx, &token, query, tokenHash)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to get user token: %w", err)
+    }
+    
+    return &token, nil
+}
+
+// Use marks a token used. Returns false if it already was, so a token
+// works once even when presented twice at the same time.
+func (r *userTokenRepository) Use(ctx context.Context, id int) (bool, error) {
+    query := `UPDATE user_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+    
+    result, err := r.db.ExecContext(ctx, query, id)
+    if err != nil {
+        return false, fmt.Errorf("failed to use user token: %w", err)
+    }
+    
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("failed to use user token: %w", err)
+    }
+    
+    return rows == 1, nil
+}
+
+// InvalidateUserTokens uses up the open tokens of a user for purpose, so
+// only the latest mailed link works
+func (r *userTokenRepository) InvalidateUserTokens(ctx context.Context, userID int, purpose models.UserTokenPurpose) error {
+    query := `
+        UPDATE user_tokens
+        SET used_at = NOW()
+        WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+    
+    if _, err := r.db.ExecContext(ctx, query, userID, purpose); err != nil {
+        return fmt.Errorf("failed to invalidate user tokens: %w", err)
+    }
+    
+    return nil
+}
@@ -1,22 +1,46 @@
 package router
 
 import (
+    "github.com/KotovBoris/AutoSave/backend/internal/authz"
     "github.com/KotovBoris/AutoSave/backend/internal/handlers"
     "github.com/KotovBoris/AutoSave/backend/internal/middleware"
+    "github.com/KotovBoris/AutoSave/backend/internal/ratelimit"
     "github.com/KotovBoris/AutoSave/backend/pkg/jwt"
     "github.com/gin-gonic/gin"
     "github.com/rs/zerolog"
 )
 
+// RateLimits are the rules Setup applies, see the routes for their keys
+type RateLimits struct {
+    API       ratelimit.Rule
+    Auth      ratelimit.Rule
+    Login     ratelimit.Rule
+    Expensive ratelimit.Rule
+}
+
 type Router struct {
-    authHandler     *handlers.AuthHandler
-    bankHandler     *handlers.BankHandler
-    accountHandler  *handlers.AccountHandler
-    analysisHandler *handlers.AnalysisHandler
-    goalHandler     *handlers.GoalHandler
-    jwtUtil         *jwt.JWTUtil
-    logger          *zerolog.Logger
-    corsOrigins     []string
+    authHandler      *handlers.AuthHandler
+    bankHandler      *handlers.BankHandler
+    accountHandler   *handlers.AccountHandler
+    analysisHandler  *handlers.AnalysisHandler
+    goalHandler      *handlers.GoalHandler
+    loanHandler      *handlers.LoanHandler
+    emergencyHandler *handlers.EmergencyHandler
+    operationHandler *handlers.OperationHandler
+    productHandler   *handlers.ProductHandler
+    categoryHandler  *handlers.CategoryHandler
+    recurringHandler *handlers.RecurringHandler
+    totpHandler      *handlers.TOTPHandler
+    jwtUtil          *jwt.JWTUtil
+    sessions         middleware.SessionChecker
+    verification     middleware.EmailVerificationChecker
+    stepUp           middleware.StepUpVerifier
+    authorizer       middleware.ResourceAuthorizer
+    limiter          ratelimit.Limiter
+    limits           RateLimits
+    logger           *zerolog.Logger
+    corsOrigins      []string
+    trustedProxies   []string
 }
 
 func NewRouter(
@@ -25,25 +49,60 @@ func NewRouter(
     accountHandler *handlers.AccountHandler,
     analysisHandler *handlers.AnalysisHandler,
     goalHandler *handlers.GoalHandler,
+    loanHandler *handlers.LoanHandler,
+    emergencyHandler *handlers.EmergencyHandler,
+    operationHandler *handlers.OperationHandler,
+    productHandler *handlers.ProductHandler,
+    categoryHandler *handlers.CategoryHandler,
+    recurringHandler *handlers.RecurringHandler,
+    totpHandler *handlers.TOTPHandler,
     jwtUtil *jwt.JWTUtil,
+    sessions middleware.SessionChecker,
+    verification middleware.EmailVerificationChecker,
+    stepUp middleware.StepUpVerifier,
+    authorizer middleware.ResourceAuthorizer,
+    limiter ratelimit.Limiter,
+    limits RateLimits,
     logger *zerolog.Logger,
     corsOrigins []string,
+    trustedProxies []string,
 ) *Router {
     return &Router{
-        authHandler:     authHandler,
-        bankHandler:     bankHandler,
-        accountHandler:  accountHandler,
-        analysisHandler: analysisHandler,
-        goalHandler:     goalHandler,
-        jwtUtil:         jwtUtil,
-        logger:          logger,
-        corsOrigins:     corsOrigins,
+        authHandler:      authHandler,
+        bankHandler:      bankHandler,
+        accountHandler:   accountHandler,
+        analysisHandler:  analysisHandler,
+        goalHandler:      goalHandler,
+        loanHandler:      loanHandler,
+        emergencyHandler: emergencyHandler,
+        operationHandler: operationHandler,
+        productHandler:   productHandler,
+        categoryHandler:  categoryHandler,
+        recurringHandler: recurringHandler,
+        totpHandler:      totpHandler,
+        jwtUtil:          jwtUtil,
+        sessions:         sessions,
+        verification:     verification,
+        stepUp:           stepUp,
+        authorizer:       authorizer,
+        limiter:          limiter,
+        limits:           limits,
+        logger:           logger,
+        corsOrigins:      corsOrigins,
+        trustedProxies:   trustedProxies,
     }
 }
 
 func (r *Router) Setup() *gin.Engine {
     router := gin.New()
     
+    // Client addresses key the per-IP limits, X-Forwarded-For is only
+    // believed from configured proxies
+    if err := router.SetTrustedProxies(r.trustedProxies); err != nil {
+        r.logger.Error().Err(err).Msg("Invalid trusted proxies, trusting none")
+        _ = router.SetTrustedProxies(nil)
+    }
+    
     // Global middleware
     router.Use(gin.Recovery())
     router.Use(middleware.LoggerMiddleware(r.logger))
@@ -54,53 +113,154 @@ func (r *Router) Setup() *gin.Engine {
         c.JSON(200, gin.H{"status": "ok"})
     })
     
+    // Public auth routes are limited per IP, login and password reset per
+    // email as well. Expensive routes get a bucket of their own per user.
+    perIP := r.limit("auth", r.limits.Auth, middleware.ByIP)
+    perEmail := r.limit("login", r.limits.Login, middleware.ByEmail)
+    expensive := func(name string) gin.HandlerFunc {
+        return r.limit(name, r.limits.Expensive, middleware.ByUser)
+    }
+    
     // API routes
     api := router.Group("/api")
     {
         // Auth (public)
         auth := api.Group("/auth")
         {
-            auth.POST("/register", r.authHandler.Register)
-            auth.POST("/login", r.authHandler.Login)
-            auth.GET("/me", middleware.AuthMiddleware(r.jwtUtil), r.authHandler.GetMe)
+            auth.POST("/register", perIP, r.authHandler.Register)
+            auth.POST("/login", perIP, perEmail, r.authHandler.Login)
+            auth.POST("/refresh", perIP, r.authHandler.Refresh)
+            auth.POST("/verify-email", perIP, r.authHandler.VerifyEmail)
+            auth.POST("/verify-email/resend", middleware.AuthMiddleware(r.jwtUtil, r.sessions), expensive("verify-email"), r.authHandler.ResendVerification)
+            auth.POST("/forgot-password", perIP, perEmail, r.authHandler.ForgotPassword)
+            auth.POST("/reset-password", perIP, r.authHandler.ResetPassword)
+            auth.POST("/logout", middleware.AuthMiddleware(r.jwtUtil, r.sessions), r.authHandler.Logout)
+            auth.GET("/me", middleware.AuthMiddleware(r.jwtUtil, r.sessions), r.authHandler.GetMe)
+            auth.PATCH("/me", middleware.AuthMiddleware(r.jwtUtil, r.sessions), r.authHandler.UpdateMe)
         }
         
         // Protected routes
         protected := api.Group("")
-        protected.Use(middleware.AuthMiddleware(r.jwtUtil))
+        protected.Use(middleware.AuthMiddleware(r.jwtUtil, r.sessions), r.limit("api", r.limits.API, middleware.ByUser))
+        
+        // Routes that move money, or set up what autopilot and autopay move
+        // in the background, need a verified email; the scheduler skips
+        // unverified users too. The most sensitive ones also take a fresh
+        // TOTP code from users who enabled it, add stepUp to a group or a
+        // single route to require it.
+        verified := middleware.RequireVerifiedEmail(r.verification)
+        stepUp := middleware.RequireStepUp(r.stepUp)
+        
+        // Routes naming a resource by ID, in the path or the body, check it
+        // belongs to the user before the handler runs
+        own := func(resource authz.Resource, param string) gin.HandlerFunc {
+            return middleware.Authorize(r.authorizer, resource, param)
+        }
+        ownAll := func(resource authz.Resource, field string) gin.HandlerFunc {
+            return middleware.AuthorizeBody(r.authorizer, resource, field)
+        }
         {
+            // Two-factor authentication
+            totp := protected.Group("/auth/totp", expensive("totp"))
+            {
+                totp.GET("", r.totpHandler.GetStatus)
+                totp.POST("/setup", r.totpHandler.Setup)
+                totp.POST("/enable", r.totpHandler.Enable)
+                totp.POST("/disable", r.totpHandler.Disable)
+                totp.POST("/recovery-codes", r.totpHandler.RegenerateRecoveryCodes)
+            }
+            
+            // Autopilot
+            autopilot := protected.Group("/autopilot", expensive("autopilot"), stepUp)
+            {
+                autopilot.PUT("", verified, r.authHandler.UpdateAutopilot)
+            }
+            
             // Banks
             banks := protected.Group("/banks")
             {
                 banks.GET("", r.bankHandler.GetBanks)
-                banks.POST("/connect", r.bankHandler.ConnectBank)
+                banks.POST("/connect", verified, expensive("connect"), stepUp, r.bankHandler.ConnectBank)
                 banks.GET("/connected", r.bankHandler.GetConnectedBanks)
-                banks.POST("/sync", r.bankHandler.SyncBanks)
-                banks.DELETE("/:bankId", r.bankHandler.DisconnectBank)
+                banks.POST("/sync", expensive("sync"), r.bankHandler.SyncBanks)
+                banks.DELETE("/:bankId", own(authz.BankConnection, "bankId"), r.bankHandler.DisconnectBank)
             }
             
             // Accounts
             accounts := protected.Group("/accounts")
             {
                 accounts.GET("", r.accountHandler.GetAccounts)
-                accounts.GET("/:accountId/transactions", r.accountHandler.GetAccountTransactions)
+                accounts.GET("/:accountId/transactions", own(authz.Account, "accountId"), r.accountHandler.GetAccountTransactions)
+            }
+            
+            // Transactions
+            transactions := protected.Group("/transactions")
+            {
+                transactions.PATCH("/:transactionId/category", own(authz.Transaction, "transactionId"), r.categoryHandler.UpdateTransactionCategory)
+            }
+            
+            // Category rules
+            categoryRules := protected.Group("/categories/rules")
+            {
+                categoryRules.GET("", r.categoryHandler.GetRules)
+                categoryRules.POST("", r.categoryHandler.CreateRule)
+                categoryRules.POST("/apply", expensive("apply-rules"), r.categoryHandler.ApplyRules)
+                categoryRules.PUT("/:ruleId", own(authz.CategoryRule, "ruleId"), r.categoryHandler.UpdateRule)
+                categoryRules.DELETE("/:ruleId", own(authz.CategoryRule, "ruleId"), r.categoryHandler.DeleteRule)
             }
             
             // Analysis
             analysis := protected.Group("/analysis")
             {
-                analysis.POST("/detect-salaries", r.analysisHandler.DetectSalaries)
-                analysis.POST("/confirm-salaries", r.analysisHandler.ConfirmSalaries)
+                analysis.POST("/detect-salaries", expensive("detect-salaries"), r.analysisHandler.DetectSalaries)
+                analysis.POST("/confirm-salaries", ownAll(authz.Transaction, "salaryTransactionIds"), r.analysisHandler.ConfirmSalaries)
+                analysis.GET("/summary", r.analysisHandler.GetSummary)
+                analysis.GET("/recurring", r.recurringHandler.GetRecurring)
+                analysis.POST("/recurring/detect", expensive("detect-recurring"), r.recurringHandler.DetectRecurring)
+                analysis.GET("/recurring/upcoming", r.recurringHandler.GetUpcoming)
+                analysis.POST("/recurring/:recurringId/confirm", own(authz.RecurringPayment, "recurringId"), r.recurringHandler.Confirm)
+                analysis.POST("/recurring/:recurringId/dismiss", own(authz.RecurringPayment, "recurringId"), r.recurringHandler.Dismiss)
             }
             
             // Goals
             goals := protected.Group("/goals")
             {
                 goals.GET("", r.goalHandler.GetGoals)
-                goals.POST("", r.goalHandler.CreateGoal)
-                goals.PUT("/:goalId", r.goalHandler.UpdateGoal)
-                goals.DELETE("/:goalId", r.goalHandler.DeleteGoal)
-                goals.PUT("/reorder", r.goalHandler.ReorderGoals)
+                goals.POST("", verified, r.goalHandler.CreateGoal)
+                goals.PUT("/:goalId", verified, own(authz.Goal, "goalId"), r.goalHandler.UpdateGoal)
+                goals.DELETE("/:goalId", verified, own(authz.Goal, "goalId"), r.goalHandler.DeleteGoal)
+                goals.POST("/:goalId/resume", verified, own(authz.Goal, "goalId"), r.goalHandler.ResumeGoal)
+                goals.PUT("/reorder", verified, r.goalHandler.ReorderGoals)
+            }
+            
+            // Loans
+            loans := protected.Group("/loans")
+            {
+                loans.GET("", r.loanHandler.GetLoans)
+                loans.POST("", verified, r.loanHandler.CreateLoan)
+                loans.PUT("/:loanId", verified, own(authz.Loan, "loanId"), r.loanHandler.UpdateLoan)
+                loans.DELETE("/:loanId", own(authz.Loan, "loanId"), r.loanHandler.DeleteLoan)
+                loans.GET("/:loanId/payments", own(authz.Loan, "loanId"), r.loanHandler.GetPayments)
+                loans.POST("/:loanId/payments", verified, own(authz.Loan, "loanId"), r.loanHandler.CreatePayment)
+            }
+            
+            // Emergency withdrawal
+            emergency := protected.Group("/emergency")
+            {
+                emergency.POST("/plan", r.emergencyHandler.Plan)
+                emergency.POST("/confirm", verified, expensive("emergency"), stepUp, ownAll(authz.Deposit, "depositIds"), r.emergencyHandler.Confirm)
+            }
+            
+            // Operations
+            operations := protected.Group("/operations")
+            {
+                operations.GET("", r.operationHandler.GetOperations)
+            }
+            
+            // Products
+            products := protected.Group("/products")
+            {
+                products.GET("", r.productHandler.GetProducts)
             }
         }
     }
@@ -108,3 +268,13 @@ func (r *Router) Setup() *gin.Engine {
     return router
 }
 
+// limit rate limits requests by key in buckets named name. Without a
+// limiter it lets everything through.
+func (r *Router) limit(name string, rule ratelimit.Rule, key middleware.RateKey) gin.HandlerFunc {
+    if r.limiter == nil {
+        return func(c *gin.Context) {
+            c.Next()
+        }
+    }
+    return middleware.RateLimit(r.limiter, name, rule, key, r.logger)
+}
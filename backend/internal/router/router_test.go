@@ -0,0 +1,275 @@
+package router
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/authz"
+    "github.com/KotovBoris/AutoSave/backend/internal/authz/authztest"
+    "github.com/KotovBoris/AutoSave/backend/internal/handlers"
+    "github.com/KotovBoris/AutoSave/backend/internal/ratelimit"
+    "github.com/KotovBoris/AutoSave/backend/pkg/jwt"
+    "github.com/gin-gonic/gin"
+    "github.com/rs/zerolog"
+)
+
+// allowAll passes session, email verification and step-up checks, so only
+// ownership decides
+type allowAll struct{}
+
+func (allowAll) SessionActive(ctx context.Context, userID, sessionID int) (bool, error) {
+    return true, nil
+}
+
+func (allowAll) EmailVerified(ctx context.Context, userID int) (bool, error) {
+    return true, nil
+}
+
+func (allowAll) TOTPEnabled(ctx context.Context, userID int) (bool, error) {
+    return false, nil
+}
+
+func (allowAll) VerifyTOTP(ctx context.Context, userID int, code string) (bool, error) {
+    return true, nil
+}
+
+// newTestEngine sets up the real routes. Handlers have no services, a
+// request that gets past authorization fails inside the handler.
+func newTestEngine(t *testing.T) (*gin.Engine, string) {
+    t.Helper()
+    return newLimitedEngine(t, nil, RateLimits{}, nil)
+}
+
+// newLimitedEngine is newTestEngine with rate limits and trusted proxies
+func newLimitedEngine(t *testing.T, limiter ratelimit.Limiter, limits RateLimits, trustedProxies []string) (*gin.Engine, string) {
+    t.Helper()
+
+    gin.SetMode(gin.TestMode)
+    gin.DefaultErrorWriter = io.Discard
+
+    logger := zerolog.Nop()
+    jwtUtil := jwt.NewJWTUtil("test-secret", time.Hour)
+
+    r := NewRouter(
+        handlers.NewAuthHandler(nil),
+        handlers.NewBankHandler(nil),
+        handlers.NewAccountHandler(nil),
+        handlers.NewAnalysisHandler(nil),
+        handlers.NewGoalHandler(nil),
+        handlers.NewLoanHandler(nil),
+        handlers.NewEmergencyHandler(nil),
+        handlers.NewOperationHandler(nil),
+        handlers.NewProductHandler(nil),
+        handlers.NewCategoryHandler(nil),
+        handlers.NewRecurringHandler(nil),
+        handlers.NewTOTPHandler(nil),
+        jwtUtil,
+        allowAll{},
+        allowAll{},
+        allowAll{},
+        authz.NewAuthorizer(authztest.Repositories()),
+        limiter,
+        limits,
+        &logger,
+        nil,
+        trustedProxies,
+    )
+
+    token, _, err := jwtUtil.GenerateToken(authztest.Owner, "owner@example.com", 1)
+    if err != nil {
+        t.Fatalf("GenerateToken: %v", err)
+    }
+
+    return r.Setup(), token
+}
+
+// ownedRoutes are all routes naming a resource in their path or body, %v is
+// where the ID goes
+var ownedRoutes = []struct {
+    method string
+    path   string
+    body   string
+}{
+    {http.MethodDelete, "/api/banks/%v", ""},
+    {http.MethodGet, "/api/accounts/%v/transactions", ""},
+    {http.MethodPatch, "/api/transactions/%v/category", `{"category":"food"}`},
+    {http.MethodPut, "/api/categories/rules/%v", `{"category":"food","pattern":"shop"}`},
+    {http.MethodDelete, "/api/categories/rules/%v", ""},
+    {http.MethodPost, "/api/analysis/recurring/%v/confirm", ""},
+    {http.MethodPost, "/api/analysis/recurring/%v/dismiss", ""},
+    {http.MethodPut, "/api/goals/%v", `{"name":"Trip"}`},
+    {http.MethodDelete, "/api/goals/%v", ""},
+    {http.MethodPost, "/api/goals/%v/resume", ""},
+    {http.MethodPut, "/api/loans/%v", `{"name":"Car"}`},
+    {http.MethodDelete, "/api/loans/%v", ""},
+    {http.MethodGet, "/api/loans/%v/payments", ""},
+    {http.MethodPost, "/api/loans/%v/payments", `{"amount":1000}`},
+    {http.MethodPost, "/api/analysis/confirm-salaries", `{"salaryTransactionIds":[%v]}`},
+    {http.MethodPost, "/api/emergency/confirm", `{"depositIds":[%v]}`},
+}
+
+func TestOwnedRoutes(t *testing.T) {
+    engine, token := newTestEngine(t)
+
+    for _, route := range ownedRoutes {
+        // Bank connections are named by bank, another user's connection
+        // is one the caller does not have
+        owned, other, missing := any(authztest.OwnedID), any(authztest.OtherID), any(authztest.MissingID)
+        otherStatus, otherCode := http.StatusForbidden, "FORBIDDEN"
+        if strings.HasPrefix(route.path, "/api/banks/") {
+            owned, other, missing = authztest.OwnedBank, authztest.OtherBank, "nobank"
+            otherStatus, otherCode = http.StatusNotFound, "NOT_FOUND"
+        }
+
+        tests := []struct {
+            name   string
+            id     any
+            status int
+            code   string
+        }{
+            {"other user's", other, otherStatus, otherCode},
+            {"missing", missing, http.StatusNotFound, "NOT_FOUND"},
+            {"own", owned, 0, ""},
+        }
+
+        for _, tt := range tests {
+            path, body := route.path, route.body
+            if strings.Contains(path, "%v") {
+                path = fmt.Sprintf(path, tt.id)
+            } else {
+                body = fmt.Sprintf(body, tt.id)
+            }
+            t.Run(route.method+" "+path+" "+body, func(t *testing.T) {
+                req := httptest.NewRequest(route.method, path, strings.NewReader(body))
+                req.Header.Set("Authorization", "Bearer "+token)
+                req.Header.Set("Content-Type", "application/json")
+
+                rec := httptest.NewRecorder()
+                engine.ServeHTTP(rec, req)
+
+                if tt.code == "" {
+                    if rec.Code == http.StatusForbidden || rec.Code == http.StatusNotFound {
+                        t.Fatalf("%s %s: own resource refused with %d: %s", route.method, path, rec.Code, rec.Body)
+                    }
+                    return
+                }
+
+                if rec.Code != tt.status {
+                    t.Fatalf("%s %s: status %d, want %d: %s", route.method, path, rec.Code, tt.status, rec.Body)
+                }
+
+                var body struct {
+                    Error struct {
+                        Code string `json:"code"`
+                    } `json:"error"`
+                }
+                if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+                    t.Fatalf("%s %s: invalid error body %q: %v", route.method, path, rec.Body, err)
+                }
+                if body.Error.Code != tt.code {
+                    t.Fatalf("%s %s: error code %q, want %q", route.method, path, body.Error.Code, tt.code)
+                }
+            })
+        }
+    }
+}
+
+// TestOwnedRoutesCovered fails when a route with an ID in its path is added
+// without an ownership case above
+func TestOwnedRoutesCovered(t *testing.T) {
+    engine, _ := newTestEngine(t)
+
+    covered := map[string]bool{}
+    for _, route := range ownedRoutes {
+        covered[route.method+" "+route.path] = true
+    }
+
+    param := regexp.MustCompile(`:[A-Za-z]+`)
+    for _, info := range engine.Routes() {
+        if !param.MatchString(info.Path) {
+            continue
+        }
+        path := param.ReplaceAllString(info.Path, "%v")
+        if !covered[info.Method+" "+path] {
+            t.Errorf("%s %s has no ownership test", info.Method, info.Path)
+        }
+    }
+}
+
+func TestForwardedForFromUntrustedClient(t *testing.T) {
+    limits := RateLimits{Auth: ratelimit.Rule{Limit: 1, Per: time.Minute}}
+
+    tests := []struct {
+        name    string
+        proxies []string
+        status  int
+    }{
+        // A client cannot get a fresh bucket by naming another address
+        {"untrusted", nil, http.StatusTooManyRequests},
+        // Behind a trusted proxy the forwarded address is the client's
+        {"trusted proxy", []string{"192.0.2.1"}, 0},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            engine, _ := newLimitedEngine(t, ratelimit.NewMemoryLimiter(), limits, tt.proxies)
+
+            var rec *httptest.ResponseRecorder
+            for _, forwarded := range []string{"203.0.113.1", "203.0.113.2"} {
+                req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader("{}"))
+                req.RemoteAddr = "192.0.2.1:1234"
+                req.Header.Set("X-Forwarded-For", forwarded)
+                req.Header.Set("Content-Type", "application/json")
+
+                rec = httptest.NewRecorder()
+                engine.ServeHTTP(rec, req)
+            }
+
+            if tt.status != 0 && rec.Code != tt.status {
+                t.Fatalf("second request: status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+            }
+            if tt.status == 0 && rec.Code == http.StatusTooManyRequests {
+                t.Fatalf("second request from another client was limited: %s", rec.Body)
+            }
+        })
+    }
+}
+
+// TestOwnedBodyKeyCase checks body IDs are authorized under any key case
+// the handler binds
+func TestOwnedBodyKeyCase(t *testing.T) {
+    engine, token := newTestEngine(t)
+
+    tests := []struct {
+        path string
+        body string
+    }{
+        {"/api/analysis/confirm-salaries", `{"SalaryTransactionIds":[%v]}`},
+        {"/api/analysis/confirm-salaries", `{"SALARYTRANSACTIONIDS":[%v]}`},
+        {"/api/emergency/confirm", `{"DepositIDs":[%v]}`},
+    }
+
+    for _, tt := range tests {
+        body := fmt.Sprintf(tt.body, authztest.OtherID)
+        t.Run(tt.path+" "+body, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+            req.Header.Set("Authorization", "Bearer "+token)
+            req.Header.Set("Content-Type", "application/json")
+
+            rec := httptest.NewRecorder()
+            engine.ServeHTTP(rec, req)
+
+            if rec.Code != http.StatusForbidden {
+                t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+            }
+        })
+    }
+}
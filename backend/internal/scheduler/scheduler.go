@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "github.com/rs/zerolog"
+)
+
+// JobFunc is a unit of background work. now is the time of the current tick.
+type JobFunc func(ctx context.Context, now time.Time) error
+
+type job struct {
+    name string
+    fn   JobFunc
+}
+
+// Scheduler runs registered jobs on a fixed interval
+type Scheduler struct {
+    interval time.Duration
+    jobs     []job
+    logger   *zerolog.Logger
+
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+}
+
+// New creates a new scheduler
+func New(interval time.Duration, logger *zerolog.Logger) *Scheduler {
+    return &Scheduler{
+        interval: interval,
+        logger:   logger,
+    }
+}
+
+// Register adds a job. Must be called before Start.
+func (s *Scheduler) Register(name string, fn JobFunc) {
+    s.jobs = append(s.jobs, job{name: name, fn: fn})
+}
+
+// Start runs all jobs once immediately and then on every tick
+func (s *Scheduler) Start(ctx context.Context) {
+    ctx, s.cancel = context.WithCancel(ctx)
+
+    s.wg.Add(1)
+    go func() {
+        defer s.wg.Done()
+
+        ticker := time.NewTicker(s.interval)
+        defer ticker.Stop()
+
+        s.runAll(ctx, time.Now())
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case now := <-ticker.C:
+                s.runAll(ctx, now)
+            }
+        }
+    }()
+
+    s.logger.Info().
+        Dur("interval", s.interval).
+        Int("jobs", len(s.jobs)).
+        Msg("Scheduler started")
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+    if s.cancel == nil {
+        return
+    }
+    s.cancel()
+    s.wg.Wait()
+    s.logger.Info().Msg("Scheduler stopped")
+}
+
+func (s *Scheduler) runAll(ctx context.Context, now time.Time) {
+    for _, j := range s.jobs {
+        if ctx.Err() != nil {
+            return
+        }
+        s.run(ctx, j, now)
+    }
+}
+
+func (s *Scheduler) run(ctx context.Context, j job, now time.Time) {
+    defer func() {
+        if p := recover(); p != nil {
+            s.logger.Error().Str("job", j.name).Interface("panic", p).Msg("Scheduled job panicked")
+        }
+    }()
+
+    start := time.Now()
+    if err := j.fn(ctx, now); err != nil {
+        s.logger.Error().Err(err).Str("job", j.name).Msg("Scheduled job failed")
+        return
+    }
+
+    s.logger.Debug().
+        Str("job", j.name).
+        Dur("duration", time.Since(start)).
+        Msg("Scheduled job finished")
+}
@@ -4,137 +4,127 @@ import (
     "context"
     "fmt"
     "math"
+    "sort"
     "time"
     
+    "github.com/KotovBoris/AutoSave/backend/internal/fx"
     "github.com/KotovBoris/AutoSave/backend/internal/models"
     "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/rs/zerolog"
 )
 
 type AnalysisService struct {
     userRepo        repository.UserRepository
     transactionRepo repository.TransactionRepository
+    rates           *fx.Rates
+    lookbackMonths  int
     logger          *zerolog.Logger
 }
 
 func NewAnalysisService(
     userRepo repository.UserRepository,
     transactionRepo repository.TransactionRepository,
+    rates *fx.Rates,
+    lookbackMonths int,
     logger *zerolog.Logger,
 ) *AnalysisService {
     return &AnalysisService{
         userRepo:        userRepo,
         transactionRepo: transactionRepo,
+        rates:           rates,
+        lookbackMonths:  lookbackMonths,
         logger:          logger,
     }
 }
 
-// DetectSalaries analyzes transactions and detects salary patterns
-func (s *AnalysisService) DetectSalaries(ctx context.Context, userID int) ([]models.SalaryDetection, error) {
-    s.logger.Info().Int("userId", userID).Msg("Detecting salaries")
+// converter converts to the base currency of the user
+func (s *AnalysisService) converter(ctx context.Context, userID int) (*currencyConverter, error) {
+    user, err := s.userRepo.GetByID(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("user not found: %w", err)
+    }
+    return newCurrencyConverter(s.rates, user.BaseCurrency, s.logger), nil
+}
+
+// DetectSalaries looks for employers paying on a monthly or semi-monthly
+// schedule. lookbackMonths <= 0 uses the configured default.
+func (s *AnalysisService) DetectSalaries(ctx context.Context, userID int, lookbackMonths int) ([]models.SalaryDetection, error) {
+    if lookbackMonths <= 0 {
+        lookbackMonths = s.lookbackMonths
+    }
+    
+    s.logger.Info().Int("userId", userID).Int("lookbackMonths", lookbackMonths).Msg("Detecting salaries")
     
-    // Get transactions for last 3 months
-    fromDate := time.Now().AddDate(0, -3, 0)
     toDate := time.Now()
+    fromDate := toDate.AddDate(0, -lookbackMonths, 0)
+    
+    conv, err := s.converter(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
     
     transactions, err := s.transactionRepo.GetUserTransactions(ctx, userID, fromDate, toDate)
     if err != nil {
         return nil, fmt.Errorf("failed to get transactions: %w", err)
     }
     
-    // Filter income transactions
-    incomeTransactions := make([]models.Transaction, 0)
+    // Group income by counterparty. Money returned from deposits is not pay.
+    // Shares of income are compared in the base currency.
+    employers := make(map[string][]models.Transaction)
+    incomes := make(map[int]money.Amount)
+    totalIncome := money.Zero
     for _, tx := range transactions {
-        if tx.Amount > 0 {
-            incomeTransactions = append(incomeTransactions, tx)
+        if tx.Amount <= 0 || stringValue(tx.Category) == models.CategorySavings {
+            continue
         }
-    }
-    
-    if len(incomeTransactions) == 0 {
-        return []models.SalaryDetection{}, nil
-    }
-    
-    // Group by counterparty and find recurring patterns
-    type incomePattern struct {
-        counterparty string
-        amounts      []float64
-        dates        []time.Time
-        transactions []models.Transaction
-    }
-    
-    patterns := make(map[string]*incomePattern)
-    
-    for _, tx := range incomeTransactions {
+        
         counterparty := "Unknown"
-        if tx.CounterpartyName != nil {
+        if tx.CounterpartyName != nil && *tx.CounterpartyName != "" {
             counterparty = *tx.CounterpartyName
         }
         
-        if _, exists := patterns[counterparty]; !exists {
-            patterns[counterparty] = &incomePattern{
-                counterparty: counterparty,
-                amounts:      []float64{},
-                dates:        []time.Time{},
-                transactions: []models.Transaction{},
-            }
+        employers[counterparty] = append(employers[counterparty], tx)
+        if amount, ok := conv.convert(tx.Amount, tx.Currency); ok {
+            incomes[tx.ID] = amount
+            totalIncome += amount
         }
-        
-        patterns[counterparty].amounts = append(patterns[counterparty].amounts, tx.Amount)
-        patterns[counterparty].dates = append(patterns[counterparty].dates, tx.BookingDateTime)
-        patterns[counterparty].transactions = append(patterns[counterparty].transactions, tx)
     }
     
-    // Analyze patterns
     detections := []models.SalaryDetection{}
     
-    for _, pattern := range patterns {
-        // Need at least 2 transactions to detect pattern
-        if len(pattern.transactions) < 2 {
-            continue
-        }
-        
-        // Calculate average amount
-        avgAmount := 0.0
-        for _, amt := range pattern.amounts {
-            avgAmount += amt
+    for counterparty, income := range employers {
+        employerIncome := money.Zero
+        for _, tx := range income {
+            employerIncome += incomes[tx.ID]
         }
-        avgAmount /= float64(len(pattern.amounts))
         
-        // Check if amounts are similar (within 20% variance)
-        variance := 0.0
-        for _, amt := range pattern.amounts {
-            diff := amt - avgAmount
-            variance += diff * diff
-        }
-        variance /= float64(len(pattern.amounts))
-        stdDev := math.Sqrt(variance)
-        
-        // If standard deviation is less than 20% of average, it's likely a salary
-        confidence := "low"
-        autoSelected := false
-        
-        if stdDev < avgAmount*0.2 && avgAmount > 10000 {
-            confidence = "high"
-            autoSelected = true
-        } else if stdDev < avgAmount*0.4 && avgAmount > 5000 {
-            confidence = "medium"
-        }
+        streams := detectPayStreams(income, lookbackMonths, employerIncome.Ratio(totalIncome))
         
-        // Add all transactions from this pattern
-        for _, tx := range pattern.transactions {
-            detection := models.SalaryDetection{
-                TransactionID: tx.ID,
-                Date:          tx.BookingDateTime.Format("2006-01-02"),
-                Amount:        tx.Amount,
-                Counterparty:  pattern.counterparty,
-                AccountID:     tx.AccountID,
-                Confidence:    confidence,
-                AutoSelected:  autoSelected,
+        for _, stream := range streams {
+            for _, tx := range stream.transactions {
+                detections = append(detections, models.SalaryDetection{
+                    TransactionID: tx.ID,
+                    Date:          tx.BookingDateTime.Format("2006-01-02"),
+                    Amount:        tx.Amount,
+                    Currency:      tx.Currency,
+                    Counterparty:  counterparty,
+                    AccountID:     tx.AccountID,
+                    Confidence:    stream.confidence,
+                    AutoSelected:  stream.confidence == "high",
+                    Schedule:      stream.schedule,
+                    PayType:       stream.payType,
+                    PayDay:        stream.payDay,
+                    Reason:        stream.reason,
+                })
             }
-            detections = append(detections, detection)
         }
     }
     
+    sort.Slice(detections, func(i, j int) bool {
+        return detections[i].Date > detections[j].Date
+    })
+    
     return detections, nil
 }
 
@@ -147,12 +137,18 @@ func (s *AnalysisService) ConfirmSalaries(ctx context.Context, userID int, trans
     }
     
     // Mark transactions as salary
-    if err := s.transactionRepo.MarkAsSalary(ctx, transactionIDs); err != nil {
+    if err := s.transactionRepo.MarkAsSalary(ctx, userID, transactionIDs); err != nil {
         return nil, fmt.Errorf("failed to mark as salary: %w", err)
     }
     
-    // Calculate financial profile
-    fromDate := time.Now().AddDate(0, -3, 0)
+    // Calculate financial profile in the base currency
+    conv, err := s.converter(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    
+    periodMonths := s.lookbackMonths
+    fromDate := time.Now().AddDate(0, -periodMonths, 0)
     toDate := time.Now()
     
     transactions, err := s.transactionRepo.GetUserTransactions(ctx, userID, fromDate, toDate)
@@ -160,36 +156,53 @@ func (s *AnalysisService) ConfirmSalaries(ctx context.Context, userID int, trans
         return nil, fmt.Errorf("failed to get transactions: %w", err)
     }
     
-    totalIncome := 0.0
-    totalExpenses := 0.0
-    salaryDatesMap := make(map[int]bool)
+    totalIncome := money.Zero
+    totalExpenses := money.Zero
+    expensesByCategory := make(map[string]money.Amount)
+    salaries := []models.Transaction{}
     
     for _, tx := range transactions {
-        if tx.Amount > 0 {
-            totalIncome += tx.Amount
+        category := models.CategoryOther
+        if tx.Category != nil && *tx.Category != "" {
+            category = *tx.Category
+        }
+        
+        // Money moved to and from deposits is saving, not income or spending
+        if category == models.CategorySavings {
+            continue
+        }
+        
+        amount, ok := conv.convert(tx.Amount, tx.Currency)
+        if !ok {
+            continue
+        }
+        
+        if amount > 0 {
+            totalIncome += amount
             if tx.IsSalary {
-                day := tx.BookingDateTime.Day()
-                salaryDatesMap[day] = true
+                salaries = append(salaries, tx)
             }
         } else {
-            totalExpenses += math.Abs(tx.Amount)
+            totalExpenses += amount.Abs()
+            expensesByCategory[category] += amount.Abs()
         }
     }
     
-    periodMonths := 3
-    avgSalary := totalIncome / float64(periodMonths)
-    avgExpenses := totalExpenses / float64(periodMonths)
+    avgSalary := totalIncome.Div(periodMonths)
+    avgExpenses := totalExpenses.Div(periodMonths)
     savingsCapacity := avgSalary - avgExpenses
     
     if savingsCapacity < 0 {
         savingsCapacity = 0
     }
     
-    // Extract salary dates
-    salaryDates := make([]int, 0, len(salaryDatesMap))
-    for day := range salaryDatesMap {
-        salaryDates = append(salaryDates, day)
+    // One salary date per pay stream, payments shifted by weekends
+    // do not add dates of their own
+    salaryDates := make([]int, 0)
+    for _, cluster := range clusterPayDays(salaries) {
+        salaryDates = append(salaryDates, typicalPayDay(cluster))
     }
+    sort.Ints(salaryDates)
     
     // Update user profile
     if err := s.userRepo.UpdateFinancialProfile(ctx, userID, avgSalary, avgExpenses, savingsCapacity, salaryDates); err != nil {
@@ -197,23 +210,216 @@ func (s *AnalysisService) ConfirmSalaries(ctx context.Context, userID int, trans
     }
     
     analysis := &models.SalaryAnalysis{
+        Currency:        conv.to,
         AvgSalary:       avgSalary,
         AvgExpenses:     avgExpenses,
         SavingsCapacity: savingsCapacity,
         SalaryDates:     salaryDates,
         Analysis: models.AnalysisData{
-            TotalIncome:   totalIncome,
-            TotalExpenses: totalExpenses,
-            PeriodMonths:  periodMonths,
+            TotalIncome:        totalIncome,
+            TotalExpenses:      totalExpenses,
+            ExpensesByCategory: expensesByCategory,
+            PeriodMonths:       periodMonths,
         },
     }
     
     s.logger.Info().
         Int("userId", userID).
-        Float64("avgSalary", avgSalary).
-        Float64("savingsCapacity", savingsCapacity).
+        Float64("avgSalary", avgSalary.Float64()).
+        Float64("savingsCapacity", savingsCapacity.Float64()).
         Msg("Financial profile calculated")
     
     return analysis, nil
 }
 
+// topCounterpartiesLimit is how many counterparties each summary bucket lists
+const topCounterpartiesLimit = 5
+
+// GetSummary aggregates income and expenses between from and to into buckets
+// and compares them with the previous period of the same length
+func (s *AnalysisService) GetSummary(ctx context.Context, userID int, from, to time.Time, groupBy models.SummaryGroupBy) (*models.AnalysisSummary, error) {
+    if !from.Before(to) {
+        return nil, fmt.Errorf("from must be before to")
+    }
+    
+    conv, err := s.converter(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    
+    rows, err := s.transactionRepo.Summarize(ctx, userID, from, to, groupBy)
+    if err != nil {
+        return nil, err
+    }
+    buckets := mergeBuckets(rows, conv)
+    sortBuckets(buckets, groupBy)
+    
+    prevTo := from.Add(-time.Nanosecond)
+    prevFrom := from.Add(-to.Sub(from))
+    rows, err = s.transactionRepo.Summarize(ctx, userID, prevFrom, prevTo, groupBy)
+    if err != nil {
+        return nil, err
+    }
+    previous := mergeBuckets(rows, conv)
+    
+    // Every transaction falls into exactly one bucket, so totals are their sum
+    summary := &models.AnalysisSummary{
+        Currency: conv.to,
+        From:    from,
+        To:      to,
+        GroupBy: groupBy,
+        Buckets: []models.SummaryBucket{},
+        Totals:  sumBuckets(buckets),
+        PreviousPeriod: models.SummaryPeriod{
+            From:   prevFrom,
+            To:     prevTo,
+            Totals: sumBuckets(previous),
+        },
+    }
+    
+    summary.Trend = models.SummaryTrend{
+        IncomeChange:   percentChange(summary.PreviousPeriod.Totals.Income, summary.Totals.Income),
+        ExpensesChange: percentChange(summary.PreviousPeriod.Totals.Expenses, summary.Totals.Expenses),
+        NetChange:      percentChange(summary.PreviousPeriod.Totals.Net, summary.Totals.Net),
+    }
+    
+    if len(buckets) == 0 {
+        return summary, nil
+    }
+    
+    // Grouping by counterparty already is the counterparty breakdown
+    top := map[string][]models.CounterpartyTotal{}
+    if groupBy != models.SummaryByCounterparty {
+        totals, err := s.transactionRepo.CounterpartyTotals(ctx, userID, from, to, groupBy)
+        if err != nil {
+            return nil, err
+        }
+        top = topCounterparties(totals, conv, topCounterpartiesLimit)
+    }
+    
+    // Months of the previous period are different months, nothing to match
+    prevByKey := map[string]models.SummaryAmounts{}
+    if groupBy != models.SummaryByMonth {
+        for _, b := range previous {
+            prevByKey[b.Key] = withNet(b.SummaryAmounts)
+        }
+    }
+    
+    for _, b := range buckets {
+        b.SummaryAmounts = withNet(b.SummaryAmounts)
+        b.TopCounterparties = top[b.Key]
+        if prev, ok := prevByKey[b.Key]; ok {
+            b.Previous = &prev
+        }
+        summary.Buckets = append(summary.Buckets, b)
+    }
+    
+    return summary, nil
+}
+
+// mergeBuckets converts bucket rows to one currency and merges the rows a
+// bucket has for each currency. Rows without an exchange rate are left out.
+func mergeBuckets(rows []models.SummaryBucket, conv *currencyConverter) []models.SummaryBucket {
+    buckets := []models.SummaryBucket{}
+    index := map[string]int{}
+    
+    for _, row := range rows {
+        income, ok := conv.convert(row.Income, row.Currency)
+        if !ok {
+            continue
+        }
+        expenses, _ := conv.convert(row.Expenses, row.Currency)
+        savings, _ := conv.convert(row.Savings, row.Currency)
+        
+        i, seen := index[row.Key]
+        if !seen {
+            i = len(buckets)
+            index[row.Key] = i
+            buckets = append(buckets, models.SummaryBucket{Key: row.Key, Label: row.Label, Currency: conv.to})
+        }
+        
+        buckets[i].Income += income
+        buckets[i].Expenses += expenses
+        buckets[i].Savings += savings
+        buckets[i].Count += row.Count
+    }
+    
+    return buckets
+}
+
+// sortBuckets puts months in order and other buckets by spending
+func sortBuckets(buckets []models.SummaryBucket, groupBy models.SummaryGroupBy) {
+    sort.SliceStable(buckets, func(i, j int) bool {
+        if groupBy == models.SummaryByMonth {
+            return buckets[i].Key < buckets[j].Key
+        }
+        if buckets[i].Expenses != buckets[j].Expenses {
+            return buckets[i].Expenses > buckets[j].Expenses
+        }
+        return buckets[i].Income > buckets[j].Income
+    })
+}
+
+// topCounterparties converts counterparty totals to one currency and keeps
+// up to limit counterparties with the largest turnover in each bucket
+func topCounterparties(rows []models.CounterpartyTotal, conv *currencyConverter, limit int) map[string][]models.CounterpartyTotal {
+    type pair struct{ key, counterparty string }
+    merged := []models.CounterpartyTotal{}
+    index := map[pair]int{}
+    
+    for _, row := range rows {
+        income, ok := conv.convert(row.Income, row.Currency)
+        if !ok {
+            continue
+        }
+        expenses, _ := conv.convert(row.Expenses, row.Currency)
+        
+        p := pair{row.Key, row.Counterparty}
+        i, seen := index[p]
+        if !seen {
+            i = len(merged)
+            index[p] = i
+            merged = append(merged, models.CounterpartyTotal{Key: row.Key, Counterparty: row.Counterparty, Currency: conv.to})
+        }
+        
+        merged[i].Income += income
+        merged[i].Expenses += expenses
+        merged[i].Count += row.Count
+    }
+    
+    sort.SliceStable(merged, func(i, j int) bool {
+        return merged[i].Income+merged[i].Expenses > merged[j].Income+merged[j].Expenses
+    })
+    
+    top := map[string][]models.CounterpartyTotal{}
+    for _, t := range merged {
+        if len(top[t.Key]) < limit {
+            top[t.Key] = append(top[t.Key], t)
+        }
+    }
+    return top
+}
+
+func withNet(amounts models.SummaryAmounts) models.SummaryAmounts {
+    amounts.Net = amounts.Income - amounts.Expenses
+    return amounts
+}
+
+func sumBuckets(buckets []models.SummaryBucket) models.SummaryAmounts {
+    var total models.SummaryAmounts
+    for _, b := range buckets {
+        total.Income += b.Income
+        total.Expenses += b.Expenses
+        total.Savings += b.Savings
+        total.Count += b.Count
+    }
+    return withNet(total)
+}
+
+func percentChange(previous, current money.Amount) *float64 {
+    if previous == 0 {
+        return nil
+    }
+    change := math.Round((current-previous).Ratio(previous.Abs())*10000) / 100
+    return &change
+}
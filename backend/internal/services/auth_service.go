@@ -2,8 +2,17 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/KotovBoris/AutoSave/backend/internal/mailer"
 	"github.com/KotovBoris/AutoSave/backend/internal/models"
 	"github.com/KotovBoris/AutoSave/backend/internal/repository"
 	"github.com/KotovBoris/AutoSave/backend/pkg/jwt"
@@ -11,35 +20,108 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidRefreshToken is returned for unknown, expired, used or revoked
+// refresh tokens
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// ErrInvalidUserToken is returned for unknown, expired or used verification
+// and password reset tokens
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// unknownUserHash is compared against when the email is not registered, so
+// a login for an unknown email takes as long as one with a wrong password
+const unknownUserHash = "$2a$10$EfCmz8gIxThX7ea0W593AePW3hVzs7DubSanoHtc7ihEt53kgQR1m"
+
+// LoginLockoutConfig locks an account after Threshold failed logins in a
+// row for Base, doubling with every further failure up to Max
+type LoginLockoutConfig struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// duration returns how long failures in a row lock an account, zero
+// below the threshold
+func (c LoginLockoutConfig) duration(failures int) time.Duration {
+	if c.Threshold < 1 || failures < c.Threshold {
+		return 0
+	}
+
+	lock := c.Base
+	for i := c.Threshold; i < failures && lock < c.Max; i++ {
+		lock *= 2
+	}
+	if lock > c.Max {
+		lock = c.Max
+	}
+	return lock
+}
+
+// Lifetime of mailed links
+const (
+	verifyEmailTokenExpiry   = 48 * time.Hour
+	resetPasswordTokenExpiry = time.Hour
+)
+
 type AuthService struct {
-	userRepo repository.UserRepository
-	jwtUtil  *jwt.JWTUtil
-	logger   *zerolog.Logger
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	tokenRepo       repository.UserTokenRepository
+	jwtUtil         *jwt.JWTUtil
+	mailer          mailer.Mailer
+	lockout         LoginLockoutConfig
+	refreshExpiry   time.Duration
+	defaultCurrency string
+	appURL          string
+	logger          *zerolog.Logger
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtUtil *jwt.JWTUtil, logger *zerolog.Logger) *AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	tokenRepo repository.UserTokenRepository,
+	jwtUtil *jwt.JWTUtil,
+	mailer mailer.Mailer,
+	lockout LoginLockoutConfig,
+	refreshExpiry time.Duration,
+	defaultCurrency string,
+	appURL string,
+	logger *zerolog.Logger,
+) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		jwtUtil:  jwtUtil,
-		logger:   logger,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		tokenRepo:       tokenRepo,
+		jwtUtil:         jwtUtil,
+		mailer:          mailer,
+		lockout:         lockout,
+		refreshExpiry:   refreshExpiry,
+		defaultCurrency: defaultCurrency,
+		appURL:          appURL,
+		logger:          logger,
 	}
 }
 
-// Register creates new user
-func (s *AuthService) Register(ctx context.Context, req models.UserRegistration) (*models.User, string, error) {
+// Register creates new user and logs them in
+func (s *AuthService) Register(ctx context.Context, req models.UserRegistration, client models.SessionClient) (*models.AuthResponse, error) {
 	s.logger.Info().Str("email", req.Email).Msg("Registering new user")
 
 	// Check if user already exists
 	existing, _ := s.userRepo.GetByEmail(ctx, req.Email)
 	if existing != nil {
-		return nil, "", fmt.Errorf("user with email %s already exists", req.Email)
+		return nil, fmt.Errorf("user with email %s already exists", req.Email)
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to hash password")
-		return nil, "", fmt.Errorf("failed to hash password: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	baseCurrency := s.defaultCurrency
+	if req.BaseCurrency != "" {
+		baseCurrency = strings.ToUpper(req.BaseCurrency)
 	}
 
 	// Create user
@@ -47,52 +129,453 @@ func (s *AuthService) Register(ctx context.Context, req models.UserRegistration)
 		Email:            req.Email,
 		PasswordHash:     string(hashedPassword),
 		AutopilotEnabled: false,
+		BaseCurrency:     baseCurrency,
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		s.logger.Error().Err(err).Msg("Failed to create user")
-		return nil, "", fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// The account works without a verified email, so a mail outage must
+	// not fail registration. The user can ask for the link again.
+	if err := s.sendVerification(ctx, user); err != nil {
+		s.logger.Warn().Err(err).Int("userId", user.ID).Msg("Failed to send verification email")
 	}
 
-	// Generate JWT token
-	token, err := s.jwtUtil.GenerateToken(user.ID, user.Email)
+	resp, err := s.startSession(ctx, user, client)
 	if err != nil {
-		s.logger.Error().Err(err).Msg("Failed to generate token")
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
 	s.logger.Info().Int("userId", user.ID).Msg("User registered successfully")
 
-	return user, token, nil
+	return resp, nil
 }
 
-// Login authenticates user
-func (s *AuthService) Login(ctx context.Context, req models.UserLogin) (*models.User, string, error) {
+// Login authenticates user and opens a new session
+func (s *AuthService) Login(ctx context.Context, req models.UserLogin, client models.SessionClient) (*models.AuthResponse, error) {
 	s.logger.Info().Str("email", req.Email).Msg("User login attempt")
 
 	// Find user
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		s.logger.Warn().Str("email", req.Email).Msg("User not found")
-		return nil, "", fmt.Errorf("invalid email or password")
+		bcrypt.CompareHashAndPassword([]byte(unknownUserHash), []byte(req.Password))
+		return nil, fmt.Errorf("invalid email or password")
 	}
 
 	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+
+	// A locked account refuses even the right password, so guessing has
+	// to wait out the lock. The answer is the same as for a wrong password
+	// or an unknown email, so the lock does not tell which emails exist.
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		s.logger.Warn().Int("userId", user.ID).Time("lockedUntil", *user.LockedUntil).Msg("Login to locked account")
+		return nil, fmt.Errorf("invalid email or password")
+	}
+
+	if passwordErr != nil {
 		s.logger.Warn().Str("email", req.Email).Msg("Invalid password")
-		return nil, "", fmt.Errorf("invalid email or password")
+		s.recordFailedLogin(ctx, user)
+		return nil, fmt.Errorf("invalid email or password")
+	}
+
+	if user.FailedLogins > 0 {
+		if err := s.userRepo.ResetFailedLogins(ctx, user.ID); err != nil {
+			s.logger.Warn().Err(err).Int("userId", user.ID).Msg("Failed to reset failed logins")
+		}
 	}
 
-	// Generate JWT token
-	token, err := s.jwtUtil.GenerateToken(user.ID, user.Email)
+	resp, err := s.startSession(ctx, user, client)
 	if err != nil {
-		s.logger.Error().Err(err).Msg("Failed to generate token")
-		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+		return nil, err
 	}
 
 	s.logger.Info().Int("userId", user.ID).Msg("User logged in successfully")
 
-	return user, token, nil
+	return resp, nil
+}
+
+// recordFailedLogin counts a failed login and locks the account once there
+// are too many in a row
+func (s *AuthService) recordFailedLogin(ctx context.Context, user *models.User) {
+	failures, err := s.userRepo.RecordFailedLogin(ctx, user.ID)
+	if err != nil {
+		s.logger.Error().Err(err).Int("userId", user.ID).Msg("Failed to record failed login")
+		return
+	}
+
+	lock := s.lockout.duration(failures)
+	if lock == 0 {
+		return
+	}
+
+	until := time.Now().Add(lock)
+	if err := s.userRepo.LockUntil(ctx, user.ID, until); err != nil {
+		s.logger.Error().Err(err).Int("userId", user.ID).Msg("Failed to lock account")
+		return
+	}
+
+	s.logger.Warn().
+		Int("userId", user.ID).
+		Int("failures", failures).
+		Dur("lock", lock).
+		Msg("Account locked after failed logins")
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token
+// works once: presenting a used one means it was copied, and as there is no
+// telling the user from whoever copied it, the whole session is revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
+	token, err := s.sessionRepo.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.UsedAt != nil {
+		return nil, s.revokeReused(ctx, session)
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Two refreshes racing with the same token are a reuse as well
+	used, err := s.sessionRepo.UseRefreshToken(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, s.revokeReused(ctx, session)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	if err := s.sessionRepo.Touch(ctx, session.ID); err != nil {
+		s.logger.Warn().Err(err).Int("sessionId", session.ID).Msg("Failed to update session")
+	}
+
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+// Logout revokes the session of the request or, with everywhere, every
+// session of the user
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID int, everywhere bool) error {
+	s.logger.Info().Int("userId", userID).Int("sessionId", sessionID).Bool("everywhere", everywhere).Msg("Logging out")
+
+	if everywhere {
+		return s.sessionRepo.RevokeUserSessions(ctx, userID, models.SessionRevokedLogoutAll)
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return fmt.Errorf("session does not belong to user")
+	}
+
+	return s.sessionRepo.Revoke(ctx, sessionID, models.SessionRevokedLogout)
+}
+
+// SessionActive reports whether an access token of user with sessionID may
+// still be used. Tokens issued before sessions existed have no session.
+func (s *AuthService) SessionActive(ctx context.Context, userID, sessionID int) (bool, error) {
+	if sessionID == 0 {
+		return false, nil
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	return session.UserID == userID && session.RevokedAt == nil, nil
+}
+
+// startSession opens a session for user and issues its first tokens
+func (s *AuthService) startSession(ctx context.Context, user *models.User, client models.SessionClient) (*models.AuthResponse, error) {
+	session := &models.Session{
+		UserID:    user.ID,
+		UserAgent: truncatedString(client.UserAgent, 255),
+		IPAddress: truncatedString(client.IPAddress, 45),
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		s.logger.Error().Err(err).Int("userId", user.ID).Msg("Failed to create session")
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, session.ID)
+}
+
+// issueTokens signs an access token and stores a new refresh token for a session
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, sessionID int) (*models.AuthResponse, error) {
+	accessToken, expiresAt, err := s.jwtUtil.GenerateToken(user.ID, user.Email, sessionID)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to generate token")
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	refreshToken, err := newRandomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	token := &models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshExpiry),
+	}
+	if err := s.sessionRepo.CreateRefreshToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		User:                  user.ToResponse(),
+		Token:                 accessToken,
+		ExpiresAt:             expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+func (s *AuthService) revokeReused(ctx context.Context, session *models.Session) error {
+	s.logger.Warn().
+		Int("userId", session.UserID).
+		Int("sessionId", session.ID).
+		Msg("Used refresh token presented again, revoking session")
+
+	if err := s.sessionRepo.Revoke(ctx, session.ID, models.SessionRevokedTokenReuse); err != nil {
+		return err
+	}
+
+	return ErrInvalidRefreshToken
+}
+
+// ResendVerification mails a new verification link, earlier links stop
+// working. Nothing is sent when the email is verified already.
+func (s *AuthService) ResendVerification(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+// VerifyEmail marks the email of the token owner verified
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	userToken, err := s.useToken(ctx, token, models.UserTokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, userToken.UserID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int("userId", userToken.UserID).Msg("Email verified")
+
+	return s.GetUser(ctx, userToken.UserID)
+}
+
+// EmailVerified reports whether the user has verified their email
+func (s *AuthService) EmailVerified(ctx context.Context, userID int) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified(), nil
+}
+
+// ForgotPassword mails a password reset link. It succeeds for unknown
+// emails too, so the answer does not tell which emails have accounts.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		s.logger.Info().Str("email", email).Msg("Password reset for unknown email")
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, user.ID, models.UserTokenResetPassword, resetPasswordTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your AutoSave password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your AutoSave account.\n\n"+
+				"To choose a new password, open this link within an hour:\n%s\n\n"+
+				"If it was not you, ignore this email, your password stays the same.",
+			s.link("/reset-password", token),
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error().Err(err).Int("userId", user.ID).Msg("Failed to send password reset email")
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	s.logger.Info().Int("userId", user.ID).Msg("Password reset email sent")
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. Every session of
+// the user ends, whoever knew the old password is logged out.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	userToken, err := s.useToken(ctx, token, models.UserTokenResetPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to hash password")
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userToken.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// The new password is not subject to the old one's failed logins
+	if err := s.userRepo.ResetFailedLogins(ctx, userToken.UserID); err != nil {
+		return err
+	}
+
+	// The link reached the mailbox, which is as good as verifying it
+	if err := s.userRepo.MarkEmailVerified(ctx, userToken.UserID); err != nil {
+		s.logger.Warn().Err(err).Int("userId", userToken.UserID).Msg("Failed to mark email verified")
+	}
+
+	if err := s.sessionRepo.RevokeUserSessions(ctx, userToken.UserID, models.SessionRevokedLogoutAll); err != nil {
+		return err
+	}
+
+	s.logger.Info().Int("userId", userToken.UserID).Msg("Password reset")
+
+	return nil
+}
+
+func (s *AuthService) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := s.issueToken(ctx, user.ID, models.UserTokenVerifyEmail, verifyEmailTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your AutoSave email",
+		Body: fmt.Sprintf(
+			"Welcome to AutoSave!\n\n"+
+				"To confirm your email, open this link within 48 hours:\n%s\n\n"+
+				"Savings and loan payments are enabled once the email is confirmed.",
+			s.link("/verify-email", token),
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+// issueToken creates a mailed token for purpose. Earlier tokens of the
+// user for the same purpose are used up, only the latest link works.
+func (s *AuthService) issueToken(ctx context.Context, userID int, purpose models.UserTokenPurpose, expiry time.Duration) (string, error) {
+	if err := s.tokenRepo.InvalidateUserTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	raw, err := newRandomToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := &models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// useToken checks a mailed token and marks it used
+func (s *AuthService) useToken(ctx context.Context, raw string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	token, err := s.tokenRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.Purpose != purpose || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	used, err := s.tokenRepo.Use(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidUserToken
+	}
+
+	return token, nil
+}
+
+// link builds a frontend URL carrying a mailed token
+func (s *AuthService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// newRandomToken makes refresh and mailed tokens
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is what is stored for refresh and mailed tokens. Tokens are random, so
+// an unsalted fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncatedString(s string, max int) *string {
+	if s == "" {
+		return nil
+	}
+	if runes := []rune(s); len(runes) > max {
+		s = string(runes[:max])
+	}
+	return &s
 }
 
 // GetUser returns user by ID
@@ -116,3 +599,29 @@ func (s *AuthService) UpdateAutopilot(ctx context.Context, userID int, enabled b
 	return nil
 }
 
+// UpdateProfile changes the fields set in req
+func (s *AuthService) UpdateProfile(ctx context.Context, userID int, req models.UpdateProfileRequest) (*models.User, error) {
+	s.logger.Info().Int("userId", userID).Msg("Updating profile")
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	if req.AvgSalary != nil {
+		user.AvgSalary = req.AvgSalary
+	}
+	if req.SalaryDates != nil {
+		user.SalaryDates = req.SalaryDates
+	}
+	if req.BaseCurrency != nil {
+		user.BaseCurrency = strings.ToUpper(*req.BaseCurrency)
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update profile")
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+const (
+    defaultDepositTermMonths = 12
+
+    // Deposits that fail for a temporary reason are retried with a delay
+    // that doubles from depositRetryDelay, up to depositMaxAttempts sends
+    depositMaxAttempts   = 5
+    depositRetryDelay    = 15 * time.Minute
+    depositMaxRetryDelay = 6 * time.Hour
+
+    // A deposit pending for longer than this was left behind by a run that
+    // stopped before recording the bank's answer
+    depositPendingTimeout = 15 * time.Minute
+)
+
+type AutopilotService struct {
+    goalRepo      repository.GoalRepository
+    depositRepo   repository.DepositRepository
+    userRepo      repository.UserRepository
+    bankRepo      repository.BankRepository
+    accountRepo   repository.AccountRepository
+    operations    *OperationService
+    credentials   *BankCredentials
+    catalog       *ProductCatalog
+    logger        *zerolog.Logger
+}
+
+func NewAutopilotService(
+    goalRepo repository.GoalRepository,
+    depositRepo repository.DepositRepository,
+    userRepo repository.UserRepository,
+    bankRepo repository.BankRepository,
+    accountRepo repository.AccountRepository,
+    operations *OperationService,
+    credentials *BankCredentials,
+    catalog *ProductCatalog,
+    logger *zerolog.Logger,
+) *AutopilotService {
+    return &AutopilotService{
+        goalRepo:      goalRepo,
+        depositRepo:   depositRepo,
+        userRepo:      userRepo,
+        bankRepo:      bankRepo,
+        accountRepo:   accountRepo,
+        operations:    operations,
+        credentials:   credentials,
+        catalog:       catalog,
+        logger:        logger,
+    }
+}
+
+// RunDueDeposits opens deposits for every active goal whose salary date has come.
+// Safe to run repeatedly: each goal gets at most one deposit per salary date.
+func (s *AutopilotService) RunDueDeposits(ctx context.Context, now time.Time) error {
+    today := truncateToDate(now)
+
+    goals, err := s.goalRepo.GetDueGoals(ctx, today)
+    if err != nil {
+        return fmt.Errorf("failed to get due goals: %w", err)
+    }
+
+    if len(goals) == 0 {
+        return nil
+    }
+
+    s.logger.Info().Int("goals", len(goals)).Time("date", today).Msg("Running autopilot deposits")
+
+    for i := range goals {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+
+        goal := &goals[i]
+        if err := s.processGoal(ctx, goal, today); err != nil {
+            s.logger.Error().
+                Err(err).
+                Int("goalId", goal.ID).
+                Int("userId", goal.UserID).
+                Msg("Autopilot deposit failed")
+        }
+    }
+
+    return nil
+}
+
+func (s *AutopilotService) processGoal(ctx context.Context, goal *models.Goal, today time.Time) error {
+    user, err := s.userRepo.GetByID(ctx, goal.UserID)
+    if err != nil {
+        return fmt.Errorf("user not found: %w", err)
+    }
+
+    // Goals created before salary dates were known have no schedule yet
+    if goal.NextDepositDate == nil {
+        if len(user.SalaryDates) == 0 {
+            return nil
+        }
+        next := calculateNextSalaryDateAfter(user.SalaryDates, today.AddDate(0, 0, -1))
+        goal.NextDepositDate = &next
+        if err := s.goalRepo.Update(ctx, goal); err != nil {
+            return err
+        }
+        if next.After(today) {
+            return nil
+        }
+    }
+
+    scheduledFor := truncateToDate(*goal.NextDepositDate)
+
+    amount := goal.MonthlyAmount
+    if remaining := goal.TargetAmount - goal.CurrentAmount; remaining < amount {
+        amount = remaining
+    }
+    if amount <= 0 {
+        return s.advanceGoal(ctx, goal, user, 0, today)
+    }
+
+    deposit := &models.Deposit{
+        GoalID:       goal.ID,
+        UserID:       goal.UserID,
+        BankID:       goal.BankID,
+        Amount:       amount,
+        Currency:     goal.Currency,
+        Rate:         goal.DepositRate,
+        TermMonths:   defaultDepositTermMonths,
+        ScheduledFor: &scheduledFor,
+    }
+
+    claimed, err := s.depositRepo.ClaimScheduled(ctx, deposit, time.Now())
+    if err != nil {
+        return err
+    }
+
+    if !claimed {
+        return s.resumeScheduled(ctx, goal, user, scheduledFor, today)
+    }
+
+    s.logger.Info().
+        Int("goalId", goal.ID).
+        Int("depositId", deposit.ID).
+        Int("attempt", deposit.Attempts).
+        Float64("amount", amount.Float64()).
+        Msg("Opening autopilot deposit")
+
+    agreement, err := s.openDeposit(ctx, goal, deposit)
+    if err != nil {
+        return s.failDeposit(ctx, goal, user, deposit, err, bankadapter.IsTransient(err), today)
+    }
+
+    return s.completeDeposit(ctx, goal, user, deposit, agreement, today)
+}
+
+// completeDeposit records the agreement the bank opened for deposit and
+// counts it towards the goal
+func (s *AutopilotService) completeDeposit(ctx context.Context, goal *models.Goal, user *models.User, deposit *models.Deposit, agreement *bankadapter.Agreement, today time.Time) error {
+    openedAt := agreement.OpenedDate
+    if openedAt.IsZero() {
+        openedAt = time.Now()
+    }
+    maturesAt := agreement.MaturityDate
+    if maturesAt.IsZero() {
+        maturesAt = bankadapter.CalculateMaturityDate(openedAt, deposit.TermMonths)
+    }
+
+    deposit.Status = string(models.DepositStatusActive)
+    deposit.Error = nil
+    deposit.NextAttemptAt = nil
+    deposit.AgreementID = &agreement.AgreementID
+    deposit.ProductID = &agreement.ProductID
+    deposit.OpenedAt = &openedAt
+    deposit.MaturesAt = &maturesAt
+    if agreement.InterestRate > 0 {
+        deposit.Rate = agreement.InterestRate
+    }
+    if agreement.TermMonths > 0 {
+        deposit.TermMonths = agreement.TermMonths
+    }
+
+    if err := s.depositRepo.Update(ctx, deposit); err != nil {
+        return fmt.Errorf("deposit %s opened but not saved: %w", agreement.AgreementID, err)
+    }
+
+    s.recordOperation(ctx, deposit, models.OperationStatusSuccess, nil)
+
+    // The goal shows the rate its money is actually earning
+    goal.DepositRate = deposit.Rate
+
+    return s.advanceGoal(ctx, goal, user, deposit.Amount, today)
+}
+
+// failDeposit records a failed attempt. With retry set the deposit is tried
+// again after a backoff, until depositMaxAttempts; otherwise this salary date
+// is given up and the goal moves on to the next one.
+func (s *AutopilotService) failDeposit(ctx context.Context, goal *models.Goal, user *models.User, deposit *models.Deposit, cause error, retry bool, today time.Time) error {
+    errMsg := cause.Error()
+    deposit.Status = string(models.DepositStatusFailed)
+    deposit.Error = &errMsg
+    deposit.NextAttemptAt = nil
+
+    if retry && deposit.Attempts < depositMaxAttempts {
+        next := time.Now().Add(depositRetryBackoff(deposit.Attempts))
+        deposit.NextAttemptAt = &next
+        if err := s.depositRepo.Update(ctx, deposit); err != nil {
+            return fmt.Errorf("failed to mark deposit as failed: %w", err)
+        }
+
+        s.logger.Warn().
+            Err(cause).
+            Int("depositId", deposit.ID).
+            Int("attempt", deposit.Attempts).
+            Time("nextAttemptAt", next).
+            Msg("Autopilot deposit will be retried")
+        return nil
+    }
+
+    if err := s.depositRepo.Update(ctx, deposit); err != nil {
+        return fmt.Errorf("failed to mark deposit as failed: %w", err)
+    }
+
+    s.recordOperation(ctx, deposit, models.OperationStatusFailed, &errMsg)
+
+    if err := s.advanceGoal(ctx, goal, user, 0, today); err != nil {
+        return err
+    }
+    return cause
+}
+
+// resumeScheduled handles a salary date that already has a deposit row.
+// If the deposit was opened but the goal was not updated (e.g. crash in between),
+// finish the bookkeeping. A deposit pending for too long is reconciled with the
+// bank, and a failed one that will not be retried moves the goal on.
+func (s *AutopilotService) resumeScheduled(ctx context.Context, goal *models.Goal, user *models.User, scheduledFor, today time.Time) error {
+    existing, err := s.depositRepo.GetScheduled(ctx, goal.ID, scheduledFor)
+    if err != nil {
+        return err
+    }
+
+    if existing == nil {
+        return nil
+    }
+
+    switch existing.Status {
+    case string(models.DepositStatusActive):
+        s.logger.Info().
+            Int("goalId", goal.ID).
+            Int("depositId", existing.ID).
+            Msg("Deposit already opened, finishing goal update")
+        return s.advanceGoal(ctx, goal, user, existing.Amount, today)
+
+    case string(models.DepositStatusPending):
+        if time.Since(existing.UpdatedAt) >= depositPendingTimeout {
+            return s.reconcilePending(ctx, goal, user, existing, today)
+        }
+
+    case string(models.DepositStatusFailed):
+        if existing.NextAttemptAt == nil {
+            return s.advanceGoal(ctx, goal, user, 0, today)
+        }
+    }
+
+    s.logger.Debug().
+        Int("goalId", goal.ID).
+        Int("depositId", existing.ID).
+        Str("status", existing.Status).
+        Msg("Scheduled deposit in progress or waiting for retry, skipping")
+
+    return nil
+}
+
+// reconcilePending settles a deposit whose bank outcome is unknown. The bank's
+// agreements are searched for one opened for it, and only if there is none
+// the deposit is failed for a retry, so the money is never put away twice.
+func (s *AutopilotService) reconcilePending(ctx context.Context, goal *models.Goal, user *models.User, deposit *models.Deposit, today time.Time) error {
+    conn, adapter, err := s.credentials.Connect(ctx, goal.UserID, goal.BankID, ConsentProducts)
+    if err != nil {
+        return err
+    }
+
+    agreements, err := adapter.GetAgreements(ctx, conn.BankToken, conn.ExternalClientID, *conn.ProductConsentID, requestingBank)
+    if err != nil {
+        return fmt.Errorf("failed to get agreements: %w", err)
+    }
+
+    for i := range agreements {
+        agreement := &agreements[i]
+        if !matchesPendingDeposit(agreement, deposit) {
+            continue
+        }
+
+        tracked, err := s.depositRepo.GetByAgreementID(ctx, agreement.AgreementID)
+        if err != nil {
+            return err
+        }
+        if tracked != nil {
+            continue
+        }
+
+        s.logger.Info().
+            Int("goalId", goal.ID).
+            Int("depositId", deposit.ID).
+            Str("agreementId", agreement.AgreementID).
+            Msg("Pending deposit was opened by the bank")
+        return s.completeDeposit(ctx, goal, user, deposit, agreement, today)
+    }
+
+    s.logger.Warn().
+        Int("goalId", goal.ID).
+        Int("depositId", deposit.ID).
+        Msg("Pending deposit was not opened by the bank")
+    return s.failDeposit(ctx, goal, user, deposit, fmt.Errorf("deposit was not opened by %s", goal.BankID), true, today)
+}
+
+func (s *AutopilotService) openDeposit(ctx context.Context, goal *models.Goal, deposit *models.Deposit) (*bankadapter.Agreement, error) {
+    conn, adapter, err := s.credentials.Connect(ctx, goal.UserID, goal.BankID, ConsentProducts)
+    if err != nil {
+        return nil, err
+    }
+
+    accounts, err := s.accountRepo.GetBankAccounts(ctx, goal.UserID, goal.BankID)
+    if err != nil {
+        return nil, err
+    }
+
+    // Accounts are ordered by balance, take the richest one in the currency
+    // of the goal
+    var source *models.Account
+    for i := range accounts {
+        if accounts[i].Currency == deposit.Currency {
+            source = &accounts[i]
+            break
+        }
+    }
+    if source == nil || source.Balance < deposit.Amount {
+        return nil, fmt.Errorf("insufficient funds in %s to open deposit of %s %s", goal.BankID, deposit.Amount, deposit.Currency)
+    }
+
+    product, termMonths, err := s.catalog.SelectDeposit(ctx, goal, deposit.Amount)
+    if err != nil {
+        return nil, err
+    }
+
+    productID := product.ProductID
+    deposit.ProductID = &productID
+    deposit.Rate = product.InterestRate
+    deposit.TermMonths = termMonths
+
+    agreement, err := adapter.OpenDeposit(
+        ctx,
+        conn.BankToken,
+        conn.ExternalClientID,
+        *conn.ProductConsentID,
+        requestingBank,
+        bankadapter.DepositRequest{
+            ProductID:       productID,
+            Amount:          deposit.Amount,
+            TermMonths:      termMonths,
+            SourceAccountID: source.ExternalID,
+        },
+    )
+    if err != nil {
+        // The product may have been withdrawn, reload the catalog next time.
+        // A bank that is down says nothing about its products.
+        if !bankadapter.IsTransient(err) {
+            s.catalog.Invalidate(goal.BankID)
+        }
+        return nil, fmt.Errorf("failed to open deposit: %w", err)
+    }
+
+    // Keep local balance roughly right until the next sync
+    if err := s.accountRepo.UpdateBalance(ctx, source.ID, source.Balance-deposit.Amount); err != nil {
+        s.logger.Warn().Err(err).Int("accountId", source.ID).Msg("Failed to update account balance")
+    }
+
+    return agreement, nil
+}
+
+// advanceGoal adds amount to the goal and moves it to the next salary date.
+// Both are written in a single update so a retry never counts a deposit twice.
+func (s *AutopilotService) advanceGoal(ctx context.Context, goal *models.Goal, user *models.User, amount money.Amount, today time.Time) error {
+    goal.CurrentAmount += amount
+
+    if goal.CurrentAmount >= goal.TargetAmount {
+        now := time.Now()
+        goal.Status = "completed"
+        goal.CompletedAt = &now
+        goal.NextDepositDate = nil
+
+        if err := s.goalRepo.Update(ctx, goal); err != nil {
+            return err
+        }
+
+        s.logger.Info().Int("goalId", goal.ID).Msg("Goal completed")
+
+        saved := goal.CurrentAmount
+        s.operations.Record(ctx, &models.Operation{
+            UserID:        goal.UserID,
+            Type:          string(models.OperationGoalCompleted),
+            Amount:        &saved,
+            RelatedGoalID: &goal.ID,
+            Status:        string(models.OperationStatusSuccess),
+            Metadata: models.JSONB{
+                "targetAmount": goal.TargetAmount,
+                "source":       "autopilot",
+            },
+        })
+        return s.activateNextGoal(ctx, user)
+    }
+
+    next := today.AddDate(0, 1, 0)
+    if len(user.SalaryDates) > 0 {
+        next = calculateNextSalaryDateAfter(user.SalaryDates, today)
+    }
+    goal.NextDepositDate = &next
+
+    return s.goalRepo.Update(ctx, goal)
+}
+
+// activateNextGoal promotes the highest priority waiting goal
+func (s *AutopilotService) activateNextGoal(ctx context.Context, user *models.User) error {
+    goals, err := s.goalRepo.GetUserGoals(ctx, user.ID)
+    if err != nil {
+        return err
+    }
+
+    for i := range goals {
+        if goals[i].Status != "waiting" {
+            continue
+        }
+
+        goals[i].Status = "active"
+        if len(user.SalaryDates) > 0 {
+            next := calculateNextSalaryDate(user.SalaryDates)
+            goals[i].NextDepositDate = &next
+        }
+        return s.goalRepo.Update(ctx, &goals[i])
+    }
+
+    return nil
+}
+
+func (s *AutopilotService) recordOperation(ctx context.Context, deposit *models.Deposit, status models.OperationStatus, errMsg *string) {
+    amount := deposit.Amount
+    metadata := models.JSONB{
+        "bankId":       deposit.BankID,
+        "currency":     deposit.Currency,
+        "scheduledFor": deposit.ScheduledFor.Format("2006-01-02"),
+        "source":       "autopilot",
+    }
+    if deposit.AgreementID != nil {
+        metadata["agreementId"] = *deposit.AgreementID
+    }
+    if deposit.ProductID != nil {
+        metadata["productId"] = *deposit.ProductID
+    }
+
+    operation := &models.Operation{
+        UserID:           deposit.UserID,
+        Type:             string(models.OperationDepositOpened),
+        Amount:           &amount,
+        RelatedGoalID:    &deposit.GoalID,
+        RelatedDepositID: &deposit.ID,
+        Status:           string(status),
+        Error:            errMsg,
+        Metadata:         metadata,
+    }
+
+    s.operations.Record(ctx, operation)
+}
+
+// matchesPendingDeposit reports whether agreement could have been opened for
+// deposit: a deposit of the same amount and currency opened after it was claimed
+func matchesPendingDeposit(agreement *bankadapter.Agreement, deposit *models.Deposit) bool {
+    if agreement.ProductType != "" && agreement.ProductType != "deposit" {
+        return false
+    }
+    if agreement.Amount != deposit.Amount {
+        return false
+    }
+    if agreement.Currency != "" && !strings.EqualFold(agreement.Currency, deposit.Currency) {
+        return false
+    }
+    if deposit.ProductID != nil && agreement.ProductID != *deposit.ProductID {
+        return false
+    }
+    return !agreement.OpenedDate.Before(truncateToDate(deposit.CreatedAt))
+}
+
+// depositRetryBackoff is the delay before the send after attempt
+func depositRetryBackoff(attempt int) time.Duration {
+    delay := depositRetryDelay << uint(attempt-1)
+    if delay <= 0 || delay > depositMaxRetryDelay {
+        delay = depositMaxRetryDelay
+    }
+    return delay
+}
+
+func truncateToDate(t time.Time) time.Time {
+    year, month, day := t.Date()
+    return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/banks"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/rs/zerolog"
+)
+
+const (
+    // tokenRefreshMargin renews tokens this long before they expire
+    tokenRefreshMargin = 5 * time.Minute
+    // consentRenewMargin renews consents this long before they expire
+    consentRenewMargin = 24 * time.Hour
+    // consentCheckInterval limits how often consent status is asked from the bank
+    consentCheckInterval = time.Hour
+)
+
+var (
+    accountConsentPermissions = []string{"ReadAccountsDetail", "ReadBalances", "ReadTransactionsDetail"}
+    productConsentPermissions = []string{"read_product_agreements", "open_product_agreements", "close_product_agreements"}
+)
+
+var (
+    // ErrReauthRequired means the user has to approve bank access again
+    ErrReauthRequired = errors.New("bank re-authorisation required")
+    // ErrConsentPending means a consent waits for the user's approval in the bank
+    ErrConsentPending = errors.New("bank consent awaiting approval")
+)
+
+// ConsentKind names a consent a bank call depends on
+type ConsentKind string
+
+const (
+    ConsentAccounts ConsentKind = "account"
+    ConsentProducts ConsentKind = "product"
+)
+
+// BankCredentials keeps bank tokens and consents of a connection usable.
+// Every call to a bank on behalf of a user should get its connection here.
+type BankCredentials struct {
+    bankRepo    repository.BankRepository
+    bankFactory *banks.Factory
+    logger      *zerolog.Logger
+}
+
+func NewBankCredentials(
+    bankRepo repository.BankRepository,
+    bankFactory *banks.Factory,
+    logger *zerolog.Logger,
+) *BankCredentials {
+    return &BankCredentials{
+        bankRepo:    bankRepo,
+        bankFactory: bankFactory,
+        logger:      logger,
+    }
+}
+
+// Connect loads the user's connection to bankID and makes sure its token and
+// the listed consents are valid
+func (c *BankCredentials) Connect(ctx context.Context, userID int, bankID string, consents ...ConsentKind) (*models.BankConnection, bankadapter.BankAdapter, error) {
+    conn, err := c.bankRepo.GetConnection(ctx, userID, bankID)
+    if err != nil {
+        return nil, nil, err
+    }
+    if conn == nil || !conn.Connected {
+        return nil, nil, fmt.Errorf("bank %s is not connected", bankID)
+    }
+
+    adapter, err := c.bankFactory.CreateAdapter(bankID)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to create bank adapter: %w", err)
+    }
+
+    if err := c.Ensure(ctx, conn, adapter, consents...); err != nil {
+        return nil, nil, err
+    }
+
+    return conn, adapter, nil
+}
+
+// Ensure refreshes an expiring token and renews expired or revoked consents.
+// A consent awaiting the user's approval in the bank saves the connection
+// with status consent_pending and returns ErrConsentPending; its status is
+// asked again on the next call. A connection in reauth_required returns
+// ErrReauthRequired until the user connects the bank again.
+func (c *BankCredentials) Ensure(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter, consents ...ConsentKind) error {
+    // Stays blocked until the user connects the bank again
+    if conn.Status == string(models.ConnectionStatusReauthRequired) {
+        reason := "connect the bank again"
+        if conn.Error != nil {
+            reason = *conn.Error
+        }
+        return fmt.Errorf("%w: %s", ErrReauthRequired, reason)
+    }
+
+    now := time.Now()
+    changed := false
+
+    if conn.TokenExpiresAt == nil || conn.TokenExpiresAt.Before(now.Add(tokenRefreshMargin)) {
+        if err := c.refreshToken(ctx, conn, adapter, now); err != nil {
+            return c.fail(ctx, conn, fmt.Sprintf("token refresh failed: %v", err), false)
+        }
+        changed = true
+    }
+
+    for _, kind := range consents {
+        var (
+            renewed bool
+            reason  string
+            err     error
+        )
+
+        switch kind {
+        case ConsentAccounts:
+            renewed, reason, err = c.ensureAccountConsent(ctx, conn, adapter, now)
+        case ConsentProducts:
+            renewed, reason, err = c.ensureProductConsent(ctx, conn, adapter, now)
+        default:
+            err = fmt.Errorf("unknown consent kind %q", kind)
+        }
+
+        if err != nil {
+            return c.fail(ctx, conn, fmt.Sprintf("%s consent renewal failed: %v", kind, err), false)
+        }
+        if reason != "" {
+            return c.fail(ctx, conn, reason, true)
+        }
+        changed = changed || renewed
+    }
+
+    if conn.Status != string(models.ConnectionStatusActive) || conn.Error != nil {
+        conn.Status = string(models.ConnectionStatusActive)
+        conn.Error = nil
+        changed = true
+    }
+
+    if changed {
+        if err := c.bankRepo.UpdateConnection(ctx, conn); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (c *BankCredentials) refreshToken(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter, now time.Time) error {
+    // Bank tokens are issued for client credentials and carry no refresh token
+    token, err := adapter.GetBankToken(ctx)
+    if err != nil {
+        return err
+    }
+
+    expiresAt := now.Add(time.Duration(token.ExpiresIn) * time.Second)
+    conn.BankToken = token.AccessToken
+    conn.TokenExpiresAt = &expiresAt
+
+    c.logger.Debug().Int("connectionId", conn.ID).Str("bankId", conn.BankID).Msg("Bank token refreshed")
+
+    return nil
+}
+
+// ensureAccountConsent asks the bank for the consent status at most once per
+// consentCheckInterval. Returns a non-empty reason if the user must act.
+func (c *BankCredentials) ensureAccountConsent(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter, now time.Time) (bool, string, error) {
+    if conn.AccountConsentID == nil {
+        return c.renewAccountConsent(ctx, conn, adapter)
+    }
+
+    expiring := conn.AccountConsentExpiresAt != nil && conn.AccountConsentExpiresAt.Before(now.Add(consentRenewMargin))
+    checked := conn.ConsentsCheckedAt != nil && conn.ConsentsCheckedAt.After(now.Add(-consentCheckInterval))
+    if !expiring && checked {
+        return false, "", nil
+    }
+
+    consent, err := adapter.GetConsent(ctx, conn.BankToken, *conn.AccountConsentID)
+    if err != nil {
+        // Status is unknown, keep using the consent and let the call itself fail
+        c.logger.Warn().Err(err).Int("connectionId", conn.ID).Msg("Failed to check account consent")
+        return false, "", nil
+    }
+    conn.ConsentsCheckedAt = &now
+
+    switch consentState(consent.Status) {
+    case consentPending:
+        // Not marked as checked, so the next call asks again
+        conn.ConsentsCheckedAt = nil
+        return true, "account consent is awaiting approval in the bank", nil
+    case consentInvalid:
+        c.logger.Info().Int("connectionId", conn.ID).Str("status", consent.Status).Msg("Account consent is no longer valid")
+        return c.renewAccountConsent(ctx, conn, adapter)
+    }
+
+    if !consent.ExpiresAt.IsZero() {
+        conn.AccountConsentExpiresAt = &consent.ExpiresAt
+        if consent.ExpiresAt.Before(now.Add(consentRenewMargin)) {
+            return c.renewAccountConsent(ctx, conn, adapter)
+        }
+    }
+
+    return true, "", nil
+}
+
+// ensureProductConsent renews by stored expiry only: GetConsent covers
+// account consents, product consents have no status endpoint. A product
+// consent still awaiting approval is therefore used by the next call and
+// the bank refuses it until the user approves.
+func (c *BankCredentials) ensureProductConsent(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter, now time.Time) (bool, string, error) {
+    if conn.ProductConsentID != nil &&
+        (conn.ProductConsentExpiresAt == nil || conn.ProductConsentExpiresAt.After(now.Add(consentRenewMargin))) {
+        return false, "", nil
+    }
+
+    consent, err := adapter.CreateProductConsent(ctx, conn.BankToken, conn.ExternalClientID, requestingBank, productConsentPermissions)
+    if err != nil {
+        return false, "", err
+    }
+
+    conn.ProductConsentID = &consent.ConsentID
+    conn.ProductConsentExpiresAt = consentExpiry(consent)
+
+    if !consent.AutoApproved && consentState(consent.Status) != consentActive {
+        return true, "new product consent is awaiting approval in the bank", nil
+    }
+
+    c.logger.Info().Int("connectionId", conn.ID).Msg("Product consent renewed")
+    return true, "", nil
+}
+
+func (c *BankCredentials) renewAccountConsent(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter) (bool, string, error) {
+    consent, err := adapter.CreateAccountConsent(ctx, conn.BankToken, conn.ExternalClientID, requestingBank, accountConsentPermissions)
+    if err != nil {
+        return false, "", err
+    }
+
+    conn.AccountConsentID = &consent.ConsentID
+    conn.AccountConsentExpiresAt = consentExpiry(consent)
+    conn.ConsentsCheckedAt = nil
+
+    if !consent.AutoApproved && consentState(consent.Status) != consentActive {
+        return true, "new account consent is awaiting approval in the bank", nil
+    }
+
+    now := time.Now()
+    conn.ConsentsCheckedAt = &now
+
+    c.logger.Info().Int("connectionId", conn.ID).Msg("Account consent renewed")
+    return true, "", nil
+}
+
+// fail stores the error on the connection. pending marks that a consent
+// waits for the user's approval in the bank, which is checked again on the
+// next call; other errors are expected to pass on retry.
+func (c *BankCredentials) fail(ctx context.Context, conn *models.BankConnection, reason string, pending bool) error {
+    conn.Error = &reason
+    if pending {
+        conn.Status = string(models.ConnectionStatusConsentPending)
+    }
+
+    if err := c.bankRepo.UpdateConnection(ctx, conn); err != nil {
+        c.logger.Error().Err(err).Int("connectionId", conn.ID).Msg("Failed to save connection error")
+    }
+
+    c.logger.Warn().
+        Int("connectionId", conn.ID).
+        Str("bankId", conn.BankID).
+        Bool("pending", pending).
+        Msg(reason)
+
+    if pending {
+        return fmt.Errorf("%w: %s", ErrConsentPending, reason)
+    }
+    return errors.New(reason)
+}
+
+type consentStatus int
+
+const (
+    consentActive consentStatus = iota
+    consentPending
+    consentInvalid
+)
+
+// consentState normalises consent statuses of the sandbox banks
+func consentState(status string) consentStatus {
+    switch strings.ToLower(status) {
+    case "pending", "awaitingauthorisation", "awaitingauthorization":
+        return consentPending
+    case "revoked", "expired", "rejected", "cancelled":
+        return consentInvalid
+    default:
+        return consentActive
+    }
+}
+
+func consentExpiry(consent *bankadapter.ConsentResponse) *time.Time {
+    if consent.ExpiresAt.IsZero() {
+        return nil
+    }
+    expiresAt := consent.ExpiresAt
+    return &expiresAt
+}
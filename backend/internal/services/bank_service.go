@@ -12,11 +12,30 @@ import (
     "github.com/rs/zerolog"
 )
 
+// requestingBank identifies our platform to the bank APIs
+const requestingBank = "team242"
+
+// maxSyncPages bounds one sync of an account, the rest is loaded next time
+const maxSyncPages = 200
+
+// TransactionSyncConfig controls how transactions are loaded from banks
+type TransactionSyncConfig struct {
+    PageSize       int
+    // Overlap is re-read before the watermark to catch late postings
+    Overlap        time.Duration
+    // BackfillMonths is the history loaded for a new account when the bank
+    // does not report how far back the consent reaches
+    BackfillMonths int
+}
+
 type BankService struct {
     bankRepo       repository.BankRepository
     accountRepo    repository.AccountRepository
     transactionRepo repository.TransactionRepository
     bankFactory    *banks.Factory
+    credentials    *BankCredentials
+    categories     *CategoryService
+    sync           TransactionSyncConfig
     logger         *zerolog.Logger
 }
 
@@ -25,6 +44,9 @@ func NewBankService(
     accountRepo repository.AccountRepository,
     transactionRepo repository.TransactionRepository,
     bankFactory *banks.Factory,
+    credentials *BankCredentials,
+    categories *CategoryService,
+    sync TransactionSyncConfig,
     logger *zerolog.Logger,
 ) *BankService {
     return &BankService{
@@ -32,22 +54,39 @@ func NewBankService(
         accountRepo:     accountRepo,
         transactionRepo: transactionRepo,
         bankFactory:     bankFactory,
+        credentials:     credentials,
+        categories:      categories,
+        sync:            sync,
         logger:          logger,
     }
 }
 
-// GetAllBanks returns all available banks
+// GetAllBanks returns all available banks, those the factory can create an
+// adapter for
 func (s *BankService) GetAllBanks(ctx context.Context) ([]models.Bank, error) {
-    return s.bankRepo.GetAll(ctx)
+    all, err := s.bankRepo.GetAll(ctx)
+    if err != nil {
+        return nil, err
+    }
+    
+    available := make([]models.Bank, 0, len(all))
+    for _, bank := range all {
+        if s.bankFactory.ValidateBankID(bank.ID) {
+            available = append(available, bank)
+        }
+    }
+    
+    return available, nil
 }
 
 // ConnectBank connects user to a bank
 func (s *BankService) ConnectBank(ctx context.Context, userID int, bankID string) (*models.BankConnection, error) {
     s.logger.Info().Int("userId", userID).Str("bankId", bankID).Msg("Connecting bank")
     
-    // Check if already connected
+    // Check if already connected, a connection needing re-authorisation
+    // goes through consent creation again
     existing, _ := s.bankRepo.GetConnection(ctx, userID, bankID)
-    if existing != nil && existing.Connected {
+    if existing != nil && existing.Connected && existing.Status != string(models.ConnectionStatusReauthRequired) {
         return existing, nil
     }
     
@@ -57,11 +96,8 @@ func (s *BankService) ConnectBank(ctx context.Context, userID int, bankID string
         return nil, fmt.Errorf("failed to create bank adapter: %w", err)
     }
     
-    // Get bank info
-    bankInfo := adapter.GetBankInfo()
-    
     // Get bank token
-    tokenResp, err := adapter.GetBankToken()
+    tokenResp, err := adapter.GetBankToken(ctx)
     if err != nil {
         s.logger.Error().Err(err).Str("bankId", bankID).Msg("Failed to get bank token")
         return nil, fmt.Errorf("failed to get bank token: %w", err)
@@ -72,10 +108,11 @@ func (s *BankService) ConnectBank(ctx context.Context, userID int, bankID string
     
     // Create account consent
     accountConsent, err := adapter.CreateAccountConsent(
+        ctx,
         tokenResp.AccessToken,
         externalClientID,
-        "team242",
-        []string{"ReadAccountsDetail", "ReadBalances", "ReadTransactionsDetail"},
+        requestingBank,
+        accountConsentPermissions,
     )
     if err != nil {
         s.logger.Error().Err(err).Msg("Failed to create account consent")
@@ -84,10 +121,11 @@ func (s *BankService) ConnectBank(ctx context.Context, userID int, bankID string
     
     // Create product consent
     productConsent, err := adapter.CreateProductConsent(
+        ctx,
         tokenResp.AccessToken,
         externalClientID,
-        "team242",
-        []string{"read_product_agreements", "open_product_agreements", "close_product_agreements"},
+        requestingBank,
+        productConsentPermissions,
     )
     if err != nil {
         s.logger.Error().Err(err).Msg("Failed to create product consent")
@@ -96,21 +134,41 @@ func (s *BankService) ConnectBank(ctx context.Context, userID int, bankID string
     
     // Save connection
     connection := &models.BankConnection{
-        UserID:             userID,
-        BankID:             bankID,
-        ExternalClientID:   externalClientID,
-        BankToken:          tokenResp.AccessToken,
+        UserID:                  userID,
+        BankID:                  bankID,
+        ExternalClientID:        externalClientID,
+        BankToken:               tokenResp.AccessToken,
         
-        AccountConsentID:   &accountConsent.ConsentID,
-        ProductConsentID:   &productConsent.ConsentID,
-        Connected:          true,
+        AccountConsentID:        &accountConsent.ConsentID,
+        ProductConsentID:        &productConsent.ConsentID,
+        AccountConsentExpiresAt: consentExpiry(accountConsent),
+        ProductConsentExpiresAt: consentExpiry(productConsent),
+        Status:                  string(models.ConnectionStatusActive),
+        Connected:               true,
     }
     
     // Update token expiry
-    expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+    now := time.Now()
+    expiresAt := now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
     connection.TokenExpiresAt = &expiresAt
     
-    if err := s.bankRepo.CreateConnection(ctx, connection); err != nil {
+    // A consent awaiting approval in the bank is checked again on the next call
+    if !accountConsent.AutoApproved && consentState(accountConsent.Status) != consentActive {
+        connection.Status = string(models.ConnectionStatusConsentPending)
+    } else {
+        connection.ConsentsCheckedAt = &now
+    }
+    
+    if existing != nil {
+        // Reconnect reuses the row, user_banks is unique per user and bank
+        connection.ID = existing.ID
+        connection.ConnectedAt = existing.ConnectedAt
+        connection.LastSyncAt = existing.LastSyncAt
+        if err := s.bankRepo.UpdateConnection(ctx, connection); err != nil {
+            s.logger.Error().Err(err).Msg("Failed to save bank connection")
+            return nil, fmt.Errorf("failed to save connection: %w", err)
+        }
+    } else if err := s.bankRepo.CreateConnection(ctx, connection); err != nil {
         s.logger.Error().Err(err).Msg("Failed to save bank connection")
         return nil, fmt.Errorf("failed to save connection: %w", err)
     }
@@ -156,6 +214,14 @@ func (s *BankService) SyncBanks(ctx context.Context, userID int) (*models.SyncBa
             continue
         }
         
+        if err := s.credentials.Ensure(ctx, &conn, adapter, ConsentAccounts); err != nil {
+            response.FailedBanks = append(response.FailedBanks, models.BankSyncError{
+                BankID: conn.BankID,
+                Error:  err.Error(),
+            })
+            continue
+        }
+        
         if err := s.syncBankData(ctx, &conn, adapter); err != nil {
             response.FailedBanks = append(response.FailedBanks, models.BankSyncError{
                 BankID: conn.BankID,
@@ -173,97 +239,208 @@ func (s *BankService) SyncBanks(ctx context.Context, userID int) (*models.SyncBa
 func (s *BankService) syncBankData(ctx context.Context, conn *models.BankConnection, adapter bankadapter.BankAdapter) error {
     // Get accounts
     accounts, err := adapter.GetAccounts(
+        ctx,
         conn.BankToken,
         conn.ExternalClientID,
         *conn.AccountConsentID,
-        "team242",
+        requestingBank,
     )
     if err != nil {
         return fmt.Errorf("failed to get accounts: %w", err)
     }
     
-    // Save accounts
     for _, acc := range accounts {
-        dbAccount := models.Account{
-            UserID:         conn.UserID,
-            UserBankID:     conn.ID,
-            BankID:         conn.BankID,
-            ExternalID:     acc.ID,
-            Identification: acc.Identification,
-            SchemeName:     &acc.SchemeName,
-            AccountType:    &acc.AccountType,
-            Nickname:       &acc.Nickname,
-            Balance:        acc.Balance.Amount,
-            Currency:       acc.Balance.Currency,
-            ServicerName:   &acc.Servicer.Name,
-            IsActive:       true,
-        }
-        
         // Check if account exists
-        existing, _ := s.accountRepo.GetByExternalID(ctx, conn.ID, acc.ID)
-        if existing != nil {
-            // Update balance
-            s.accountRepo.UpdateBalance(ctx, existing.ID, acc.Balance.Amount)
+        dbAccount, _ := s.accountRepo.GetByExternalID(ctx, conn.ID, acc.ID)
+        isNew := dbAccount == nil
+        
+        if isNew {
+            dbAccount = &models.Account{
+                UserID:         conn.UserID,
+                UserBankID:     conn.ID,
+                BankID:         conn.BankID,
+                ExternalID:     acc.ID,
+                Identification: acc.Identification,
+                SchemeName:     &acc.SchemeName,
+                AccountType:    &acc.AccountType,
+                Nickname:       &acc.Nickname,
+                Balance:        acc.Balance.Amount,
+                Currency:       acc.Balance.Currency,
+                ServicerName:   &acc.Servicer.Name,
+                IsActive:       true,
+            }
+            if err := s.accountRepo.Create(ctx, dbAccount); err != nil {
+                s.logger.Warn().Err(err).Str("accountId", acc.ID).Msg("Failed to save account")
+                continue
+            }
         } else {
-            // Create new
-            s.accountRepo.Create(ctx, &dbAccount)
+            s.accountRepo.UpdateBalance(ctx, dbAccount.ID, acc.Balance.Amount)
+        }
+        
+        // A failed account keeps its cursor and resumes on the next sync
+        if err := s.syncAccountTransactions(ctx, conn, adapter, dbAccount, isNew); err != nil {
+            s.logger.Warn().Err(err).Str("accountId", acc.ID).Msg("Failed to sync transactions")
+        }
+        
+        // Cancelled by the client or shutdown, the rest waits for the next sync
+        if err := ctx.Err(); err != nil {
+            return fmt.Errorf("sync interrupted: %w", err)
         }
     }
     
-    // Get transactions for each account (last 3 months)
-    fromDate := time.Now().AddDate(0, -3, 0)
-    toDate := time.Now()
+    // Update last sync time
+    now := time.Now()
+    conn.LastSyncAt = &now
+    s.bankRepo.UpdateConnection(ctx, conn)
     
-    for _, acc := range accounts {
-        transactions, err := adapter.GetTransactions(
+    return nil
+}
+
+// syncAccountTransactions pages through transactions of one account from
+// its watermark to now. The cursor is saved after every page, so a sync
+// interrupted half way continues from the page it stopped at.
+func (s *BankService) syncAccountTransactions(
+    ctx context.Context,
+    conn *models.BankConnection,
+    adapter bankadapter.BankAdapter,
+    account *models.Account,
+    isNew bool,
+) error {
+    from, to, page, backfill := s.syncWindow(ctx, conn, account, isNew)
+    
+    loaded := 0
+    previousFirst := ""
+    
+    for pages := 0; ; pages++ {
+        if pages == maxSyncPages {
+            // Leave the cursor, the next sync carries on from here
+            s.logger.Warn().Int("accountId", account.ID).Int("page", page).Msg("Transaction sync stopped at page limit")
+            return nil
+        }
+        
+        result, err := adapter.GetTransactions(
+            ctx,
             conn.BankToken,
-            acc.ID,
+            account.ExternalID,
             *conn.AccountConsentID,
-            "team242",
-            fromDate,
-            toDate,
-            100,
+            requestingBank,
+            from,
+            to,
+            page,
+            s.sync.PageSize,
         )
         if err != nil {
-            s.logger.Warn().Err(err).Str("accountId", acc.ID).Msg("Failed to get transactions")
-            continue
+            return fmt.Errorf("failed to get transactions page %d: %w", page, err)
         }
         
-        // Get DB account
-        dbAccount, err := s.accountRepo.GetByExternalID(ctx, conn.ID, acc.ID)
-        if err != nil {
+        // The first connection loads as much history as the consent gives
+        if backfill && !result.FirstAvailable.IsZero() && result.FirstAvailable.Before(from) {
+            from = result.FirstAvailable
+            backfill = false
             continue
         }
+        backfill = false
         
-        // Save transactions
-        dbTransactions := make([]models.Transaction, 0, len(transactions))
-        for _, tx := range transactions {
-            dbTx := models.Transaction{
-                AccountID:            dbAccount.ID,
-                ExternalID:           tx.TransactionID,
-                BookingDateTime:      tx.BookingDateTime,
-                ValueDateTime:        &tx.ValueDateTime,
-                Amount:               tx.Amount,
-                Currency:             tx.Currency,
-                Description:          &tx.TransactionInfo.Description,
-                CreditDebitIndicator: &tx.CreditDebitIndicator,
-                CounterpartyName:     &tx.CounterpartyName,
-                CounterpartyAccount:  &tx.CounterpartyAccount,
-                Category:             &tx.Category,
-                IsSalary:             false,
-            }
-            dbTransactions = append(dbTransactions, dbTx)
+        if len(result.Transactions) == 0 {
+            break
         }
         
-        if len(dbTransactions) > 0 {
-            s.transactionRepo.CreateBatch(ctx, dbTransactions)
+        // A bank ignoring the page parameter returns the same page again
+        if result.Transactions[0].TransactionID == previousFirst {
+            s.logger.Warn().Int("accountId", account.ID).Msg("Bank returned the same transactions page twice")
+            break
+        }
+        previousFirst = result.Transactions[0].TransactionID
+        
+        if err := s.saveTransactions(ctx, conn.UserID, account.ID, result.Transactions); err != nil {
+            return err
+        }
+        loaded += len(result.Transactions)
+        
+        if !result.HasMore {
+            break
+        }
+        
+        page++
+        if err := s.accountRepo.UpdateSyncCursor(ctx, account.ID, from, to, page); err != nil {
+            return err
         }
     }
     
-    // Update last sync time
-    now := time.Now()
-    conn.LastSyncAt = &now
-    s.bankRepo.UpdateConnection(ctx, conn)
+    if err := s.accountRepo.ClearSyncCursor(ctx, account.ID); err != nil {
+        return err
+    }
+    
+    s.logger.Debug().
+        Int("accountId", account.ID).
+        Time("from", from).
+        Int("transactions", loaded).
+        Msg("Account transactions synced")
+    
+    return nil
+}
+
+// syncWindow decides where a sync of the account starts: the saved cursor of
+// an interrupted sync, the newest booked transaction or the last sync less
+// the overlap for late postings, or a full backfill for a new account
+func (s *BankService) syncWindow(
+    ctx context.Context,
+    conn *models.BankConnection,
+    account *models.Account,
+    isNew bool,
+) (from, to time.Time, page int, backfill bool) {
+    if account.SyncPage != nil && account.SyncFrom != nil && account.SyncTo != nil {
+        return *account.SyncFrom, *account.SyncTo, *account.SyncPage, false
+    }
+    
+    to = time.Now()
+    
+    latest, err := s.transactionRepo.GetLatestBookingTime(ctx, account.ID)
+    if err != nil {
+        s.logger.Warn().Err(err).Int("accountId", account.ID).Msg("Failed to get sync watermark")
+    }
+    
+    switch {
+    case latest != nil:
+        from = latest.Add(-s.sync.Overlap)
+    case !isNew && conn.LastSyncAt != nil:
+        from = conn.LastSyncAt.Add(-s.sync.Overlap)
+    default:
+        from = to.AddDate(0, -s.sync.BackfillMonths, 0)
+        backfill = true
+    }
+    
+    return from, to, 1, backfill
+}
+
+// saveTransactions stores one page of bank transactions. Pages overlap with
+// what is already stored, duplicates are skipped by the repository.
+func (s *BankService) saveTransactions(ctx context.Context, userID, accountID int, transactions []bankadapter.Transaction) error {
+    dbTransactions := make([]models.Transaction, 0, len(transactions))
+    for _, tx := range transactions {
+        dbTransactions = append(dbTransactions, models.Transaction{
+            AccountID:            accountID,
+            ExternalID:           tx.TransactionID,
+            BookingDateTime:      tx.BookingDateTime,
+            ValueDateTime:        &tx.ValueDateTime,
+            Amount:               tx.Amount,
+            Currency:             tx.Currency,
+            Description:          &tx.TransactionInfo.Description,
+            CreditDebitIndicator: &tx.CreditDebitIndicator,
+            CounterpartyName:     &tx.CounterpartyName,
+            CounterpartyAccount:  &tx.CounterpartyAccount,
+            Category:             &tx.Category,
+            IsSalary:             false,
+        })
+    }
+    
+    if err := s.categories.Categorize(ctx, userID, dbTransactions); err != nil {
+        s.logger.Warn().Err(err).Int("accountId", accountID).Msg("Failed to categorize transactions")
+    }
+    
+    if err := s.transactionRepo.CreateBatch(ctx, dbTransactions); err != nil {
+        return err
+    }
     
     return nil
 }
@@ -0,0 +1,235 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/rs/zerolog"
+)
+
+type transactionsCall struct {
+    from time.Time
+    page int
+}
+
+// fakeTransactionsAdapter answers GetTransactions from pages, keyed by the
+// page number
+type fakeTransactionsAdapter struct {
+    bankadapter.BankAdapter
+    firstAvailable time.Time
+    pages          map[int]*bankadapter.TransactionPage
+    failPage       int
+    calls          []transactionsCall
+}
+
+func (a *fakeTransactionsAdapter) GetTransactions(ctx context.Context, token, accountID, consentID, requestingBank string, from, to time.Time, page, limit int) (*bankadapter.TransactionPage, error) {
+    a.calls = append(a.calls, transactionsCall{from: from, page: page})
+    if page == a.failPage {
+        return nil, errors.New("bank unavailable")
+    }
+
+    result := &bankadapter.TransactionPage{Page: page, FirstAvailable: a.firstAvailable}
+    if p, ok := a.pages[page]; ok {
+        result.Transactions = p.Transactions
+        result.HasMore = p.HasMore
+    }
+    return result, nil
+}
+
+// transactionsPage makes a page of n transactions whose IDs start with prefix
+func transactionsPage(prefix string, n int, hasMore bool) *bankadapter.TransactionPage {
+    page := &bankadapter.TransactionPage{HasMore: hasMore}
+    for i := 0; i < n; i++ {
+        page.Transactions = append(page.Transactions, bankadapter.Transaction{
+            TransactionID:   fmt.Sprintf("%s-%d", prefix, i),
+            BookingDateTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+        })
+    }
+    return page
+}
+
+func TestSyncAccountTransactions(t *testing.T) {
+    syncConfig := TransactionSyncConfig{PageSize: 2, Overlap: 48 * time.Hour, BackfillMonths: 12}
+
+    latest := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+    lastSync := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+    firstAvailable := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+    cursorFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    cursorTo := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    cursorPage := 3
+
+    twoPages := map[int]*bankadapter.TransactionPage{
+        1: transactionsPage("a", 2, true),
+        2: transactionsPage("b", 1, false),
+    }
+
+    tests := []struct {
+        name           string
+        isNew          bool
+        latest         *time.Time
+        lastSync       *time.Time
+        account        models.Account
+        firstAvailable time.Time
+        pages          map[int]*bankadapter.TransactionPage
+        failPage       int
+
+        // wantFrom is the start of the window asked from the bank, a zero
+        // value means a backfill of syncConfig.BackfillMonths
+        wantFrom    time.Time
+        wantPages   []int
+        wantCursors []int
+        wantSaved   int
+        wantCleared bool
+        wantErr     bool
+    }{
+        {
+            name:        "new account backfills",
+            isNew:       true,
+            pages:       twoPages,
+            wantPages:   []int{1, 2},
+            wantCursors: []int{2},
+            wantSaved:   3,
+            wantCleared: true,
+        },
+        {
+            name:           "new account backfills what the consent gives",
+            isNew:          true,
+            firstAvailable: firstAvailable,
+            pages:          twoPages,
+            wantFrom:       firstAvailable,
+            wantPages:      []int{1, 1, 2},
+            wantCursors:    []int{2},
+            wantSaved:      3,
+            wantCleared:    true,
+        },
+        {
+            name:           "stored transactions start from the watermark",
+            latest:         &latest,
+            lastSync:       &lastSync,
+            firstAvailable: firstAvailable,
+            pages:          twoPages,
+            wantFrom:       latest.Add(-syncConfig.Overlap),
+            wantPages:      []int{1, 2},
+            wantCursors:    []int{2},
+            wantSaved:      3,
+            wantCleared:    true,
+        },
+        {
+            name:        "no stored transactions start from the last sync",
+            lastSync:    &lastSync,
+            pages:       twoPages,
+            wantFrom:    lastSync.Add(-syncConfig.Overlap),
+            wantPages:   []int{1, 2},
+            wantCursors: []int{2},
+            wantSaved:   3,
+            wantCleared: true,
+        },
+        {
+            name:        "saved cursor resumes an interrupted sync",
+            latest:      &latest,
+            account:     models.Account{SyncFrom: &cursorFrom, SyncTo: &cursorTo, SyncPage: &cursorPage},
+            pages:       map[int]*bankadapter.TransactionPage{3: transactionsPage("c", 2, true), 4: transactionsPage("d", 2, false)},
+            wantFrom:    cursorFrom,
+            wantPages:   []int{3, 4},
+            wantCursors: []int{4},
+            wantSaved:   4,
+            wantCleared: true,
+        },
+        {
+            name:        "empty window",
+            latest:      &latest,
+            wantFrom:    latest.Add(-syncConfig.Overlap),
+            wantPages:   []int{1},
+            wantCleared: true,
+        },
+        {
+            name:   "bank repeating a page stops",
+            latest: &latest,
+            pages: map[int]*bankadapter.TransactionPage{
+                1: transactionsPage("a", 2, true),
+                2: transactionsPage("a", 2, true),
+            },
+            wantFrom:    latest.Add(-syncConfig.Overlap),
+            wantPages:   []int{1, 2},
+            wantCursors: []int{2},
+            wantSaved:   2,
+            wantCleared: true,
+        },
+        {
+            name:        "failed page keeps the cursor",
+            latest:      &latest,
+            pages:       twoPages,
+            failPage:    2,
+            wantFrom:    latest.Add(-syncConfig.Overlap),
+            wantPages:   []int{1, 2},
+            wantCursors: []int{2},
+            wantSaved:   2,
+            wantErr:     true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            logger := zerolog.Nop()
+            accounts := &fakeAccountRepo{}
+            transactions := &fakeTransactionRepo{latest: tt.latest}
+            categories := NewCategoryService(fakeRuleRepo{}, transactions, accounts, &logger)
+            service := NewBankService(nil, accounts, transactions, nil, nil, categories, syncConfig, &logger)
+
+            consentID := "consent"
+            conn := &models.BankConnection{UserID: 1, AccountConsentID: &consentID, LastSyncAt: tt.lastSync}
+            adapter := &fakeTransactionsAdapter{firstAvailable: tt.firstAvailable, pages: tt.pages, failPage: tt.failPage}
+            account := tt.account
+            account.ID = 1
+
+            start := time.Now()
+            err := service.syncAccountTransactions(context.Background(), conn, adapter, &account, tt.isNew)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+            }
+
+            var pages []int
+            for _, call := range adapter.calls {
+                pages = append(pages, call.page)
+            }
+            if fmt.Sprint(pages) != fmt.Sprint(tt.wantPages) {
+                t.Errorf("pages asked = %v, want %v", pages, tt.wantPages)
+            }
+
+            // The backfill decision is taken on the first page, the window is
+            // what the later pages ask for
+            from := adapter.calls[len(adapter.calls)-1].from
+            if tt.wantFrom.IsZero() {
+                backfillFrom := start.AddDate(0, -syncConfig.BackfillMonths, 0)
+                if from.Before(backfillFrom.Add(-time.Minute)) || from.After(backfillFrom.Add(time.Minute)) {
+                    t.Errorf("from = %v, want about %v", from, backfillFrom)
+                }
+            } else if !from.Equal(tt.wantFrom) {
+                t.Errorf("from = %v, want %v", from, tt.wantFrom)
+            }
+
+            var cursors []int
+            for _, cursor := range accounts.cursors {
+                if !cursor.from.Equal(from) {
+                    t.Errorf("cursor from = %v, want %v", cursor.from, from)
+                }
+                cursors = append(cursors, cursor.page)
+            }
+            if fmt.Sprint(cursors) != fmt.Sprint(tt.wantCursors) {
+                t.Errorf("cursor pages = %v, want %v", cursors, tt.wantCursors)
+            }
+
+            if len(transactions.saved) != tt.wantSaved {
+                t.Errorf("saved %d transactions, want %d", len(transactions.saved), tt.wantSaved)
+            }
+            if accounts.cleared != tt.wantCleared {
+                t.Errorf("cursor cleared = %v, want %v", accounts.cleared, tt.wantCleared)
+            }
+        })
+    }
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/rs/zerolog"
+)
+
+// categoryKeywords is the built-in dictionary used when neither a user rule
+// nor the bank provides a category. Checked in order, first match wins.
+var categoryKeywords = []struct {
+    category string
+    keywords []string
+}{
+    {models.CategorySavings, []string{"вклад", "депозит", "deposit", "накопит"}},
+    {models.CategoryLoans, []string{"кредит", "погашение", "loan", "ипотек"}},
+    {models.CategoryGroceries, []string{"пятерочка", "перекресток", "магнит", "ашан", "лента", "вкусвилл", "продукт", "supermarket", "grocery"}},
+    {models.CategoryRestaurants, []string{"кафе", "ресторан", "кофе", "бургер", "доставка еды", "restaurant", "cafe", "coffee", "mcdonald"}},
+    {models.CategoryTransport, []string{"метро", "такси", "транспорт", "азс", "бензин", "яндекс go", "taxi", "uber", "metro", "fuel"}},
+    {models.CategoryUtilities, []string{"жкх", "жку", "коммунал", "электроэнерг", "водоканал", "utilities"}},
+    {models.CategoryCommunication, []string{"мтс", "билайн", "мегафон", "теле2", "интернет", "связь", "mobile"}},
+    {models.CategoryHealth, []string{"аптека", "клиника", "медицин", "стоматолог", "pharmacy", "clinic"}},
+    {models.CategoryEntertainment, []string{"кино", "театр", "концерт", "подписка", "netflix", "spotify", "cinema"}},
+    {models.CategoryShopping, []string{"wildberries", "ozon", "маркетплейс", "одежда", "обувь", "shop"}},
+    {models.CategoryTransfers, []string{"перевод", "сбп", "transfer"}},
+}
+
+// salaryKeywords only categorise incoming money
+var salaryKeywords = []string{"зарплат", "заработная плата", "аванс", "salary", "payroll"}
+
+// CategoryService assigns categories to transactions: user rules first, then
+// the bank's category, then the built-in dictionary. Categories set by the
+// user by hand are never overwritten.
+type CategoryService struct {
+    ruleRepo        repository.CategoryRuleRepository
+    transactionRepo repository.TransactionRepository
+    accountRepo     repository.AccountRepository
+    logger          *zerolog.Logger
+}
+
+func NewCategoryService(
+    ruleRepo repository.CategoryRuleRepository,
+    transactionRepo repository.TransactionRepository,
+    accountRepo repository.AccountRepository,
+    logger *zerolog.Logger,
+) *CategoryService {
+    return &CategoryService{
+        ruleRepo:        ruleRepo,
+        transactionRepo: transactionRepo,
+        accountRepo:     accountRepo,
+        logger:          logger,
+    }
+}
+
+// GetRules returns user rules in the order they are applied
+func (s *CategoryService) GetRules(ctx context.Context, userID int) ([]models.CategoryRule, error) {
+    rules, err := s.ruleRepo.GetUserRules(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if rules == nil {
+        rules = []models.CategoryRule{}
+    }
+    return rules, nil
+}
+
+func (s *CategoryService) CreateRule(ctx context.Context, userID int, req models.CategoryRuleRequest) (*models.CategoryRule, error) {
+    rule := &models.CategoryRule{UserID: userID}
+    if err := s.fillRule(ctx, rule, req); err != nil {
+        return nil, err
+    }
+
+    if err := s.ruleRepo.Create(ctx, rule); err != nil {
+        return nil, err
+    }
+
+    s.logger.Info().Int("userId", userID).Int("ruleId", rule.ID).Str("category", rule.Category).Msg("Category rule created")
+
+    return rule, nil
+}
+
+// UpdateRule replaces all conditions of the rule
+func (s *CategoryService) UpdateRule(ctx context.Context, userID, ruleID int, req models.CategoryRuleRequest) (*models.CategoryRule, error) {
+    rule, err := s.getUserRule(ctx, userID, ruleID)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := s.fillRule(ctx, rule, req); err != nil {
+        return nil, err
+    }
+
+    if err := s.ruleRepo.Update(ctx, rule); err != nil {
+        return nil, err
+    }
+
+    return rule, nil
+}
+
+func (s *CategoryService) DeleteRule(ctx context.Context, userID, ruleID int) error {
+    if _, err := s.getUserRule(ctx, userID, ruleID); err != nil {
+        return err
+    }
+
+    return s.ruleRepo.Delete(ctx, ruleID)
+}
+
+// Categorize sets Category and CategorySource of transactions about to be
+// saved for userID
+func (s *CategoryService) Categorize(ctx context.Context, userID int, transactions []models.Transaction) error {
+    rules, err := s.loadRules(ctx, userID)
+    if err != nil {
+        return err
+    }
+
+    for i := range transactions {
+        tx := &transactions[i]
+        if isManualCategory(tx) {
+            continue
+        }
+        tx.Category, tx.CategorySource = resolveCategory(rules, tx)
+    }
+
+    return nil
+}
+
+// ApplyRules re-categorises the whole transaction history of the user and
+// returns how many transactions changed category
+func (s *CategoryService) ApplyRules(ctx context.Context, userID int) (*models.ApplyCategoryRulesResponse, error) {
+    rules, err := s.loadRules(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    transactions, err := s.transactionRepo.GetUserTransactions(ctx, userID, time.Time{}, time.Now())
+    if err != nil {
+        return nil, err
+    }
+
+    response := &models.ApplyCategoryRulesResponse{}
+
+    for i := range transactions {
+        tx := &transactions[i]
+        if isManualCategory(tx) {
+            continue
+        }
+
+        category, source := resolveCategory(rules, tx)
+        if stringValue(category) == stringValue(tx.Category) && stringValue(source) == stringValue(tx.CategorySource) {
+            continue
+        }
+
+        if err := s.transactionRepo.UpdateCategory(ctx, tx.ID, category, source); err != nil {
+            return nil, err
+        }
+        response.Updated++
+    }
+
+    s.logger.Info().Int("userId", userID).Int("updated", response.Updated).Msg("Category rules applied")
+
+    return response, nil
+}
+
+// SetTransactionCategory sets the category by hand. Rules never change it afterwards.
+func (s *CategoryService) SetTransactionCategory(ctx context.Context, userID, transactionID int, category string) (*models.Transaction, error) {
+    tx, err := s.transactionRepo.GetByID(ctx, transactionID)
+    if err != nil {
+        return nil, err
+    }
+
+    account, err := s.accountRepo.GetByID(ctx, tx.AccountID)
+    if err != nil || account.UserID != userID {
+        return nil, fmt.Errorf("transaction does not belong to user")
+    }
+
+    category = strings.ToLower(strings.TrimSpace(category))
+    source := string(models.CategorySourceManual)
+    if err := s.transactionRepo.UpdateCategory(ctx, tx.ID, &category, &source); err != nil {
+        return nil, err
+    }
+
+    tx.Category = &category
+    tx.CategorySource = &source
+
+    return tx, nil
+}
+
+func (s *CategoryService) getUserRule(ctx context.Context, userID, ruleID int) (*models.CategoryRule, error) {
+    rule, err := s.ruleRepo.GetByID(ctx, ruleID)
+    if err != nil {
+        return nil, err
+    }
+    if rule.UserID != userID {
+        return nil, fmt.Errorf("category rule does not belong to user")
+    }
+    return rule, nil
+}
+
+func (s *CategoryService) fillRule(ctx context.Context, rule *models.CategoryRule, req models.CategoryRuleRequest) error {
+    if req.Counterparty == nil && req.DescriptionPattern == nil &&
+        req.MinAmount == nil && req.MaxAmount == nil && req.AccountID == nil {
+        return fmt.Errorf("rule needs at least one condition")
+    }
+
+    if req.DescriptionPattern != nil {
+        if _, err := regexp.Compile(*req.DescriptionPattern); err != nil {
+            return fmt.Errorf("invalid description pattern: %w", err)
+        }
+    }
+
+    if req.MinAmount != nil && req.MaxAmount != nil && *req.MinAmount > *req.MaxAmount {
+        return fmt.Errorf("minAmount is greater than maxAmount")
+    }
+
+    if req.AccountID != nil {
+        account, err := s.accountRepo.GetByID(ctx, *req.AccountID)
+        if err != nil || account.UserID != rule.UserID {
+            return fmt.Errorf("account %d does not belong to user", *req.AccountID)
+        }
+    }
+
+    rule.Category = strings.ToLower(strings.TrimSpace(req.Category))
+    rule.Counterparty = req.Counterparty
+    rule.DescriptionPattern = req.DescriptionPattern
+    rule.MinAmount = req.MinAmount
+    rule.MaxAmount = req.MaxAmount
+    rule.AccountID = req.AccountID
+    rule.Priority = req.Priority
+
+    return nil
+}
+
+type compiledRule struct {
+    rule    models.CategoryRule
+    pattern *regexp.Regexp
+}
+
+func (s *CategoryService) loadRules(ctx context.Context, userID int) ([]compiledRule, error) {
+    rules, err := s.ruleRepo.GetUserRules(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    compiled := make([]compiledRule, 0, len(rules))
+    for _, rule := range rules {
+        c := compiledRule{rule: rule}
+        if rule.DescriptionPattern != nil {
+            // Patterns are validated on save, skip one that no longer compiles
+            pattern, err := regexp.Compile("(?i)" + *rule.DescriptionPattern)
+            if err != nil {
+                s.logger.Warn().Err(err).Int("ruleId", rule.ID).Msg("Skipping category rule with invalid pattern")
+                continue
+            }
+            c.pattern = pattern
+        }
+        compiled = append(compiled, c)
+    }
+
+    return compiled, nil
+}
+
+// matches reports whether every condition set on the rule holds for tx
+func (c compiledRule) matches(tx *models.Transaction) bool {
+    r := c.rule
+    amount := tx.Amount.Abs()
+
+    if r.AccountID != nil && *r.AccountID != tx.AccountID {
+        return false
+    }
+    if r.MinAmount != nil && amount < *r.MinAmount {
+        return false
+    }
+    if r.MaxAmount != nil && amount > *r.MaxAmount {
+        return false
+    }
+    if r.Counterparty != nil &&
+        !strings.Contains(strings.ToLower(stringValue(tx.CounterpartyName)), strings.ToLower(*r.Counterparty)) {
+        return false
+    }
+    if c.pattern != nil && !c.pattern.MatchString(stringValue(tx.Description)) {
+        return false
+    }
+
+    return true
+}
+
+// resolveCategory picks the category of a transaction that was not set by hand.
+// A category from the bank is kept only while it is still the bank's value:
+// once a rule overwrote it the original is gone and the dictionary is used.
+func resolveCategory(rules []compiledRule, tx *models.Transaction) (*string, *string) {
+    source := func(s models.CategorySource) *string {
+        v := string(s)
+        return &v
+    }
+
+    for _, c := range rules {
+        if c.matches(tx) {
+            category := c.rule.Category
+            return &category, source(models.CategorySourceRule)
+        }
+    }
+
+    fromBank := tx.CategorySource == nil || *tx.CategorySource == string(models.CategorySourceBank)
+    if fromBank && stringValue(tx.Category) != "" {
+        category := strings.ToLower(*tx.Category)
+        return &category, source(models.CategorySourceBank)
+    }
+
+    if category := dictionaryCategory(tx); category != "" {
+        return &category, source(models.CategorySourceDictionary)
+    }
+
+    return nil, nil
+}
+
+func dictionaryCategory(tx *models.Transaction) string {
+    text := strings.ToLower(stringValue(tx.CounterpartyName) + " " + stringValue(tx.Description))
+
+    if tx.Amount > 0 {
+        for _, keyword := range salaryKeywords {
+            if strings.Contains(text, keyword) {
+                return models.CategorySalary
+            }
+        }
+    }
+
+    for _, entry := range categoryKeywords {
+        for _, keyword := range entry.keywords {
+            if strings.Contains(text, keyword) {
+                return entry.category
+            }
+        }
+    }
+
+    return ""
+}
+
+func isManualCategory(tx *models.Transaction) bool {
+    return tx.CategorySource != nil && *tx.CategorySource == string(models.CategorySourceManual)
+}
+
+func stringValue(s *string) string {
+    if s == nil {
+        return ""
+    }
+    return *s
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+    "github.com/KotovBoris/AutoSave/backend/internal/fx"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+// currencyConverter brings amounts in different currencies to one currency
+// so they can be added up. Amounts without a rate are left out, a warning
+// is logged once per currency.
+type currencyConverter struct {
+    rates   *fx.Rates
+    to      string
+    missing map[string]bool
+    logger  *zerolog.Logger
+}
+
+func newCurrencyConverter(rates *fx.Rates, to string, logger *zerolog.Logger) *currencyConverter {
+    return &currencyConverter{
+        rates:   rates,
+        to:      to,
+        missing: map[string]bool{},
+        logger:  logger,
+    }
+}
+
+// convert returns amount in the target currency, false when there is no rate.
+// Amounts with no currency are taken to be in the target one.
+func (c *currencyConverter) convert(amount money.Amount, currency string) (money.Amount, bool) {
+    if currency == "" || currency == c.to {
+        return amount, true
+    }
+
+    converted, err := c.rates.Convert(amount, currency, c.to)
+    if err != nil {
+        if !c.missing[currency] {
+            c.missing[currency] = true
+            c.logger.Warn().Err(err).Str("from", currency).Str("to", c.to).Msg("Leaving out amounts without exchange rate")
+        }
+        return 0, false
+    }
+
+    return converted, true
+}
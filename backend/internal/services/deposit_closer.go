@@ -0,0 +1,62 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+// depositCloser returns deposit money through the bank API
+type depositCloser struct {
+    depositRepo repository.DepositRepository
+    credentials *BankCredentials
+}
+
+func newDepositCloser(depositRepo repository.DepositRepository, credentials *BankCredentials) *depositCloser {
+    return &depositCloser{
+        depositRepo: depositRepo,
+        credentials: credentials,
+    }
+}
+
+// Close closes deposit in the bank and locally, returning the bank
+// response and the interest lost to early closure
+func (c *depositCloser) Close(ctx context.Context, deposit *models.Deposit) (*bankadapter.CloseDepositResponse, money.Amount, error) {
+    conn, adapter, err := c.credentials.Connect(ctx, deposit.UserID, deposit.BankID, ConsentProducts)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    closed, err := adapter.CloseDeposit(
+        ctx,
+        conn.BankToken,
+        conn.ExternalClientID,
+        *conn.ProductConsentID,
+        requestingBank,
+        *deposit.AgreementID,
+    )
+    if err != nil {
+        return nil, 0, fmt.Errorf("failed to close deposit: %w", err)
+    }
+
+    closedAt := closed.ClosedAt
+    if closedAt.IsZero() {
+        closedAt = time.Now()
+    }
+
+    if err := c.depositRepo.Close(ctx, deposit.ID, closedAt); err != nil {
+        return nil, 0, err
+    }
+
+    lost := closed.PenaltyAmount
+    if lost == 0 {
+        lost = estimateLostInterest(*deposit, closed.AccruedInterest, closedAt)
+    }
+
+    return closed, lost, nil
+}
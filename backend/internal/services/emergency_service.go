@@ -0,0 +1,364 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/fx"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+type EmergencyService struct {
+    goalRepo      repository.GoalRepository
+    depositRepo   repository.DepositRepository
+    userRepo      repository.UserRepository
+    operations    *OperationService
+    closer        *depositCloser
+    rates         *fx.Rates
+    logger        *zerolog.Logger
+}
+
+func NewEmergencyService(
+    goalRepo repository.GoalRepository,
+    depositRepo repository.DepositRepository,
+    userRepo repository.UserRepository,
+    operations *OperationService,
+    credentials *BankCredentials,
+    rates *fx.Rates,
+    logger *zerolog.Logger,
+) *EmergencyService {
+    return &EmergencyService{
+        goalRepo:      goalRepo,
+        depositRepo:   depositRepo,
+        userRepo:      userRepo,
+        operations:    operations,
+        closer:        newDepositCloser(depositRepo, credentials),
+        rates:         rates,
+        logger:        logger,
+    }
+}
+
+// converter converts to the base currency of the user
+func (s *EmergencyService) converter(ctx context.Context, userID int) (*currencyConverter, error) {
+    user, err := s.userRepo.GetByID(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("user not found: %w", err)
+    }
+    return newCurrencyConverter(s.rates, user.BaseCurrency, s.logger), nil
+}
+
+// PlanWithdraw picks active deposits to close, lowest priority goals first,
+// until the returned money covers the requested amount. The amount is in
+// the base currency of the user, deposits without an exchange rate are skipped.
+func (s *EmergencyService) PlanWithdraw(ctx context.Context, userID int, amount money.Amount) (*models.EmergencyWithdrawPlan, error) {
+    s.logger.Info().Int("userId", userID).Float64("amount", amount.Float64()).Msg("Planning emergency withdrawal")
+
+    conv, err := s.converter(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    goals, deposits, err := s.loadSavings(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    // Lowest priority goal first, newest deposit first within a goal:
+    // young deposits have the least interest to lose
+    sort.SliceStable(deposits, func(i, j int) bool {
+        gi, gj := goals[deposits[i].GoalID], goals[deposits[j].GoalID]
+        if gi.Position != gj.Position {
+            return gi.Position > gj.Position
+        }
+        return depositOpenedAt(deposits[i]).After(depositOpenedAt(deposits[j]))
+    })
+
+    now := time.Now()
+    plan := &models.EmergencyWithdrawPlan{
+        Currency:        conv.to,
+        RequestedAmount: amount,
+        DepositsToClose: []models.DepositToClose{},
+        AffectedGoals:   []models.AffectedGoal{},
+    }
+
+    for _, d := range deposits {
+        if plan.TotalReturned >= amount {
+            break
+        }
+
+        accrued := estimateAccruedInterest(d, now)
+        lost := estimateLostInterest(d, accrued, now)
+        goal := goals[d.GoalID]
+
+        principal, ok := conv.convert(d.Amount, d.Currency)
+        if !ok {
+            continue
+        }
+        accruedBase, _ := conv.convert(accrued, d.Currency)
+        lostBase, _ := conv.convert(lost, d.Currency)
+
+        plan.DepositsToClose = append(plan.DepositsToClose, models.DepositToClose{
+            DepositID:       d.ID,
+            GoalID:          d.GoalID,
+            GoalName:        goal.Name,
+            Amount:          d.Amount,
+            Currency:        d.Currency,
+            AccruedInterest: accrued,
+            LostInterest:    lost,
+            BankID:          d.BankID,
+        })
+
+        plan.TotalAmount += principal
+        plan.TotalAccruedInterest += accruedBase
+        plan.TotalLostInterest += lostBase
+        plan.TotalReturned += principal + accruedBase - lostBase
+    }
+
+    if plan.TotalReturned < amount {
+        return nil, fmt.Errorf("insufficient savings: %s available, %s requested", plan.TotalReturned, amount)
+    }
+
+    plan.AffectedGoals = affectedGoals(goals, plan.DepositsToClose, now)
+
+    return plan, nil
+}
+
+// ConfirmWithdraw closes the chosen deposits. Deposits that fail to close
+// are left active and reported through Success=false.
+func (s *EmergencyService) ConfirmWithdraw(ctx context.Context, userID int, depositIDs []int) (*models.EmergencyWithdrawResult, error) {
+    s.logger.Info().Int("userId", userID).Ints("depositIds", depositIDs).Msg("Confirming emergency withdrawal")
+
+    conv, err := s.converter(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    goals, deposits, err := s.loadSavings(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    active := make(map[int]models.Deposit, len(deposits))
+    for _, d := range deposits {
+        active[d.ID] = d
+    }
+
+    // A deposit named twice is closed once
+    toClose := make([]models.Deposit, 0, len(depositIDs))
+    seen := make(map[int]bool, len(depositIDs))
+    for _, id := range depositIDs {
+        if seen[id] {
+            continue
+        }
+        seen[id] = true
+        
+        d, ok := active[id]
+        if !ok {
+            return nil, fmt.Errorf("deposit %d is not an active deposit of user", id)
+        }
+        toClose = append(toClose, d)
+    }
+
+    result := &models.EmergencyWithdrawResult{
+        Success:        true,
+        ClosedDeposits: []int{},
+        Currency:       conv.to,
+        Operations:     []models.Operation{},
+    }
+    withdrawn := make(map[int]money.Amount)
+
+    for i := range toClose {
+        d := &toClose[i]
+
+        closed, lost, err := s.closer.Close(ctx, d)
+        if err != nil {
+            s.logger.Error().Err(err).Int("depositId", d.ID).Msg("Failed to close deposit")
+            result.Success = false
+            errMsg := err.Error()
+            if op := s.recordOperation(ctx, d, d.Amount, 0, models.OperationStatusFailed, &errMsg); op != nil {
+                result.Operations = append(result.Operations, *op)
+            }
+            continue
+        }
+
+        returned := closed.ReturnedAmount
+        if returned == 0 {
+            returned = d.Amount + closed.AccruedInterest - lost
+        }
+
+        // Totals of deposits without an exchange rate are left out, the
+        // deposits are closed all the same
+        result.ClosedDeposits = append(result.ClosedDeposits, d.ID)
+        if returnedBase, ok := conv.convert(returned, d.Currency); ok {
+            lostBase, _ := conv.convert(lost, d.Currency)
+            result.TotalReturned += returnedBase
+            result.TotalLostInterest += lostBase
+        }
+        withdrawn[d.GoalID] += d.Amount
+
+        if op := s.recordOperation(ctx, d, returned, lost, models.OperationStatusSuccess, nil); op != nil {
+            result.Operations = append(result.Operations, *op)
+        }
+    }
+
+    now := time.Now()
+    for goalID, amount := range withdrawn {
+        goal := goals[goalID]
+        goal.CurrentAmount = money.Max(0, goal.CurrentAmount-amount)
+        // A goal left short of its plan stops receiving deposits until the
+        // user resumes it, see GoalService.ResumeGoal
+        if goal.Status == "active" && goal.CurrentAmount < goalPlannedAmount(goal, now) {
+            goal.Status = "paused"
+            goal.NextDepositDate = nil
+        }
+
+        if err := s.goalRepo.Update(ctx, goal); err != nil {
+            s.logger.Error().Err(err).Int("goalId", goalID).Msg("Failed to update goal after withdrawal")
+            result.Success = false
+        }
+    }
+
+    return result, nil
+}
+
+// loadSavings returns user goals by ID and their active, opened deposits
+func (s *EmergencyService) loadSavings(ctx context.Context, userID int) (map[int]*models.Goal, []models.Deposit, error) {
+    userGoals, err := s.goalRepo.GetUserGoals(ctx, userID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    goals := make(map[int]*models.Goal, len(userGoals))
+    for i := range userGoals {
+        goals[userGoals[i].ID] = &userGoals[i]
+    }
+
+    active, err := s.depositRepo.GetActiveDeposits(ctx, userID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    deposits := make([]models.Deposit, 0, len(active))
+    for _, d := range active {
+        if d.AgreementID == nil || goals[d.GoalID] == nil {
+            continue
+        }
+        deposits = append(deposits, d)
+    }
+
+    return goals, deposits, nil
+}
+
+func (s *EmergencyService) recordOperation(
+    ctx context.Context,
+    deposit *models.Deposit,
+    amount, lostInterest money.Amount,
+    status models.OperationStatus,
+    errMsg *string,
+) *models.Operation {
+    operation := &models.Operation{
+        UserID:           deposit.UserID,
+        Type:             string(models.OperationEmergencyWithdraw),
+        Amount:           &amount,
+        RelatedGoalID:    &deposit.GoalID,
+        RelatedDepositID: &deposit.ID,
+        Status:           string(status),
+        Error:            errMsg,
+        Metadata: models.JSONB{
+            "bankId":       deposit.BankID,
+            "currency":     deposit.Currency,
+            "agreementId":  *deposit.AgreementID,
+            "lostInterest": lostInterest,
+        },
+    }
+
+    if err := s.operations.Record(ctx, operation); err != nil {
+        return nil
+    }
+
+    return operation
+}
+
+func affectedGoals(goals map[int]*models.Goal, deposits []models.DepositToClose, now time.Time) []models.AffectedGoal {
+    withdrawn := make(map[int]money.Amount)
+    order := []int{}
+    for _, d := range deposits {
+        if _, ok := withdrawn[d.GoalID]; !ok {
+            order = append(order, d.GoalID)
+        }
+        withdrawn[d.GoalID] += d.Amount
+    }
+
+    affected := make([]models.AffectedGoal, 0, len(order))
+    for _, goalID := range order {
+        goal := goals[goalID]
+        after := money.Max(0, goal.CurrentAmount-withdrawn[goalID])
+
+        affected = append(affected, models.AffectedGoal{
+            GoalID:        goal.ID,
+            GoalName:      goal.Name,
+            CurrentAmount: goal.CurrentAmount,
+            AfterWithdraw: after,
+            WillBePaused:  goal.Status == "active" && after < goalPlannedAmount(goal, now),
+        })
+    }
+
+    return affected
+}
+
+// goalPlannedAmount is what the goal should have saved by now: one monthly
+// amount per full month since creation, capped at the target
+func goalPlannedAmount(goal *models.Goal, now time.Time) money.Amount {
+    months := (now.Year()-goal.CreatedAt.Year())*12 + int(now.Month()-goal.CreatedAt.Month())
+    if now.Day() < goal.CreatedAt.Day() {
+        months--
+    }
+    if months < 0 {
+        months = 0
+    }
+
+    return money.Min(goal.TargetAmount, goal.MonthlyAmount.Mul(months))
+}
+
+// estimateAccruedInterest uses the stored value when the bank reported one,
+// otherwise simple interest since opening
+func estimateAccruedInterest(deposit models.Deposit, now time.Time) money.Amount {
+    if deposit.AccruedInterest > 0 {
+        return deposit.AccruedInterest
+    }
+
+    days := int(now.Sub(depositOpenedAt(deposit)).Hours() / 24)
+    if days <= 0 {
+        return 0
+    }
+
+    return bankadapter.CalculateInterest(deposit.Amount, deposit.Rate, days)
+}
+
+func estimateLostInterest(deposit models.Deposit, accrued money.Amount, now time.Time) money.Amount {
+    openedAt := depositOpenedAt(deposit)
+    daysHeld := int(now.Sub(openedAt).Hours() / 24)
+
+    totalDays := deposit.TermMonths * 30
+    if deposit.MaturesAt != nil {
+        totalDays = int(deposit.MaturesAt.Sub(openedAt).Hours() / 24)
+    }
+    if totalDays <= 0 || daysHeld >= totalDays {
+        return 0
+    }
+
+    return bankadapter.CalculatePenalty(accrued, daysHeld, totalDays)
+}
+
+func depositOpenedAt(deposit models.Deposit) time.Time {
+    if deposit.OpenedAt != nil {
+        return *deposit.OpenedAt
+    }
+    return deposit.CreatedAt
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+)
+
+// The fakes embed their interface, calling a method a test does not set up
+// panics
+
+type syncCursor struct {
+    from, to time.Time
+    page     int
+}
+
+type fakeAccountRepo struct {
+    repository.AccountRepository
+    cursors []syncCursor
+    cleared bool
+}
+
+func (r *fakeAccountRepo) UpdateSyncCursor(ctx context.Context, id int, from, to time.Time, page int) error {
+    r.cursors = append(r.cursors, syncCursor{from: from, to: to, page: page})
+    return nil
+}
+
+func (r *fakeAccountRepo) ClearSyncCursor(ctx context.Context, id int) error {
+    r.cleared = true
+    return nil
+}
+
+type fakeTransactionRepo struct {
+    repository.TransactionRepository
+    latest *time.Time
+    saved  []models.Transaction
+}
+
+func (r *fakeTransactionRepo) GetLatestBookingTime(ctx context.Context, accountID int) (*time.Time, error) {
+    return r.latest, nil
+}
+
+func (r *fakeTransactionRepo) CreateBatch(ctx context.Context, transactions []models.Transaction) error {
+    r.saved = append(r.saved, transactions...)
+    return nil
+}
+
+type fakeRuleRepo struct {
+    repository.CategoryRuleRepository
+}
+
+func (fakeRuleRepo) GetUserRules(ctx context.Context, userID int) ([]models.CategoryRule, error) {
+    return nil, nil
+}
+
+type fakeLoanRepo struct {
+    repository.LoanRepository
+    loans    map[int]*models.Loan
+    payments []models.LoanPayment
+    advanced []time.Time
+}
+
+func (r *fakeLoanRepo) GetByID(ctx context.Context, id int) (*models.Loan, error) {
+    if loan, ok := r.loans[id]; ok {
+        return loan, nil
+    }
+    return nil, fmt.Errorf("loan %w", repository.ErrNotFound)
+}
+
+func (r *fakeLoanRepo) UpdatePayment(ctx context.Context, payment *models.LoanPayment) error {
+    r.payments = append(r.payments, *payment)
+    return nil
+}
+
+func (r *fakeLoanRepo) AdvancePaymentDate(ctx context.Context, loanID int, from, to time.Time) error {
+    r.advanced = append(r.advanced, to)
+    return nil
+}
+
+type fakeOperationRepo struct {
+    repository.OperationRepository
+    created []models.Operation
+}
+
+func (r *fakeOperationRepo) Create(ctx context.Context, operation *models.Operation) error {
+    r.created = append(r.created, *operation)
+    return nil
+}
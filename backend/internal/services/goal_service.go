@@ -4,19 +4,27 @@ import (
     "context"
     "fmt"
     "math"
+    "sort"
+    "strings"
     "time"
     
+    "github.com/KotovBoris/AutoSave/backend/internal/fx"
     "github.com/KotovBoris/AutoSave/backend/internal/models"
     "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
     "github.com/rs/zerolog"
 )
 
 type GoalService struct {
-    goalRepo    repository.GoalRepository
-    depositRepo repository.DepositRepository
-    userRepo    repository.UserRepository
-    bankRepo    repository.BankRepository
-    logger      *zerolog.Logger
+    goalRepo      repository.GoalRepository
+    depositRepo   repository.DepositRepository
+    userRepo      repository.UserRepository
+    bankRepo      repository.BankRepository
+    operations    *OperationService
+    closer        *depositCloser
+    catalog       *ProductCatalog
+    rates         *fx.Rates
+    logger        *zerolog.Logger
 }
 
 func NewGoalService(
@@ -24,31 +32,45 @@ func NewGoalService(
     depositRepo repository.DepositRepository,
     userRepo repository.UserRepository,
     bankRepo repository.BankRepository,
+    operations *OperationService,
+    credentials *BankCredentials,
+    catalog *ProductCatalog,
+    rates *fx.Rates,
     logger *zerolog.Logger,
 ) *GoalService {
     return &GoalService{
-        goalRepo:    goalRepo,
-        depositRepo: depositRepo,
-        userRepo:    userRepo,
-        bankRepo:    bankRepo,
-        logger:      logger,
+        goalRepo:      goalRepo,
+        depositRepo:   depositRepo,
+        userRepo:      userRepo,
+        bankRepo:      bankRepo,
+        operations:    operations,
+        closer:        newDepositCloser(depositRepo, credentials),
+        catalog:       catalog,
+        rates:         rates,
+        logger:        logger,
     }
 }
 
 // GetUserGoals returns all goals for user
 func (s *GoalService) GetUserGoals(ctx context.Context, userID int) ([]models.GoalResponse, error) {
+    user, err := s.userRepo.GetByID(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("user not found: %w", err)
+    }
+    
     goals, err := s.goalRepo.GetUserGoals(ctx, userID)
     if err != nil {
         return nil, fmt.Errorf("failed to get goals: %w", err)
     }
     
     response := make([]models.GoalResponse, 0, len(goals))
+    conv := newCurrencyConverter(s.rates, user.BaseCurrency, s.logger)
     
     for _, goal := range goals {
         // Get deposits
         deposits, _ := s.depositRepo.GetGoalDeposits(ctx, goal.ID)
         
-        resp := s.buildGoalResponse(&goal, deposits)
+        resp := s.buildGoalResponse(&goal, deposits, conv)
         response = append(response, resp)
     }
     
@@ -60,7 +82,7 @@ func (s *GoalService) CreateGoal(ctx context.Context, userID int, req models.Cre
     s.logger.Info().
         Int("userId", userID).
         Str("name", req.Name).
-        Float64("targetAmount", req.TargetAmount).
+        Float64("targetAmount", req.TargetAmount.Float64()).
         Msg("Creating goal")
     
     // Check if bank is connected
@@ -98,6 +120,11 @@ func (s *GoalService) CreateGoal(ctx context.Context, userID int, req models.Cre
         }
     }
     
+    currency := user.BaseCurrency
+    if req.Currency != "" {
+        currency = strings.ToUpper(req.Currency)
+    }
+    
     // Create goal
     goal := &models.Goal{
         UserID:          userID,
@@ -105,6 +132,7 @@ func (s *GoalService) CreateGoal(ctx context.Context, userID int, req models.Cre
         TargetAmount:    req.TargetAmount,
         CurrentAmount:   0,
         MonthlyAmount:   req.MonthlyAmount,
+        Currency:        currency,
         BankID:          req.BankID,
         DepositRate:     bank.DepositRate,
         Position:        position,
@@ -112,31 +140,51 @@ func (s *GoalService) CreateGoal(ctx context.Context, userID int, req models.Cre
         NextDepositDate: nextDepositDate,
     }
     
+    // Prefer the live rate of the product the first deposit would go to,
+    // the seeded bank rate is only a fallback
+    if product, _, err := s.catalog.SelectDeposit(ctx, goal, goal.MonthlyAmount); err == nil {
+        goal.DepositRate = product.InterestRate
+    } else {
+        s.logger.Warn().Err(err).Str("bankId", req.BankID).Msg("Failed to select deposit product, using bank rate")
+    }
+    
     if err := s.goalRepo.Create(ctx, goal); err != nil {
         return nil, fmt.Errorf("failed to create goal: %w", err)
     }
     
+    target := goal.TargetAmount
+    s.operations.Record(ctx, &models.Operation{
+        UserID:        userID,
+        Type:          string(models.OperationGoalCreated),
+        Amount:        &target,
+        RelatedGoalID: &goal.ID,
+        Status:        string(models.OperationStatusSuccess),
+        Metadata: models.JSONB{
+            "bankId":        goal.BankID,
+            "monthlyAmount": goal.MonthlyAmount,
+            "currency":      goal.Currency,
+            "position":      goal.Position,
+        },
+    })
+    
     // Build response with plan
-    resp := s.buildGoalResponse(goal, []models.Deposit{})
+    conv := newCurrencyConverter(s.rates, user.BaseCurrency, s.logger)
+    resp := s.buildGoalResponse(goal, []models.Deposit{}, conv)
     
     s.logger.Info().Int("goalId", goal.ID).Msg("Goal created successfully")
     
     return &resp, nil
 }
 
-// UpdateGoal updates goal
+// UpdateGoal updates goal. Ownership is checked by the router.
 func (s *GoalService) UpdateGoal(ctx context.Context, userID, goalID int, req models.UpdateGoalRequest) error {
-    s.logger.Info().Int("goalId", goalID).Msg("Updating goal")
+    s.logger.Info().Int("userId", userID).Int("goalId", goalID).Msg("Updating goal")
     
     goal, err := s.goalRepo.GetByID(ctx, goalID)
     if err != nil {
         return fmt.Errorf("goal not found: %w", err)
     }
     
-    if goal.UserID != userID {
-        return fmt.Errorf("goal does not belong to user")
-    }
-    
     if req.Name != nil {
         goal.Name = *req.Name
     }
@@ -152,36 +200,123 @@ func (s *GoalService) UpdateGoal(ctx context.Context, userID, goalID int, req mo
     return nil
 }
 
-// DeleteGoal deletes goal and closes all deposits
-func (s *GoalService) DeleteGoal(ctx context.Context, userID, goalID int) error {
-    s.logger.Info().Int("goalId", goalID).Msg("Deleting goal")
+// ResumeGoal restarts saving for a goal paused by an emergency withdrawal.
+// The goal is active again only if it is first in line, then autopilot picks
+// it up on the next salary date; otherwise it waits for its turn. Ownership
+// is checked by the router.
+func (s *GoalService) ResumeGoal(ctx context.Context, userID, goalID int) error {
+    s.logger.Info().Int("userId", userID).Int("goalId", goalID).Msg("Resuming goal")
     
     goal, err := s.goalRepo.GetByID(ctx, goalID)
     if err != nil {
         return fmt.Errorf("goal not found: %w", err)
     }
     
-    if goal.UserID != userID {
-        return fmt.Errorf("goal does not belong to user")
+    if goal.Status != "paused" {
+        return fmt.Errorf("only a paused goal can be resumed")
+    }
+    
+    goals, err := s.goalRepo.GetUserGoals(ctx, goal.UserID)
+    if err != nil {
+        return fmt.Errorf("failed to get goals: %w", err)
+    }
+    
+    // Another goal already saving, or waiting ahead of this one, keeps its turn
+    goal.Status = "active"
+    for _, other := range goals {
+        if other.ID == goal.ID {
+            continue
+        }
+        if other.Status == "active" || (other.Status == "waiting" && other.Position < goal.Position) {
+            goal.Status = "waiting"
+            break
+        }
     }
     
-    // TODO: Close all deposits via bank API
+    // Autopilot schedules a goal without a deposit date from the salary dates
+    goal.NextDepositDate = nil
+    if err := s.goalRepo.Update(ctx, goal); err != nil {
+        return fmt.Errorf("failed to resume goal: %w", err)
+    }
+    
+    return nil
+}
+
+// DeleteGoal closes all active deposits of the goal in the bank, then cancels
+// the goal. If any deposit fails to close the goal is kept, so deletion can
+// be retried and only the remaining deposits get closed. Ownership is
+// checked by the router.
+func (s *GoalService) DeleteGoal(ctx context.Context, userID, goalID int) (*models.CloseGoalResponse, error) {
+    s.logger.Info().Int("userId", userID).Int("goalId", goalID).Msg("Deleting goal")
     
-    // Delete goal
+    goal, err := s.goalRepo.GetByID(ctx, goalID)
+    if err != nil {
+        return nil, fmt.Errorf("goal not found: %w", err)
+    }
+    
+    if goal.Status == "cancelled" {
+        return nil, fmt.Errorf("goal is already cancelled")
+    }
+    
+    deposits, err := s.depositRepo.GetGoalDeposits(ctx, goalID)
+    if err != nil {
+        return nil, err
+    }
+    
+    response := &models.CloseGoalResponse{
+        ClosedDeposits: []models.ClosedDepositInfo{},
+    }
+    
+    for i := range deposits {
+        deposit := &deposits[i]
+        if deposit.Status != string(models.DepositStatusActive) || deposit.AgreementID == nil {
+            continue
+        }
+        
+        closed, lost, err := s.closer.Close(ctx, deposit)
+        if err != nil {
+            errMsg := err.Error()
+            s.recordDepositClosed(ctx, deposit, deposit.Amount, 0, models.OperationStatusFailed, &errMsg)
+            return nil, fmt.Errorf("failed to close deposit %d: %w", deposit.ID, err)
+        }
+        
+        returned := closed.ReturnedAmount
+        if returned == 0 {
+            returned = deposit.Amount + closed.AccruedInterest - lost
+        }
+        
+        response.ClosedDeposits = append(response.ClosedDeposits, models.ClosedDepositInfo{
+            DepositID:       deposit.ID,
+            Amount:          returned,
+            AccruedInterest: closed.AccruedInterest,
+            LostInterest:    lost,
+        })
+        response.TotalReturned += returned
+        response.TotalLostInterest += lost
+        
+        s.recordDepositClosed(ctx, deposit, returned, lost, models.OperationStatusSuccess, nil)
+    }
+    
+    // Goal is only marked cancelled, deposits and operations keep referencing it
     if err := s.goalRepo.Delete(ctx, goalID); err != nil {
-        return fmt.Errorf("failed to delete goal: %w", err)
+        return nil, fmt.Errorf("failed to delete goal: %w", err)
     }
     
     // Reorder remaining goals
     s.reorderGoalsAfterDelete(ctx, userID, goal.Position)
     
-    return nil
+    response.Message = fmt.Sprintf("Goal deleted, %d deposits closed", len(response.ClosedDeposits))
+    
+    return response, nil
 }
 
-// ReorderGoals changes goal priorities
+// ReorderGoals changes goal priorities. The first waiting or active goal
+// becomes active, paused and finished goals keep their status.
 func (s *GoalService) ReorderGoals(ctx context.Context, userID int, goalIDs []int) error {
     s.logger.Info().Int("userId", userID).Ints("goalIds", goalIDs).Msg("Reordering goals")
     
+    first := true
+    
     // Verify all goals belong to user
     for i, goalID := range goalIDs {
         goal, err := s.goalRepo.GetByID(ctx, goalID)
@@ -197,10 +332,13 @@ func (s *GoalService) ReorderGoals(ctx context.Context, userID int, goalIDs []in
         goal.Position = newPosition
         
         // Update status
-        if newPosition == 1 {
-            goal.Status = "active"
-        } else if goal.Status == "active" {
-            goal.Status = "waiting"
+        if goal.Status == "waiting" || goal.Status == "active" {
+            if first {
+                goal.Status = "active"
+            } else {
+                goal.Status = "waiting"
+            }
+            first = false
         }
         
         s.goalRepo.Update(ctx, goal)
@@ -211,9 +349,9 @@ func (s *GoalService) ReorderGoals(ctx context.Context, userID int, goalIDs []in
 
 // Helper functions
 
-func (s *GoalService) buildGoalResponse(goal *models.Goal, deposits []models.Deposit) models.GoalResponse {
+func (s *GoalService) buildGoalResponse(goal *models.Goal, deposits []models.Deposit, conv *currencyConverter) models.GoalResponse {
     // Calculate total interest
-    totalInterest := 0.0
+    totalInterest := money.Zero
     for _, dep := range deposits {
         totalInterest += dep.AccruedInterest
     }
@@ -224,7 +362,7 @@ func (s *GoalService) buildGoalResponse(goal *models.Goal, deposits []models.Dep
     
     if goal.MonthlyAmount > 0 {
         remaining := goal.TargetAmount - goal.CurrentAmount
-        monthsRemaining = int(math.Ceil(remaining / goal.MonthlyAmount))
+        monthsRemaining = int(math.Ceil(remaining.Ratio(goal.MonthlyAmount)))
         
         if goal.NextDepositDate != nil {
             completion := goal.NextDepositDate.AddDate(0, monthsRemaining, 0)
@@ -235,18 +373,19 @@ func (s *GoalService) buildGoalResponse(goal *models.Goal, deposits []models.Dep
     // Calculate progress
     progress := 0.0
     if goal.TargetAmount > 0 {
-        progress = (goal.CurrentAmount / goal.TargetAmount) * 100
+        progress = goal.CurrentAmount.Ratio(goal.TargetAmount) * 100
         if progress > 100 {
             progress = 100
         }
     }
     
-    return models.GoalResponse{
+    resp := models.GoalResponse{
         ID:                  goal.ID,
         Name:                goal.Name,
         TargetAmount:        goal.TargetAmount,
         CurrentAmount:       goal.CurrentAmount,
         MonthlyAmount:       goal.MonthlyAmount,
+        Currency:            goal.Currency,
         BankID:              goal.BankID,
         BankName:            goal.BankName,
         DepositRate:         goal.DepositRate,
@@ -259,7 +398,44 @@ func (s *GoalService) buildGoalResponse(goal *models.Goal, deposits []models.Dep
         EstimatedCompletion: estimatedCompletion,
         EstimatedInterest:   totalInterest,
         ProgressPercentage:  progress,
+        BaseCurrency:        conv.to,
+    }
+    
+    // Goals in other currencies are also shown in the base currency
+    if target, ok := conv.convert(goal.TargetAmount, goal.Currency); ok {
+        current, _ := conv.convert(goal.CurrentAmount, goal.Currency)
+        resp.TargetAmountBase = &target
+        resp.CurrentAmountBase = &current
+    }
+    
+    return resp
+}
+
+func (s *GoalService) recordDepositClosed(
+    ctx context.Context,
+    deposit *models.Deposit,
+    amount, lostInterest money.Amount,
+    status models.OperationStatus,
+    errMsg *string,
+) {
+    operation := &models.Operation{
+        UserID:           deposit.UserID,
+        Type:             string(models.OperationDepositClosed),
+        Amount:           &amount,
+        RelatedGoalID:    &deposit.GoalID,
+        RelatedDepositID: &deposit.ID,
+        Status:           string(status),
+        Error:            errMsg,
+        Metadata: models.JSONB{
+            "bankId":       deposit.BankID,
+            "currency":     deposit.Currency,
+            "agreementId":  *deposit.AgreementID,
+            "lostInterest": lostInterest,
+            "source":       "goal_deleted",
+        },
     }
+    
+    s.operations.Record(ctx, operation)
 }
 
 func (s *GoalService) reorderGoalsAfterDelete(ctx context.Context, userID, deletedPosition int) {
@@ -279,26 +455,34 @@ func (s *GoalService) reorderGoalsAfterDelete(ctx context.Context, userID, delet
 }
 
 func calculateNextSalaryDate(salaryDates []int) time.Time {
-    now := time.Now()
-    year := now.Year()
-    month := now.Month()
-    
-    // Find nearest salary date
-    for _, day := range salaryDates {
-        next := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-        if next.After(now) {
+    return calculateNextSalaryDateAfter(salaryDates, time.Now())
+}
+
+// calculateNextSalaryDateAfter returns the first salary date strictly after from
+func calculateNextSalaryDateAfter(salaryDates []int, from time.Time) time.Time {
+    days := make([]int, len(salaryDates))
+    copy(days, salaryDates)
+    sort.Ints(days)
+    
+    year, month, _ := from.Date()
+    
+    // Find nearest salary date this month
+    for _, day := range days {
+        next := salaryDateIn(year, month, day)
+        if next.After(from) {
             return next
         }
     }
     
     // If no date this month, use next month
-    nextMonth := month + 1
-    nextYear := year
-    if nextMonth > 12 {
-        nextMonth = 1
-        nextYear++
-    }
-    
-    return time.Date(nextYear, nextMonth, salaryDates[0], 0, 0, 0, 0, time.UTC)
+    return salaryDateIn(year, month+1, days[0])
 }
 
+// salaryDateIn clamps day to the last day of short months
+func salaryDateIn(year int, month time.Month, day int) time.Time {
+    lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+    if day > lastDay {
+        day = lastDay
+    }
+    return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,547 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+const (
+    // A payment failing for a temporary reason is sent again after a delay
+    // that doubles from loanPaymentRetryDelay, up to loanPaymentMaxAttempts sends
+    loanPaymentMaxAttempts   = 5
+    loanPaymentRetryDelay    = 15 * time.Minute
+    loanPaymentMaxRetryDelay = 6 * time.Hour
+)
+
+type LoanAutopayService struct {
+    loanRepo      repository.LoanRepository
+    accountRepo   repository.AccountRepository
+    operations    *OperationService
+    loanService   *LoanService
+    credentials   *BankCredentials
+    logger        *zerolog.Logger
+}
+
+func NewLoanAutopayService(
+    loanRepo repository.LoanRepository,
+    accountRepo repository.AccountRepository,
+    operations *OperationService,
+    loanService *LoanService,
+    credentials *BankCredentials,
+    logger *zerolog.Logger,
+) *LoanAutopayService {
+    return &LoanAutopayService{
+        loanRepo:      loanRepo,
+        accountRepo:   accountRepo,
+        operations:    operations,
+        loanService:   loanService,
+        credentials:   credentials,
+        logger:        logger,
+    }
+}
+
+// RunAutopay schedules payments for loans whose payment day has come, sends
+// scheduled payments to the bank and settles payments left in processing.
+// Safe to run repeatedly: every send of a payment carries the same
+// idempotency key, so the bank makes it at most once.
+func (s *LoanAutopayService) RunAutopay(ctx context.Context, now time.Time) error {
+    today := truncateToDate(now)
+
+    if err := s.scheduleDuePayments(ctx, today); err != nil {
+        return err
+    }
+
+    // Payments the bank has not settled yet are checked once per run
+    processing, err := s.loanRepo.GetProcessingPayments(ctx)
+    if err != nil {
+        return err
+    }
+
+    for i := range processing {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+
+        payment := &processing[i]
+        if err := s.resumePayment(ctx, payment); err != nil {
+            s.logger.Error().Err(err).Int("paymentId", payment.ID).Msg("Failed to check loan payment status")
+        }
+    }
+
+    scheduled, err := s.loanRepo.GetScheduledPayments(ctx, today, now)
+    if err != nil {
+        return err
+    }
+
+    if len(scheduled) == 0 {
+        return nil
+    }
+
+    s.logger.Info().Int("payments", len(scheduled)).Time("date", today).Msg("Running loan autopay")
+
+    for i := range scheduled {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+
+        payment := &scheduled[i]
+        if err := s.executePayment(ctx, payment); err != nil {
+            s.logger.Error().
+                Err(err).
+                Int("paymentId", payment.ID).
+                Int("loanId", payment.LoanID).
+                Msg("Loan autopay failed")
+        }
+    }
+
+    return nil
+}
+
+// scheduleDuePayments creates one autopay payment per loan for its payment
+// date. The loan moves to its next payment date once that payment settles, so
+// a loan stays due while its payment is being retried. A loan that cannot be
+// scheduled is logged and skipped.
+func (s *LoanAutopayService) scheduleDuePayments(ctx context.Context, today time.Time) error {
+    loans, err := s.loanRepo.GetDueAutopayLoans(ctx, today)
+    if err != nil {
+        return err
+    }
+
+    for i := range loans {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+
+        loan := &loans[i]
+        if err := s.scheduleLoanPayment(ctx, loan, today); err != nil {
+            s.logger.Error().Err(err).Int("loanId", loan.ID).Msg("Failed to schedule loan payment")
+        }
+    }
+
+    return nil
+}
+
+func (s *LoanAutopayService) scheduleLoanPayment(ctx context.Context, loan *models.Loan, today time.Time) error {
+    payments, err := s.loanRepo.GetPayments(ctx, loan.ID)
+    if err != nil {
+        return err
+    }
+
+    amount := autopayAmount(loan, payments, today)
+    if amount <= 0 {
+        // Nothing owed this month
+        return s.advancePaymentDate(ctx, loan, *loan.NextPaymentDate)
+    }
+
+    // A payment already made for this date, still retrying, is left alone
+    payment := &models.LoanPayment{
+        LoanID:        loan.ID,
+        UserID:        loan.UserID,
+        Amount:        amount,
+        ScheduledDate: *loan.NextPaymentDate,
+    }
+
+    created, err := s.loanRepo.CreateScheduledPayment(ctx, payment)
+    if err != nil {
+        return err
+    }
+    if created {
+        s.logger.Info().
+            Int("loanId", loan.ID).
+            Float64("amount", amount.Float64()).
+            Time("date", payment.ScheduledDate).
+            Msg("Loan payment scheduled")
+    }
+
+    return nil
+}
+
+func (s *LoanAutopayService) executePayment(ctx context.Context, payment *models.LoanPayment) error {
+    claimed, err := s.loanRepo.ClaimPayment(ctx, payment.ID)
+    if err != nil {
+        return err
+    }
+    if !claimed {
+        return nil
+    }
+    payment.Status = string(models.LoanPaymentProcessing)
+    payment.Attempts++
+
+    loan, err := s.loanRepo.GetByID(ctx, payment.LoanID)
+    if err != nil {
+        return err
+    }
+
+    if loan.Status != string(models.LoanStatusActive) || !loan.AutopayEnabled {
+        return s.failPayment(ctx, loan, payment, fmt.Errorf("autopay is not active for loan"))
+    }
+
+    adapter, conn, err := s.connect(ctx, loan)
+    if err != nil {
+        return s.failPayment(ctx, loan, payment, err)
+    }
+
+    resp, err := s.sendPayment(ctx, adapter, conn, loan, payment)
+    if err != nil {
+        return s.failPayment(ctx, loan, payment, err)
+    }
+
+    // Save bank payment ID first so a restart polls instead of paying again
+    payment.BankPaymentID = &resp.PaymentID
+    if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+        return err
+    }
+
+    return s.settlePayment(ctx, loan, payment, resp)
+}
+
+// resumePayment settles a payment left in processing. A payment without a
+// bank ID was claimed but its bank response was lost; it is sent again with
+// the same idempotency key, so the bank returns the first payment if it
+// already made one. It is not sent again once autopay is turned off.
+func (s *LoanAutopayService) resumePayment(ctx context.Context, payment *models.LoanPayment) error {
+    loan, err := s.loanRepo.GetByID(ctx, payment.LoanID)
+    if err != nil {
+        return err
+    }
+
+    if payment.BankPaymentID == nil && (loan.Status != string(models.LoanStatusActive) || !loan.AutopayEnabled) {
+        return s.failPayment(ctx, loan, payment, fmt.Errorf("autopay is not active for loan"))
+    }
+
+    adapter, conn, err := s.connect(ctx, loan)
+    if err != nil {
+        return err
+    }
+
+    if payment.BankPaymentID == nil {
+        payment.Attempts++
+
+        resp, err := s.sendPayment(ctx, adapter, conn, loan, payment)
+        if err != nil {
+            return s.failPayment(ctx, loan, payment, err)
+        }
+
+        payment.BankPaymentID = &resp.PaymentID
+        if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+            return err
+        }
+        return s.settlePayment(ctx, loan, payment, resp)
+    }
+
+    resp, err := adapter.GetPaymentStatus(ctx, conn.BankToken, conn.ExternalClientID, *payment.BankPaymentID)
+    if err != nil {
+        return err
+    }
+
+    return s.settlePayment(ctx, loan, payment, resp)
+}
+
+func (s *LoanAutopayService) connect(ctx context.Context, loan *models.Loan) (bankadapter.BankAdapter, *models.BankConnection, error) {
+    if loan.AutopayBankID == nil {
+        return nil, nil, fmt.Errorf("loan has no autopay bank")
+    }
+
+    conn, adapter, err := s.credentials.Connect(ctx, loan.UserID, *loan.AutopayBankID)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return adapter, conn, nil
+}
+
+func (s *LoanAutopayService) sendPayment(
+    ctx context.Context,
+    adapter bankadapter.BankAdapter,
+    conn *models.BankConnection,
+    loan *models.Loan,
+    payment *models.LoanPayment,
+) (*bankadapter.PaymentResponse, error) {
+    if loan.CreditorAccount == nil || *loan.CreditorAccount == "" {
+        return nil, fmt.Errorf("loan has no creditor account")
+    }
+
+    accounts, err := s.accountRepo.GetBankAccounts(ctx, loan.UserID, conn.BankID)
+    if err != nil {
+        return nil, err
+    }
+
+    // Accounts are ordered by balance, take the richest one. A resend
+    // stays with the account the first send was debited from.
+    var source *models.Account
+    for i := range accounts {
+        if payment.SourceAccountID == nil || accounts[i].ID == *payment.SourceAccountID {
+            source = &accounts[i]
+            break
+        }
+    }
+    if source == nil || source.Balance < payment.Amount {
+        return nil, fmt.Errorf("insufficient funds in %s to pay %s", conn.BankID, payment.Amount)
+    }
+
+    // Saved with the attempt before sending, so a resend after a crash and
+    // the balance update on completion use this account
+    payment.SourceAccountID = &source.ID
+    if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+        return nil, err
+    }
+
+    reference := fmt.Sprintf("loan-%d-%s", loan.ID, payment.ScheduledDate.Format("2006-01-02"))
+    creditorName := ""
+    if loan.CreditorName != nil {
+        creditorName = *loan.CreditorName
+    }
+    creditorBankCode := ""
+    if loan.CreditorBankCode != nil {
+        creditorBankCode = *loan.CreditorBankCode
+    }
+
+    consent, err := adapter.CreatePaymentConsent(
+        ctx,
+        conn.BankToken,
+        conn.ExternalClientID,
+        requestingBank,
+        bankadapter.PaymentConsentRequest{
+            ConsentType:     "single_use",
+            Amount:          payment.Amount,
+            Currency:        source.Currency,
+            DebtorAccount:   source.Identification,
+            CreditorAccount: *loan.CreditorAccount,
+            CreditorName:    creditorName,
+            Reference:       reference,
+            MaxUses:         1,
+            ValidUntil:      time.Now().Add(24 * time.Hour),
+        },
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to get payment consent: %w", err)
+    }
+    if strings.EqualFold(consent.Status, "rejected") {
+        return nil, fmt.Errorf("payment consent rejected by %s", conn.BankID)
+    }
+
+    resp, err := adapter.CreatePayment(
+        ctx,
+        conn.BankToken,
+        conn.ExternalClientID,
+        requestingBank,
+        bankadapter.PaymentRequest{
+            DebtorAccountID:   source.Identification,
+            CreditorAccountID: *loan.CreditorAccount,
+            CreditorBankCode:  creditorBankCode,
+            Amount:            payment.Amount,
+            Currency:          source.Currency,
+            Reference:         reference,
+            Description:       fmt.Sprintf("Loan payment: %s", loan.Name),
+            ConsentID:         consent.ConsentID,
+            IdempotencyKey:    paymentIdempotencyKey(payment),
+        },
+    )
+    if err != nil {
+        return nil, fmt.Errorf("failed to create payment: %w", err)
+    }
+
+    return resp, nil
+}
+
+// settlePayment records the bank's final status of a payment. A payment the
+// bank is still processing is left for the next run to check.
+func (s *LoanAutopayService) settlePayment(ctx context.Context, loan *models.Loan, payment *models.LoanPayment, resp *bankadapter.PaymentResponse) error {
+    switch paymentOutcome(resp.Status) {
+    case models.LoanPaymentCompleted:
+        return s.completePayment(ctx, loan, payment, resp)
+    case models.LoanPaymentFailed:
+        reason := resp.Error
+        if reason == "" {
+            reason = fmt.Sprintf("payment %s", resp.Status)
+        }
+        return s.failPayment(ctx, loan, payment, fmt.Errorf("%s", reason))
+    }
+
+    s.logger.Debug().
+        Int("paymentId", payment.ID).
+        Str("bankPaymentId", *payment.BankPaymentID).
+        Msg("Loan payment still processing, will check on next run")
+    return nil
+}
+
+func (s *LoanAutopayService) completePayment(ctx context.Context, loan *models.Loan, payment *models.LoanPayment, resp *bankadapter.PaymentResponse) error {
+    completedAt := resp.CompletedAt
+    if completedAt.IsZero() {
+        completedAt = time.Now()
+    }
+
+    payment.Status = string(models.LoanPaymentCompleted)
+    payment.CompletedAt = &completedAt
+    payment.Error = nil
+    payment.NextAttemptAt = nil
+    if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+        return err
+    }
+
+    if err := s.advancePaymentDate(ctx, loan, payment.ScheduledDate); err != nil {
+        return err
+    }
+
+    s.debitSource(ctx, payment)
+
+    if _, err := s.loanService.RecalculateDebt(ctx, loan); err != nil {
+        return err
+    }
+
+    s.logger.Info().
+        Int("loanId", loan.ID).
+        Int("paymentId", payment.ID).
+        Float64("amount", payment.Amount.Float64()).
+        Float64("currentDebt", loan.CurrentDebt.Float64()).
+        Msg("Loan payment completed")
+
+    s.recordOperation(ctx, loan, payment, models.OperationStatusSuccess, nil)
+    return nil
+}
+
+// debitSource keeps the local balance of the paying account roughly right
+// until the next sync
+func (s *LoanAutopayService) debitSource(ctx context.Context, payment *models.LoanPayment) {
+    if payment.SourceAccountID == nil {
+        return
+    }
+
+    account, err := s.accountRepo.GetByID(ctx, *payment.SourceAccountID)
+    if err == nil {
+        err = s.accountRepo.UpdateBalance(ctx, account.ID, account.Balance-payment.Amount)
+    }
+    if err != nil {
+        s.logger.Warn().Err(err).Int("accountId", *payment.SourceAccountID).Msg("Failed to update account balance")
+    }
+}
+
+// failPayment puts a payment that failed for a temporary reason back in the
+// schedule with a growing delay. Other failures, and the last attempt, are
+// final: the payment is failed and the loan moves to its next payment date.
+func (s *LoanAutopayService) failPayment(ctx context.Context, loan *models.Loan, payment *models.LoanPayment, cause error) error {
+    errMsg := cause.Error()
+    payment.Error = &errMsg
+
+    if bankadapter.IsTransient(cause) && payment.Attempts < loanPaymentMaxAttempts {
+        next := time.Now().Add(loanPaymentRetryBackoff(payment.Attempts))
+        payment.Status = string(models.LoanPaymentScheduled)
+        payment.NextAttemptAt = &next
+        if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+            return err
+        }
+
+        s.logger.Warn().
+            Err(cause).
+            Int("paymentId", payment.ID).
+            Int("attempt", payment.Attempts).
+            Time("nextAttemptAt", next).
+            Msg("Loan payment will be retried")
+        return nil
+    }
+
+    payment.Status = string(models.LoanPaymentFailed)
+    payment.NextAttemptAt = nil
+    if err := s.loanRepo.UpdatePayment(ctx, payment); err != nil {
+        return err
+    }
+
+    if err := s.advancePaymentDate(ctx, loan, payment.ScheduledDate); err != nil {
+        return err
+    }
+
+    s.recordOperation(ctx, loan, payment, models.OperationStatusFailed, &errMsg)
+    return cause
+}
+
+// advancePaymentDate moves the loan past the payment date from, once the
+// payment for it is settled
+func (s *LoanAutopayService) advancePaymentDate(ctx context.Context, loan *models.Loan, from time.Time) error {
+    if loan.AutopayDay == nil {
+        return nil
+    }
+
+    next := calculateNextSalaryDateAfter([]int{*loan.AutopayDay}, truncateToDate(time.Now()))
+    if !next.After(from) {
+        next = calculateNextSalaryDateAfter([]int{*loan.AutopayDay}, from)
+    }
+
+    return s.loanRepo.AdvancePaymentDate(ctx, loan.ID, from, next)
+}
+
+func (s *LoanAutopayService) recordOperation(ctx context.Context, loan *models.Loan, payment *models.LoanPayment, status models.OperationStatus, errMsg *string) {
+    amount := payment.Amount
+    metadata := models.JSONB{
+        "paymentId":     payment.ID,
+        "scheduledDate": payment.ScheduledDate.Format("2006-01-02"),
+        "source":        "autopay",
+    }
+    if loan.AutopayBankID != nil {
+        metadata["bankId"] = *loan.AutopayBankID
+    }
+    if payment.BankPaymentID != nil {
+        metadata["bankPaymentId"] = *payment.BankPaymentID
+    }
+
+    operation := &models.Operation{
+        UserID:        payment.UserID,
+        Type:          string(models.OperationLoanPayment),
+        Amount:        &amount,
+        RelatedLoanID: &payment.LoanID,
+        Status:        string(status),
+        Error:         errMsg,
+        Metadata:      metadata,
+    }
+
+    s.operations.Record(ctx, operation)
+}
+
+// autopayAmount is the monthly payment capped at the debt owed today
+func autopayAmount(loan *models.Loan, payments []models.LoanPayment, today time.Time) money.Amount {
+    settlement := models.LoanPayment{
+        Status:      string(models.LoanPaymentCompleted),
+        CompletedAt: &today,
+    }
+
+    debt := calculateLoanDebt(loan, append(payments, settlement))
+    if debt <= 0 {
+        return 0
+    }
+
+    return money.Min(loan.MonthlyPayment, debt)
+}
+
+// loanPaymentRetryBackoff is the delay before the send after attempt
+func loanPaymentRetryBackoff(attempt int) time.Duration {
+    delay := loanPaymentRetryDelay << uint(attempt-1)
+    if delay <= 0 || delay > loanPaymentMaxRetryDelay {
+        delay = loanPaymentMaxRetryDelay
+    }
+    return delay
+}
+
+// paymentIdempotencyKey identifies a loan payment to the bank. It is fixed by
+// the payment row, which exists before the first send.
+func paymentIdempotencyKey(payment *models.LoanPayment) string {
+    return fmt.Sprintf("autosave-loan-payment-%d", payment.ID)
+}
+
+// paymentOutcome maps bank payment statuses onto loan payment statuses
+func paymentOutcome(status string) models.LoanPaymentStatus {
+    switch strings.ToLower(status) {
+    case "completed", "acceptedsettlementcompleted", "acceptedcreditsettlementcompleted":
+        return models.LoanPaymentCompleted
+    case "failed", "rejected", "cancelled":
+        return models.LoanPaymentFailed
+    default:
+        return models.LoanPaymentProcessing
+    }
+}
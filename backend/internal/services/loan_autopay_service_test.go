@@ -0,0 +1,175 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/rs/zerolog"
+)
+
+func TestLoanPaymentRetryBackoff(t *testing.T) {
+    tests := []struct {
+        attempt int
+        want    time.Duration
+    }{
+        {1, 15 * time.Minute},
+        {2, 30 * time.Minute},
+        {3, time.Hour},
+        {4, 2 * time.Hour},
+        {5, 4 * time.Hour},
+        {6, 6 * time.Hour},
+        {100, 6 * time.Hour},
+    }
+
+    for _, tt := range tests {
+        if got := loanPaymentRetryBackoff(tt.attempt); got != tt.want {
+            t.Errorf("loanPaymentRetryBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+        }
+    }
+}
+
+func newTestLoanAutopayService(loans *fakeLoanRepo, operations *fakeOperationRepo) *LoanAutopayService {
+    logger := zerolog.Nop()
+    return NewLoanAutopayService(loans, nil, NewOperationService(operations, nil, nil, nil, &logger), nil, nil, &logger)
+}
+
+func testLoan() *models.Loan {
+    autopayDay := 15
+    return &models.Loan{
+        ID:             1,
+        UserID:         1,
+        Status:         string(models.LoanStatusActive),
+        AutopayEnabled: true,
+        AutopayDay:     &autopayDay,
+    }
+}
+
+func TestFailPayment(t *testing.T) {
+    unavailable := &bankadapter.BankError{Code: bankadapter.ErrCodeBankUnavailable, Message: "bank is down"}
+    rejected := &bankadapter.BankError{Status: 400, Message: "insufficient funds"}
+
+    tests := []struct {
+        name     string
+        attempts int
+        cause    error
+
+        wantRetry bool
+    }{
+        {"temporary error is retried", 1, unavailable, true},
+        {"temporary error before the last attempt", loanPaymentMaxAttempts - 1, unavailable, true},
+        {"temporary error on the last attempt", loanPaymentMaxAttempts, unavailable, false},
+        {"rejected payment", 1, rejected, false},
+        {"unknown error", 1, errors.New("connection reset"), false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            loans := &fakeLoanRepo{}
+            operations := &fakeOperationRepo{}
+            service := newTestLoanAutopayService(loans, operations)
+
+            scheduled := truncateToDate(time.Now())
+            payment := &models.LoanPayment{
+                ID:            1,
+                LoanID:        1,
+                UserID:        1,
+                Status:        string(models.LoanPaymentProcessing),
+                ScheduledDate: scheduled,
+                Attempts:      tt.attempts,
+            }
+
+            start := time.Now()
+            err := service.failPayment(context.Background(), testLoan(), payment, tt.cause)
+
+            if len(loans.payments) != 1 {
+                t.Fatalf("payment updated %d times, want 1", len(loans.payments))
+            }
+            saved := loans.payments[0]
+            if saved.Error == nil || *saved.Error != tt.cause.Error() {
+                t.Errorf("error = %v, want %q", saved.Error, tt.cause.Error())
+            }
+
+            if tt.wantRetry {
+                if err != nil {
+                    t.Errorf("error = %v, want nil", err)
+                }
+                if saved.Status != string(models.LoanPaymentScheduled) {
+                    t.Errorf("status = %s, want %s", saved.Status, models.LoanPaymentScheduled)
+                }
+                wantNext := start.Add(loanPaymentRetryBackoff(tt.attempts))
+                if saved.NextAttemptAt == nil || saved.NextAttemptAt.Before(wantNext) || saved.NextAttemptAt.After(wantNext.Add(time.Minute)) {
+                    t.Errorf("next attempt = %v, want about %v", saved.NextAttemptAt, wantNext)
+                }
+                if len(loans.advanced) != 0 {
+                    t.Errorf("payment date advanced on a retry")
+                }
+                if len(operations.created) != 0 {
+                    t.Errorf("operation recorded on a retry")
+                }
+                return
+            }
+
+            if err != tt.cause {
+                t.Errorf("error = %v, want %v", err, tt.cause)
+            }
+            if saved.Status != string(models.LoanPaymentFailed) || saved.NextAttemptAt != nil {
+                t.Errorf("status = %s, next attempt %v, want failed without retry", saved.Status, saved.NextAttemptAt)
+            }
+            if len(loans.advanced) != 1 || !loans.advanced[0].After(scheduled) {
+                t.Errorf("payment date advanced to %v, want past %v", loans.advanced, scheduled)
+            }
+            if len(operations.created) != 1 || operations.created[0].Status != string(models.OperationStatusFailed) {
+                t.Errorf("operations = %+v, want one failed", operations.created)
+            }
+        })
+    }
+}
+
+func TestResumePaymentAutopayOff(t *testing.T) {
+    tests := []struct {
+        name   string
+        status models.LoanStatus
+        on     bool
+    }{
+        {"autopay turned off", models.LoanStatusActive, false},
+        {"loan paid off", models.LoanStatusPaidOff, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            loan := testLoan()
+            loan.Status = string(tt.status)
+            loan.AutopayEnabled = tt.on
+
+            loans := &fakeLoanRepo{loans: map[int]*models.Loan{loan.ID: loan}}
+            operations := &fakeOperationRepo{}
+
+            // The loan has no autopay bank, reaching connect fails without
+            // failing the payment
+            service := newTestLoanAutopayService(loans, operations)
+
+            payment := &models.LoanPayment{
+                ID:            1,
+                LoanID:        loan.ID,
+                UserID:        loan.UserID,
+                Status:        string(models.LoanPaymentProcessing),
+                ScheduledDate: truncateToDate(time.Now()),
+                Attempts:      1,
+            }
+
+            if err := service.resumePayment(context.Background(), payment); err == nil {
+                t.Fatal("resumePayment succeeded, want the payment failed")
+            }
+            if len(loans.payments) != 1 || loans.payments[0].Status != string(models.LoanPaymentFailed) {
+                t.Errorf("payments = %+v, want one failed", loans.payments)
+            }
+            if len(operations.created) != 1 {
+                t.Errorf("recorded %d operations, want 1", len(operations.created))
+            }
+        })
+    }
+}
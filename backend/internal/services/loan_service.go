@@ -0,0 +1,341 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+type LoanService struct {
+    loanRepo   repository.LoanRepository
+    bankRepo   repository.BankRepository
+    operations *OperationService
+    logger     *zerolog.Logger
+}
+
+func NewLoanService(
+    loanRepo repository.LoanRepository,
+    bankRepo repository.BankRepository,
+    operations *OperationService,
+    logger *zerolog.Logger,
+) *LoanService {
+    return &LoanService{
+        loanRepo:   loanRepo,
+        bankRepo:   bankRepo,
+        operations: operations,
+        logger:     logger,
+    }
+}
+
+// GetUserLoans returns all loans for user
+func (s *LoanService) GetUserLoans(ctx context.Context, userID int) ([]models.Loan, error) {
+    loans, err := s.loanRepo.GetUserLoans(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get loans: %w", err)
+    }
+
+    if loans == nil {
+        loans = []models.Loan{}
+    }
+
+    return loans, nil
+}
+
+// CreateLoan creates new loan
+func (s *LoanService) CreateLoan(ctx context.Context, userID int, req models.CreateLoanRequest) (*models.Loan, error) {
+    s.logger.Info().
+        Int("userId", userID).
+        Str("name", req.Name).
+        Float64("currentDebt", req.CurrentDebt.Float64()).
+        Msg("Creating loan")
+
+    loan := &models.Loan{
+        UserID:           userID,
+        Name:             req.Name,
+        CurrentDebt:      req.CurrentDebt,
+        Rate:             req.Rate,
+        MonthlyPayment:   req.MonthlyPayment,
+        AutopayEnabled:   req.AutopayEnabled,
+        AutopayBankID:    req.AutopayBankID,
+        AutopayDay:       req.AutopayDay,
+        CreditorAccount:  req.CreditorAccount,
+        CreditorName:     req.CreditorName,
+        CreditorBankCode: req.CreditorBankCode,
+    }
+
+    if err := s.validateAutopay(ctx, loan); err != nil {
+        return nil, err
+    }
+
+    if loan.AutopayDay != nil {
+        next := calculateNextSalaryDate([]int{*loan.AutopayDay})
+        loan.NextPaymentDate = &next
+    }
+
+    if err := s.loanRepo.Create(ctx, loan); err != nil {
+        return nil, fmt.Errorf("failed to create loan: %w", err)
+    }
+
+    s.logger.Info().Int("loanId", loan.ID).Msg("Loan created successfully")
+
+    return loan, nil
+}
+
+// UpdateLoan updates loan settings
+func (s *LoanService) UpdateLoan(ctx context.Context, userID, loanID int, req models.UpdateLoanRequest) (*models.Loan, error) {
+    s.logger.Info().Int("loanId", loanID).Msg("Updating loan")
+
+    loan, err := s.getUserLoan(ctx, userID, loanID)
+    if err != nil {
+        return nil, err
+    }
+
+    if req.Name != nil {
+        loan.Name = *req.Name
+    }
+    if req.MonthlyPayment != nil {
+        loan.MonthlyPayment = *req.MonthlyPayment
+    }
+    if req.AutopayEnabled != nil {
+        loan.AutopayEnabled = *req.AutopayEnabled
+    }
+    if req.AutopayBankID != nil {
+        loan.AutopayBankID = req.AutopayBankID
+    }
+    if req.AutopayDay != nil {
+        loan.AutopayDay = req.AutopayDay
+        next := calculateNextSalaryDate([]int{*loan.AutopayDay})
+        loan.NextPaymentDate = &next
+    }
+    if req.CreditorAccount != nil {
+        loan.CreditorAccount = req.CreditorAccount
+    }
+    if req.CreditorName != nil {
+        loan.CreditorName = req.CreditorName
+    }
+    if req.CreditorBankCode != nil {
+        loan.CreditorBankCode = req.CreditorBankCode
+    }
+
+    if err := s.validateAutopay(ctx, loan); err != nil {
+        return nil, err
+    }
+
+    if err := s.loanRepo.Update(ctx, loan); err != nil {
+        return nil, fmt.Errorf("failed to update loan: %w", err)
+    }
+
+    return loan, nil
+}
+
+// DeleteLoan cancels loan, payment history is kept
+func (s *LoanService) DeleteLoan(ctx context.Context, userID, loanID int) error {
+    s.logger.Info().Int("loanId", loanID).Msg("Deleting loan")
+
+    if _, err := s.getUserLoan(ctx, userID, loanID); err != nil {
+        return err
+    }
+
+    if err := s.loanRepo.Delete(ctx, loanID); err != nil {
+        return fmt.Errorf("failed to delete loan: %w", err)
+    }
+
+    return nil
+}
+
+// GetPayments returns payment history of loan
+func (s *LoanService) GetPayments(ctx context.Context, userID, loanID int) ([]models.LoanPayment, error) {
+    if _, err := s.getUserLoan(ctx, userID, loanID); err != nil {
+        return nil, err
+    }
+
+    payments, err := s.loanRepo.GetPayments(ctx, loanID)
+    if err != nil {
+        return nil, err
+    }
+
+    if payments == nil {
+        payments = []models.LoanPayment{}
+    }
+
+    return payments, nil
+}
+
+// RecordPayment registers a payment made outside of autopay
+func (s *LoanService) RecordPayment(ctx context.Context, userID, loanID int, req models.CreateLoanPaymentRequest) (*models.LoanPayment, error) {
+    s.logger.Info().
+        Int("loanId", loanID).
+        Float64("amount", req.Amount.Float64()).
+        Msg("Recording loan payment")
+
+    loan, err := s.getUserLoan(ctx, userID, loanID)
+    if err != nil {
+        return nil, err
+    }
+
+    if loan.Status != string(models.LoanStatusActive) {
+        return nil, fmt.Errorf("loan is %s", loan.Status)
+    }
+
+    paidAt := time.Now()
+    if req.PaidAt != nil {
+        if req.PaidAt.Before(loan.CreatedAt) || req.PaidAt.After(paidAt) {
+            return nil, fmt.Errorf("payment date must be between loan creation and now")
+        }
+        paidAt = *req.PaidAt
+    }
+
+    payments, err := s.loanRepo.GetPayments(ctx, loanID)
+    if err != nil {
+        return nil, err
+    }
+
+    payment := models.LoanPayment{
+        LoanID:        loanID,
+        UserID:        userID,
+        Amount:        req.Amount,
+        IsAutopay:     false,
+        Status:        string(models.LoanPaymentCompleted),
+        ScheduledDate: truncateToDate(paidAt),
+        CompletedAt:   &paidAt,
+    }
+
+    if debt := calculateLoanDebt(loan, append(payments, payment)); debt < 0 {
+        return nil, fmt.Errorf("payment exceeds current debt by %s", -debt)
+    }
+
+    if err := s.loanRepo.CreatePayment(ctx, &payment); err != nil {
+        return nil, fmt.Errorf("failed to record payment: %w", err)
+    }
+
+    if _, err := s.RecalculateDebt(ctx, loan); err != nil {
+        return nil, err
+    }
+
+    amount := payment.Amount
+    s.operations.Record(ctx, &models.Operation{
+        UserID:        userID,
+        Type:          string(models.OperationLoanPayment),
+        Amount:        &amount,
+        RelatedLoanID: &loanID,
+        Status:        string(models.OperationStatusSuccess),
+        Metadata: models.JSONB{
+            "paymentId":   payment.ID,
+            "paidAt":      paidAt.Format(time.RFC3339),
+            "currentDebt": loan.CurrentDebt,
+            "source":      "manual",
+        },
+    })
+
+    return &payment, nil
+}
+
+// RecalculateDebt rebuilds current debt from the original debt, interest and
+// completed payments. A loan whose debt reaches zero is marked paid off.
+func (s *LoanService) RecalculateDebt(ctx context.Context, loan *models.Loan) (*models.Loan, error) {
+    payments, err := s.loanRepo.GetPayments(ctx, loan.ID)
+    if err != nil {
+        return nil, err
+    }
+
+    debt := calculateLoanDebt(loan, payments)
+    if debt < 0 {
+        debt = 0
+    }
+
+    if err := s.loanRepo.UpdateDebt(ctx, loan.ID, debt); err != nil {
+        return nil, err
+    }
+    loan.CurrentDebt = debt
+
+    if debt == 0 && loan.Status == string(models.LoanStatusActive) {
+        if err := s.loanRepo.UpdateStatus(ctx, loan.ID, string(models.LoanStatusPaidOff)); err != nil {
+            return nil, err
+        }
+        now := time.Now()
+        loan.Status = string(models.LoanStatusPaidOff)
+        loan.PaidOffAt = &now
+
+        s.logger.Info().Int("loanId", loan.ID).Msg("Loan paid off")
+    }
+
+    return loan, nil
+}
+
+func (s *LoanService) getUserLoan(ctx context.Context, userID, loanID int) (*models.Loan, error) {
+    loan, err := s.loanRepo.GetByID(ctx, loanID)
+    if err != nil {
+        return nil, fmt.Errorf("loan not found: %w", err)
+    }
+
+    if loan.UserID != userID || loan.Status == string(models.LoanStatusCancelled) {
+        return nil, fmt.Errorf("loan does not belong to user")
+    }
+
+    return loan, nil
+}
+
+func (s *LoanService) validateAutopay(ctx context.Context, loan *models.Loan) error {
+    if !loan.AutopayEnabled {
+        return nil
+    }
+
+    if loan.AutopayBankID == nil || loan.AutopayDay == nil {
+        return fmt.Errorf("autopay requires bank and payment day")
+    }
+
+    if loan.CreditorAccount == nil || *loan.CreditorAccount == "" {
+        return fmt.Errorf("autopay requires creditor account")
+    }
+
+    conn, err := s.bankRepo.GetConnection(ctx, loan.UserID, *loan.AutopayBankID)
+    if err != nil || conn == nil || !conn.Connected {
+        return fmt.Errorf("bank %s is not connected", *loan.AutopayBankID)
+    }
+
+    return nil
+}
+
+// calculateLoanDebt applies simple daily interest between completed payments.
+// Interest of the current period is due with the next payment, so debt is
+// reported as of the latest payment. Interest is rounded to the kopeck at
+// every payment, as the bank charges it.
+func calculateLoanDebt(loan *models.Loan, payments []models.LoanPayment) money.Amount {
+    completed := make([]models.LoanPayment, 0, len(payments))
+    for _, p := range payments {
+        if p.Status == string(models.LoanPaymentCompleted) {
+            completed = append(completed, p)
+        }
+    }
+
+    sort.Slice(completed, func(i, j int) bool {
+        return loanPaymentDate(completed[i]).Before(loanPaymentDate(completed[j]))
+    })
+
+    debt := loan.OriginalDebt
+    last := loan.CreatedAt
+
+    for _, p := range completed {
+        paidAt := loanPaymentDate(p)
+        if hours := int64(paidAt.Sub(last) / time.Hour); hours > 0 && debt > 0 {
+            debt += debt.ProratedPercent(loan.Rate, hours, 365*24)
+        }
+        debt -= p.Amount
+        last = paidAt
+    }
+
+    return debt
+}
+
+func loanPaymentDate(p models.LoanPayment) time.Time {
+    if p.CompletedAt != nil {
+        return *p.CompletedAt
+    }
+    return p.ScheduledDate
+}
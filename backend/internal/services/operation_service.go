@@ -1,25 +1,148 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/KotovBoris/AutoSave/backend/internal/models"
 	"github.com/KotovBoris/AutoSave/backend/internal/repository"
 	"github.com/rs/zerolog"
 )
 
+const (
+	defaultOperationsLimit = 20
+	maxOperationsLimit     = 100
+)
+
 type OperationService struct {
 	operationRepo repository.OperationRepository
+	goalRepo      repository.GoalRepository
+	loanRepo      repository.LoanRepository
+	depositRepo   repository.DepositRepository
 	logger        *zerolog.Logger
 }
 
 func NewOperationService(
 	operationRepo repository.OperationRepository,
+	goalRepo repository.GoalRepository,
+	loanRepo repository.LoanRepository,
+	depositRepo repository.DepositRepository,
 	logger *zerolog.Logger,
 ) *OperationService {
 	return &OperationService{
 		operationRepo: operationRepo,
+		goalRepo:      goalRepo,
+		loanRepo:      loanRepo,
+		depositRepo:   depositRepo,
 		logger:        logger,
 	}
 }
 
-// Placeholder for operations service
-// Will be implemented when needed
+// Record writes operation to the audit log. A failed write is logged and
+// returned, but must not undo the change it describes.
+func (s *OperationService) Record(ctx context.Context, operation *models.Operation) error {
+	if operation.Status == "" {
+		operation.Status = string(models.OperationStatusSuccess)
+	}
+
+	if err := s.operationRepo.Create(ctx, operation); err != nil {
+		s.logger.Error().
+			Err(err).
+			Int("userId", operation.UserID).
+			Str("type", operation.Type).
+			Msg("Failed to record operation")
+		return err
+	}
+
+	return nil
+}
+
+// GetOperations returns a page of user operations with related objects.
+// cursor is the NextCursor of the previous page.
+func (s *OperationService) GetOperations(ctx context.Context, filter models.OperationFilter, cursor string) (*models.OperationsResponse, error) {
+	if cursor != "" {
+		beforeID, err := strconv.Atoi(cursor)
+		if err != nil || beforeID <= 0 {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if filter.Limit <= 0 || filter.Limit > maxOperationsLimit {
+		filter.Limit = defaultOperationsLimit
+	}
+	limit := filter.Limit
+
+	// One extra row tells whether there is a next page
+	filter.Limit++
+	operations, err := s.operationRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.OperationsResponse{
+		Operations: []models.Operation{},
+	}
+
+	if len(operations) > limit {
+		operations = operations[:limit]
+		next := strconv.Itoa(operations[limit-1].ID)
+		response.NextCursor = &next
+	}
+
+	if len(operations) > 0 {
+		s.attachRelated(ctx, operations)
+		response.Operations = operations
+	}
+
+	return response, nil
+}
+
+// attachRelated loads goals, loans and deposits referenced by operations,
+// each object once per page
+func (s *OperationService) attachRelated(ctx context.Context, operations []models.Operation) {
+	goals := make(map[int]*models.Goal)
+	loans := make(map[int]*models.Loan)
+	deposits := make(map[int]*models.Deposit)
+
+	for i := range operations {
+		op := &operations[i]
+
+		if op.RelatedGoalID != nil {
+			id := *op.RelatedGoalID
+			if _, ok := goals[id]; !ok {
+				goal, err := s.goalRepo.GetByID(ctx, id)
+				if err != nil {
+					s.logger.Warn().Err(err).Int("goalId", id).Msg("Failed to load operation goal")
+				}
+				goals[id] = goal
+			}
+			op.Goal = goals[id]
+		}
 
+		if op.RelatedLoanID != nil {
+			id := *op.RelatedLoanID
+			if _, ok := loans[id]; !ok {
+				loan, err := s.loanRepo.GetByID(ctx, id)
+				if err != nil {
+					s.logger.Warn().Err(err).Int("loanId", id).Msg("Failed to load operation loan")
+				}
+				loans[id] = loan
+			}
+			op.Loan = loans[id]
+		}
+
+		if op.RelatedDepositID != nil {
+			id := *op.RelatedDepositID
+			if _, ok := deposits[id]; !ok {
+				deposit, err := s.depositRepo.GetByID(ctx, id)
+				if err != nil {
+					s.logger.Warn().Err(err).Int("depositId", id).Msg("Failed to load operation deposit")
+				}
+				deposits[id] = deposit
+			}
+			op.Deposit = deposits[id]
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/bankadapter"
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+// productCacheTTL is how long a bank catalog is reused before asking again
+const productCacheTTL = time.Hour
+
+type cachedProducts struct {
+    products  []bankadapter.Product
+    fetchedAt time.Time
+}
+
+// ProductCatalog caches bank product catalogs and picks deposit products
+// for goals. Catalogs are the same for every client of a bank, so they are
+// cached per bank and product type.
+type ProductCatalog struct {
+    bankRepo    repository.BankRepository
+    credentials *BankCredentials
+    logger      *zerolog.Logger
+
+    mu    sync.Mutex
+    cache map[string]cachedProducts
+}
+
+func NewProductCatalog(
+    bankRepo repository.BankRepository,
+    credentials *BankCredentials,
+    logger *zerolog.Logger,
+) *ProductCatalog {
+    return &ProductCatalog{
+        bankRepo:    bankRepo,
+        credentials: credentials,
+        logger:      logger,
+        cache:       make(map[string]cachedProducts),
+    }
+}
+
+// Products returns products of bankID, loading the catalog with userID's
+// connection when the cached one is missing or stale
+func (c *ProductCatalog) Products(ctx context.Context, userID int, bankID, productType string) ([]bankadapter.Product, error) {
+    key := bankID + "/" + productType
+
+    c.mu.Lock()
+    cached, ok := c.cache[key]
+    c.mu.Unlock()
+    if ok && time.Since(cached.fetchedAt) < productCacheTTL {
+        return cached.products, nil
+    }
+
+    conn, adapter, err := c.credentials.Connect(ctx, userID, bankID)
+    if err != nil {
+        return nil, err
+    }
+
+    products, err := adapter.GetProducts(ctx, conn.BankToken, productType)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get %s products: %w", productType, err)
+    }
+
+    c.mu.Lock()
+    c.cache[key] = cachedProducts{products: products, fetchedAt: time.Now()}
+    c.mu.Unlock()
+
+    c.logger.Debug().Str("bankId", bankID).Str("type", productType).Int("products", len(products)).Msg("Product catalog loaded")
+
+    return products, nil
+}
+
+// Invalidate drops cached catalogs of bankID, e.g. after the bank refused a product
+func (c *ProductCatalog) Invalidate(bankID string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for key := range c.cache {
+        if strings.HasPrefix(key, bankID+"/") {
+            delete(c.cache, key)
+        }
+    }
+}
+
+// GetOffers lists products of every connected bank, best rate first.
+// A bank whose catalog fails to load is reported in Errors.
+func (c *ProductCatalog) GetOffers(ctx context.Context, userID int, productType string) (*models.ProductsResponse, error) {
+    connections, err := c.bankRepo.GetUserConnections(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    response := &models.ProductsResponse{
+        Products: []models.ProductOffer{},
+    }
+
+    for _, conn := range connections {
+        products, err := c.Products(ctx, userID, conn.BankID, productType)
+        if err != nil {
+            c.logger.Warn().Err(err).Str("bankId", conn.BankID).Msg("Failed to load product catalog")
+            response.Errors = append(response.Errors, models.BankProductsError{
+                BankID:  conn.BankID,
+                Message: err.Error(),
+            })
+            continue
+        }
+
+        for _, p := range products {
+            response.Products = append(response.Products, models.ProductOffer{
+                BankID:       conn.BankID,
+                BankName:     conn.BankName,
+                ProductID:    p.ProductID,
+                ProductType:  p.ProductType,
+                ProductName:  p.ProductName,
+                Description:  p.Description,
+                InterestRate: p.InterestRate,
+                MinAmount:    p.MinAmount,
+                MaxAmount:    p.MaxAmount,
+                TermMonths:   p.TermMonths,
+                Currency:     p.Currency,
+            })
+        }
+    }
+
+    sort.SliceStable(response.Products, func(i, j int) bool {
+        return response.Products[i].InterestRate > response.Products[j].InterestRate
+    })
+
+    return response, nil
+}
+
+// SelectDeposit picks the deposit product and term for putting amount
+// into goal's bank now. Only products in the goal's currency qualify, amount
+// is in that currency.
+func (c *ProductCatalog) SelectDeposit(ctx context.Context, goal *models.Goal, amount money.Amount) (*bankadapter.Product, int, error) {
+    products, err := c.Products(ctx, goal.UserID, goal.BankID, "deposit")
+    if err != nil {
+        return nil, 0, err
+    }
+
+    inCurrency := make([]bankadapter.Product, 0, len(products))
+    for _, p := range products {
+        if strings.EqualFold(p.Currency, goal.Currency) {
+            inCurrency = append(inCurrency, p)
+        }
+    }
+
+    product, term := selectDepositOffer(inCurrency, amount, goalHorizonMonths(goal))
+    if product == nil {
+        return nil, 0, fmt.Errorf("no deposit product in %s accepts %s %s", goal.BankID, amount, goal.Currency)
+    }
+
+    return product, term, nil
+}
+
+// goalHorizonMonths is the number of months until the last planned deposit
+// of the goal, when the saved money is expected to be needed
+func goalHorizonMonths(goal *models.Goal) int {
+    if goal.MonthlyAmount <= 0 {
+        return 0
+    }
+
+    remaining := goal.TargetAmount - goal.CurrentAmount
+    deposits := int(math.Ceil(remaining.Ratio(goal.MonthlyAmount)))
+    if deposits <= 1 {
+        return 0
+    }
+
+    return deposits - 1
+}
+
+// selectDepositOffer returns the highest rate product and term that accepts
+// amount and matures within horizonMonths. Among equal rates the longer term
+// wins. When no term fits the horizon the shortest one is used, so the money
+// is locked for as little as possible.
+func selectDepositOffer(products []bankadapter.Product, amount money.Amount, horizonMonths int) (*bankadapter.Product, int) {
+    var (
+        best     *bankadapter.Product
+        bestTerm int
+        bestFits bool
+    )
+
+    for i := range products {
+        p := &products[i]
+        if p.MinAmount > 0 && amount < p.MinAmount {
+            continue
+        }
+        if p.MaxAmount > 0 && amount > p.MaxAmount {
+            continue
+        }
+
+        terms := p.TermMonths
+        if len(terms) == 0 {
+            terms = []int{defaultDepositTermMonths}
+        }
+
+        for _, term := range terms {
+            fits := term <= horizonMonths
+
+            better := false
+            switch {
+            case best == nil:
+                better = true
+            case fits != bestFits:
+                better = fits
+            case fits:
+                better = p.InterestRate > best.InterestRate ||
+                    (p.InterestRate == best.InterestRate && term > bestTerm)
+            default:
+                better = term < bestTerm ||
+                    (term == bestTerm && p.InterestRate > best.InterestRate)
+            }
+
+            if better {
+                best, bestTerm, bestFits = p, term, fits
+            }
+        }
+    }
+
+    return best, bestTerm
+}
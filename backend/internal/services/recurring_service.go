@@ -0,0 +1,314 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+    "github.com/rs/zerolog"
+)
+
+// recurringLookbackMonths covers two payments of a yearly subscription
+const recurringLookbackMonths = 13
+
+// recurringPeriods are the schedules detection recognises. An interval is
+// regular when it is within tolerance days of the period length.
+var recurringPeriods = []struct {
+    period         models.RecurringPeriod
+    days           float64
+    tolerance      float64
+    minOccurrences int
+}{
+    {models.RecurringWeekly, 7, 2, 4},
+    {models.RecurringMonthly, 30.4, 4, 3},
+    {models.RecurringYearly, 365, 15, 2},
+}
+
+var rentKeywords = []string{"аренд", "найм", "rent"}
+
+type RecurringService struct {
+    recurringRepo   repository.RecurringRepository
+    transactionRepo repository.TransactionRepository
+    logger          *zerolog.Logger
+}
+
+func NewRecurringService(
+    recurringRepo repository.RecurringRepository,
+    transactionRepo repository.TransactionRepository,
+    logger *zerolog.Logger,
+) *RecurringService {
+    return &RecurringService{
+        recurringRepo:   recurringRepo,
+        transactionRepo: transactionRepo,
+        logger:          logger,
+    }
+}
+
+// DetectRecurring looks for outgoing payments repeating weekly, monthly or
+// yearly with a stable amount and saves them. Payments the user already
+// confirmed or dismissed keep their status.
+func (s *RecurringService) DetectRecurring(ctx context.Context, userID int) ([]models.RecurringPayment, error) {
+    s.logger.Info().Int("userId", userID).Msg("Detecting recurring payments")
+
+    now := time.Now()
+    transactions, err := s.transactionRepo.GetUserTransactions(ctx, userID, now.AddDate(0, -recurringLookbackMonths, 0), now)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get transactions: %w", err)
+    }
+
+    // Group outgoing payments by counterparty, or description when the bank
+    // gives no counterparty. Deposits are savings, not payments.
+    groups := make(map[string][]models.Transaction)
+    for _, tx := range transactions {
+        if tx.Amount >= 0 || stringValue(tx.Category) == models.CategorySavings {
+            continue
+        }
+
+        key := strings.ToLower(strings.TrimSpace(stringValue(tx.CounterpartyName)))
+        if key == "" {
+            key = strings.ToLower(strings.TrimSpace(stringValue(tx.Description)))
+        }
+        if key == "" {
+            continue
+        }
+
+        groups[key] = append(groups[key], tx)
+    }
+
+    detected := []models.RecurringPayment{}
+
+    for _, group := range groups {
+        payment := detectRecurringPayment(group, now)
+        if payment == nil {
+            continue
+        }
+
+        payment.UserID = userID
+        if err := s.recurringRepo.Upsert(ctx, payment); err != nil {
+            return nil, err
+        }
+        detected = append(detected, *payment)
+    }
+
+    sort.Slice(detected, func(i, j int) bool {
+        return detected[i].NextDueDate.Before(detected[j].NextDueDate)
+    })
+
+    s.logger.Info().Int("userId", userID).Int("detected", len(detected)).Msg("Recurring payments detected")
+
+    return detected, nil
+}
+
+// GetRecurring returns saved payments with status, all but dismissed by default
+func (s *RecurringService) GetRecurring(ctx context.Context, userID int, status string) ([]models.RecurringPayment, error) {
+    payments, err := s.recurringRepo.GetUserPayments(ctx, userID, status)
+    if err != nil {
+        return nil, err
+    }
+    if payments == nil {
+        payments = []models.RecurringPayment{}
+    }
+    return payments, nil
+}
+
+// SetStatus confirms or dismisses a detected payment
+func (s *RecurringService) SetStatus(ctx context.Context, userID, paymentID int, status models.RecurringStatus) (*models.RecurringPayment, error) {
+    payment, err := s.recurringRepo.GetByID(ctx, paymentID)
+    if err != nil {
+        return nil, err
+    }
+    if payment.UserID != userID {
+        return nil, fmt.Errorf("recurring payment does not belong to user")
+    }
+
+    if err := s.recurringRepo.UpdateStatus(ctx, paymentID, string(status)); err != nil {
+        return nil, err
+    }
+    payment.Status = string(status)
+
+    return payment, nil
+}
+
+// Upcoming returns payments expected up to until, including overdue ones,
+// so cash-flow checks can keep money for them
+func (s *RecurringService) Upcoming(ctx context.Context, userID int, until time.Time) (*models.UpcomingPayments, error) {
+    payments, err := s.recurringRepo.GetUpcoming(ctx, userID, until)
+    if err != nil {
+        return nil, err
+    }
+
+    upcoming := &models.UpcomingPayments{
+        Until:    until,
+        Payments: []models.RecurringPayment{},
+    }
+    for _, p := range payments {
+        upcoming.Total += p.AvgAmount
+        upcoming.Payments = append(upcoming.Payments, p)
+    }
+
+    return upcoming, nil
+}
+
+// detectRecurringPayment checks the payments to one counterparty for a
+// regular schedule and a stable amount. Returns nil when there is none or
+// when the payments have stopped.
+func detectRecurringPayment(transactions []models.Transaction, now time.Time) *models.RecurringPayment {
+    sort.Slice(transactions, func(i, j int) bool {
+        return transactions[i].BookingDateTime.Before(transactions[j].BookingDateTime)
+    })
+
+    // Several payments on one day count as one occurrence
+    var (
+        dates   []time.Time
+        amounts []float64
+    )
+    for _, tx := range transactions {
+        day := truncateToDate(tx.BookingDateTime)
+        if n := len(dates); n > 0 && dates[n-1].Equal(day) {
+            amounts[n-1] += tx.Amount.Abs().Float64()
+            continue
+        }
+        dates = append(dates, day)
+        amounts = append(amounts, tx.Amount.Abs().Float64())
+    }
+
+    if len(dates) < 2 {
+        return nil
+    }
+
+    intervals := make([]float64, 0, len(dates)-1)
+    for i := 1; i < len(dates); i++ {
+        intervals = append(intervals, dates[i].Sub(dates[i-1]).Hours()/24)
+    }
+    median := medianOf(intervals)
+
+    for _, p := range recurringPeriods {
+        if math.Abs(median-p.days) > p.tolerance || len(dates) < p.minOccurrences {
+            continue
+        }
+
+        regular := 0
+        for _, interval := range intervals {
+            if math.Abs(interval-p.days) <= p.tolerance {
+                regular++
+            }
+        }
+        regularity := float64(regular) / float64(len(intervals))
+        if regularity < 0.75 {
+            return nil
+        }
+
+        mean, stdDev := meanAndStdDev(amounts)
+        variation := stdDev / mean
+        if variation > 0.25 {
+            return nil
+        }
+
+        last := dates[len(dates)-1]
+        if now.Sub(last).Hours()/24 > p.days*1.5 {
+            return nil
+        }
+
+        confidence := "low"
+        if variation <= 0.05 && regularity == 1 {
+            confidence = "high"
+        } else if variation <= 0.15 {
+            confidence = "medium"
+        }
+
+        lastTx := transactions[len(transactions)-1]
+        accountID := lastTx.AccountID
+        counterparty := strings.TrimSpace(stringValue(lastTx.CounterpartyName))
+        if counterparty == "" {
+            counterparty = strings.TrimSpace(stringValue(lastTx.Description))
+        }
+        if r := []rune(counterparty); len(r) > 255 {
+            counterparty = string(r[:255])
+        }
+
+        return &models.RecurringPayment{
+            Counterparty: counterparty,
+            AccountID:    &accountID,
+            Category:     lastTx.Category,
+            Kind:         string(recurringKind(lastTx, p.period, variation)),
+            Period:       string(p.period),
+            AvgAmount:    money.FromFloat(mean),
+            LastAmount:   money.FromFloat(amounts[len(amounts)-1]),
+            Occurrences:  len(dates),
+            Confidence:   confidence,
+            LastPaidAt:   lastTx.BookingDateTime,
+            NextDueDate:  nextRecurringDate(last, p.period),
+        }
+    }
+
+    return nil
+}
+
+func recurringKind(tx models.Transaction, period models.RecurringPeriod, variation float64) models.RecurringKind {
+    switch stringValue(tx.Category) {
+    case models.CategoryUtilities:
+        return models.RecurringUtilities
+    case models.CategoryLoans:
+        return models.RecurringLoan
+    case models.CategoryEntertainment, models.CategoryCommunication:
+        return models.RecurringSubscription
+    }
+
+    text := strings.ToLower(stringValue(tx.CounterpartyName) + " " + stringValue(tx.Description))
+    for _, keyword := range rentKeywords {
+        if strings.Contains(text, keyword) {
+            return models.RecurringRent
+        }
+    }
+
+    // A fixed price charged on schedule is what subscriptions look like
+    if variation < 0.01 && period != models.RecurringWeekly {
+        return models.RecurringSubscription
+    }
+
+    return models.RecurringOther
+}
+
+func nextRecurringDate(last time.Time, period models.RecurringPeriod) time.Time {
+    switch period {
+    case models.RecurringWeekly:
+        return last.AddDate(0, 0, 7)
+    case models.RecurringYearly:
+        return last.AddDate(1, 0, 0)
+    default:
+        return last.AddDate(0, 1, 0)
+    }
+}
+
+func medianOf(values []float64) float64 {
+    sorted := append([]float64(nil), values...)
+    sort.Float64s(sorted)
+
+    n := len(sorted)
+    if n%2 == 1 {
+        return sorted[n/2]
+    }
+    return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func meanAndStdDev(values []float64) (float64, float64) {
+    mean := 0.0
+    for _, v := range values {
+        mean += v
+    }
+    mean /= float64(len(values))
+
+    variance := 0.0
+    for _, v := range values {
+        variance += (v - mean) * (v - mean)
+    }
+    variance /= float64(len(values))
+
+    return mean, math.Sqrt(variance)
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+type charge struct {
+    date   string
+    amount string
+}
+
+func outgoingTransactions(counterparty, category string, charges ...charge) []models.Transaction {
+    transactions := make([]models.Transaction, 0, len(charges))
+    for _, c := range charges {
+        date, err := time.Parse("2006-01-02", c.date)
+        if err != nil {
+            panic(err)
+        }
+
+        tx := models.Transaction{
+            AccountID:        1,
+            Amount:           money.MustParse(c.amount).Mul(-1),
+            BookingDateTime:  date.Add(12 * time.Hour),
+            CounterpartyName: &counterparty,
+        }
+        if category != "" {
+            tx.Category = &category
+        }
+        transactions = append(transactions, tx)
+    }
+    return transactions
+}
+
+func TestDetectRecurringPayment(t *testing.T) {
+    now := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+
+    monthly := []charge{
+        {"2024-01-15", "499"}, {"2024-02-15", "499"}, {"2024-03-15", "499"},
+        {"2024-04-15", "499"}, {"2024-05-15", "499"}, {"2024-06-15", "499"},
+    }
+
+    tests := []struct {
+        name         string
+        counterparty string
+        category     string
+        charges      []charge
+
+        // want is nil when no recurring payment is expected
+        want *models.RecurringPayment
+    }{
+        {
+            name:         "monthly subscription",
+            counterparty: "Music Service",
+            charges:      monthly,
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringSubscription),
+                Period:      string(models.RecurringMonthly),
+                AvgAmount:   money.MustParse("499"),
+                LastAmount:  money.MustParse("499"),
+                Occurrences: 6,
+                Confidence:  "high",
+                NextDueDate: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "weekly with a varying amount",
+            counterparty: "Grocery",
+            charges: []charge{
+                {"2024-05-23", "1000"}, {"2024-05-30", "1200"}, {"2024-06-06", "900"},
+                {"2024-06-13", "1100"}, {"2024-06-20", "1000"},
+            },
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringOther),
+                Period:      string(models.RecurringWeekly),
+                AvgAmount:   money.MustParse("1040"),
+                LastAmount:  money.MustParse("1000"),
+                Occurrences: 5,
+                Confidence:  "medium",
+                NextDueDate: time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "yearly",
+            counterparty: "Cloud Storage",
+            charges:      []charge{{"2023-03-01", "2990"}, {"2024-03-01", "2990"}},
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringSubscription),
+                Period:      string(models.RecurringYearly),
+                AvgAmount:   money.MustParse("2990"),
+                LastAmount:  money.MustParse("2990"),
+                Occurrences: 2,
+                Confidence:  "high",
+                NextDueDate: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "rent by counterparty name",
+            counterparty: "Аренда квартиры",
+            charges: []charge{
+                {"2024-03-01", "45000"}, {"2024-04-01", "45000"},
+                {"2024-05-01", "45000"}, {"2024-06-01", "45000"},
+            },
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringRent),
+                Period:      string(models.RecurringMonthly),
+                AvgAmount:   money.MustParse("45000"),
+                LastAmount:  money.MustParse("45000"),
+                Occurrences: 4,
+                Confidence:  "high",
+                NextDueDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "utilities by category",
+            counterparty: "City Power",
+            category:     models.CategoryUtilities,
+            charges: []charge{
+                {"2024-03-10", "3000"}, {"2024-04-10", "3300"},
+                {"2024-05-10", "2800"}, {"2024-06-10", "3100"},
+            },
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringUtilities),
+                Period:      string(models.RecurringMonthly),
+                AvgAmount:   money.MustParse("3050"),
+                LastAmount:  money.MustParse("3100"),
+                Occurrences: 4,
+                Confidence:  "medium",
+                NextDueDate: time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "same day payments are one occurrence",
+            counterparty: "Music Service",
+            charges: []charge{
+                {"2024-04-15", "299"}, {"2024-04-15", "200"},
+                {"2024-05-15", "499"}, {"2024-06-15", "499"},
+            },
+            want: &models.RecurringPayment{
+                Kind:        string(models.RecurringSubscription),
+                Period:      string(models.RecurringMonthly),
+                AvgAmount:   money.MustParse("499"),
+                LastAmount:  money.MustParse("499"),
+                Occurrences: 3,
+                Confidence:  "high",
+                NextDueDate: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+            },
+        },
+        {
+            name:         "stopped",
+            counterparty: "Music Service",
+            charges:      monthly[:3],
+        },
+        {
+            name:         "unstable amount",
+            counterparty: "Marketplace",
+            charges: []charge{
+                {"2024-03-15", "500"}, {"2024-04-15", "4000"},
+                {"2024-05-15", "900"}, {"2024-06-15", "2500"},
+            },
+        },
+        {
+            name:         "irregular intervals",
+            counterparty: "Taxi",
+            charges: []charge{
+                {"2024-03-01", "500"}, {"2024-03-20", "500"}, {"2024-04-25", "500"},
+                {"2024-05-03", "500"}, {"2024-06-15", "500"},
+            },
+        },
+        {
+            name:         "too few monthly payments",
+            counterparty: "Music Service",
+            charges:      monthly[4:],
+        },
+        {
+            name:         "single payment",
+            counterparty: "Music Service",
+            charges:      monthly[5:],
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := detectRecurringPayment(outgoingTransactions(tt.counterparty, tt.category, tt.charges...), now)
+            if tt.want == nil {
+                if got != nil {
+                    t.Fatalf("detected %+v, want nothing", got)
+                }
+                return
+            }
+            if got == nil {
+                t.Fatal("detected nothing")
+            }
+
+            if got.Counterparty != tt.counterparty {
+                t.Errorf("counterparty = %q, want %q", got.Counterparty, tt.counterparty)
+            }
+            if got.Kind != tt.want.Kind || got.Period != tt.want.Period || got.Confidence != tt.want.Confidence {
+                t.Errorf("kind, period, confidence = %s, %s, %s, want %s, %s, %s",
+                    got.Kind, got.Period, got.Confidence, tt.want.Kind, tt.want.Period, tt.want.Confidence)
+            }
+            if got.AvgAmount != tt.want.AvgAmount || got.LastAmount != tt.want.LastAmount {
+                t.Errorf("amounts = %s, %s, want %s, %s", got.AvgAmount, got.LastAmount, tt.want.AvgAmount, tt.want.LastAmount)
+            }
+            if got.Occurrences != tt.want.Occurrences {
+                t.Errorf("occurrences = %d, want %d", got.Occurrences, tt.want.Occurrences)
+            }
+            if !got.NextDueDate.Equal(tt.want.NextDueDate) {
+                t.Errorf("next due = %v, want %v", got.NextDueDate, tt.want.NextDueDate)
+            }
+        })
+    }
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+)
+
+const (
+    // payDayTolerance is how far payments of one stream drift from their
+    // usual day, e.g. when the pay day falls on a weekend
+    payDayTolerance = 4
+    // minIncomeShare is the part of income below which an employer is not
+    // treated as a salary source
+    minIncomeShare = 0.1
+)
+
+// payStream is one regular payment of an employer: a monthly salary, or the
+// advance or salary half of a semi-monthly schedule
+type payStream struct {
+    transactions []models.Transaction
+    payDay       int
+    avgAmount    float64
+    variation    float64
+    regularity   float64
+    intervals    int
+    monthly      bool
+
+    schedule   string
+    payType    string
+    confidence string
+    reason     string
+}
+
+// detectPayStreams splits income from one employer into pay streams by the
+// day of month and scores each by interval regularity and amount stability.
+// share is the employer's part of all income in the lookback window.
+func detectPayStreams(income []models.Transaction, lookbackMonths int, share float64) []*payStream {
+    streams := make([]*payStream, 0)
+    for _, cluster := range clusterPayDays(income) {
+        streams = append(streams, newPayStream(cluster))
+    }
+
+    // Two monthly streams half a month apart are an advance and a salary
+    var monthly []*payStream
+    for _, st := range streams {
+        if st.monthly {
+            monthly = append(monthly, st)
+        }
+    }
+
+    semiMonthly := false
+    if len(monthly) == 2 {
+        gap := dayGap(monthly[0].payDay, monthly[1].payDay)
+        semiMonthly = gap >= 10 && gap <= 20
+    }
+
+    for _, st := range streams {
+        switch {
+        case semiMonthly && st.monthly:
+            st.schedule = models.SalaryScheduleSemiMonthly
+            st.payType = models.PayTypeSalary
+            other := monthly[0]
+            if other == st {
+                other = monthly[1]
+            }
+            // Equal halves are both salary, otherwise the smaller one is the advance
+            if st.avgAmount < other.avgAmount*0.9 {
+                st.payType = models.PayTypeAdvance
+            }
+        case st.monthly:
+            st.schedule = models.SalaryScheduleMonthly
+            st.payType = models.PayTypeSalary
+        default:
+            st.schedule = models.SalaryScheduleIrregular
+        }
+
+        st.score(lookbackMonths, share)
+    }
+
+    return streams
+}
+
+func newPayStream(transactions []models.Transaction) *payStream {
+    sort.Slice(transactions, func(i, j int) bool {
+        return transactions[i].BookingDateTime.Before(transactions[j].BookingDateTime)
+    })
+
+    st := &payStream{
+        transactions: transactions,
+        payDay:       typicalPayDay(transactions),
+    }
+
+    amounts := make([]float64, 0, len(transactions))
+    for _, tx := range transactions {
+        amounts = append(amounts, tx.Amount.Float64())
+    }
+    mean, stdDev := meanAndStdDev(amounts)
+    st.avgAmount = mean
+    if mean > 0 {
+        st.variation = stdDev / mean
+    }
+
+    // A month apart, allowing for pay days moved by weekends and holidays
+    regular := 0
+    for i := 1; i < len(transactions); i++ {
+        days := transactions[i].BookingDateTime.Sub(transactions[i-1].BookingDateTime).Hours() / 24
+        if days >= 25 && days <= 36 {
+            regular++
+        }
+        st.intervals++
+    }
+    if st.intervals > 0 {
+        st.regularity = float64(regular) / float64(st.intervals)
+    }
+    st.monthly = st.intervals > 0 && st.regularity >= 0.5
+
+    return st
+}
+
+// score sets confidence and a human readable reason for it
+func (st *payStream) score(lookbackMonths int, share float64) {
+    reasons := []string{}
+
+    switch st.schedule {
+    case models.SalaryScheduleSemiMonthly:
+        reasons = append(reasons, fmt.Sprintf("%s of a semi-monthly schedule, paid around day %d", st.payType, st.payDay))
+    case models.SalaryScheduleMonthly:
+        reasons = append(reasons, fmt.Sprintf("paid monthly around day %d", st.payDay))
+    default:
+        if len(st.transactions) == 1 {
+            reasons = append(reasons, "single payment")
+        } else {
+            reasons = append(reasons, "no monthly pattern in payment dates")
+        }
+    }
+
+    reasons = append(reasons, fmt.Sprintf("%d payments in %d months", len(st.transactions), lookbackMonths))
+    if st.intervals > 0 {
+        reasons = append(reasons, fmt.Sprintf("%.0f%% of intervals about a month", st.regularity*100))
+    }
+    if len(st.transactions) > 1 {
+        reasons = append(reasons, fmt.Sprintf("amount varies by %.0f%%", st.variation*100))
+    }
+    reasons = append(reasons, fmt.Sprintf("employer gives %.0f%% of income", share*100))
+
+    // A full history has a payment in almost every month of the window
+    enough := len(st.transactions) >= int(math.Max(2, float64(lookbackMonths-1)))
+
+    switch {
+    case st.schedule != models.SalaryScheduleIrregular && enough && st.regularity >= 0.75 && st.variation <= 0.2:
+        st.confidence = "high"
+    case st.schedule != models.SalaryScheduleIrregular && st.variation <= 0.4:
+        st.confidence = "medium"
+    default:
+        st.confidence = "low"
+    }
+
+    if share < minIncomeShare && st.confidence != "low" {
+        st.confidence = "low"
+        reasons = append(reasons, "too small a share of income for a salary")
+    }
+
+    st.reason = strings.Join(reasons, "; ")
+}
+
+// clusterPayDays groups transactions whose days of month are within
+// payDayTolerance of each other. Days at the very end and start of a month
+// fall into one group.
+func clusterPayDays(transactions []models.Transaction) [][]models.Transaction {
+    if len(transactions) == 0 {
+        return nil
+    }
+
+    sorted := append([]models.Transaction(nil), transactions...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return sorted[i].BookingDateTime.Day() < sorted[j].BookingDateTime.Day()
+    })
+
+    clusters := [][]models.Transaction{{sorted[0]}}
+    for _, tx := range sorted[1:] {
+        current := clusters[len(clusters)-1]
+        lastDay := current[len(current)-1].BookingDateTime.Day()
+        if tx.BookingDateTime.Day()-lastDay <= payDayTolerance {
+            clusters[len(clusters)-1] = append(current, tx)
+            continue
+        }
+        clusters = append(clusters, []models.Transaction{tx})
+    }
+
+    if n := len(clusters); n > 1 {
+        first := clusters[0][0].BookingDateTime.Day()
+        last := clusters[n-1][len(clusters[n-1])-1].BookingDateTime.Day()
+        if first+31-last <= payDayTolerance {
+            clusters[0] = append(clusters[0], clusters[n-1]...)
+            clusters = clusters[:n-1]
+        }
+    }
+
+    return clusters
+}
+
+// typicalPayDay is the median day of month of the payments, counting the
+// first days of a month as the end of the previous one when they mix
+func typicalPayDay(transactions []models.Transaction) int {
+    days := make([]int, 0, len(transactions))
+    minDay, maxDay := 31, 1
+    for _, tx := range transactions {
+        day := tx.BookingDateTime.Day()
+        days = append(days, day)
+        minDay = int(math.Min(float64(minDay), float64(day)))
+        maxDay = int(math.Max(float64(maxDay), float64(day)))
+    }
+
+    wrapped := maxDay-minDay > 15
+    for i, day := range days {
+        if wrapped && day <= 15 {
+            days[i] = day + 31
+        }
+    }
+    sort.Ints(days)
+
+    day := days[len(days)/2]
+    if day > 31 {
+        day -= 31
+    }
+    return day
+}
+
+// dayGap is the distance between two days of month, going round month end
+func dayGap(a, b int) int {
+    gap := a - b
+    if gap < 0 {
+        gap = -gap
+    }
+    if 31-gap < gap {
+        gap = 31 - gap
+    }
+    return gap
+}
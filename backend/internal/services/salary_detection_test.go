@@ -0,0 +1,221 @@
+package services
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+)
+
+type payment struct {
+    month, day int
+    amount     string
+}
+
+func incomeTransactions(payments ...payment) []models.Transaction {
+    transactions := make([]models.Transaction, 0, len(payments))
+    for _, p := range payments {
+        transactions = append(transactions, models.Transaction{
+            Amount:          money.MustParse(p.amount),
+            BookingDateTime: time.Date(2024, time.Month(p.month), p.day, 10, 0, 0, 0, time.UTC),
+        })
+    }
+    return transactions
+}
+
+// monthlyPayments pays amount on day for months months starting in January
+func monthlyPayments(day, months int, amount string) []payment {
+    payments := make([]payment, 0, months)
+    for m := 1; m <= months; m++ {
+        payments = append(payments, payment{m, day, amount})
+    }
+    return payments
+}
+
+func TestDetectPayStreams(t *testing.T) {
+    type stream struct {
+        schedule   string
+        payType    string
+        confidence string
+        payDay     int
+    }
+
+    tests := []struct {
+        name       string
+        payments   []payment
+        share      float64
+        want       []stream
+        wantReason string
+    }{
+        {
+            name:     "monthly salary",
+            payments: monthlyPayments(10, 6, "100000"),
+            share:    0.9,
+            want:     []stream{{models.SalaryScheduleMonthly, models.PayTypeSalary, "high", 10}},
+        },
+        {
+            name: "pay day moved by weekends",
+            payments: []payment{
+                {1, 10, "100000"}, {2, 9, "100000"}, {3, 10, "100000"},
+                {4, 12, "100000"}, {5, 10, "100000"}, {6, 8, "100000"},
+            },
+            share: 0.9,
+            want:  []stream{{models.SalaryScheduleMonthly, models.PayTypeSalary, "high", 10}},
+        },
+        {
+            name:     "advance and salary",
+            payments: append(monthlyPayments(10, 6, "40000"), monthlyPayments(25, 6, "60000")...),
+            share:    0.9,
+            want: []stream{
+                {models.SalaryScheduleSemiMonthly, models.PayTypeAdvance, "high", 10},
+                {models.SalaryScheduleSemiMonthly, models.PayTypeSalary, "high", 25},
+            },
+        },
+        {
+            name:     "equal halves",
+            payments: append(monthlyPayments(10, 6, "50000"), monthlyPayments(25, 6, "50000")...),
+            share:    0.9,
+            want: []stream{
+                {models.SalaryScheduleSemiMonthly, models.PayTypeSalary, "high", 10},
+                {models.SalaryScheduleSemiMonthly, models.PayTypeSalary, "high", 25},
+            },
+        },
+        {
+            name:     "unstable amount",
+            payments: []payment{{1, 10, "30000"}, {2, 10, "100000"}, {3, 10, "40000"}, {4, 10, "150000"}},
+            share:    0.9,
+            want:     []stream{{models.SalaryScheduleMonthly, models.PayTypeSalary, "low", 10}},
+        },
+        {
+            name:     "irregular days",
+            payments: []payment{{1, 2, "30000"}, {3, 16, "30000"}, {5, 27, "30000"}},
+            share:    0.9,
+            want: []stream{
+                {models.SalaryScheduleIrregular, "", "low", 2},
+                {models.SalaryScheduleIrregular, "", "low", 16},
+                {models.SalaryScheduleIrregular, "", "low", 27},
+            },
+            wantReason: "single payment",
+        },
+        {
+            name:       "small share of income",
+            payments:   monthlyPayments(10, 6, "5000"),
+            share:      0.05,
+            want:       []stream{{models.SalaryScheduleMonthly, models.PayTypeSalary, "low", 10}},
+            wantReason: "too small a share",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            streams := detectPayStreams(incomeTransactions(tt.payments...), 6, tt.share)
+            if len(streams) != len(tt.want) {
+                t.Fatalf("got %d streams, want %d", len(streams), len(tt.want))
+            }
+
+            for i, st := range streams {
+                got := stream{st.schedule, st.payType, st.confidence, st.payDay}
+                if got != tt.want[i] {
+                    t.Errorf("stream %d = %+v, want %+v", i, got, tt.want[i])
+                }
+                if !strings.Contains(st.reason, tt.wantReason) {
+                    t.Errorf("stream %d reason %q does not mention %q", i, st.reason, tt.wantReason)
+                }
+            }
+        })
+    }
+}
+
+// The tests below pay in odd months, which all have 31 days
+
+func TestClusterPayDays(t *testing.T) {
+    tests := []struct {
+        name string
+        days []int
+        want [][]int
+    }{
+        {"empty", nil, nil},
+        {"one day", []int{10, 10, 10}, [][]int{{10, 10, 10}}},
+        {"within tolerance", []int{8, 10, 12}, [][]int{{8, 10, 12}}},
+        {"two pay days", []int{10, 25, 11, 24}, [][]int{{10, 11}, {24, 25}}},
+        {"month end wraps", []int{30, 1, 31, 2}, [][]int{{1, 2, 30, 31}}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var payments []payment
+            for i, day := range tt.days {
+                payments = append(payments, payment{2*i + 1, day, "1"})
+            }
+
+            clusters := clusterPayDays(incomeTransactions(payments...))
+            if len(clusters) != len(tt.want) {
+                t.Fatalf("got %d clusters, want %d", len(clusters), len(tt.want))
+            }
+            for i, cluster := range clusters {
+                var days []int
+                for _, tx := range cluster {
+                    days = append(days, tx.BookingDateTime.Day())
+                }
+                if !equalInts(days, tt.want[i]) {
+                    t.Errorf("cluster %d = %v, want %v", i, days, tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+func TestTypicalPayDay(t *testing.T) {
+    tests := []struct {
+        days []int
+        want int
+    }{
+        {[]int{10}, 10},
+        {[]int{9, 10, 12}, 10},
+        {[]int{28, 30, 1}, 30},
+        {[]int{30, 1, 2}, 1},
+    }
+
+    for _, tt := range tests {
+        var payments []payment
+        for i, day := range tt.days {
+            payments = append(payments, payment{2*i + 1, day, "1"})
+        }
+        if got := typicalPayDay(incomeTransactions(payments...)); got != tt.want {
+            t.Errorf("typicalPayDay(%v) = %d, want %d", tt.days, got, tt.want)
+        }
+    }
+}
+
+func TestDayGap(t *testing.T) {
+    tests := []struct {
+        a, b int
+        want int
+    }{
+        {10, 25, 15},
+        {25, 10, 15},
+        {10, 10, 0},
+        {30, 2, 3},
+        {1, 31, 1},
+    }
+
+    for _, tt := range tests {
+        if got := dayGap(tt.a, tt.b); got != tt.want {
+            t.Errorf("dayGap(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
+
+func equalInts(a, b []int) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
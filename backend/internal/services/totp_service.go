@@ -0,0 +1,249 @@
+package services
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base32"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/KotovBoris/AutoSave/backend/internal/models"
+    "github.com/KotovBoris/AutoSave/backend/internal/repository"
+    "github.com/KotovBoris/AutoSave/backend/pkg/totp"
+    "github.com/rs/zerolog"
+)
+
+var (
+    ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+    ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+    ErrTOTPNotSetUp       = errors.New("two-factor authentication has not been set up")
+    ErrInvalidTOTPCode    = errors.New("invalid or already used code")
+)
+
+// recoveryCodeCount recovery codes are issued at a time, each of 8 base32
+// characters shown as xxxx-xxxx
+const recoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPService manages the optional TOTP second factor. Users who enrolled
+// confirm sensitive requests with a fresh code from their app or with a
+// recovery code.
+type TOTPService struct {
+    totpRepo repository.TOTPRepository
+    userRepo repository.UserRepository
+    issuer   string
+    logger   *zerolog.Logger
+}
+
+func NewTOTPService(totpRepo repository.TOTPRepository, userRepo repository.UserRepository, issuer string, logger *zerolog.Logger) *TOTPService {
+    return &TOTPService{
+        totpRepo: totpRepo,
+        userRepo: userRepo,
+        issuer:   issuer,
+        logger:   logger,
+    }
+}
+
+func (s *TOTPService) Status(ctx context.Context, userID int) (*models.TOTPStatusResponse, error) {
+    enrolment, err := s.totpRepo.Get(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if !enrolment.Enabled() {
+        return &models.TOTPStatusResponse{}, nil
+    }
+
+    left, err := s.totpRepo.CountRecoveryCodes(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    return &models.TOTPStatusResponse{
+        Enabled:           true,
+        EnabledAt:         enrolment.ConfirmedAt,
+        RecoveryCodesLeft: left,
+    }, nil
+}
+
+// Setup starts enrolment with a new secret. It takes effect once Enable
+// gets a code generated from it.
+func (s *TOTPService) Setup(ctx context.Context, userID int) (*models.TOTPSetupResponse, error) {
+    enrolment, err := s.totpRepo.Get(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if enrolment.Enabled() {
+        return nil, ErrTOTPAlreadyEnabled
+    }
+
+    user, err := s.userRepo.GetByID(ctx, userID)
+    if err != nil {
+        return nil, fmt.Errorf("user not found: %w", err)
+    }
+
+    secret, err := totp.GenerateSecret()
+    if err != nil {
+        return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+    }
+
+    if err := s.totpRepo.SavePending(ctx, userID, secret); err != nil {
+        return nil, err
+    }
+
+    return &models.TOTPSetupResponse{
+        Secret:     secret,
+        OTPAuthURL: totp.URI(s.issuer, user.Email, secret),
+    }, nil
+}
+
+// Enable confirms enrolment with a first code and returns recovery codes
+func (s *TOTPService) Enable(ctx context.Context, userID int, code string) ([]string, error) {
+    enrolment, err := s.totpRepo.Get(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if enrolment == nil {
+        return nil, ErrTOTPNotSetUp
+    }
+    if enrolment.Enabled() {
+        return nil, ErrTOTPAlreadyEnabled
+    }
+
+    if err := s.checkCode(ctx, enrolment, normalizeTOTPCode(code)); err != nil {
+        return nil, err
+    }
+
+    if err := s.totpRepo.Confirm(ctx, userID); err != nil {
+        return nil, err
+    }
+
+    s.logger.Info().Int("userId", userID).Msg("Two-factor authentication enabled")
+
+    return s.newRecoveryCodes(ctx, userID)
+}
+
+// Disable removes the second factor, it takes a code like any other
+// sensitive request
+func (s *TOTPService) Disable(ctx context.Context, userID int, code string) error {
+    if err := s.Verify(ctx, userID, code); err != nil {
+        return err
+    }
+
+    if err := s.totpRepo.Delete(ctx, userID); err != nil {
+        return err
+    }
+
+    s.logger.Info().Int("userId", userID).Msg("Two-factor authentication disabled")
+
+    return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user
+func (s *TOTPService) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+    if err := s.Verify(ctx, userID, code); err != nil {
+        return nil, err
+    }
+
+    return s.newRecoveryCodes(ctx, userID)
+}
+
+// Verify accepts a current TOTP code or an unused recovery code. Either
+// works only once.
+func (s *TOTPService) Verify(ctx context.Context, userID int, code string) error {
+    enrolment, err := s.totpRepo.Get(ctx, userID)
+    if err != nil {
+        return err
+    }
+    if !enrolment.Enabled() {
+        return ErrTOTPNotEnabled
+    }
+
+    code = normalizeTOTPCode(code)
+    if len(code) == totp.Digits {
+        return s.checkCode(ctx, enrolment, code)
+    }
+
+    used, err := s.totpRepo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+    if err != nil {
+        return err
+    }
+    if !used {
+        return ErrInvalidTOTPCode
+    }
+
+    s.logger.Warn().Int("userId", userID).Msg("Recovery code used")
+
+    return nil
+}
+
+// TOTPEnabled reports whether the user has a confirmed second factor
+func (s *TOTPService) TOTPEnabled(ctx context.Context, userID int) (bool, error) {
+    enrolment, err := s.totpRepo.Get(ctx, userID)
+    if err != nil {
+        return false, err
+    }
+    return enrolment.Enabled(), nil
+}
+
+// VerifyTOTP is Verify for the step-up middleware, a wrong code is false
+// rather than an error
+func (s *TOTPService) VerifyTOTP(ctx context.Context, userID int, code string) (bool, error) {
+    err := s.Verify(ctx, userID, code)
+    if errors.Is(err, ErrInvalidTOTPCode) {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+// checkCode validates a TOTP code and records its step, so the same code
+// cannot be used twice
+func (s *TOTPService) checkCode(ctx context.Context, enrolment *models.UserTOTP, code string) error {
+    step, ok := totp.Validate(enrolment.Secret, code, time.Now())
+    if !ok {
+        return ErrInvalidTOTPCode
+    }
+
+    fresh, err := s.totpRepo.UseStep(ctx, enrolment.UserID, step)
+    if err != nil {
+        return err
+    }
+    if !fresh {
+        return ErrInvalidTOTPCode
+    }
+
+    return nil
+}
+
+func (s *TOTPService) newRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+    codes := make([]string, recoveryCodeCount)
+    hashes := make([]string, recoveryCodeCount)
+
+    for i := range codes {
+        buf := make([]byte, 5)
+        if _, err := rand.Read(buf); err != nil {
+            return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+        }
+        raw := strings.ToLower(recoveryEncoding.EncodeToString(buf))
+        codes[i] = raw[:4] + "-" + raw[4:]
+        hashes[i] = hashToken(raw)
+    }
+
+    if err := s.totpRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+        return nil, err
+    }
+
+    return codes, nil
+}
+
+func normalizeTOTPCode(code string) string {
+    return strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+}
+
+// normalizeRecoveryCode accepts recovery codes with or without the dash,
+// in either case
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,4 @@
+-- 003_autopilot_schedule.down.sql
+DROP INDEX IF EXISTS idx_goals_next_deposit_date;
+DROP INDEX IF EXISTS idx_deposits_goal_scheduled_for;
+ALTER TABLE deposits DROP COLUMN IF EXISTS scheduled_for;
@@ -0,0 +1,11 @@
+-- 003_autopilot_schedule.up.sql
+-- Track which salary date an autopilot deposit was opened for
+
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS scheduled_for DATE;
+
+-- One autopilot deposit per goal per salary date
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deposits_goal_scheduled_for
+    ON deposits(goal_id, scheduled_for)
+    WHERE scheduled_for IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_goals_next_deposit_date ON goals(next_deposit_date);
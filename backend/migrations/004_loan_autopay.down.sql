@@ -0,0 +1,7 @@
+-- 004_loan_autopay.down.sql
+DROP INDEX IF EXISTS idx_loans_next_payment_date;
+DROP INDEX IF EXISTS idx_loan_payments_status;
+DROP INDEX IF EXISTS idx_loan_payments_autopay_date;
+ALTER TABLE loans DROP COLUMN IF EXISTS creditor_bank_code;
+ALTER TABLE loans DROP COLUMN IF EXISTS creditor_name;
+ALTER TABLE loans DROP COLUMN IF EXISTS creditor_account;
@@ -0,0 +1,13 @@
+-- 004_loan_autopay.up.sql
+-- Creditor details for loan autopay and one autopay payment per loan per date
+
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS creditor_account VARCHAR(255);
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS creditor_name VARCHAR(255);
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS creditor_bank_code VARCHAR(50);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loan_payments_autopay_date
+    ON loan_payments(loan_id, scheduled_date)
+    WHERE is_autopay = true;
+
+CREATE INDEX IF NOT EXISTS idx_loan_payments_status ON loan_payments(status);
+CREATE INDEX IF NOT EXISTS idx_loans_next_payment_date ON loans(next_payment_date);
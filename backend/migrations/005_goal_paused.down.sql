@@ -0,0 +1,6 @@
+-- 005_goal_paused.down.sql
+UPDATE goals SET status = 'waiting' WHERE status = 'paused';
+
+ALTER TABLE goals DROP CONSTRAINT IF EXISTS goals_status_check;
+ALTER TABLE goals ADD CONSTRAINT goals_status_check
+    CHECK (status IN ('active', 'waiting', 'completed', 'cancelled'));
@@ -0,0 +1,6 @@
+-- 005_goal_paused.up.sql
+-- Goals can be paused after an emergency withdrawal
+
+ALTER TABLE goals DROP CONSTRAINT IF EXISTS goals_status_check;
+ALTER TABLE goals ADD CONSTRAINT goals_status_check
+    CHECK (status IN ('active', 'waiting', 'paused', 'completed', 'cancelled'));
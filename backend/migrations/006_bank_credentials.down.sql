@@ -0,0 +1,5 @@
+-- 006_bank_credentials.down.sql
+ALTER TABLE user_banks DROP COLUMN IF EXISTS status;
+ALTER TABLE user_banks DROP COLUMN IF EXISTS consents_checked_at;
+ALTER TABLE user_banks DROP COLUMN IF EXISTS product_consent_expires_at;
+ALTER TABLE user_banks DROP COLUMN IF EXISTS account_consent_expires_at;
@@ -0,0 +1,8 @@
+-- 006_bank_credentials.up.sql
+-- Consent expiry tracking and re-authorisation status for bank connections
+
+ALTER TABLE user_banks ADD COLUMN IF NOT EXISTS account_consent_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE user_banks ADD COLUMN IF NOT EXISTS product_consent_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE user_banks ADD COLUMN IF NOT EXISTS consents_checked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE user_banks ADD COLUMN IF NOT EXISTS status VARCHAR(30) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'reauth_required'));
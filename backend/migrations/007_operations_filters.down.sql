@@ -0,0 +1,4 @@
+-- 007_operations_filters.down.sql
+DROP INDEX IF EXISTS idx_operations_related_loan_id;
+DROP INDEX IF EXISTS idx_operations_related_goal_id;
+DROP INDEX IF EXISTS idx_operations_user_id_id;
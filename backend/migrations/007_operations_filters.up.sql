@@ -0,0 +1,6 @@
+-- 007_operations_filters.up.sql
+-- Indexes for operations audit log filters and pagination
+
+CREATE INDEX IF NOT EXISTS idx_operations_user_id_id ON operations(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_operations_related_goal_id ON operations(related_goal_id);
+CREATE INDEX IF NOT EXISTS idx_operations_related_loan_id ON operations(related_loan_id);
@@ -0,0 +1,4 @@
+-- 008_transaction_categories.down.sql
+DROP INDEX IF EXISTS idx_transactions_category;
+ALTER TABLE transactions DROP COLUMN IF EXISTS category_source;
+DROP TABLE IF EXISTS category_rules;
@@ -0,0 +1,30 @@
+-- 008_transaction_categories.up.sql
+-- User categorisation rules and the origin of each transaction category
+
+CREATE TABLE IF NOT EXISTS category_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(50) NOT NULL,
+    counterparty VARCHAR(255),
+    description_pattern TEXT,
+    min_amount DECIMAL(15,2),
+    max_amount DECIMAL(15,2),
+    account_id INTEGER REFERENCES accounts(id) ON DELETE CASCADE,
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_rules_user_id ON category_rules(user_id);
+
+DROP TRIGGER IF EXISTS update_category_rules_updated_at ON category_rules;
+CREATE TRIGGER update_category_rules_updated_at BEFORE UPDATE ON category_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- bank: as reported by the bank, rule: user rule, dictionary: built-in keywords,
+-- manual: set by the user and never overwritten
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS category_source VARCHAR(20)
+    CHECK (category_source IN ('bank', 'rule', 'dictionary', 'manual'));
+UPDATE transactions SET category_source = 'bank' WHERE category IS NOT NULL AND category <> '';
+
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category);
@@ -0,0 +1,2 @@
+-- 009_recurring_payments.down.sql
+DROP TABLE IF EXISTS recurring_payments;
@@ -0,0 +1,28 @@
+-- 009_recurring_payments.up.sql
+-- Outgoing payments detected as recurring, with user confirmation
+
+CREATE TABLE IF NOT EXISTS recurring_payments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    counterparty VARCHAR(255) NOT NULL,
+    account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL,
+    category VARCHAR(50),
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('subscription', 'rent', 'utilities', 'loan', 'other')),
+    period VARCHAR(10) NOT NULL CHECK (period IN ('weekly', 'monthly', 'yearly')),
+    avg_amount DECIMAL(15,2) NOT NULL,
+    last_amount DECIMAL(15,2) NOT NULL,
+    occurrences INTEGER NOT NULL,
+    confidence VARCHAR(10) NOT NULL CHECK (confidence IN ('high', 'medium', 'low')),
+    last_paid_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_due_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'detected' CHECK (status IN ('detected', 'confirmed', 'dismissed')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(user_id, counterparty, period)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_payments_user_due ON recurring_payments(user_id, next_due_date);
+
+DROP TRIGGER IF EXISTS update_recurring_payments_updated_at ON recurring_payments;
+CREATE TRIGGER update_recurring_payments_updated_at BEFORE UPDATE ON recurring_payments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+-- 010_transaction_sync.down.sql
+DROP INDEX IF EXISTS idx_transactions_account_booking;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS sync_page;
+ALTER TABLE accounts DROP COLUMN IF EXISTS sync_to;
+ALTER TABLE accounts DROP COLUMN IF EXISTS sync_from;
@@ -0,0 +1,9 @@
+-- 010_transaction_sync.up.sql
+-- Cursor of an unfinished transaction sync, so it resumes at the page it stopped
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sync_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sync_to TIMESTAMP WITH TIME ZONE;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sync_page INTEGER;
+
+-- Watermark lookup: latest booked transaction of an account
+CREATE INDEX IF NOT EXISTS idx_transactions_account_booking ON transactions(account_id, booking_date_time DESC);
@@ -0,0 +1,2 @@
+-- 011_bank_registry.down.sql
+ALTER TABLE banks DROP COLUMN IF EXISTS driver;
@@ -0,0 +1,9 @@
+-- 011_bank_registry.up.sql
+-- Banks name the adapter driver that talks to their API
+
+ALTER TABLE banks ADD COLUMN IF NOT EXISTS driver VARCHAR(50);
+
+-- Seeded sandbox banks each have a driver of the same name
+UPDATE banks SET driver = id WHERE driver IS NULL;
+
+ALTER TABLE banks ALTER COLUMN driver SET NOT NULL;
@@ -0,0 +1,4 @@
+-- 012_multi_currency.down.sql
+ALTER TABLE deposits DROP COLUMN IF EXISTS currency;
+ALTER TABLE goals DROP COLUMN IF EXISTS currency;
+ALTER TABLE users DROP COLUMN IF EXISTS base_currency;
@@ -0,0 +1,7 @@
+-- 012_multi_currency.up.sql
+-- Totals are shown in the base currency of the user, goals and their
+-- deposits keep the currency the money is saved in
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS base_currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
@@ -0,0 +1,3 @@
+-- 014_email_verification.down.sql
+DROP TABLE IF EXISTS user_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
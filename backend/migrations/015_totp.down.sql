@@ -0,0 +1,3 @@
+-- 015_totp.down.sql
+DROP TABLE IF EXISTS totp_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
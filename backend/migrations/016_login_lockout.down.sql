@@ -0,0 +1,3 @@
+-- 016_login_lockout.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_logins;
@@ -0,0 +1,6 @@
+-- 016_login_lockout.up.sql
+-- Failed logins in a row. Past a threshold the account is locked for a
+-- while that grows with every further failure, a successful login resets it.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+-- 017_loan_payment_retry.down.sql
+ALTER TABLE loan_payments DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE loan_payments DROP COLUMN IF EXISTS attempts;
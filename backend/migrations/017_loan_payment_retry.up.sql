@@ -0,0 +1,6 @@
+-- 017_loan_payment_retry.up.sql
+-- Autopay payments that failed for a temporary reason are tried again later.
+-- attempts counts sends to the bank, next_attempt_at holds the retry back.
+
+ALTER TABLE loan_payments ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loan_payments ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+-- 018_deposit_retry.down.sql
+ALTER TABLE deposits DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE deposits DROP COLUMN IF EXISTS attempts;
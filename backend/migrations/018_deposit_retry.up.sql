@@ -0,0 +1,11 @@
+-- 018_deposit_retry.up.sql
+-- Autopilot deposits that failed for a temporary reason are tried again later.
+-- attempts counts sends to the bank, next_attempt_at holds the retry back.
+-- A failed deposit without next_attempt_at is not retried.
+
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE deposits ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+
+-- Failed deposits used to be retried on every run, give them one more try
+UPDATE deposits SET attempts = 1, next_attempt_at = NOW()
+WHERE status = 'failed' AND scheduled_for IS NOT NULL;
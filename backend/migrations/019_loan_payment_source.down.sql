@@ -0,0 +1,2 @@
+-- 019_loan_payment_source.down.sql
+ALTER TABLE loan_payments DROP COLUMN IF EXISTS source_account_id;
@@ -0,0 +1,5 @@
+-- 019_loan_payment_source.up.sql
+-- The account an autopay payment is debited from, so a resend uses the same
+-- account and its local balance changes once the payment completes.
+
+ALTER TABLE loan_payments ADD COLUMN IF NOT EXISTS source_account_id INTEGER REFERENCES accounts(id) ON DELETE SET NULL;
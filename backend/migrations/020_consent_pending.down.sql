@@ -0,0 +1,6 @@
+-- 020_consent_pending.down.sql
+UPDATE user_banks SET status = 'reauth_required' WHERE status = 'consent_pending';
+
+ALTER TABLE user_banks DROP CONSTRAINT IF EXISTS user_banks_status_check;
+ALTER TABLE user_banks ADD CONSTRAINT user_banks_status_check
+    CHECK (status IN ('active', 'reauth_required'));
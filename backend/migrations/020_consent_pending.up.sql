@@ -0,0 +1,11 @@
+-- 020_consent_pending.up.sql
+-- Connections whose consent waits for approval in the bank get their own
+-- status, checked again on the next call instead of blocking until the
+-- user connects the bank again. Until now such connections were stored as
+-- reauth_required.
+
+ALTER TABLE user_banks DROP CONSTRAINT IF EXISTS user_banks_status_check;
+ALTER TABLE user_banks ADD CONSTRAINT user_banks_status_check
+    CHECK (status IN ('active', 'consent_pending', 'reauth_required'));
+
+UPDATE user_banks SET status = 'consent_pending' WHERE status = 'reauth_required';
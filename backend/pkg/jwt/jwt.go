@@ -13,8 +13,11 @@ type JWTUtil struct {
 }
 
 type Claims struct {
-    UserID int    `json:"user_id"`
-    Email  string `json:"email"`
+    UserID    int    `json:"user_id"`
+    Email     string `json:"email"`
+    // SessionID is the login session the token belongs to, tokens issued
+    // before sessions existed have none
+    SessionID int    `json:"sid,omitempty"`
     jwt.RegisteredClaims
 }
 
@@ -25,18 +28,27 @@ func NewJWTUtil(secret string, expiry time.Duration) *JWTUtil {
     }
 }
 
-func (j *JWTUtil) GenerateToken(userID int, email string) (string, error) {
+// GenerateToken issues an access token for a session and returns it with
+// its expiry time
+func (j *JWTUtil) GenerateToken(userID int, email string, sessionID int) (string, time.Time, error) {
+    now := time.Now()
+    expiresAt := now.Add(j.expiry)
     claims := &Claims{
-        UserID: userID,
-        Email:  email,
+        UserID:    userID,
+        Email:     email,
+        SessionID: sessionID,
         RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiry)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
+            ExpiresAt: jwt.NewNumericDate(expiresAt),
+            IssuedAt:  jwt.NewNumericDate(now),
         },
     }
     
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString(j.secret)
+    signed, err := token.SignedString(j.secret)
+    if err != nil {
+        return "", time.Time{}, err
+    }
+    return signed, expiresAt, nil
 }
 
 func (j *JWTUtil) ValidateToken(tokenString string) (*Claims, error) {
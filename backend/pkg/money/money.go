@@ -0,0 +1,302 @@
+// Package money holds sums of money as exact fixed-point numbers.
+//
+// Rounding rules: amounts have two decimal places. Anything finer, whether
+// parsed from a bank, converted from a float or produced by a calculation,
+// is rounded to the nearest kopeck with halves rounded away from zero, the
+// way banks round. Sums and differences are exact.
+package money
+
+import (
+    "database/sql/driver"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// Amount is a sum of money in minor units, kopecks for roubles. It reads
+// and writes JSON as a number with two decimals and scans DECIMAL columns.
+type Amount int64
+
+// Zero is no money
+const Zero Amount = 0
+
+// minorUnits is how many minor units make one major unit
+const minorUnits = 100
+
+// FromMinor makes an amount of minor units
+func FromMinor(minor int64) Amount {
+    return Amount(minor)
+}
+
+// FromMajor makes an amount of whole major units, roubles for roubles
+func FromMajor(major int64) Amount {
+    return Amount(major * minorUnits)
+}
+
+// FromFloat converts a float, rounding to the kopeck
+func FromFloat(f float64) Amount {
+    return Amount(math.Round(f * minorUnits))
+}
+
+// Parse reads a decimal string such as "1500.5" or "-12.345", rounding to
+// the kopeck
+func Parse(s string) (Amount, error) {
+    s = strings.TrimSpace(s)
+    r, ok := new(big.Rat).SetString(s)
+    if !ok || strings.ContainsAny(s, "/eE") {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+
+    minor := roundRat(r.Mul(r, big.NewRat(minorUnits, 1)))
+    if !minor.IsInt64() {
+        return 0, fmt.Errorf("amount %q is out of range", s)
+    }
+    return Amount(minor.Int64()), nil
+}
+
+// MustParse is Parse for constants, it panics on invalid input
+func MustParse(s string) Amount {
+    a, err := Parse(s)
+    if err != nil {
+        panic(err)
+    }
+    return a
+}
+
+// Minor returns the amount in minor units
+func (a Amount) Minor() int64 {
+    return int64(a)
+}
+
+// Float64 returns the amount in major units, for statistics and display
+// only, never for further money calculations
+func (a Amount) Float64() float64 {
+    return float64(a) / minorUnits
+}
+
+// String formats the amount with two decimals, like "-1500.05"
+func (a Amount) String() string {
+    sign := ""
+    minor := int64(a)
+    if minor < 0 {
+        sign = "-"
+        minor = -minor
+    }
+    return fmt.Sprintf("%s%d.%02d", sign, minor/minorUnits, minor%minorUnits)
+}
+
+// Abs returns the amount without its sign
+func (a Amount) Abs() Amount {
+    if a < 0 {
+        return -a
+    }
+    return a
+}
+
+// Mul multiplies the amount by a whole number
+func (a Amount) Mul(n int) Amount {
+    return a * Amount(n)
+}
+
+// MulFrac multiplies the amount by num/den, rounding to the kopeck
+func (a Amount) MulFrac(num, den int64) Amount {
+    if den == 0 {
+        panic("money: MulFrac by zero denominator")
+    }
+    r := new(big.Rat).SetFrac(
+        new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num)),
+        big.NewInt(den),
+    )
+    return Amount(roundRat(r).Int64())
+}
+
+// MulRat multiplies the amount by an exact ratio such as an exchange rate,
+// rounding to the kopeck
+func (a Amount) MulRat(ratio *big.Rat) Amount {
+    r := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(a)), ratio)
+    return Amount(roundRat(r).Int64())
+}
+
+// Div divides the amount into n parts, rounding to the kopeck
+func (a Amount) Div(n int) Amount {
+    return a.MulFrac(1, int64(n))
+}
+
+// Percent returns rate percent of the amount, rounding to the kopeck. Rates
+// are taken to four decimal places, 8.125 is 8.125%.
+func (a Amount) Percent(rate float64) Amount {
+    return a.MulFrac(rateBasis(rate), 100*rateScale)
+}
+
+// ProratedPercent returns rate percent of the amount prorated by num/den,
+// such as interest for num days of a den-day year, rounding once at the end
+func (a Amount) ProratedPercent(rate float64, num, den int64) Amount {
+    return a.MulFrac(rateBasis(rate)*num, 100*rateScale*den)
+}
+
+// Ratio returns a/b, zero when b is zero
+func (a Amount) Ratio(b Amount) float64 {
+    if b == 0 {
+        return 0
+    }
+    return float64(a) / float64(b)
+}
+
+// Min returns the smaller amount
+func Min(a, b Amount) Amount {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// Max returns the larger amount
+func Max(a, b Amount) Amount {
+    if a > b {
+        return a
+    }
+    return b
+}
+
+// Sum adds amounts up
+func Sum(amounts ...Amount) Amount {
+    var total Amount
+    for _, a := range amounts {
+        total += a
+    }
+    return total
+}
+
+// rateScale is the precision of rates in Percent
+const rateScale = 10000
+
+func rateBasis(rate float64) int64 {
+    return int64(math.Round(rate * rateScale))
+}
+
+// roundRat rounds r to an integer, halves away from zero
+func roundRat(r *big.Rat) *big.Int {
+    num := new(big.Int).Abs(r.Num())
+    den := r.Denom()
+
+    q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+    if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+        q.Add(q, big.NewInt(1))
+    }
+    if r.Sign() < 0 {
+        q.Neg(q)
+    }
+    return q
+}
+
+// MarshalJSON writes the amount as a number with two decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+    return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a number or a quoted decimal string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+    s := string(data)
+    if s == "null" {
+        return nil
+    }
+    if unquoted, err := strconv.Unquote(s); err == nil {
+        s = unquoted
+    }
+
+    parsed, err := Parse(s)
+    if err != nil {
+        return err
+    }
+    *a = parsed
+    return nil
+}
+
+// Scan reads a DECIMAL column
+func (a *Amount) Scan(src interface{}) error {
+    switch v := src.(type) {
+    case nil:
+        *a = 0
+        return nil
+    case []byte:
+        parsed, err := Parse(string(v))
+        if err != nil {
+            return err
+        }
+        *a = parsed
+    case string:
+        parsed, err := Parse(v)
+        if err != nil {
+            return err
+        }
+        *a = parsed
+    case int64:
+        *a = Amount(v * minorUnits)
+    case float64:
+        *a = FromFloat(v)
+    default:
+        return fmt.Errorf("money: cannot scan %T into Amount", src)
+    }
+    return nil
+}
+
+// Value writes the amount as a decimal string, exact in DECIMAL columns
+func (a Amount) Value() (driver.Value, error) {
+    return a.String(), nil
+}
+
+// Money is an amount in a currency
+type Money struct {
+    Amount   Amount
+    Currency string
+}
+
+// New makes money of an amount in currency
+func New(amount Amount, currency string) Money {
+    return Money{Amount: amount, Currency: currency}
+}
+
+// ErrCurrencyMismatch is returned when adding money in different currencies
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// Add adds money in the same currency
+func (m Money) Add(other Money) (Money, error) {
+    if m.Currency != other.Currency {
+        return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+    }
+    return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// String formats money like "1500.00 RUB"
+func (m Money) String() string {
+    return m.Amount.String() + " " + m.Currency
+}
+
+// moneyJSON is the Open Banking shape of money, the amount is a string
+type moneyJSON struct {
+    Amount   string `json:"amount"`
+    Currency string `json:"currency"`
+}
+
+// MarshalJSON writes {"amount": "1500.00", "currency": "RUB"} as banks do
+func (m Money) MarshalJSON() ([]byte, error) {
+    return json.Marshal(moneyJSON{Amount: m.Amount.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON reads the amount as a string or a number
+func (m *Money) UnmarshalJSON(data []byte) error {
+    var raw struct {
+        Amount   Amount `json:"amount"`
+        Currency string `json:"currency"`
+    }
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return err
+    }
+    m.Amount = raw.Amount
+    m.Currency = raw.Currency
+    return nil
+}
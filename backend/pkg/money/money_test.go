@@ -0,0 +1,221 @@
+package money
+
+import (
+    "encoding/json"
+    "errors"
+    "math/big"
+    "testing"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    Amount
+        wantErr bool
+    }{
+        {"0", 0, false},
+        {"1500", 150000, false},
+        {"1500.5", 150050, false},
+        {" 1500.05 ", 150005, false},
+        {"-12.34", -1234, false},
+        // Halves round away from zero
+        {"0.005", 1, false},
+        {"-0.005", -1, false},
+        {"0.0049", 0, false},
+        {"-12.345", -1235, false},
+        {"", 0, true},
+        {"abc", 0, true},
+        {"1/3", 0, true},
+        {"1e3", 0, true},
+        {"100000000000000000000", 0, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.in, func(t *testing.T) {
+            got, err := Parse(tt.in)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Parse(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestString(t *testing.T) {
+    tests := []struct {
+        in   Amount
+        want string
+    }{
+        {0, "0.00"},
+        {5, "0.05"},
+        {150005, "1500.05"},
+        {-5, "-0.05"},
+        {-150000, "-1500.00"},
+    }
+
+    for _, tt := range tests {
+        if got := tt.in.String(); got != tt.want {
+            t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+        }
+    }
+}
+
+func TestFromFloat(t *testing.T) {
+    tests := []struct {
+        in   float64
+        want Amount
+    }{
+        {0, 0},
+        {1500.5, 150050},
+        {0.1 + 0.2, 30},
+        {-12.345, -1235},
+        {19.999, 2000},
+    }
+
+    for _, tt := range tests {
+        if got := FromFloat(tt.in); got != tt.want {
+            t.Errorf("FromFloat(%v) = %d, want %d", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestArithmetic(t *testing.T) {
+    tests := []struct {
+        name string
+        got  Amount
+        want Amount
+    }{
+        {"mul", MustParse("10.01").Mul(3), MustParse("30.03")},
+        {"div even", MustParse("100").Div(4), MustParse("25")},
+        {"div rounds half up", MustParse("0.05").Div(2), MustParse("0.03")},
+        {"div rounds down", MustParse("100").Div(3), MustParse("33.33")},
+        {"div negative", MustParse("-0.05").Div(2), MustParse("-0.03")},
+        {"mul frac", MustParse("1000").MulFrac(2, 3), MustParse("666.67")},
+        {"mul rat", MustParse("100").MulRat(big.NewRat(1, 90)), MustParse("1.11")},
+        {"percent", MustParse("100000").Percent(8), MustParse("8000")},
+        {"percent fraction", MustParse("1000").Percent(8.125), MustParse("81.25")},
+        {"percent rounds", MustParse("0.10").Percent(5), MustParse("0.01")},
+        {"prorated", MustParse("100000").ProratedPercent(10, 30, 365), MustParse("821.92")},
+        {"abs", MustParse("-5").Abs(), MustParse("5")},
+        {"min", Min(MustParse("1"), MustParse("2")), MustParse("1")},
+        {"max", Max(MustParse("1"), MustParse("2")), MustParse("2")},
+        {"sum", Sum(MustParse("0.10"), MustParse("0.20"), MustParse("-0.05")), MustParse("0.25")},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if tt.got != tt.want {
+                t.Errorf("got %s, want %s", tt.got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRatio(t *testing.T) {
+    if got := MustParse("50").Ratio(MustParse("200")); got != 0.25 {
+        t.Errorf("Ratio = %v, want 0.25", got)
+    }
+    if got := MustParse("50").Ratio(0); got != 0 {
+        t.Errorf("Ratio by zero = %v, want 0", got)
+    }
+}
+
+func TestAmountJSON(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    Amount
+        wantErr bool
+    }{
+        {`1500.5`, 150050, false},
+        {`"1500.50"`, 150050, false},
+        {`-0.01`, -1, false},
+        {`null`, 0, false},
+        {`"abc"`, 0, true},
+        {`true`, 0, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.in, func(t *testing.T) {
+            var got Amount
+            err := json.Unmarshal([]byte(tt.in), &got)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.in, err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, got, tt.want)
+            }
+        })
+    }
+
+    data, err := json.Marshal(struct {
+        Amount Amount `json:"amount"`
+    }{MustParse("1500.5")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := string(data); got != `{"amount":1500.50}` {
+        t.Errorf("Marshal = %s", got)
+    }
+}
+
+func TestScan(t *testing.T) {
+    tests := []struct {
+        name    string
+        src     interface{}
+        want    Amount
+        wantErr bool
+    }{
+        {"nil", nil, 0, false},
+        {"bytes", []byte("1500.05"), 150005, false},
+        {"string", "-12.34", -1234, false},
+        {"int64", int64(15), 1500, false},
+        {"float64", 12.345, 1235, false},
+        {"bad string", "abc", 0, true},
+        {"bool", true, 0, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got Amount
+            err := got.Scan(tt.src)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("Scan(%v) error = %v, want error %v", tt.src, err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("Scan(%v) = %d, want %d", tt.src, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestMoney(t *testing.T) {
+    sum, err := New(MustParse("10"), "RUB").Add(New(MustParse("0.50"), "RUB"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := sum.String(); got != "10.50 RUB" {
+        t.Errorf("sum = %s, want 10.50 RUB", got)
+    }
+
+    if _, err := New(MustParse("10"), "RUB").Add(New(MustParse("1"), "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+        t.Errorf("adding USD to RUB: error = %v, want %v", err, ErrCurrencyMismatch)
+    }
+
+    var parsed Money
+    if err := json.Unmarshal([]byte(`{"amount":"1500.05","currency":"RUB"}`), &parsed); err != nil {
+        t.Fatal(err)
+    }
+    if parsed != New(150005, "RUB") {
+        t.Errorf("Unmarshal = %+v", parsed)
+    }
+
+    data, err := json.Marshal(parsed)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := string(data); got != `{"amount":"1500.05","currency":"RUB"}` {
+        t.Errorf("Marshal = %s", got)
+    }
+}
@@ -0,0 +1,149 @@
+package totp
+
+import (
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// rfcSecret is the RFC 6238 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+    // RFC 6238 appendix B, SHA1, last six of the eight digits
+    tests := []struct {
+        unix int64
+        want string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    }
+
+    for _, tt := range tests {
+        got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+        if err != nil {
+            t.Fatalf("Code at %d: %v", tt.unix, err)
+        }
+        if got != tt.want {
+            t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+        }
+    }
+}
+
+func TestCodeSecretFormat(t *testing.T) {
+    want, err := Code(rfcSecret, 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Apps show secrets lower-cased or padded
+    for _, secret := range []string{strings.ToLower(rfcSecret), rfcSecret + "===="} {
+        got, err := Code(secret, 1)
+        if err != nil {
+            t.Fatalf("Code(%q): %v", secret, err)
+        }
+        if got != want {
+            t.Errorf("Code(%q) = %s, want %s", secret, got, want)
+        }
+    }
+
+    if _, err := Code("not base32!", 1); err == nil {
+        t.Error("Code accepted an invalid secret")
+    }
+}
+
+func TestValidate(t *testing.T) {
+    now := time.Unix(1111111111, 0)
+    step := Step(now)
+
+    code := func(step int64) string {
+        c, err := Code(rfcSecret, step)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return c
+    }
+
+    tests := []struct {
+        name     string
+        code     string
+        wantStep int64
+        wantOK   bool
+    }{
+        {"current step", code(step), step, true},
+        {"previous step", code(step - 1), step - 1, true},
+        {"next step", code(step + 1), step + 1, true},
+        {"two steps back", code(step - 2), 0, false},
+        {"two steps ahead", code(step + 2), 0, false},
+        {"wrong code", "000000", 0, false},
+        {"short", code(step)[:5], 0, false},
+        {"long", code(step) + "0", 0, false},
+        {"empty", "", 0, false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            gotStep, ok := Validate(rfcSecret, tt.code, now)
+            if ok != tt.wantOK || gotStep != tt.wantStep {
+                t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+            }
+        })
+    }
+}
+
+func TestGenerateSecret(t *testing.T) {
+    secret, err := GenerateSecret()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    key, err := encoding.DecodeString(secret)
+    if err != nil {
+        t.Fatalf("secret %q is not base32: %v", secret, err)
+    }
+    if len(key) != secretSize {
+        t.Errorf("secret is %d bytes, want %d", len(key), secretSize)
+    }
+
+    other, err := GenerateSecret()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if other == secret {
+        t.Error("two secrets are equal")
+    }
+}
+
+func TestURI(t *testing.T) {
+    uri := URI("AutoSave", "user@example.com", rfcSecret)
+
+    parsed, err := url.Parse(uri)
+    if err != nil {
+        t.Fatalf("URI %q: %v", uri, err)
+    }
+    if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+        t.Errorf("URI %q is not an otpauth totp link", uri)
+    }
+    if parsed.Path != "/AutoSave:user@example.com" {
+        t.Errorf("label = %q", parsed.Path)
+    }
+
+    params := parsed.Query()
+    want := map[string]string{
+        "secret":    rfcSecret,
+        "issuer":    "AutoSave",
+        "algorithm": "SHA1",
+        "digits":    "6",
+        "period":    "30",
+    }
+    for key, value := range want {
+        if got := params.Get(key); got != value {
+            t.Errorf("%s = %q, want %q", key, got, value)
+        }
+    }
+}
@@ -1,13 +1,28 @@
 package validator
 
 import (
+    "reflect"
+    "strings"
+    "sync"
+
+    "github.com/KotovBoris/AutoSave/backend/pkg/money"
+
     "github.com/go-playground/validator/v10"
 )
 
 var validate *validator.Validate
 
+var (
+    bankMu    sync.RWMutex
+    knownBank func(id string) bool
+)
+
 func init() {
     validate = validator.New()
+    validate.RegisterValidation("bank", validateBank)
+    validate.RegisterValidation("currency", validateCurrency)
+    // Limits such as min=1000 on amounts are in roubles, not kopecks
+    validate.RegisterCustomTypeFunc(amountValue, money.Amount(0))
 }
 
 func Validate(data interface{}) error {
@@ -18,3 +33,30 @@ func GetValidator() *validator.Validate {
     return validate
 }
 
+// SetBankValidator sets the check behind the "bank" tag, so the tag accepts
+// exactly the banks configured at startup
+func SetBankValidator(known func(id string) bool) {
+    bankMu.Lock()
+    defer bankMu.Unlock()
+    knownBank = known
+}
+
+func amountValue(v reflect.Value) interface{} {
+    return v.Interface().(money.Amount).Float64()
+}
+
+func validateBank(fl validator.FieldLevel) bool {
+    bankMu.RLock()
+    defer bankMu.RUnlock()
+
+    if knownBank == nil {
+        return false
+    }
+    return knownBank(fl.Field().String())
+}
+
+// validateCurrency accepts ISO 4217 codes in any case, services store them
+// upper-cased
+func validateCurrency(fl validator.FieldLevel) bool {
+    return validate.Var(strings.ToUpper(fl.Field().String()), "iso4217") == nil
+}